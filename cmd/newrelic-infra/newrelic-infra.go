@@ -66,6 +66,7 @@ import (
 var (
 	configFile   string
 	validate     bool
+	validateLogs bool
 	showVersion  bool
 	debug        bool
 	cpuprofile   string
@@ -85,6 +86,7 @@ func elapsedTime() time.Duration {
 func init() {
 	flag.StringVar(&configFile, "config", "", "Overrides default configuration file")
 	flag.BoolVar(&validate, "validate", false, "Validate agent config and exit")
+	flag.BoolVar(&validateLogs, "validate-logs", false, "Validate log forwarding configs, print the generated Fluent Bit config and exit")
 	flag.BoolVar(&showVersion, "version", false, "Shows version details")
 	flag.BoolVar(&debug, "debug", false, "Enables agent debugging functionality")
 	flag.StringVar(&cpuprofile, "cpuprofile", "", "Writes cpu profile to `file`")
@@ -180,6 +182,18 @@ func main() {
 		os.Exit(0)
 	}
 
+	if validateLogs {
+		if err != nil {
+			fmt.Printf("can't load configuration file: %s\n", err)
+			os.Exit(1)
+		}
+		logFwCfg := config.NewLogForward(cfg, config.Troubleshoot{})
+		if !logs.NewFolderLoader(logFwCfg, nil, nil).Validate(os.Stdout) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if err != nil {
 		alog.WithError(err).Error("can't load configuration file")
 		os.Exit(1)
@@ -438,6 +452,7 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 		FluentBitNRLibPath:   c.FluentBitNRLibPath,
 		FluentBitParsersPath: c.FluentBitParsersPath,
 		FluentBitVerbose:     c.Verbose != 0 && trace.IsEnabled(trace.LOG_FWD),
+		FluentBitMetricsPort: c.FluentBitMetricsPort,
 	}
 	if fbIntCfg.IsLogForwarderAvailable() {
 		logCfgLoader := logs.NewFolderLoader(logFwCfg, agt.Context.Identity, agt.Context.HostnameResolver())
@@ -453,6 +468,12 @@ func initializeAgentAndRun(c *config.Config, logFwCfg config.LogForward) error {
 		aslog.Debug("Log forwarder is not available for this platform. The agent will start without log forwarding support.")
 	}
 
+	// in-process log forwarder, for the logging configuration blocks that don't require Fluent Bit
+	if logFwCfg.ConfigsDir != "" {
+		nativeCfgLoader := logs.NewFolderLoader(logFwCfg, agt.Context.Identity, agt.Context.HostnameResolver())
+		go logs.NewNativeForwarder(nativeCfgLoader, transport).Run(agt.Context.Ctx)
+	}
+
 	ffHandle.SetOHIHandler(integrationManager)
 
 	go integrationManager.Start(agt.Context.Ctx)
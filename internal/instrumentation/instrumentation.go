@@ -18,6 +18,10 @@ type MetricName int
 const (
 	DMRequestsForwarded MetricName = iota // integration payload received
 	DMDatasetsReceived
+	DMDatasetsRejected
+	DMMetricsRejected
+	DMEventsRejected
+	DMItemsSanitised
 	EntityRegisterEntitiesRegistered
 	EntityRegisterEntitiesRegisteredWithWarning
 	EntityRegisterEntitiesRegistrationFailed
@@ -28,6 +32,10 @@ var (
 	metricsToRegister = map[MetricName]string{
 		DMRequestsForwarded:                         "dm.requests_forwarded",
 		DMDatasetsReceived:                          "dm.datasets_received",
+		DMDatasetsRejected:                          "dm.datasets_rejected",
+		DMMetricsRejected:                           "dm.metrics_rejected",
+		DMEventsRejected:                            "dm.events_rejected",
+		DMItemsSanitised:                            "dm.items_sanitised",
 		EntityRegisterEntitiesRegistered:            "entity_register.entities_registered",
 		EntityRegisterEntitiesRegisteredWithWarning: "entity_register.entities_registered_with_warning",
 		EntityRegisterEntitiesRegistrationFailed:    "entity_register.entities_registration_failed",
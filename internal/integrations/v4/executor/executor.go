@@ -27,6 +27,8 @@ type Executor struct {
 	Cfg     *Config
 	Command string
 	Args    []string
+	// ReplayPath when set, the recording in this path is replayed instead of running Command
+	ReplayPath string
 }
 
 // FromCmdSlice builds a Executor instance from a string slices, being the first element
@@ -41,6 +43,10 @@ func FromCmdSlice(cmd []string, cfg *Config) Executor {
 // When writable PID channel is provided, generated PID will be written, so process could be signaled by 3rd parties.
 // When the process ends, all the channels are closed.
 func (r *Executor) Execute(ctx context.Context, pidChan, exitCodeCh chan<- int) OutputReceive {
+	if r.ReplayPath != "" {
+		return r.replay(ctx, exitCodeCh)
+	}
+
 	out, receiver := NewOutput()
 	commandCtx, cancelCommand := context.WithCancel(ctx)
 
@@ -168,8 +174,9 @@ func (r *Executor) DeepClone() Executor {
 	copy(argsCopy, r.Args)
 
 	return Executor{
-		Cfg:     r.Cfg.deepClone(),
-		Command: r.Command, // as strings are immutable we don't need to clone it
-		Args:    argsCopy,
+		Cfg:        r.Cfg.deepClone(),
+		Command:    r.Command, // as strings are immutable we don't need to clone it
+		Args:       argsCopy,
+		ReplayPath: r.ReplayPath,
 	}
 }
@@ -351,3 +351,34 @@ func execConfig(t require.TestingT) *Config {
 		Environment: nil,
 	}
 }
+
+func TestRunnable_Replay(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	// GIVEN a recording file
+	recFile, err := ioutil.TempFile("", "replay")
+	require.NoError(t, err)
+	defer os.Remove(recFile.Name())
+	_, err = recFile.WriteString(`{"kind":"start","integration":"nri-test"}
+{"kind":"stdout","line":"stdout line"}
+{"kind":"stderr","line":"error line"}
+{"kind":"exit","exit_code":2}
+`)
+	require.NoError(t, err)
+	require.NoError(t, recFile.Close())
+
+	// AND a replay runnable instance
+	r := FromReplayFile(recFile.Name(), execConfig(t))
+
+	// WHEN it is executed
+	exitCodeCh := make(chan int, 1)
+	to := r.Execute(context.Background(), nil, exitCodeCh)
+
+	// THEN recorded output lines are returned
+	assert.Equal(t, "stdout line", testhelp.ChannelRead(to.Stdout))
+	assert.Equal(t, "error line", testhelp.ChannelRead(to.Stderr))
+
+	// AND the recorded exit code
+	assert.Equal(t, &ReplayExitErr{ExitCode: 2}, <-to.Errors)
+	assert.Equal(t, 2, <-exitCodeCh)
+}
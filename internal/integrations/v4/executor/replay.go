@@ -0,0 +1,98 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/recording"
+)
+
+// ReplayExitErr is submitted through the Errors channel when a replayed execution didn't finish successfully.
+type ReplayExitErr struct {
+	ExitCode int
+}
+
+func (e *ReplayExitErr) Error() string {
+	return fmt.Sprintf("replayed integration exit status %d", e.ExitCode)
+}
+
+// FromReplayFile builds an Executor instance that, instead of running a command, feeds the output
+// stored in an integration recording file.
+func FromReplayFile(path string, cfg *Config) Executor {
+	return Executor{Cfg: cfg, ReplayPath: path}
+}
+
+// replay sends the recorded executions through the output channels as if they were produced by
+// a running process. All the executions in the recording are replayed sequentially.
+func (r *Executor) replay(ctx context.Context, exitCodeCh chan<- int) OutputReceive {
+	out, receiver := NewOutput()
+
+	go func() {
+		defer out.Close()
+
+		executions, err := recording.ReadFile(r.ReplayPath)
+		if err != nil {
+			out.Errors <- err
+			if exitCodeCh != nil {
+				exitCodeCh <- unknownErrExitCode
+			}
+			return
+		}
+
+		illog.
+			WithField("path", r.ReplayPath).
+			WithField("executions", len(executions)).
+			Debug("Replaying integration recording.")
+
+		exitCode := 0
+		for _, execution := range executions {
+			for _, entry := range execution.Entries {
+				var sent bool
+				switch entry.Kind {
+				case recording.KindStdout:
+					sent = sendLine(ctx, out.Stdout, entry.Line)
+				case recording.KindStderr:
+					sent = sendLine(ctx, out.Stderr, entry.Line)
+				case recording.KindError:
+					sent = sendError(ctx, out.Errors, errors.New(entry.Line))
+				default:
+					sent = true
+				}
+				if !sent {
+					return
+				}
+			}
+			exitCode = execution.ExitCode
+		}
+
+		if exitCode != 0 {
+			out.Errors <- &ReplayExitErr{ExitCode: exitCode}
+		}
+		if exitCodeCh != nil {
+			exitCodeCh <- exitCode
+		}
+	}()
+
+	return receiver
+}
+
+func sendLine(ctx context.Context, ch chan<- []byte, line string) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case ch <- []byte(line):
+		return true
+	}
+}
+
+func sendError(ctx context.Context, ch chan<- error, err error) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case ch <- err:
+		return true
+	}
+}
@@ -20,4 +20,5 @@ var (
 	FileContentsWithArgCmd   = testhelp.Script("../fixtures/filecontents_witharg.sh")
 	FileContentsFromEnvCmd   = testhelp.Script("../fixtures/filecontents_fromenv.sh")
 	EchoFromEnv              = testhelp.Script("../fixtures/echo_from_env.sh")
+	IntegrationBlocked       = testhelp.Script("../fixtures/integration_blocked.sh")
 )
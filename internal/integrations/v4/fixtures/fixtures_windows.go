@@ -19,4 +19,5 @@ var (
 	FileContentsCmd        = testhelp.Script("unsupported-test-case")
 	FileContentsFromEnvCmd = testhelp.Script("unsupported-test-case")
 	EchoFromEnv            = testhelp.Script("unsupported-test-case")
+	IntegrationBlocked     = testhelp.Script("unsupported-test-case")
 )
@@ -11,6 +11,7 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/when"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
 	cfgreq "github.com/newrelic/infrastructure-agent/pkg/integrations/configrequest/protocol"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/track/ctx"
@@ -43,13 +44,25 @@ type Definition struct {
 	WhenConditions  []when.Condition
 	CmdChanReq      *ctx.CmdChannelRequest // not empty: command-channel run/stop integration requests
 	CfgProtocol     *cfgreq.Context
-	runnable        executor.Executor
-	newTempFile     func(template []byte) (string, error)
+	// StrictValidation overrides the agent-wide payload strict validation when set
+	StrictValidation *bool
+	// Record when set, the integration output is recorded into a file
+	Record *config.RecordConfig
+	// InventoryTTL when set, inventory items not reported again within this period are removed
+	InventoryTTL time.Duration
+	// whenConfig source of WhenConditions, used for hashing as conditions are functions
+	whenConfig  config.EnableConditions
+	runnable    executor.Executor
+	newTempFile func(template []byte) (string, error)
 }
 
 func (d *Definition) Hash() string {
 	h := sha256.New()
-	identifier := fmt.Sprintf("%v%v%v%v%v%v%v%v%v%v%v%v",
+	var strictValidation string
+	if d.StrictValidation != nil {
+		strictValidation = fmt.Sprintf("%v", *d.StrictValidation)
+	}
+	identifier := fmt.Sprintf("%v%v%v%v%v%v%v%v%v%v%v%v%v%v%v%v",
 		d.Name,
 		d.Labels,
 		d.ExecutorConfig,
@@ -57,16 +70,29 @@ func (d *Definition) Hash() string {
 		d.Timeout,
 		d.ConfigTemplate,
 		d.InventorySource,
-		d.WhenConditions,
+		d.whenConfig,
 		d.runnable.Args,
 		d.runnable.Cfg,
 		d.runnable.Command,
+		d.runnable.ReplayPath,
 		d.CfgProtocol,
+		strictValidation,
+		d.Record,
+		d.InventoryTTL,
 	)
 	h.Write([]byte(identifier))
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
+// StrictValidationEnabled returns whether payloads should be strictly validated, taking the
+// agent-wide setting as default.
+func (d *Definition) StrictValidationEnabled(agentDefault bool) bool {
+	if d.StrictValidation != nil {
+		return *d.StrictValidation
+	}
+	return agentDefault
+}
+
 func (d *Definition) TimeoutEnabled() bool {
 	return d.Timeout > 0
 }
@@ -75,6 +101,11 @@ func (d *Definition) SingleRun() bool {
 	return d.Interval == 0
 }
 
+// ReferencedVars returns the names of the variables referenced by the definition placeholders.
+func (d *Definition) ReferencedVars() []string {
+	return databind.ReferencedVars([]interface{}{d.runnable, d.ConfigTemplate, d.Labels})
+}
+
 // PluginID returns inventory plugin ID
 func (d *Definition) PluginID(integrationName string) ids.PluginID {
 	// user specified an inventory source has precedence
@@ -122,9 +153,10 @@ func (d *Definition) Run(ctx context.Context, bindVals *databind.Values, pidC, e
 	if err != nil {
 		return nil, err
 	}
+	labels := d.replaceLabels(bindVals, len(matches))
 
 	logger.Debug("Running through all discovery matches.")
-	for _, ir := range matches {
+	for i, ir := range matches {
 		dc, ok := ir.Variables.(discoveredConfig)
 		if !ok { // should never happen, but left here for type safety
 			elog.WithField("type", fmt.Sprintf("%T", ir)).
@@ -170,11 +202,60 @@ func (d *Definition) Run(ctx context.Context, bindVals *databind.Values, pidC, e
 		if removeFile != nil {
 			go removeFile(taskOutput.Done)
 		}
-		tasksOutput = append(tasksOutput, Output{Receive: taskOutput, ExtraLabels: ir.MetricAnnotations, EntityRewrite: ir.EntityRewrites})
+		tasksOutput = append(tasksOutput, Output{Receive: taskOutput, ExtraLabels: d.extraLabels(ir.MetricAnnotations, labels[i]), EntityRewrite: ir.EntityRewrites})
 	}
 	return tasksOutput, nil
 }
 
+// replaceLabels returns the labels of each of the discovered instances, replacing their ${variable}
+// placeholders. Replacement is best-effort: labels that can't be replaced (e.g. holding a literal or an
+// undiscovered placeholder) are kept as they are.
+func (d *Definition) replaceLabels(bindVals *databind.Values, instances int) []map[string]string {
+	labels := make([]map[string]string, instances)
+	for i := range labels {
+		labels[i] = make(map[string]string, len(d.Labels))
+	}
+	for key, value := range d.Labels {
+		for i := range labels {
+			labels[i][key] = value
+		}
+		replaced, err := databind.Replace(bindVals, value)
+		if err != nil || (len(replaced) != 1 && len(replaced) != instances) {
+			elog.WithField("integration_name", d.Name).WithField("label", key).WithError(err).
+				Debug("Can't replace the label variables. Keeping its value.")
+			continue
+		}
+		for i := range labels {
+			if v, ok := replaced[i%len(replaced)].Variables.(string); ok {
+				labels[i][key] = v
+			}
+		}
+	}
+	return labels
+}
+
+// extraLabels adds to the discovery annotations the labels whose values have been replaced, so they
+// override the definition labels when the integration payloads are emitted.
+func (d *Definition) extraLabels(annotations data.Map, labels map[string]string) data.Map {
+	var extra data.Map
+	for key, value := range labels {
+		if value == d.Labels[key] {
+			continue
+		}
+		if extra == nil {
+			extra = make(data.Map, len(annotations)+len(labels))
+			for k, v := range annotations {
+				extra[k] = v
+			}
+		}
+		extra[data.LabelInfix+key] = value
+	}
+	if extra == nil {
+		return annotations
+	}
+	return extra
+}
+
 // remoteTempFile returns a function that removes the file corresponding to the passed path when the provided channel
 // is closed
 func removeTempFile(path string) func(<-chan struct{}) {
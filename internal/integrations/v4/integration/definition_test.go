@@ -112,6 +112,84 @@ func TestRun_Discovery(t *testing.T) {
 	assert.Equal(t, data.Map{"label.tree": "three", "other_tag": "true"}, outs[2].ExtraLabels)
 }
 
+func TestRun_DiscoveryFunctions(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("there is a problem when executing directly powershell with environment variables")
+	}
+	// GIVEN a definition entry whose discoverable configuration and labels use template functions
+	def, err := NewDefinition(config.ConfigEntry{
+		InstanceName: "foo",
+		Exec:         testhelp.Command(fixtures.BasicCmd, "${argument | upper}"),
+		Env: map[string]string{
+			"PREFIX": `${prefix | default "hi"}`,
+		},
+		Labels: map[string]string{
+			"env":  `${env | default "production"}`,
+			"team": "infra",
+		},
+	}, ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	// WHEN the def is executed with discovery matches where some variables are missing
+	vals := databind.NewValues(nil,
+		databind.NewDiscovery(data.Map{"prefix": "hello", "argument": "world", "env": "staging"}, data.InterfaceMap{"special": true}, nil),
+		databind.NewDiscovery(data.Map{"argument": "people"}, nil, nil),
+	)
+	outs, err := def.Run(context.Background(), &vals, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, outs, 2)
+
+	// THEN the tasks are executed with the transformed or the default values
+	assert.NoError(t, testhelp.ChannelErrClosed(outs[0].Receive.Errors))
+	assert.Equal(t, "stdout line", testhelp.ChannelRead(outs[0].Receive.Stdout))
+	assert.Equal(t, "error line", testhelp.ChannelRead(outs[0].Receive.Stderr))
+	assert.Equal(t, "hello-WORLD", testhelp.ChannelRead(outs[0].Receive.Stdout))
+	assert.Equal(t, data.Map{"label.env": "staging", "special": "true"}, outs[0].ExtraLabels)
+
+	assert.NoError(t, testhelp.ChannelErrClosed(outs[1].Receive.Errors))
+	assert.Equal(t, "stdout line", testhelp.ChannelRead(outs[1].Receive.Stdout))
+	assert.Equal(t, "error line", testhelp.ChannelRead(outs[1].Receive.Stderr))
+	assert.Equal(t, "hi-PEOPLE", testhelp.ChannelRead(outs[1].Receive.Stdout))
+	assert.Equal(t, data.Map{"label.env": "production"}, outs[1].ExtraLabels)
+}
+
+func TestRun_UnresolvedLabels(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("there is a problem when executing directly powershell with environment variables")
+	}
+	// GIVEN a definition entry with labels holding placeholders that can't be replaced
+	def, err := NewDefinition(config.ConfigEntry{
+		InstanceName: "foo",
+		Exec:         testhelp.Command(fixtures.BasicCmd, "${argument}"),
+		Labels: map[string]string{
+			"literal":      "${not-a-variable",
+			"undiscovered": "${missing}",
+			"env":          "${env}",
+		},
+	}, ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	// WHEN the def is executed with discovery matches
+	vals := databind.NewValues(nil,
+		databind.NewDiscovery(data.Map{"argument": "world", "env": "staging"}, nil, nil),
+	)
+	outs, err := def.Run(context.Background(), &vals, nil, nil)
+
+	// THEN the integration is executed
+	require.NoError(t, err)
+	require.Len(t, outs, 1)
+	assert.NoError(t, testhelp.ChannelErrClosed(outs[0].Receive.Errors))
+	assert.Equal(t, "stdout line", testhelp.ChannelRead(outs[0].Receive.Stdout))
+	assert.Equal(t, "error line", testhelp.ChannelRead(outs[0].Receive.Stderr))
+	assert.Equal(t, "-world", testhelp.ChannelRead(outs[0].Receive.Stdout))
+	// AND only the labels that could be replaced are overridden
+	assert.Equal(t, data.Map{"label.env": "staging"}, outs[0].ExtraLabels)
+}
+
 func TestRun_CmdSlice(t *testing.T) {
 	defer leaktest.Check(t)()
 
@@ -365,6 +443,29 @@ func TestDefinition_Hash(t *testing.T) {
 	assert.Equal(t, def3.Hash(), def2.Hash())
 }
 
+func TestDefinition_Hash_StableForSameConfig(t *testing.T) {
+	ce := func() config.ConfigEntry {
+		return config.ConfigEntry{
+			InstanceName: "def",
+			Exec:         config.ShlexOpt{"/bin/echo", "hello"},
+			When:         config.EnableConditions{FileExists: "/tmp", EnvExists: map[string]string{"A": "b"}},
+		}
+	}
+	def, err := NewDefinition(ce(), ErrLookup, nil, nil)
+	require.NoError(t, err)
+	def2, err := NewDefinition(ce(), ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, def.Hash(), def2.Hash())
+
+	changed := ce()
+	changed.When.FileExists = "/var"
+	def3, err := NewDefinition(changed, ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, def.Hash(), def3.Hash())
+}
+
 func TestNewDefinition_LowerCasedEnvGetsUppercased(t *testing.T) {
 	const (
 		envA = "an_env_var"
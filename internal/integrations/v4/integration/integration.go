@@ -76,12 +76,16 @@ func newDefinitionWithoutLookup(ce config2.ConfigEntry, passthroughEnv []string,
 			Environment: ce.Env,
 			Passthrough: passthroughEnv,
 		},
-		Labels:         ce.Labels,
-		Name:           ce.InstanceName,
-		Interval:       interval,
-		WhenConditions: conditions(ce.When),
-		ConfigTemplate: configTemplate,
-		newTempFile:    newTempFile,
+		Labels:           ce.Labels,
+		Name:             ce.InstanceName,
+		Interval:         interval,
+		WhenConditions:   conditions(ce.When),
+		whenConfig:       ce.When,
+		ConfigTemplate:   configTemplate,
+		StrictValidation: ce.StrictValidation,
+		Record:           ce.Record,
+		InventoryTTL:     time.Duration(ce.InventoryTTLIntervals) * interval,
+		newTempFile:      newTempFile,
 	}
 
 	if ce.InventorySource == "" {
@@ -124,6 +128,11 @@ func NewDefinition(ce config2.ConfigEntry, lookup InstancesLookup, passthroughEn
 		return
 	}
 
+	// if replaying a recorded output instead of executing anything
+	if ce.Replay != "" {
+		d.runnable = executor.FromReplayFile(ce.Replay, &d.ExecutorConfig)
+		return
+	}
 	// if looking for a v3 integration from the v4 engine
 	if ce.IntegrationName != "" {
 		err = d.fromLegacyV3(ce, lookup)
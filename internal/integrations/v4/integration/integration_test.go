@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"runtime"
 	"testing"
+	"time"
 
 	config2 "github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
 
@@ -226,6 +227,29 @@ timeout: 0
 	assert.False(t, i.TimeoutEnabled())
 }
 
+func TestInventoryTTL(t *testing.T) {
+	// GIVEN an inventory TTL configured as an amount of intervals
+	var config config2.ConfigEntry
+	require.NoError(t, yaml.Unmarshal([]byte(`
+name: foo
+exec: bar
+interval: 30s
+inventory_ttl_intervals: 3
+`), &config))
+
+	// WHEN the integration is loaded
+	i, err := NewDefinition(config, ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	// THEN the inventory TTL spans that amount of intervals
+	assert.Equal(t, 90*time.Second, i.InventoryTTL)
+}
+
+func TestInventoryTTL_Negative(t *testing.T) {
+	_, err := NewDefinition(config2.ConfigEntry{InstanceName: "foo", Exec: config2.ShlexOpt{"bar"}, InventoryTTLIntervals: -1}, ErrLookup, nil, nil)
+	assert.Error(t, err)
+}
+
 func TestDefinition_fromName(t *testing.T) {
 	cfg := config2.ConfigEntry{
 		InstanceName: "nri-foo",
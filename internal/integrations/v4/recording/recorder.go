@@ -0,0 +1,168 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package recording
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+)
+
+const (
+	// DefaultMaxSize is the recording file size limit when none is configured.
+	DefaultMaxSize = 10 * 1024 * 1024
+	// recorded lines longer than this are truncated
+	maxLineSize = 1024 * 1024
+	// JSON escaping can take up to 6 bytes per recorded line byte (\u00XX), plus the entry envelope
+	maxEntrySize = 6*maxLineSize + 64*1024
+	// suffix of the recording file that is rotated out once the size limit is reached
+	rotatedSuffix = ".1"
+)
+
+var rlog = log.WithComponent("integrations.Recorder")
+
+// Recorder writes the output of the executions of an integration into a size capped file.
+// When the file reaches the size limit, it's rotated keeping a single previous file.
+type Recorder struct {
+	lock       sync.Mutex
+	path       string
+	maxSize    int64
+	size       int64
+	file       *os.File
+	now        func() time.Time
+	executions uint64
+}
+
+// ExecutionRecorder records the output of a single integration execution. Each entry is tagged
+// with the execution, so concurrent executions sharing a Recorder can be told apart when read.
+type ExecutionRecorder struct {
+	recorder *Recorder
+	id       uint64
+}
+
+// NewRecorder creates a recorder writing into the given path. Zero or negative maxSize
+// falls back to DefaultMaxSize.
+func NewRecorder(path string, maxSize int64) *Recorder {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	return &Recorder{
+		path:    path,
+		maxSize: maxSize,
+		now:     time.Now,
+	}
+}
+
+// Start records the beginning of an integration execution, with its environment (sensitive
+// values are obfuscated), and returns the recorder for the rest of the execution output.
+func (r *Recorder) Start(integrationName string, env map[string]string) *ExecutionRecorder {
+	er := &ExecutionRecorder{recorder: r, id: atomic.AddUint64(&r.executions, 1)}
+	er.write(Entry{Kind: KindStart, Integration: integrationName, Env: helpers.ObfuscateSensitiveDataFromMap(env)})
+	return er
+}
+
+// Stdout records a standard output line.
+func (er *ExecutionRecorder) Stdout(line []byte) {
+	er.write(Entry{Kind: KindStdout, Line: string(line)})
+}
+
+// Stderr records a standard error line.
+func (er *ExecutionRecorder) Stderr(line []byte) {
+	er.write(Entry{Kind: KindStderr, Line: string(line)})
+}
+
+// Error records an execution error, not being the exit status.
+func (er *ExecutionRecorder) Error(err error) {
+	er.write(Entry{Kind: KindError, Line: helpers.ObfuscateSensitiveDataFromError(err).Error()})
+}
+
+// Exit records the end of an integration execution.
+func (er *ExecutionRecorder) Exit(exitCode int) {
+	er.write(Entry{Kind: KindExit, ExitCode: &exitCode})
+}
+
+func (er *ExecutionRecorder) write(e Entry) {
+	e.Execution = er.id
+	er.recorder.write(e)
+}
+
+// Close releases the recording file.
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *Recorder) write(e Entry) {
+	e.Time = r.now()
+	if len(e.Line) > maxLineSize {
+		e.Line = e.Line[:maxLineSize]
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		rlog.WithError(err).Debug("can't marshal recording entry")
+		return
+	}
+	line = append(line, '\n')
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := r.ensureFile(int64(len(line))); err != nil {
+		rlog.WithError(err).WithField("path", r.path).Warn("can't write integration recording")
+		return
+	}
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	if err != nil {
+		rlog.WithError(err).WithField("path", r.path).Warn("can't write integration recording")
+	}
+}
+
+// ensureFile opens the recording file, rotating it when the next write would exceed the size limit.
+func (r *Recorder) ensureFile(nextWrite int64) error {
+	if r.file != nil && r.size+nextWrite <= r.maxSize {
+		return nil
+	}
+
+	if r.file != nil {
+		_ = r.file.Close()
+		r.file = nil
+		if err := os.Rename(r.path, r.path+rotatedSuffix); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	if r.size > 0 && r.size+nextWrite > r.maxSize {
+		// previous recording from another agent run already reached the limit
+		_ = r.file.Close()
+		r.file = nil
+		if err := os.Rename(r.path, r.path+rotatedSuffix); err != nil {
+			return err
+		}
+		return r.ensureFile(nextWrite)
+	}
+	return nil
+}
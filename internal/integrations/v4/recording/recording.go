@@ -0,0 +1,96 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+// Package recording stores the raw output of integration executions into a file, so it can be
+// replayed later through the agent for offline debugging or fixture based tests.
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// Kinds of recorded entries.
+const (
+	KindStart  = "start"
+	KindStdout = "stdout"
+	KindStderr = "stderr"
+	KindError  = "error"
+	KindExit   = "exit"
+)
+
+// Errors
+var (
+	EmptyRecordingErr = errors.New("recording does not contain any execution")
+)
+
+// Entry is a single line of a recording file. Each integration execution starts with a
+// KindStart entry and finishes with a KindExit one. Entries of concurrent executions are
+// interleaved, so they're tagged with the execution they belong to.
+type Entry struct {
+	Time        time.Time         `json:"time"`
+	Execution   uint64            `json:"execution,omitempty"`
+	Kind        string            `json:"kind"`
+	Integration string            `json:"integration,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	Line        string            `json:"line,omitempty"`
+	ExitCode    *int              `json:"exit_code,omitempty"`
+}
+
+// Execution is the recorded output of a single integration run.
+type Execution struct {
+	Integration string
+	Env         map[string]string
+	Entries     []Entry
+	ExitCode    int
+}
+
+// Read loads all the executions stored in a recording.
+func Read(r io.Reader) ([]Execution, error) {
+	var executions []Execution
+	indexes := map[uint64]int{} // key: execution tag, value: index in executions
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		index, ok := indexes[e.Execution]
+		if e.Kind == KindStart || !ok {
+			executions = append(executions, Execution{Integration: e.Integration, Env: e.Env})
+			index = len(executions) - 1
+			indexes[e.Execution] = index
+			if e.Kind == KindStart {
+				continue
+			}
+		}
+		current := &executions[index]
+		if e.Kind == KindExit && e.ExitCode != nil {
+			current.ExitCode = *e.ExitCode
+			continue
+		}
+		current.Entries = append(current.Entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(executions) == 0 {
+		return nil, EmptyRecordingErr
+	}
+	return executions, nil
+}
+
+// ReadFile loads all the executions stored in a recording file.
+func ReadFile(path string) ([]Execution, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	return Read(f)
+}
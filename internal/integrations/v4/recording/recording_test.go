@@ -0,0 +1,138 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package recording
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder_RecordAndRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nri-test.rec")
+
+	// GIVEN a recorder
+	rec := NewRecorder(path, 0)
+
+	// WHEN two executions are recorded
+	first := rec.Start("nri-test", map[string]string{"PORT": "1234", "PASSWORD": "secret"})
+	first.Stdout([]byte(`{"protocol_version":"4"}`))
+	first.Stderr([]byte("level=error msg=boom"))
+	first.Exit(0)
+	second := rec.Start("nri-test", nil)
+	second.Error(errors.New("can't start"))
+	second.Exit(3)
+	require.NoError(t, rec.Close())
+
+	// THEN they can be read back
+	executions, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, executions, 2)
+
+	assert.Equal(t, "nri-test", executions[0].Integration)
+	assert.Equal(t, "1234", executions[0].Env["PORT"])
+	assert.NotEqual(t, "secret", executions[0].Env["PASSWORD"])
+	require.Len(t, executions[0].Entries, 2)
+	assert.Equal(t, KindStdout, executions[0].Entries[0].Kind)
+	assert.Equal(t, `{"protocol_version":"4"}`, executions[0].Entries[0].Line)
+	assert.Equal(t, KindStderr, executions[0].Entries[1].Kind)
+	assert.Equal(t, 0, executions[0].ExitCode)
+
+	require.Len(t, executions[1].Entries, 1)
+	assert.Equal(t, KindError, executions[1].Entries[0].Kind)
+	assert.Equal(t, 3, executions[1].ExitCode)
+}
+
+func TestRecorder_RotatesWhenMaxSizeIsReached(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nri-test.rec")
+
+	rec := NewRecorder(path, 300)
+	execution := rec.Start("nri-test", nil)
+	for i := 0; i < 10; i++ {
+		execution.Stdout([]byte(strings.Repeat("a", 50)))
+	}
+	require.NoError(t, rec.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, info.Size() <= 300)
+	_, err = os.Stat(path + rotatedSuffix)
+	assert.NoError(t, err)
+}
+
+func TestRecorder_ConcurrentExecutions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nri-test.rec")
+
+	// GIVEN a recorder shared by two executions running at the same time
+	rec := NewRecorder(path, 0)
+	first := rec.Start("nri-test", map[string]string{"PORT": "1"})
+	second := rec.Start("nri-test", map[string]string{"PORT": "2"})
+
+	// WHEN their output is interleaved
+	first.Stdout([]byte("first-1"))
+	second.Stdout([]byte("second-1"))
+	first.Stdout([]byte("first-2"))
+	second.Exit(2)
+	first.Exit(1)
+	require.NoError(t, rec.Close())
+
+	// THEN each execution is read back with its own output
+	executions, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, executions, 2)
+
+	assert.Equal(t, "1", executions[0].Env["PORT"])
+	require.Len(t, executions[0].Entries, 2)
+	assert.Equal(t, "first-1", executions[0].Entries[0].Line)
+	assert.Equal(t, "first-2", executions[0].Entries[1].Line)
+	assert.Equal(t, 1, executions[0].ExitCode)
+
+	assert.Equal(t, "2", executions[1].Env["PORT"])
+	require.Len(t, executions[1].Entries, 1)
+	assert.Equal(t, "second-1", executions[1].Entries[0].Line)
+	assert.Equal(t, 2, executions[1].ExitCode)
+}
+
+func TestRecorder_LongLinesCanBeRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nri-test.rec")
+
+	// GIVEN a line exceeding the size limit, whose characters are escaped in JSON
+	line := strings.Repeat("\x01", maxLineSize+10)
+
+	// WHEN it's recorded
+	rec := NewRecorder(path, 100*1024*1024)
+	execution := rec.Start("nri-test", nil)
+	execution.Stdout([]byte(line))
+	execution.Exit(0)
+	require.NoError(t, rec.Close())
+
+	// THEN the truncated line can be read back
+	executions, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, executions, 1)
+	require.Len(t, executions[0].Entries, 1)
+	assert.Equal(t, line[:maxLineSize], executions[0].Entries[0].Line)
+}
+
+func TestRead_Empty(t *testing.T) {
+	_, err := Read(strings.NewReader(""))
+	assert.Equal(t, EmptyRecordingErr, err)
+}
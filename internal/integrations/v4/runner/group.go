@@ -64,9 +64,20 @@ func NewGroup(
 // provided context
 func (g *Group) Run(ctx context.Context) (hasStartedAnyOHI bool) {
 	for _, integr := range g.integrations {
-		go NewRunner(integr, g.emitter, g.dSources, g.handleErrorsProvide, g.cmdReqHandle, g.configHandle, g.terminateDefinitionQ, g.idLookup).Run(ctx, nil, nil)
+		g.RunDefinition(ctx, integr)
 		hasStartedAnyOHI = true
 	}
 
 	return
 }
+
+// RunDefinition launches a single integration of the group to run in background, sharing the
+// group discovery sources. It can be cancelled with the provided context
+func (g *Group) RunDefinition(ctx context.Context, definition integration.Definition) {
+	go NewRunner(definition, g.emitter, g.dSources, g.handleErrorsProvide, g.cmdReqHandle, g.configHandle, g.terminateDefinitionQ, g.idLookup).Run(ctx, nil, nil)
+}
+
+// Definitions returns the integrations definitions that the group runs.
+func (g *Group) Definitions() []integration.Definition {
+	return g.integrations
+}
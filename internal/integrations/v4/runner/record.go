@@ -0,0 +1,60 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"os/exec"
+	"sync"
+
+	"github.com/newrelic/infrastructure-agent/internal/gobackfill"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/recording"
+)
+
+// recordOutput records the output of an integration execution while forwarding it through the
+// returned receiver, so the recording is transparent for the output handlers.
+func recordOutput(recorder *recording.Recorder, integrationName string, env map[string]string, in executor.OutputReceive) executor.OutputReceive {
+	rec := recorder.Start(integrationName, env)
+
+	out, receiver := executor.NewOutput()
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	exitCode := 0
+
+	go func() {
+		defer wg.Done()
+		for line := range in.Stdout {
+			rec.Stdout(line)
+			out.Stdout <- line
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for line := range in.Stderr {
+			rec.Stderr(line)
+			out.Stderr <- line
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for err := range in.Errors {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				exitCode = gobackfill.ExitCode(exitErr)
+			} else if replayErr, ok := err.(*executor.ReplayExitErr); ok {
+				exitCode = replayErr.ExitCode
+			} else {
+				rec.Error(err)
+			}
+			out.Errors <- err
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		<-in.Done
+		rec.Exit(exitCode)
+		out.Close()
+	}()
+
+	return receiver
+}
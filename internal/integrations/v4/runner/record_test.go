@@ -0,0 +1,48 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/executor"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/recording"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.rec")
+
+	// GIVEN an integration output being recorded
+	rec := recording.NewRecorder(path, 0)
+	send, receive := executor.NewOutput()
+	recorded := recordOutput(rec, "nri-test", map[string]string{"A": "b"}, receive)
+
+	// WHEN the integration writes into it
+	send.Stdout <- []byte("out")
+	send.Stderr <- []byte("err")
+	send.Errors <- errors.New("failure")
+	send.Close()
+
+	// THEN the output is forwarded
+	assert.Equal(t, "out", string(<-recorded.Stdout))
+	assert.Equal(t, "err", string(<-recorded.Stderr))
+	assert.EqualError(t, <-recorded.Errors, "failure")
+	<-recorded.Done
+	require.NoError(t, rec.Close())
+
+	// AND recorded
+	executions, err := recording.ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, executions, 1)
+	assert.Equal(t, "nri-test", executions[0].Integration)
+	require.Len(t, executions[0].Entries, 3)
+}
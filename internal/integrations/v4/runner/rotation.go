@@ -0,0 +1,125 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package runner
+
+import (
+	"context"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+// rotationGracePeriod is the time long-running instances have to exit after being asked to terminate
+// because their variables changed. After it, they are killed.
+var rotationGracePeriod = 10 * time.Second
+
+// watchesRotation returns true if the integration instances run for longer than the variables they
+// reference are valid, so the variables must be watched while the instances run.
+func (r *runner) watchesRotation(values *databind.Values) bool {
+	return values != nil && r.definition.SingleRun() && r.dSources != nil && r.dSources.VarsTTL() > 0 &&
+		len(r.definition.ReferencedVars()) > 0
+}
+
+// watchRotation fetches the variables each time their TTL expires and closes the returned channel
+// when the values of the variables referenced by the integration differ from the values the instances
+// have been started with. Other variables of the same file don't restart the instances. The discovery
+// is not run again: the discovered instances are only refreshed when the integration is restarted.
+func (r *runner) watchRotation(ctx context.Context) <-chan struct{} {
+	rotated := make(chan struct{})
+	referenced := r.definition.ReferencedVars()
+	go func() {
+		// leased variables can expire before the configured TTL, so it's checked again after each fetch
+		timer := time.NewTimer(r.dSources.VarsTTL())
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			values, err := databind.FetchVars(r.dSources)
+			timer.Reset(r.dSources.VarsTTL())
+			if err != nil {
+				r.log.
+					WithError(helpers.ObfuscateSensitiveDataFromError(err)).
+					Warn("can't refresh variables. Running instances keep the previous values")
+				continue
+			}
+			if values.VarsHash(referenced) != r.varsHash {
+				// the values are never logged, only the fact they have changed
+				r.log.WithField("variables", len(referenced)).
+					Info("Variables have been rotated. Restarting integration instances")
+				close(rotated)
+				return
+			}
+		}
+	}()
+	return rotated
+}
+
+// instancePIDs keeps the PIDs of the running instances while forwarding them to the runner caller.
+type instancePIDs struct {
+	lock sync.Mutex
+	pids []int
+}
+
+// forward reads the PIDs sent by the running instances until all of them have finished, forwarding them to
+// pidWCh, if not nil. The returned channel must be passed to the instances instead of pidWCh.
+func (p *instancePIDs) forward(pidWCh chan<- int, finished <-chan struct{}) chan<- int {
+	pidCh := make(chan int)
+	go func() {
+		for {
+			select {
+			case <-finished:
+				return
+			case pid := <-pidCh:
+				p.lock.Lock()
+				p.pids = append(p.pids, pid)
+				p.lock.Unlock()
+				if pidWCh != nil {
+					pidWCh <- pid
+				}
+			}
+		}
+	}()
+	return pidCh
+}
+
+// terminate asks the running instances to exit. It returns false if they can't be signaled
+// (e.g. on Windows), so they need to be killed.
+func (p *instancePIDs) terminate() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.pids) == 0 {
+		return false
+	}
+	for _, pid := range p.pids {
+		process, err := os.FindProcess(pid)
+		if err != nil {
+			return false
+		}
+		if err := process.Signal(syscall.SIGTERM); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// stopInstances gracefully stops the running instances, waiting for them to finish. Instances that don't
+// exit during the grace period are killed by cancelling their context.
+func (r *runner) stopInstances(pids *instancePIDs, cancel context.CancelFunc, finished <-chan struct{}) {
+	if pids.terminate() {
+		select {
+		case <-finished:
+			return
+		case <-time.After(rotationGracePeriod):
+			r.log.Warn("Integration instances didn't finish after being terminated. Killing them")
+		}
+	}
+	cancel()
+	<-finished
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/cache"
 
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/recording"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/when"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
@@ -63,6 +64,8 @@ type runner struct {
 	cache          cache.Cache
 	terminateQueue chan<- string
 	idLookup       host.IDLookup
+	recorder       *recording.Recorder // nil when output recording is disabled
+	varsHash       string              // hash of the referenced variables the running instances have been started with
 }
 
 // NewRunner creates an integration runner instance.
@@ -89,6 +92,9 @@ func NewRunner(
 		cache:          cache.CreateCache(),
 		idLookup:       idLookup,
 	}
+	if intDef.Record != nil {
+		r.recorder = recording.NewRecorder(intDef.Record.Path, intDef.Record.MaxSize)
+	}
 	if handleErrorsProvide != nil {
 		r.handleErrors = handleErrorsProvide()
 	} else {
@@ -101,6 +107,7 @@ func NewRunner(
 func (r *runner) Run(ctx context.Context, pidWCh, exitCodeCh chan<- int) {
 	r.log = illog.WithFields(LogFields(r.definition))
 	defer r.killChildren()
+	defer r.closeRecorder()
 	for {
 		waitForNextExecution := time.After(r.definition.Interval)
 
@@ -110,6 +117,7 @@ func (r *runner) Run(ctx context.Context, pidWCh, exitCodeCh chan<- int) {
 		//	exitCodeCh = make(chan int, 1)
 		//}
 
+		rotated := false
 		values, err := r.applyDiscovery()
 		if err != nil {
 			r.log.
@@ -117,10 +125,15 @@ func (r *runner) Run(ctx context.Context, pidWCh, exitCodeCh chan<- int) {
 				Error("can't fetch discovery items")
 		} else {
 			if when.All(r.definition.WhenConditions...) {
-				r.execute(ctx, values, pidWCh, exitCodeCh)
+				rotated = r.execute(ctx, values, pidWCh, exitCodeCh)
 			}
 		}
 
+		// long-running instances are started again with the rotated variables
+		if rotated && ctx.Err() == nil {
+			continue
+		}
+
 		if r.definition.SingleRun() {
 			r.log.Debug("Integration single run finished")
 			return
@@ -156,6 +169,15 @@ func (r *runner) killChildren() {
 	}
 }
 
+func (r *runner) closeRecorder() {
+	if r.recorder == nil {
+		return
+	}
+	if err := r.recorder.Close(); err != nil {
+		r.log.WithError(err).Warn("can't close integration output recording")
+	}
+}
+
 func LogFields(def integration.Definition) logrus.Fields {
 	fields := logrus.Fields{
 		"integration_name": def.Name,
@@ -202,8 +224,9 @@ func (r *runner) heartBeat() {
 // execute the integration and wait for all the possible instances (resulting of multiple dSources matches)
 // to finish
 // For long-time running integrations, avoids starting the next
-// discover-execute cycle until all the parallel processes have ended
-func (r *runner) execute(ctx context.Context, matches *databind.Values, pidWCh, exitCodeCh chan<- int) {
+// discover-execute cycle until all the parallel processes have ended, or until their variables
+// change. In the latter case, the instances are stopped and it returns true.
+func (r *runner) execute(ctx context.Context, matches *databind.Values, pidWCh, exitCodeCh chan<- int) (rotated bool) {
 	ctx, txn := instrumentation.SelfInstrumentation.StartTransaction(ctx, "integration.v4."+r.definition.Name)
 	if hostname, ok := r.definition.ExecutorConfig.Environment["HOSTNAME"]; ok {
 		txn.AddAttribute("integration_hostname", hostname)
@@ -232,20 +255,36 @@ func (r *runner) execute(ctx context.Context, matches *databind.Values, pidWCh,
 		r.log.WithError(err).Error("can't fetch host ID")
 	}
 
+	waitForCurrent := make(chan struct{})
+
+	// Long-running instances are restarted when their variables change (e.g. a rotated password)
+	var rotation <-chan struct{}
+	pids := &instancePIDs{}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if r.watchesRotation(matches) {
+		r.varsHash = matches.VarsHash(r.definition.ReferencedVars())
+		rotation = r.watchRotation(ctx)
+		pidWCh = pids.forward(pidWCh, waitForCurrent)
+	}
+
 	// Runs all the matching integration instances
 	outputs, err := r.definition.Run(ctx, matches, pidWCh, exitCodeCh)
 	if err != nil {
 		txn.NoticeError(err)
 		r.log.WithError(err).Error("can't start integration")
+		close(waitForCurrent)
 		return
 	}
 
 	// Waits for all the integrations to finish and reads the standard output and errors
 	wg := sync.WaitGroup{}
-	waitForCurrent := make(chan struct{})
 	wg.Add(len(outputs) * 3)
 	for _, out := range outputs {
 		o := out
+		if r.recorder != nil {
+			o.Receive = recordOutput(r.recorder, r.definition.Name, r.definition.ExecutorConfig.Environment, o.Receive)
+		}
 		go func(txn instrumentation.Transaction) {
 			defer wg.Done()
 			r.handleLines(ctx, o.Receive.Stdout, o.ExtraLabels, o.EntityRewrite)
@@ -274,6 +313,9 @@ func (r *runner) execute(ctx context.Context, matches *databind.Values, pidWCh,
 		r.log.Debug("Integration has been interrupted. Finishing.")
 	case <-waitForCurrent:
 		r.log.Debug("Integration instances finished their execution. Waiting until next interval.")
+	case <-rotation:
+		r.stopInstances(pids, cancel, waitForCurrent)
+		return true
 	}
 
 	return
@@ -5,6 +5,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/entity/host"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync/atomic"
 	"testing"
@@ -15,6 +16,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp/testemit"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/cmdrequest"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/configrequest"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/configrequest/protocol"
@@ -182,3 +184,131 @@ func Test_runner_Run_handlesCfgProtocol(t *testing.T) {
 		return false
 	}, time.Second, 10*time.Millisecond)
 }
+
+func Test_runner_Run_restartsOnRotatedVariables(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip()
+	}
+	log.SetOutput(ioutil.Discard)  // discard logs so not to break race tests
+	defer log.SetOutput(os.Stderr) // return back to default
+	hook := new(test.Hook)
+	log.AddHook(hook)
+
+	// GIVEN a long-running integration whose arguments are taken from a variable
+	secretFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, ioutil.WriteFile(secretFile, []byte("first-password"), 0600))
+	dSources, err := databind.LoadYAML([]byte(`
+variables:
+  password:
+    ttl: 100ms
+    file:
+      path: ` + secretFile + `
+`))
+	require.NoError(t, err)
+	def, err := integration.NewDefinition(config.ConfigEntry{
+		InstanceName: "foo",
+		Exec:         testhelp.Command(fixtures.IntegrationBlocked, "${password}"),
+		Interval:     "0",
+	}, integration.ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	e := &testemit.RecordEmitter{}
+	r := NewRunner(def, e, dSources, nil, cmdrequest.NoopHandleFn, configrequest.NoopHandleFn, nil, host.IDLookup{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		r.Run(ctx, nil, nil)
+		close(finished)
+	}()
+	defer func() {
+		cancel()
+		<-finished
+	}()
+
+	// WHEN the integration is started
+	// THEN it runs with the current variable value
+	dataset, err := e.ReceiveFrom("foo")
+	require.NoError(t, err)
+	require.Len(t, dataset.DataSet.Metrics, 1)
+	assert.Equal(t, "first-password", dataset.DataSet.Metrics[0]["value"])
+
+	// WHEN the variable value changes
+	require.NoError(t, ioutil.WriteFile(secretFile, []byte("second-password"), 0600))
+
+	// THEN the integration is restarted with the new value
+	dataset, err = e.ReceiveFrom("foo")
+	require.NoError(t, err)
+	require.Len(t, dataset.DataSet.Metrics, 1)
+	assert.Equal(t, "second-password", dataset.DataSet.Metrics[0]["value"])
+
+	// AND the rotation is logged without the variable values
+	rotationLogged := false
+	for _, entry := range hook.AllEntries() {
+		if entry.Message == "Variables have been rotated. Restarting integration instances" {
+			rotationLogged = true
+		}
+		line, err := entry.String()
+		require.NoError(t, err)
+		assert.NotContains(t, line, "second-password")
+	}
+	assert.True(t, rotationLogged)
+}
+
+func Test_runner_Run_keepsRunningOnUnreferencedRotatedVariables(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip()
+	}
+	log.SetOutput(ioutil.Discard)  // discard logs so not to break race tests
+	defer log.SetOutput(os.Stderr) // return back to default
+
+	// GIVEN a long-running integration that only references one of the variables of its file
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(passwordFile, []byte("first-password"), 0600))
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("first-token"), 0600))
+	dSources, err := databind.LoadYAML([]byte(`
+variables:
+  password:
+    ttl: 100ms
+    file:
+      path: ` + passwordFile + `
+  token:
+    ttl: 100ms
+    file:
+      path: ` + tokenFile + `
+`))
+	require.NoError(t, err)
+	def, err := integration.NewDefinition(config.ConfigEntry{
+		InstanceName: "foo",
+		Exec:         testhelp.Command(fixtures.IntegrationBlocked, "${password}"),
+		Interval:     "0",
+	}, integration.ErrLookup, nil, nil)
+	require.NoError(t, err)
+
+	e := &testemit.RecordEmitter{}
+	r := NewRunner(def, e, dSources, nil, cmdrequest.NoopHandleFn, configrequest.NoopHandleFn, nil, host.IDLookup{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		r.Run(ctx, nil, nil)
+		close(finished)
+	}()
+	defer func() {
+		cancel()
+		<-finished
+	}()
+
+	dataset, err := e.ReceiveFrom("foo")
+	require.NoError(t, err)
+	require.Len(t, dataset.DataSet.Metrics, 1)
+	assert.Equal(t, "first-password", dataset.DataSet.Metrics[0]["value"])
+
+	// WHEN the variable that is not referenced by the integration changes
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("second-token"), 0600))
+
+	// THEN the integration is not restarted
+	require.NoError(t, e.ExpectTimeout("foo", time.Second))
+}
@@ -365,6 +365,12 @@ type Config struct {
 	// Public: Yes
 	MetricsProcessSampleRate int `yaml:"metrics_process_sample_rate" envconfig:"metrics_process_sample_rate"`
 
+	// MetricsCPUCoreSampleRate Sample rate of CPU Core Samples in seconds, reporting the usage, frequency and
+	// interrupts of every core. Minimum value is 5. Disabled by default, as it reports one sample per core.
+	// Default: -1
+	// Public: Yes
+	MetricsCPUCoreSampleRate int `yaml:"metrics_cpu_core_sample_rate" envconfig:"metrics_cpu_core_sample_rate"`
+
 	// HeartBeatSampleRate Interval in seconds for sending the HeartBeatSample.
 	// Default: False
 	// Public: No
@@ -729,6 +735,13 @@ type Config struct {
 	// Public: Yes
 	PassthroughEnvironment []string `yaml:"passthrough_environment" envconfig:"passthrough_environment"`
 
+	// StrictIntegrationValidation enables the strict validation of protocol v4 integration payloads. Invalid
+	// datasets, metrics and events are rejected individually instead of failing late. It can be overridden per
+	// integration through the `strict_validation` configuration entry.
+	// Default: false
+	// Public: Yes
+	StrictIntegrationValidation bool `yaml:"strict_integration_validation" envconfig:"strict_integration_validation"`
+
 	// PluginConfigFiles This configuration parameter specify the agent to look for newrelic-infra-plugins.yml
 	// Default: Empty
 	// Public: No
@@ -744,6 +757,12 @@ type Config struct {
 	// Public: Yes
 	LoggingConfigsDir string `yaml:"logging_configs_dir "envconfig:"logging_configs_dir" public:"true"`
 
+	// LoggingMask masks sensitive data in all the forwarded log records, before they leave the host. Built-in
+	// detectors (email, credit_card, ip, bearer_token) and custom regex/replacement rules are supported.
+	// Default: Empty
+	// Public: Yes
+	LoggingMask LogMaskConfig `yaml:"logging_mask" envconfig:"logging_mask"`
+
 	// LoggingBinDir folder containing binaries for the log forwarder.
 	// Default: /var/db/newrelic-infra/newrelic-integrations/logging/
 	// Public: No
@@ -772,6 +791,12 @@ type Config struct {
 	// Public: No
 	FluentBitNRLibPath string `yaml:"fluent_bit_nr_lib_path "envconfig:"fluent_bit_nr_lib_path" public:"false"`
 
+	// FluentBitMetricsPort is the local port where fluent-bit serves its internal metrics, used by the agent to
+	// report the log records dropped by rate limiting and sampling. It's only open when any is configured.
+	// Default: 8004
+	// Public: No
+	FluentBitMetricsPort int `yaml:"fluent_bit_metrics_port" envconfig:"fluent_bit_metrics_port" public:"false"`
+
 	// HTTPServerEnabled By setting true this configuration parameter (used by statsD integration v1) the agent will
 	//	// open HTTP port (by default, 8001) to receive integration payloads via HTTP.
 	// Default: False
@@ -1039,6 +1064,13 @@ type Config struct {
 	// Public: Yes
 	DetailedNFS bool `yaml:"detailed_nfs" envconfig:"detailed_nfs"`
 
+	// MetricsContainerSampleRate Sample rate of Container Samples in seconds, which are collected from the cgroup v2
+	// hierarchy for the containers and slices in the host. Minimum value is 5. Disabled by default, as the Docker
+	// integration already reports a ContainerSample for each container.
+	// Default: -1
+	// Public: Yes
+	MetricsContainerSampleRate int `yaml:"metrics_container_sample_rate" envconfig:"metrics_container_sample_rate"`
+
 	// Internals
 
 	// concurrency support
@@ -1110,10 +1142,35 @@ type LogForward struct {
 	Troubleshoot Troubleshoot
 	ConfigsDir   string
 	HomeDir      string
+	AgentDir     string
 	License      string
 	IsFedramp    bool
 	IsStaging    bool
 	ProxyCfg     LogForwardProxy
+	Mask         LogMaskConfig
+	MetricsPort  int
+}
+
+// LogMaskConfig masks sensitive data in the log records before they are forwarded.
+type LogMaskConfig struct {
+	Detectors []string      `yaml:"detectors"` // built-in detectors: email, credit_card, ip, bearer_token
+	Rules     []LogMaskRule `yaml:"rules"`
+}
+
+// LogMaskRule replaces the matches of a regular expression. The replacement can refer to the regex groups, as $1.
+type LogMaskRule struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+}
+
+// IsEmpty returns true if no masking has been configured.
+func (m *LogMaskConfig) IsEmpty() bool {
+	return len(m.Detectors) == 0 && len(m.Rules) == 0
+}
+
+// Decode parses the mask configuration from an environment variable in YAML format.
+func (m *LogMaskConfig) Decode(value string) error {
+	return yaml.Unmarshal([]byte(value), m)
 }
 
 type LogForwardProxy struct {
@@ -1130,6 +1187,7 @@ func NewLogForward(config *Config, troubleshoot Troubleshoot) LogForward {
 		Troubleshoot: troubleshoot,
 		ConfigsDir:   config.LoggingConfigsDir,
 		HomeDir:      config.LoggingHomeDir,
+		AgentDir:     config.AgentDir,
 		License:      config.License,
 		IsFedramp:    config.Fedramp,
 		IsStaging:    config.Staging,
@@ -1140,6 +1198,8 @@ func NewLogForward(config *Config, troubleshoot Troubleshoot) LogForward {
 			CABundleDir:       config.CABundleDir,
 			ValidateCerts:     config.ProxyValidateCerts,
 		},
+		Mask:        config.LoggingMask,
+		MetricsPort: config.FluentBitMetricsPort,
 	}
 }
 
@@ -1364,6 +1424,7 @@ func NewConfig() *Config {
 		HTTPServerPort:                defaultHTTPServerPort,
 		TCPServerPort:                 defaultTCPServerPort,
 		StatusServerPort:              defaultStatusServerPort,
+		FluentBitMetricsPort:          defaultFluentBitMetricsPort,
 		DockerApiVersion:              DefaultDockerApiVersion,
 		FingerprintUpdateFreqSec:      defaultFingerprintUpdateFreqSec,
 		CloudMetadataExpiryInSec:      defaultCloudMetadataExpiryInSec,
@@ -1404,6 +1465,8 @@ func NewConfig() *Config {
 		PartitionsTTL:               defaultPartitionsTTL,
 		StartupConnectionTimeout:    defaultStartupConnectionTimeout,
 		MetricsNFSSampleRate:        DefaultMetricsNFSSampleRate,
+		MetricsContainerSampleRate:  FREQ_DISABLE_SAMPLING,
+		MetricsCPUCoreSampleRate:    FREQ_DISABLE_SAMPLING,
 		SmartVerboseModeEntryLimit:  DefaultSmartVerboseModeEntryLimit,
 		DefaultIntegrationsTempDir:  defaultIntegrationsTempDir,
 		IncludeMetricsMatchers:      defaultMetricsMatcherConfig,
@@ -1788,6 +1851,16 @@ func NormalizeConfig(cfg *Config, cfgMetadata config_loader.YAMLMetadata) (err e
 	}
 	nlog.WithField("MetricsProcessSampleRate", cfg.MetricsProcessSampleRate).Debug("Metrics Process Sample Rate.")
 
+	if cfg.MetricsCPUCoreSampleRate < FREQ_INTERVAL_FLOOR_SYSTEM_METRICS && cfg.MetricsCPUCoreSampleRate > FREQ_DISABLE_SAMPLING {
+		cfg.MetricsCPUCoreSampleRate = FREQ_INTERVAL_FLOOR_SYSTEM_METRICS
+	}
+	nlog.WithField("MetricsCPUCoreSampleRate", cfg.MetricsCPUCoreSampleRate).Debug("Metrics CPU Core Sample Rate.")
+
+	if cfg.MetricsContainerSampleRate < FREQ_INTERVAL_FLOOR_SYSTEM_METRICS && cfg.MetricsContainerSampleRate > FREQ_DISABLE_SAMPLING {
+		cfg.MetricsContainerSampleRate = FREQ_INTERVAL_FLOOR_SYSTEM_METRICS
+	}
+	nlog.WithField("MetricsContainerSampleRate", cfg.MetricsContainerSampleRate).Debug("Metrics Container Sample Rate.")
+
 	nlog.WithField("FilesConfigOn", cfg.FilesConfigOn).Debug("Configuration file monitoring.")
 
 	if cfg.NetworkInterfaceFilters == nil || len(cfg.NetworkInterfaceFilters) == 0 {
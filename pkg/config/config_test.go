@@ -664,6 +664,30 @@ license_key: ${license}
 	assert.Equal(t, "XXX", cfg.License)
 }
 
+func TestLoadYamlConfig_MetricsContainerSampleRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected int
+	}{
+		{"disabled by default", "license_key: abc123\n", FREQ_DISABLE_SAMPLING},
+		{"explicitly disabled", "license_key: abc123\nmetrics_container_sample_rate: -1\n", FREQ_DISABLE_SAMPLING},
+		{"below the floor", "license_key: abc123\nmetrics_container_sample_rate: 1\n", FREQ_INTERVAL_FLOOR_SYSTEM_METRICS},
+		{"enabled", "license_key: abc123\nmetrics_container_sample_rate: 30\n", 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp, err := createTestFile([]byte(tt.yaml))
+			require.NoError(t, err)
+			defer os.Remove(tmp.Name())
+
+			cfg, err := LoadConfig(tmp.Name())
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cfg.MetricsContainerSampleRate)
+		})
+	}
+}
+
 func createTestFile(data []byte) (*os.File, error) {
 	tmp, err := ioutil.TempFile("", "loadconfig")
 	if err != nil {
@@ -71,6 +71,7 @@ var (
 	defaultHTTPServerPort                = 8001
 	defaultTCPServerPort                 = 8002
 	defaultStatusServerPort              = 8003
+	defaultFluentBitMetricsPort          = 8004
 	defaultIpData                        = true
 	defaultTruncTextValues               = true
 	defaultLogToStdout                   = true
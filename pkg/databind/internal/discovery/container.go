@@ -11,6 +11,7 @@ import (
 type Container struct {
 	Match      map[string]string `yaml:"match"`
 	ApiVersion string            `yaml:"api_version"` // for docker client
+	Socket     string            `yaml:"socket"`      // container runtime socket, when not using the default one
 }
 
 func (d *Container) Validate() error {
@@ -0,0 +1,263 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cri
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/counter"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/naming"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+const (
+	defaultSocket          = "/run/containerd/containerd.sock"
+	requestTimeout         = 10 * time.Second
+	metricAnnotationsToAdd = 7
+)
+
+// CRI RuntimeService API versions, in order of preference
+var apiVersions = []string{"runtime.v1", "runtime.v1alpha2"}
+
+// Discoverer returns a container discoverer for runtimes implementing the Kubernetes Container Runtime
+// Interface (containerd, CRI-O...), from the provided configuration.
+// The fetching process will return an array of map values for each discovered container, with the
+// keys discovery.port and discovery.ip
+func Discoverer(d discovery.Container) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	if d.Socket == "" {
+		d.Socket = defaultSocket
+	}
+	matcher, err := discovery.NewMatcher(d.Match)
+	if err != nil {
+		return nil, err
+	}
+	return func() ([]discovery.Discovery, error) {
+		return fetch(d, &matcher)
+	}, nil
+}
+
+func fetch(d discovery.Container, matcher *discovery.FieldsMatcher) ([]discovery.Discovery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, socketTarget(d.Socket), grpc.WithInsecure(), grpc.WithBlock(), grpc.FailOnNonTempDialError(true))
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to CRI socket %q: %s", d.Socket, err)
+	}
+	defer conn.Close()
+
+	rt := runtimeService{conn: conn}
+	containers, err := rt.listRunningContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []discovery.Discovery
+	sandboxes := map[string]podSandboxStatus{}
+	for _, cont := range containers {
+		sandbox, ok := sandboxes[cont.podSandboxID]
+		if !ok {
+			sandbox, err = rt.podSandboxStatus(ctx, cont.podSandboxID)
+			if err != nil {
+				return nil, err
+			}
+			sandboxes[cont.podSandboxID] = sandbox
+		}
+
+		// discovery attributes that identify the container
+		labels := map[string]string{}
+		for k, v := range cont.labels {
+			labels[data.LabelInfix+k] = v
+		}
+		labels[data.Name] = cont.name
+		labels[data.Image] = cont.image
+		labels[data.ContainerID] = cont.id
+		if sandbox.name != "" {
+			labels[data.PodName] = sandbox.name
+			labels[data.Namespace] = sandbox.namespace
+		}
+		if sandbox.ip != "" {
+			labels[data.IP] = sandbox.ip
+			labels[data.PrivateIP] = sandbox.ip
+		}
+		addPorts(sandbox.portMappings(), labels)
+
+		// only containers matching all the criteria will be added
+		if matcher.All(labels) {
+			prefixedLabels := discovery.LabelsToMap(data.DiscoveryPrefix, labels)
+
+			ma := make(data.InterfaceMap, metricAnnotationsToAdd)
+			naming.AddImage(ma, cont.image)
+			naming.AddImageID(ma, cont.imageRef)
+			naming.AddContainerName(ma, cont.name)
+			naming.AddContainerID(ma, cont.id)
+			naming.AddLabels(ma, cont.labels)
+			if sandbox.name != "" {
+				naming.AddPodName(ma, sandbox.name)
+				naming.AddNamespace(ma, sandbox.namespace)
+			}
+
+			matches = append(matches, discovery.Discovery{
+				Variables: prefixedLabels,
+				EntityRewrites: []data.EntityRewrite{
+					{
+						Action:       data.EntityRewriteActionReplace,
+						Match:        naming.ToVariable(data.IP),
+						ReplaceField: data.ContainerReplaceFieldPrefix + naming.ToVariable(data.ContainerID),
+					},
+				},
+				MetricAnnotations: ma,
+			})
+		}
+	}
+
+	return matches, nil
+}
+
+// runtimeService invokes the CRI RuntimeService, negotiating the API version supported by the runtime.
+type runtimeService struct {
+	conn       *grpc.ClientConn
+	apiVersion string
+}
+
+func (r *runtimeService) listRunningContainers(ctx context.Context) ([]container, error) {
+	resp, err := r.invoke(ctx, "ListContainers", listRunningContainersRequest())
+	if err != nil {
+		return nil, err
+	}
+	containers, err := decodeListContainersResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	// runtimes may ignore the filter
+	running := containers[:0]
+	for _, c := range containers {
+		if c.state == containerRunning {
+			running = append(running, c)
+		}
+	}
+	return running, nil
+}
+
+func (r *runtimeService) podSandboxStatus(ctx context.Context, podSandboxID string) (podSandboxStatus, error) {
+	resp, err := r.invoke(ctx, "PodSandboxStatus", podSandboxStatusRequest(podSandboxID))
+	if err != nil {
+		return podSandboxStatus{}, err
+	}
+	return decodePodSandboxStatusResponse(resp)
+}
+
+func (r *runtimeService) invoke(ctx context.Context, method string, req []byte) ([]byte, error) {
+	versions := apiVersions
+	if r.apiVersion != "" {
+		versions = []string{r.apiVersion}
+	}
+	var err error
+	for _, version := range versions {
+		var resp []byte
+		err = r.conn.Invoke(ctx, "/"+version+".RuntimeService/"+method, &req, &resp, grpc.ForceCodec(rawCodec{}))
+		if status.Code(err) == codes.Unimplemented {
+			continue
+		}
+		if err == nil {
+			r.apiVersion = version
+		}
+		return resp, err
+	}
+	return nil, fmt.Errorf("no supported CRI API version found: %s", err)
+}
+
+type portMapping struct {
+	protocol      string
+	containerPort int
+}
+
+// portMappings returns the sandbox port mappings from the verbose status information. This information
+// is runtime specific, so it may not be available.
+func (s podSandboxStatus) portMappings() []portMapping {
+	raw, ok := s.info["info"]
+	if !ok {
+		return nil
+	}
+	var info struct {
+		Config struct {
+			PortMappings []struct {
+				Protocol      interface{} `json:"protocol"`
+				ContainerPort int         `json:"container_port"`
+			} `json:"port_mappings"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal([]byte(raw), &info); err != nil {
+		return nil
+	}
+
+	mappings := make([]portMapping, 0, len(info.Config.PortMappings))
+	for _, pm := range info.Config.PortMappings {
+		mappings = append(mappings, portMapping{
+			protocol:      protocolName(pm.Protocol),
+			containerPort: pm.ContainerPort,
+		})
+	}
+	return mappings
+}
+
+// protocolName converts the CRI protocol, which may be serialized as its enum number or name.
+func protocolName(protocol interface{}) string {
+	switch p := protocol.(type) {
+	case string:
+		return strings.ToLower(p)
+	case float64:
+		switch p {
+		case 1:
+			return "udp"
+		case 2:
+			return "sctp"
+		}
+	}
+	return "tcp"
+}
+
+// addPorts labels the container ports, which are reachable through the sandbox IP.
+func addPorts(mappings []portMapping, labels map[string]string) {
+	// sort ports from lower to higher so we are always consistent with the returned ports
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].containerPort < mappings[j].containerPort
+	})
+
+	protocols := counter.ByKind{}
+	for index, pm := range mappings {
+		indexStr := "." + strconv.Itoa(index)
+		pNum := protocols.Count(pm.protocol)
+		portStr := strconv.Itoa(pm.containerPort)
+		if index == 0 {
+			labels[data.Port] = portStr // discovery.port = <...>
+			labels[data.PrivatePort] = portStr
+		}
+		labels[data.Ports+indexStr] = portStr // discovery.ports.0 = <...>
+		labels[data.PrivatePorts+indexStr] = portStr
+		if pNum == 0 {
+			labels[data.Ports+"."+pm.protocol] = portStr // discovery.ports.tcp = <...>
+		}
+		labels[data.Ports+"."+pm.protocol+"."+strconv.Itoa(pNum)] = portStr // discovery.ports.tcp.0 = <...>
+	}
+}
+
+// socketTarget accepts both socket paths and URLs as gRPC target.
+func socketTarget(socket string) string {
+	if strings.Contains(socket, "://") {
+		return socket
+	}
+	return "unix://" + socket
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cri
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+)
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendMapEntry(b []byte, num protowire.Number, key, value string) []byte {
+	return appendMessage(b, num, appendString(appendString(nil, 1, key), 2, value))
+}
+
+func encodeContainer(id, sandboxID, name, image string, state uint64, labels map[string]string) []byte {
+	var c []byte
+	c = appendString(c, 1, id)
+	c = appendString(c, 2, sandboxID)
+	c = appendMessage(c, 3, appendString(nil, 1, name))
+	c = appendMessage(c, 4, appendString(nil, 1, image))
+	c = appendString(c, 5, "sha256:"+id)
+	c = protowire.AppendTag(c, 6, protowire.VarintType)
+	c = protowire.AppendVarint(c, state)
+	for k, v := range labels {
+		c = appendMapEntry(c, 8, k, v)
+	}
+	return c
+}
+
+func encodeSandboxStatus(name, namespace, ip, info string) []byte {
+	var metadata, network, st, resp []byte
+	metadata = appendString(appendString(metadata, 1, name), 3, namespace)
+	network = appendString(network, 1, ip)
+	st = appendString(st, 1, "sandbox")
+	st = appendMessage(st, 2, metadata)
+	st = appendMessage(st, 5, network)
+	resp = appendMessage(resp, 1, st)
+	resp = appendMapEntry(resp, 2, "info", info)
+	return resp
+}
+
+// fakeRuntime serves the CRI RuntimeService for the given API version on a unix socket.
+func fakeRuntime(t *testing.T, apiVersion string) string {
+	socket := filepath.Join(t.TempDir(), "cri.sock")
+	lis, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	srv := grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			if !strings.HasPrefix(method, "/"+apiVersion+".RuntimeService/") {
+				return status.Error(codes.Unimplemented, "unknown service")
+			}
+			var req []byte
+			if err := stream.RecvMsg(&req); err != nil {
+				return err
+			}
+			var resp []byte
+			switch {
+			case strings.HasSuffix(method, "/ListContainers"):
+				resp = appendMessage(resp, 1, encodeContainer("c1", "s1", "redis", "redis:6", containerRunning,
+					map[string]string{"io.kubernetes.pod.name": "redis-0"}))
+				resp = appendMessage(resp, 1, encodeContainer("c2", "s1", "exporter", "redis-exporter", containerRunning, nil))
+				resp = appendMessage(resp, 1, encodeContainer("c3", "s2", "redis", "redis:6", 2, nil))
+			case strings.HasSuffix(method, "/PodSandboxStatus"):
+				resp = encodeSandboxStatus("redis-0", "cache", "10.0.0.12",
+					`{"pid":123,"config":{"port_mappings":[{"protocol":1,"container_port":9121},{"container_port":6379}]}}`)
+			default:
+				return status.Error(codes.Unimplemented, "unknown method")
+			}
+			return stream.SendMsg(&resp)
+		}),
+	)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return socket
+}
+
+func TestDiscoverer(t *testing.T) {
+	for _, apiVersion := range apiVersions {
+		t.Run(apiVersion, func(t *testing.T) {
+			socket := fakeRuntime(t, apiVersion)
+
+			// GIVEN a CRI discoverer matching by container name
+			fetch, err := Discoverer(discovery.Container{
+				Socket: socket,
+				Match:  map[string]string{"name": "redis"},
+			})
+			require.NoError(t, err)
+
+			// WHEN the discovery is fetched
+			matches, err := fetch()
+			require.NoError(t, err)
+
+			// THEN only the running container is discovered
+			require.Len(t, matches, 1)
+			vars := matches[0].Variables
+			assert.Equal(t, "redis", vars["discovery.name"])
+			assert.Equal(t, "redis:6", vars["discovery.image"])
+			assert.Equal(t, "c1", vars["discovery.containerId"])
+			assert.Equal(t, "10.0.0.12", vars["discovery.ip"])
+			assert.Equal(t, "10.0.0.12", vars["discovery.private.ip"])
+			assert.Equal(t, "6379", vars["discovery.port"])
+			assert.Equal(t, "6379", vars["discovery.ports.tcp"])
+			assert.Equal(t, "9121", vars["discovery.ports.udp"])
+			assert.Equal(t, "9121", vars["discovery.ports.1"])
+			assert.Equal(t, "redis-0", vars["discovery.podName"])
+			assert.Equal(t, "cache", vars["discovery.namespace"])
+			assert.Equal(t, "redis-0", vars["discovery.label.io.kubernetes.pod.name"])
+
+			ma := matches[0].MetricAnnotations
+			assert.Equal(t, "redis:6", ma["image"])
+			assert.Equal(t, "sha256:c1", ma["imageId"])
+			assert.Equal(t, "redis", ma["containerName"])
+			assert.Equal(t, "c1", ma["containerId"])
+
+			require.Len(t, matches[0].EntityRewrites, 1)
+			assert.Equal(t, "${ip}", matches[0].EntityRewrites[0].Match)
+			assert.Equal(t, "container:${containerId}", matches[0].EntityRewrites[0].ReplaceField)
+		})
+	}
+}
+
+func TestDiscoverer_UnavailableSocket(t *testing.T) {
+	fetch, err := Discoverer(discovery.Container{
+		Socket: filepath.Join(t.TempDir(), "missing.sock"),
+		Match:  map[string]string{"name": "redis"},
+	})
+	require.NoError(t, err)
+
+	_, err = fetch()
+	assert.Error(t, err)
+}
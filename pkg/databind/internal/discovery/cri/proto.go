@@ -0,0 +1,223 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cri
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Minimal protobuf encoding of the CRI RuntimeService messages used for discovery, to avoid
+// depending on the whole Kubernetes CRI API.
+// https://github.com/kubernetes/cri-api/blob/master/pkg/apis/runtime/v1/api.proto
+
+// CRI container states
+const (
+	containerRunning = 1
+)
+
+// rawCodec sends and receives already encoded protobuf messages.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return *msg, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*msg = append((*msg)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+type container struct {
+	id           string
+	podSandboxID string
+	name         string
+	image        string
+	imageRef     string
+	state        uint64
+	labels       map[string]string
+}
+
+type podSandboxStatus struct {
+	name      string
+	namespace string
+	ip        string
+	info      map[string]string
+}
+
+// listRunningContainersRequest encodes a ListContainersRequest filtering by running state.
+func listRunningContainersRequest() []byte {
+	var stateValue, filter, req []byte
+	stateValue = protowire.AppendTag(stateValue, 1, protowire.VarintType) // ContainerStateValue.state
+	stateValue = protowire.AppendVarint(stateValue, containerRunning)
+	filter = protowire.AppendTag(filter, 2, protowire.BytesType) // ContainerFilter.state
+	filter = protowire.AppendBytes(filter, stateValue)
+	req = protowire.AppendTag(req, 1, protowire.BytesType) // ListContainersRequest.filter
+	req = protowire.AppendBytes(req, filter)
+	return req
+}
+
+// podSandboxStatusRequest encodes a verbose PodSandboxStatusRequest.
+func podSandboxStatusRequest(podSandboxID string) []byte {
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.BytesType) // pod_sandbox_id
+	req = protowire.AppendString(req, podSandboxID)
+	req = protowire.AppendTag(req, 2, protowire.VarintType) // verbose
+	req = protowire.AppendVarint(req, protowire.EncodeBool(true))
+	return req
+}
+
+// decodeListContainersResponse decodes the containers of a ListContainersResponse.
+func decodeListContainersResponse(b []byte) ([]container, error) {
+	var containers []container
+	err := decodeMessage(b, func(num protowire.Number, value []byte, _ uint64) error {
+		if num != 1 {
+			return nil
+		}
+		c, err := decodeContainer(value)
+		if err != nil {
+			return err
+		}
+		containers = append(containers, c)
+		return nil
+	})
+	return containers, err
+}
+
+func decodeContainer(b []byte) (container, error) {
+	c := container{labels: map[string]string{}}
+	err := decodeMessage(b, func(num protowire.Number, value []byte, varint uint64) error {
+		switch num {
+		case 1:
+			c.id = string(value)
+		case 2:
+			c.podSandboxID = string(value)
+		case 3: // ContainerMetadata
+			return decodeMessage(value, func(num protowire.Number, value []byte, _ uint64) error {
+				if num == 1 {
+					c.name = string(value)
+				}
+				return nil
+			})
+		case 4: // ImageSpec
+			return decodeMessage(value, func(num protowire.Number, value []byte, _ uint64) error {
+				if num == 1 {
+					c.image = string(value)
+				}
+				return nil
+			})
+		case 5:
+			c.imageRef = string(value)
+		case 6:
+			c.state = varint
+		case 8:
+			return decodeMapEntry(value, c.labels)
+		}
+		return nil
+	})
+	return c, err
+}
+
+// decodePodSandboxStatusResponse decodes a PodSandboxStatusResponse.
+func decodePodSandboxStatusResponse(b []byte) (podSandboxStatus, error) {
+	s := podSandboxStatus{info: map[string]string{}}
+	err := decodeMessage(b, func(num protowire.Number, value []byte, _ uint64) error {
+		switch num {
+		case 1: // PodSandboxStatus
+			return decodeMessage(value, func(num protowire.Number, value []byte, _ uint64) error {
+				switch num {
+				case 2: // PodSandboxMetadata
+					return decodeMessage(value, func(num protowire.Number, value []byte, _ uint64) error {
+						switch num {
+						case 1:
+							s.name = string(value)
+						case 3:
+							s.namespace = string(value)
+						}
+						return nil
+					})
+				case 5: // PodSandboxNetworkStatus
+					return decodeMessage(value, func(num protowire.Number, value []byte, _ uint64) error {
+						if num == 1 {
+							s.ip = string(value)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+		case 2:
+			return decodeMapEntry(value, s.info)
+		}
+		return nil
+	})
+	return s, err
+}
+
+// decodeMapEntry decodes a map<string, string> entry into the destination map.
+func decodeMapEntry(b []byte, dst map[string]string) error {
+	var key, value string
+	err := decodeMessage(b, func(num protowire.Number, v []byte, _ uint64) error {
+		switch num {
+		case 1:
+			key = string(v)
+		case 2:
+			value = string(v)
+		}
+		return nil
+	})
+	dst[key] = value
+	return err
+}
+
+// decodeMessage iterates the fields of an encoded message. Length delimited fields are passed as
+// value and varint fields as varint. Other field types are ignored.
+func decodeMessage(b []byte, field func(num protowire.Number, value []byte, varint uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var err error
+		switch typ {
+		case protowire.BytesType:
+			var value []byte
+			value, n = protowire.ConsumeBytes(b)
+			if n >= 0 {
+				err = field(num, value, 0)
+			}
+		case protowire.VarintType:
+			var varint uint64
+			varint, n = protowire.ConsumeVarint(b)
+			if n >= 0 {
+				err = field(num, nil, varint)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
@@ -42,7 +42,11 @@ func Discoverer(d discovery.Container) (fetchDiscoveries func() (discoveries []d
 func fetch(d discovery.Container, matcher *discovery.FieldsMatcher) ([]discovery.Discovery, error) {
 	var matches []discovery.Discovery
 
-	dc, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if d.Socket != "" {
+		opts = append(opts, client.WithHost(socketHost(d.Socket)))
+	}
+	dc, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -71,12 +75,14 @@ func fetch(d discovery.Container, matcher *discovery.FieldsMatcher) ([]discovery
 		labels[data.ContainerID] = cont.ID
 
 		index := 0
-		for _, network := range cont.NetworkSettings.Networks {
-			if index == 0 {
-				labels[data.PrivateIP] = network.IPAddress
+		if cont.NetworkSettings != nil {
+			for _, network := range cont.NetworkSettings.Networks {
+				if index == 0 {
+					labels[data.PrivateIP] = network.IPAddress
+				}
+				labels[data.PrivateIP+"."+strconv.Itoa(index)] = network.IPAddress
+				index++
 			}
-			labels[data.PrivateIP+"."+strconv.Itoa(index)] = network.IPAddress
-			index++
 		}
 
 		addPorts(cont, labels)
@@ -150,3 +156,11 @@ func addPorts(cont types.Container, labels map[string]string) {
 		}
 	}
 }
+
+// socketHost accepts both socket paths and URLs as the container runtime host.
+func socketHost(socket string) string {
+	if strings.Contains(socket, "://") {
+		return socket
+	}
+	return "unix://" + socket
+}
@@ -0,0 +1,30 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+	"time"
+)
+
+// Kubelet discovery parameters
+type Kubelet struct {
+	Match map[string]string `yaml:"match"`
+	// URL of the kubelet API. Default: https://localhost:10250
+	URL string `yaml:"url"`
+	// TokenFile bearer token used to authenticate against the kubelet. Default: service account token
+	TokenFile string `yaml:"token_file"`
+	// CAFile certificate authority to verify the kubelet certificate. Default: service account CA
+	CAFile string `yaml:"ca_file"`
+	// InsecureSkipVerify disables the kubelet certificate verification
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify"`
+	Timeout            time.Duration `yaml:"timeout"`
+}
+
+func (k *Kubelet) Validate() error {
+	if len(k.Match) == 0 {
+		return errors.New("missing 'match' entries")
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/counter"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/naming"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+const (
+	defaultKubeletURL      = "https://localhost:10250"
+	defaultTokenFile       = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	defaultCAFile          = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	defaultTimeout         = 5 * time.Second
+	podsPath               = "/pods"
+	metricAnnotationsToAdd = 7
+)
+
+// Discoverer returns a Kubernetes discoverer that looks for the containers of the running pods
+// of the node, as reported by the kubelet API.
+// The fetching process will return an array of map values for each discovered container, with the
+// keys discovery.port and discovery.ip
+func Discoverer(k discovery.Kubelet) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	if k.URL == "" {
+		k.URL = defaultKubeletURL
+	}
+	if k.TokenFile == "" {
+		k.TokenFile = defaultTokenFile
+	}
+	if k.Timeout <= 0 {
+		k.Timeout = defaultTimeout
+	}
+	matcher, err := discovery.NewMatcher(k.Match)
+	if err != nil {
+		return nil, err
+	}
+	client, err := httpClient(k)
+	if err != nil {
+		return nil, err
+	}
+	return func() ([]discovery.Discovery, error) {
+		pods, err := kubeletPods(client, k)
+		if err != nil {
+			return nil, err
+		}
+		return match(pods, &matcher), nil
+	}, nil
+}
+
+func httpClient(k discovery.Kubelet) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: k.InsecureSkipVerify}
+
+	caFile := k.CAFile
+	if caFile == "" {
+		if _, err := os.Stat(defaultCAFile); err == nil {
+			caFile = defaultCAFile
+		}
+	}
+	if caFile != "" && !k.InsecureSkipVerify {
+		caCert, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read kubelet CA file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no valid certificates found in kubelet CA file %q", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Timeout: k.Timeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
+
+func kubeletPods(client *http.Client, k discovery.Kubelet) (*podList, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(k.URL, "/")+podsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the token is read on each request as service account tokens are periodically rotated
+	if token, err := ioutil.ReadFile(k.TokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	} else if k.TokenFile != defaultTokenFile {
+		return nil, fmt.Errorf("can't read kubelet token file: %s", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubelet responded %v - %v", resp.StatusCode, resp.Status)
+	}
+
+	pods := podList{}
+	if err := json.NewDecoder(resp.Body).Decode(&pods); err != nil {
+		return nil, err
+	}
+	return &pods, nil
+}
+
+func match(pods *podList, matcher *discovery.FieldsMatcher) []discovery.Discovery {
+	var matches []discovery.Discovery
+
+	for _, p := range pods.Items {
+		if p.Status.Phase != podRunning || p.Status.PodIP == "" {
+			continue
+		}
+		statuses := make(map[string]containerStatus, len(p.Status.ContainerStatuses))
+		for _, cs := range p.Status.ContainerStatuses {
+			statuses[cs.Name] = cs
+		}
+
+		for _, cont := range p.Spec.Containers {
+			status := statuses[cont.Name]
+			containerID := trimScheme(status.ContainerID)
+
+			// discovery attributes that identify the container
+			labels := map[string]string{}
+			for k, v := range p.Metadata.Labels {
+				labels[data.LabelInfix+k] = v
+			}
+			for k, v := range p.Metadata.Annotations {
+				labels[data.AnnotationInfix+k] = v
+			}
+			labels[data.Name] = cont.Name
+			labels[data.PodName] = p.Metadata.Name
+			labels[data.Namespace] = p.Metadata.Namespace
+			labels[data.Image] = cont.Image
+			labels[data.ContainerID] = containerID
+			labels[data.IP] = p.Status.PodIP
+			labels[data.PrivateIP] = p.Status.PodIP
+
+			addPorts(cont, labels)
+
+			// only containers matching all the criteria will be added
+			if matcher.All(labels) {
+				prefixedLabels := discovery.LabelsToMap(data.DiscoveryPrefix, labels)
+
+				ma := make(data.InterfaceMap, metricAnnotationsToAdd)
+				naming.AddImage(ma, cont.Image)
+				naming.AddImageID(ma, status.ImageID)
+				naming.AddContainerName(ma, cont.Name)
+				naming.AddContainerID(ma, containerID)
+				naming.AddLabels(ma, p.Metadata.Labels)
+				naming.AddPodName(ma, p.Metadata.Name)
+				naming.AddNamespace(ma, p.Metadata.Namespace)
+
+				matches = append(matches, discovery.Discovery{
+					Variables: prefixedLabels,
+					EntityRewrites: []data.EntityRewrite{
+						{
+							Action:       data.EntityRewriteActionReplace,
+							Match:        naming.ToVariable(data.IP),
+							ReplaceField: data.ContainerReplaceFieldPrefix + naming.ToVariable(data.ContainerID),
+						},
+					},
+					MetricAnnotations: ma,
+				})
+			}
+		}
+	}
+
+	return matches
+}
+
+func addPorts(cont container, labels map[string]string) {
+	// sort ports from lower to higher so we are always consistent with the returned ports
+	sort.Slice(cont.Ports, func(i, j int) bool {
+		return cont.Ports[i].ContainerPort < cont.Ports[j].ContainerPort
+	})
+
+	protocols := counter.ByKind{}
+	for index, p := range cont.Ports {
+		protocol := strings.ToLower(p.Protocol)
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		pNum := protocols.Count(protocol)
+		portStr := strconv.Itoa(int(p.ContainerPort))
+		indexStr := "." + strconv.Itoa(index)
+
+		if index == 0 {
+			labels[data.Port] = portStr // discovery.port = <...>
+			labels[data.PrivatePort] = portStr
+		}
+		labels[data.Ports+indexStr] = portStr // discovery.ports.0 = <...>
+		labels[data.PrivatePorts+indexStr] = portStr
+
+		if pNum == 0 {
+			labels[data.Ports+"."+protocol] = portStr // discovery.ports.tcp = <...>
+		}
+		labels[data.Ports+"."+protocol+"."+strconv.Itoa(pNum)] = portStr // discovery.ports.tcp.0 = <...>
+
+		if p.Name != "" {
+			labels[data.Ports+"."+p.Name] = portStr // discovery.ports.metrics = <...>
+		}
+	}
+}
+
+// trimScheme removes the runtime prefix of the container IDs (e.g. containerd://<id>).
+func trimScheme(containerID string) string {
+	if idx := strings.Index(containerID, "://"); idx >= 0 {
+		return containerID[idx+3:]
+	}
+	return containerID
+}
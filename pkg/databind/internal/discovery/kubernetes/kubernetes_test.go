@@ -0,0 +1,205 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+)
+
+const kubeletPodsResponse = `{
+  "kind": "PodList",
+  "items": [
+    {
+      "metadata": {
+        "name": "redis-0",
+        "namespace": "cache",
+        "labels": {"app": "redis"},
+        "annotations": {"newrelic.com/integration": "nri-redis"}
+      },
+      "spec": {
+        "nodeName": "node-1",
+        "containers": [
+          {
+            "name": "redis",
+            "image": "redis:6",
+            "ports": [
+              {"name": "metrics", "containerPort": 9121, "protocol": "TCP"},
+              {"name": "redis", "containerPort": 6379, "protocol": "TCP"}
+            ]
+          },
+          {"name": "sidecar", "image": "busybox"}
+        ]
+      },
+      "status": {
+        "phase": "Running",
+        "podIP": "10.0.0.12",
+        "containerStatuses": [
+          {"name": "redis", "imageID": "docker-pullable://redis@sha256:1234", "containerID": "containerd://abcdef"},
+          {"name": "sidecar", "containerID": "containerd://012345"}
+        ]
+      }
+    },
+    {
+      "metadata": {"name": "redis-1", "namespace": "cache", "labels": {"app": "redis"}},
+      "spec": {"containers": [{"name": "redis", "image": "redis:6"}]},
+      "status": {"phase": "Pending"}
+    },
+    {
+      "metadata": {"name": "nginx", "namespace": "default", "labels": {"app": "nginx"}},
+      "spec": {"containers": [{"name": "nginx", "image": "nginx", "ports": [{"containerPort": 80}]}]},
+      "status": {"phase": "Running", "podIP": "10.0.0.13"}
+    }
+  ]
+}`
+
+func fakeKubelet(t *testing.T) (server *httptest.Server, caFile, tokenFile string) {
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != podsPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(kubeletPodsResponse))
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	caFile = filepath.Join(dir, "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, ioutil.WriteFile(caFile, caPEM, 0600))
+	tokenFile = filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("secret-token\n"), 0600))
+	return
+}
+
+func TestDiscoverer(t *testing.T) {
+	server, caFile, tokenFile := fakeKubelet(t)
+
+	// GIVEN a kubernetes discoverer matching by label and container name
+	fetch, err := Discoverer(discovery.Kubelet{
+		URL:       server.URL,
+		CAFile:    caFile,
+		TokenFile: tokenFile,
+		Match: map[string]string{
+			"label.app": "redis",
+			"name":      "redis",
+		},
+	})
+	require.NoError(t, err)
+
+	// WHEN the discovery is fetched
+	matches, err := fetch()
+	require.NoError(t, err)
+
+	// THEN only the container of the running pod is discovered
+	require.Len(t, matches, 1)
+	vars := matches[0].Variables
+	assert.Equal(t, "10.0.0.12", vars["discovery.ip"])
+	assert.Equal(t, "6379", vars["discovery.port"])
+	assert.Equal(t, "6379", vars["discovery.ports.0"])
+	assert.Equal(t, "9121", vars["discovery.ports.1"])
+	assert.Equal(t, "6379", vars["discovery.ports.tcp"])
+	assert.Equal(t, "9121", vars["discovery.ports.metrics"])
+	assert.Equal(t, "redis", vars["discovery.name"])
+	assert.Equal(t, "redis-0", vars["discovery.podName"])
+	assert.Equal(t, "cache", vars["discovery.namespace"])
+	assert.Equal(t, "redis:6", vars["discovery.image"])
+	assert.Equal(t, "abcdef", vars["discovery.containerId"])
+	assert.Equal(t, "redis", vars["discovery.label.app"])
+	assert.Equal(t, "nri-redis", vars["discovery.annotation.newrelic.com/integration"])
+
+	// AND the metric annotations and entity rewrites are set as for other container runtimes
+	ma := matches[0].MetricAnnotations
+	assert.Equal(t, "redis:6", ma["image"])
+	assert.Equal(t, "docker-pullable://redis@sha256:1234", ma["imageId"])
+	assert.Equal(t, "redis", ma["containerName"])
+	assert.Equal(t, "abcdef", ma["containerId"])
+	assert.Equal(t, "redis-0", ma["podName"])
+	assert.Equal(t, "cache", ma["namespace"])
+	assert.Equal(t, map[string]string{"app": "redis"}, ma["label"])
+
+	require.Len(t, matches[0].EntityRewrites, 1)
+	assert.Equal(t, "replace", matches[0].EntityRewrites[0].Action)
+	assert.Equal(t, "${ip}", matches[0].EntityRewrites[0].Match)
+	assert.Equal(t, "container:${containerId}", matches[0].EntityRewrites[0].ReplaceField)
+}
+
+func TestDiscoverer_MatchByNamespaceAndPort(t *testing.T) {
+	server, caFile, tokenFile := fakeKubelet(t)
+
+	fetch, err := Discoverer(discovery.Kubelet{
+		URL:       server.URL,
+		CAFile:    caFile,
+		TokenFile: tokenFile,
+		Match: map[string]string{
+			"namespace": "/^(default|cache)$/",
+			"port":      "80",
+		},
+	})
+	require.NoError(t, err)
+
+	matches, err := fetch()
+	require.NoError(t, err)
+
+	require.Len(t, matches, 1)
+	assert.Equal(t, "nginx", matches[0].Variables["discovery.podName"])
+	assert.Equal(t, "10.0.0.13", matches[0].Variables["discovery.ip"])
+}
+
+func TestDiscoverer_Unauthorized(t *testing.T) {
+	server, caFile, _ := fakeKubelet(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("wrong-token"), 0600))
+
+	fetch, err := Discoverer(discovery.Kubelet{
+		URL:       server.URL,
+		CAFile:    caFile,
+		TokenFile: tokenFile,
+		Match:     map[string]string{"name": "redis"},
+	})
+	require.NoError(t, err)
+
+	_, err = fetch()
+	assert.Error(t, err)
+}
+
+func TestDiscoverer_UnknownCertificate(t *testing.T) {
+	server, _, tokenFile := fakeKubelet(t)
+
+	fetch, err := Discoverer(discovery.Kubelet{
+		URL:       server.URL,
+		TokenFile: tokenFile,
+		Match:     map[string]string{"name": "redis"},
+	})
+	require.NoError(t, err)
+
+	_, err = fetch()
+	assert.Error(t, err)
+
+	// unless the certificate verification is disabled
+	fetch, err = Discoverer(discovery.Kubelet{
+		URL:                server.URL,
+		TokenFile:          tokenFile,
+		InsecureSkipVerify: true,
+		Match:              map[string]string{"name": "redis"},
+	})
+	require.NoError(t, err)
+
+	matches, err := fetch()
+	require.NoError(t, err)
+	assert.Len(t, matches, 1)
+}
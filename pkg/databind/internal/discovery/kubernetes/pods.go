@@ -0,0 +1,58 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package kubernetes
+
+// podList is the subset of the kubelet /pods response required for discovery.
+// https://kubernetes.io/docs/reference/kubernetes-api/workload-resources/pod-v1/
+type podList struct {
+	Items []pod `json:"items"`
+}
+
+type pod struct {
+	Metadata podMetadata `json:"metadata"`
+	Spec     podSpec     `json:"spec"`
+	Status   podStatus   `json:"status"`
+}
+
+type podMetadata struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type podSpec struct {
+	NodeName   string      `json:"nodeName"`
+	Containers []container `json:"containers"`
+}
+
+type container struct {
+	Name  string          `json:"name"`
+	Image string          `json:"image"`
+	Ports []containerPort `json:"ports"`
+}
+
+type containerPort struct {
+	Name          string `json:"name"`
+	ContainerPort int32  `json:"containerPort"`
+	HostPort      int32  `json:"hostPort"`
+	Protocol      string `json:"protocol"`
+}
+
+type podStatus struct {
+	Phase             string            `json:"phase"`
+	PodIP             string            `json:"podIP"`
+	HostIP            string            `json:"hostIP"`
+	ContainerStatuses []containerStatus `json:"containerStatuses"`
+}
+
+type containerStatus struct {
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	ImageID     string `json:"imageID"`
+	ContainerID string `json:"containerID"`
+	Ready       bool   `json:"ready"`
+}
+
+const podRunning = "Running"
@@ -86,3 +86,13 @@ func AddCommand(metricAnnotations data.InterfaceMap, command string) {
 func AddDockerContainerName(metricAnnotations data.InterfaceMap, dockerContainerName string) {
 	metricAnnotations[data.DockerContainerName] = dockerContainerName
 }
+
+// AddPodName adds Kubernetes pod name to metricAnnotations
+func AddPodName(metricAnnotations data.InterfaceMap, podName string) {
+	metricAnnotations[data.PodName] = podName
+}
+
+// AddNamespace adds Kubernetes namespace to metricAnnotations
+func AddNamespace(metricAnnotations data.InterfaceMap, namespace string) {
+	metricAnnotations[data.Namespace] = namespace
+}
@@ -0,0 +1,40 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package podman
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/docker"
+)
+
+const (
+	rootfulSocket = "/run/podman/podman.sock"
+	// rootless Podman socket, relative to the user runtime directory
+	rootlessSocket = "podman/podman.sock"
+)
+
+// Discoverer returns a Podman container discoverer from the provided configuration.
+// Containers are fetched through the Docker compatible REST API of the Podman service, so the
+// discovered variables are the same as for Docker.
+func Discoverer(d discovery.Container) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	if d.Socket == "" {
+		d.Socket = defaultSocket()
+	}
+	return docker.Discoverer(d)
+}
+
+// defaultSocket returns the rootless socket of the user running the agent, if available, otherwise
+// the system wide rootful socket.
+func defaultSocket() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		socket := filepath.Join(runtimeDir, rootlessSocket)
+		if _, err := os.Stat(socket); err == nil {
+			return socket
+		}
+	}
+	return rootfulSocket
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package podman
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+)
+
+// response of the Docker compatible API of the Podman service
+const containersResponse = `[
+  {
+    "Id": "abcdef",
+    "Names": ["/redis"],
+    "Image": "docker.io/library/redis:6",
+    "ImageID": "sha256:1234",
+    "Command": "redis-server",
+    "Labels": {"env": "production"},
+    "Ports": [{"IP": "127.0.0.1", "PrivatePort": 6379, "PublicPort": 16379, "Type": "tcp"}],
+    "NetworkSettings": {"Networks": {"podman": {"IPAddress": "10.88.0.5"}}}
+  },
+  {
+    "Id": "012345",
+    "Names": ["/nginx"],
+    "Image": "docker.io/library/nginx",
+    "Labels": {},
+    "Ports": []
+  }
+]`
+
+func fakePodman(t *testing.T) string {
+	socket := filepath.Join(t.TempDir(), "podman.sock")
+	lis, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "1.40")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			_, _ = w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(containersResponse))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})}
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return socket
+}
+
+func TestDiscoverer(t *testing.T) {
+	socket := fakePodman(t)
+
+	// GIVEN a podman discoverer matching by label
+	fetch, err := Discoverer(discovery.Container{
+		Socket: socket,
+		Match:  map[string]string{"label.env": "production"},
+	})
+	require.NoError(t, err)
+
+	// WHEN the discovery is fetched
+	matches, err := fetch()
+	require.NoError(t, err)
+
+	// THEN the same variables as for Docker are discovered
+	require.Len(t, matches, 1)
+	vars := matches[0].Variables
+	assert.Equal(t, "redis", vars["discovery.name"])
+	assert.Equal(t, "docker.io/library/redis:6", vars["discovery.image"])
+	assert.Equal(t, "127.0.0.1", vars["discovery.ip"])
+	assert.Equal(t, "16379", vars["discovery.port"])
+	assert.Equal(t, "6379", vars["discovery.private.port"])
+	assert.Equal(t, "10.88.0.5", vars["discovery.private.ip"])
+	assert.Equal(t, "abcdef", vars["discovery.containerId"])
+
+	assert.Equal(t, "redis", matches[0].MetricAnnotations["containerName"])
+	require.Len(t, matches[0].EntityRewrites, 1)
+	assert.Equal(t, "container:${containerId}", matches[0].EntityRewrites[0].ReplaceField)
+}
@@ -0,0 +1,20 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+)
+
+// Process discovery parameters
+type Process struct {
+	Match map[string]string `yaml:"match"`
+}
+
+func (p *Process) Validate() error {
+	if len(p.Match) == 0 {
+		return errors.New("missing 'match' entries")
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package process
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+var NotSupportedErr = errors.New("process discovery is only supported on Linux")
+
+// process holds the discovery attributes of a running process.
+type process struct {
+	pid       int
+	name      string
+	cmdline   string
+	user      string
+	listeners []listener
+	// inodes of all the listening sockets of the process
+	inodes []string
+}
+
+// Discoverer returns a discoverer of the local processes, correlated with the sockets they listen on.
+// The fetching process will return an array of map values for each discovered process, with the
+// keys discovery.pid, discovery.port and discovery.ip among others.
+func Discoverer(p discovery.Process) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	if runtime.GOOS != "linux" {
+		return nil, NotSupportedErr
+	}
+	matcher, err := discovery.NewMatcher(p.Match)
+	if err != nil {
+		return nil, err
+	}
+	return func() ([]discovery.Discovery, error) {
+		return fetch(helpers.HostProc(), &matcher)
+	}, nil
+}
+
+func fetch(procRoot string, matcher *discovery.FieldsMatcher) ([]discovery.Discovery, error) {
+	procs, err := processes(procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []discovery.Discovery
+	reported := map[string]bool{} // listening socket inodes of the matched processes
+	for _, p := range procs {
+		// workers of pre-forked servers (e.g. nginx, apache) share the listening sockets of their
+		// parent, which has a lower PID, so they would be discovered as duplicates of it
+		if sharesSocket(p, reported) {
+			continue
+		}
+		if labels, ok := match(p, matcher); ok {
+			for _, inode := range p.inodes {
+				reported[inode] = true
+			}
+			matches = append(matches, discovery.Discovery{
+				Variables:         discovery.LabelsToMap(data.DiscoveryPrefix, labels),
+				MetricAnnotations: data.InterfaceMap{},
+			})
+		}
+	}
+	return matches, nil
+}
+
+// sharesSocket returns true if any of the process listening sockets is in the provided set.
+func sharesSocket(p process, inodes map[string]bool) bool {
+	for _, inode := range p.inodes {
+		if inodes[inode] {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the labels of the process when it matches all the criteria. As a process may listen
+// on multiple ports, each of them is checked until one matches, which is exposed as discovery.port.
+func match(p process, matcher *discovery.FieldsMatcher) (map[string]string, bool) {
+	labels := map[string]string{
+		data.Pid:     strconv.Itoa(p.pid),
+		data.Name:    p.name,
+		data.Cmdline: p.cmdline,
+		data.User:    p.user,
+	}
+	addPorts(p.listeners, labels)
+
+	if len(p.listeners) == 0 {
+		return labels, matcher.All(labels)
+	}
+	for _, l := range p.listeners {
+		labels[data.Port] = strconv.Itoa(l.port)
+		labels[data.IP] = l.ip
+		if matcher.All(labels) {
+			return labels, true
+		}
+	}
+	return nil, false
+}
+
+func addPorts(listeners []listener, labels map[string]string) {
+	protocols := map[string]bool{}
+	for index, l := range listeners {
+		portStr := strconv.Itoa(l.port)
+		labels[data.Ports+"."+strconv.Itoa(index)] = portStr // discovery.ports.0 = <...>
+		if !protocols[l.protocol] {
+			labels[data.Ports+"."+l.protocol] = portStr // discovery.ports.tcp = <...>
+			protocols[l.protocol] = true
+		}
+	}
+}
+
+// processes returns the user space processes, with their listening sockets sorted by port.
+func processes(procRoot string) ([]process, error) {
+	entries, err := ioutil.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	sockets, err := ListeningSockets(procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var procs []process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		// processes may finish while being read, so they're just ignored on error
+		p, err := readProcess(procRoot, pid, sockets)
+		if err != nil {
+			continue
+		}
+		procs = append(procs, p)
+	}
+	sort.Slice(procs, func(i, j int) bool {
+		return procs[i].pid < procs[j].pid
+	})
+	return procs, nil
+}
+
+func readProcess(procRoot string, pid int, sockets Sockets) (process, error) {
+	p := process{pid: pid}
+	pidDir := filepath.Join(procRoot, strconv.Itoa(pid))
+
+	cmdline, err := ioutil.ReadFile(filepath.Join(pidDir, "cmdline"))
+	if err != nil {
+		return p, err
+	}
+	p.cmdline = strings.TrimSpace(strings.Replace(string(cmdline), "\x00", " ", -1))
+	if p.cmdline == "" {
+		return p, errors.New("kernel thread")
+	}
+
+	comm, err := ioutil.ReadFile(filepath.Join(pidDir, "comm"))
+	if err != nil {
+		return p, err
+	}
+	p.name = strings.TrimSpace(string(comm))
+
+	p.user, err = processUser(pidDir)
+	if err != nil {
+		return p, err
+	}
+
+	p.inodes = listeningInodes(pidDir, sockets)
+	p.listeners = listenersOf(p.inodes, sockets)
+	return p, nil
+}
+
+// processUser returns the name of the real user of the process, or its ID when it can't be resolved.
+func processUser(pidDir string) (string, error) {
+	status, err := ioutil.ReadFile(filepath.Join(pidDir, "status"))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if !strings.HasPrefix(line, "Uid:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			break
+		}
+		if u, err := user.LookupId(fields[1]); err == nil {
+			return u.Username, nil
+		}
+		return fields[1], nil
+	}
+	return "", errors.New("missing process Uid")
+}
+
+// processListeners returns the listening sockets whose inode is referenced by the process file descriptors.
+func processListeners(pidDir string, sockets Sockets) []listener {
+	return listenersOf(listeningInodes(pidDir, sockets), sockets)
+}
+
+// listeningInodes returns the inodes of the listening sockets referenced by the process file descriptors.
+func listeningInodes(pidDir string, sockets Sockets) []string {
+	fdDir := filepath.Join(pidDir, "fd")
+	fds, err := ioutil.ReadDir(fdDir)
+	if err != nil {
+		return nil
+	}
+	var inodes []string
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+		if err != nil || !strings.HasPrefix(target, "socket:[") {
+			continue
+		}
+		inode := strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]")
+		if _, ok := sockets[inode]; ok {
+			inodes = append(inodes, inode)
+		}
+	}
+	return inodes
+}
+
+func listenersOf(inodes []string, sockets Sockets) []listener {
+	listeners := make([]listener, 0, len(inodes))
+	for _, inode := range inodes {
+		listeners = append(listeners, sockets[inode])
+	}
+	return uniqueListeners(listeners)
+}
+
+// uniqueListeners sorts listeners by port and protocol, removing the same port being listened on
+// multiple addresses. IPv4 addresses are preferred.
+func uniqueListeners(listeners []listener) []listener {
+	sort.Slice(listeners, func(i, j int) bool {
+		if listeners[i].port != listeners[j].port {
+			return listeners[i].port < listeners[j].port
+		}
+		if listeners[i].protocol != listeners[j].protocol {
+			return listeners[i].protocol < listeners[j].protocol
+		}
+		return !listeners[i].ipv6 && listeners[j].ipv6
+	})
+	var unique []listener
+	for _, l := range listeners {
+		if len(unique) > 0 {
+			last := unique[len(unique)-1]
+			if last.port == l.port && last.protocol == l.protocol {
+				continue
+			}
+		}
+		unique = append(unique, l)
+	}
+	return unique
+}
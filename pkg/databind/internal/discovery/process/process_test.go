@@ -0,0 +1,196 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+)
+
+const (
+	netTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:18EB 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:18EC 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 2001 1 0000000000000000 100 0 0 10 0
+   2: 0100007F:18EB 0100007F:C350 01 00000000:00000000 00:00000000 00000000   999        0 1003 1 0000000000000000 100 0 0 10 0
+   3: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 3001 1 0000000000000000 100 0 0 10 0
+`
+	netTCP6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:18EB 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 1002 1 0000000000000000 100 0 0 10 0
+`
+	netUDP = `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  1: 0A00000A:0202 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 3002 2 0000000000000000 0
+`
+)
+
+type fakeProcess struct {
+	pid     int
+	comm    string
+	cmdline string
+	uid     string
+	sockets []string
+}
+
+func fakeProc(t *testing.T, procs ...fakeProcess) string {
+	root := t.TempDir()
+	netDir := filepath.Join(root, "net")
+	require.NoError(t, os.MkdirAll(netDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(netDir, "tcp"), []byte(netTCP), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(netDir, "tcp6"), []byte(netTCP6), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(netDir, "udp"), []byte(netUDP), 0644))
+
+	for _, p := range procs {
+		pidDir := filepath.Join(root, strconv.Itoa(p.pid))
+		fdDir := filepath.Join(pidDir, "fd")
+		require.NoError(t, os.MkdirAll(fdDir, 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pidDir, "comm"), []byte(p.comm+"\n"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pidDir, "cmdline"), []byte(p.cmdline), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pidDir, "status"),
+			[]byte("Name:\t"+p.comm+"\nUid:\t"+p.uid+"\t"+p.uid+"\t"+p.uid+"\t"+p.uid+"\n"), 0644))
+		for i, inode := range p.sockets {
+			require.NoError(t, os.Symlink("socket:["+inode+"]", filepath.Join(fdDir, strconv.Itoa(i+3))))
+		}
+		require.NoError(t, os.Symlink("/dev/null", filepath.Join(fdDir, "0")))
+	}
+	return root
+}
+
+var testProcesses = []fakeProcess{
+	{pid: 100, comm: "redis-server", cmdline: "/usr/bin/redis-server\x00*:6379\x00", uid: "9999999", sockets: []string{"1001", "1002"}},
+	{pid: 200, comm: "redis-server", cmdline: "/usr/bin/redis-server\x00127.0.0.1:6380\x00", uid: "9999999", sockets: []string{"2001"}},
+	{pid: 300, comm: "nginx", cmdline: "nginx: master process\x00", uid: "0", sockets: []string{"3001", "3002"}},
+	{pid: 400, comm: "kworker/0:1", cmdline: "", uid: "0"},
+}
+
+func TestFetch_ByName(t *testing.T) {
+	root := fakeProc(t, testProcesses...)
+	matcher, err := discovery.NewMatcher(map[string]string{"name": "redis-server"})
+	require.NoError(t, err)
+
+	matches, err := fetch(root, &matcher)
+	require.NoError(t, err)
+
+	require.Len(t, matches, 2)
+	first := matches[0].Variables
+	assert.Equal(t, "100", first["discovery.pid"])
+	assert.Equal(t, "6379", first["discovery.port"])
+	assert.Equal(t, "127.0.0.1", first["discovery.ip"])
+	assert.Equal(t, "/usr/bin/redis-server *:6379", first["discovery.cmdline"])
+	assert.Equal(t, "9999999", first["discovery.user"])
+	assert.Equal(t, "redis-server", first["discovery.name"])
+	assert.Equal(t, "6379", first["discovery.ports.0"])
+	assert.Equal(t, "6379", first["discovery.ports.tcp"])
+	assert.NotContains(t, first, "discovery.ports.1", "same port on IPv4 and IPv6 should be reported once")
+
+	second := matches[1].Variables
+	assert.Equal(t, "200", second["discovery.pid"])
+	assert.Equal(t, "6380", second["discovery.port"])
+}
+
+func TestFetch_ByPort(t *testing.T) {
+	root := fakeProc(t, testProcesses...)
+	matcher, err := discovery.NewMatcher(map[string]string{"port": "514"})
+	require.NoError(t, err)
+
+	matches, err := fetch(root, &matcher)
+	require.NoError(t, err)
+
+	// THEN the matching port is exposed as discovery.port, although it's not the lowest one
+	require.Len(t, matches, 1)
+	vars := matches[0].Variables
+	assert.Equal(t, "300", vars["discovery.pid"])
+	assert.Equal(t, "514", vars["discovery.port"])
+	assert.Equal(t, "10.0.0.10", vars["discovery.ip"])
+	assert.Equal(t, "514", vars["discovery.ports.udp"])
+	assert.Equal(t, "8080", vars["discovery.ports.tcp"])
+	assert.Equal(t, "root", vars["discovery.user"])
+}
+
+func TestFetch_ByCmdlineAndUser(t *testing.T) {
+	root := fakeProc(t, testProcesses...)
+	matcher, err := discovery.NewMatcher(map[string]string{
+		"cmdline": "/127\\.0\\.0\\.1:/",
+		"user":    "9999999",
+	})
+	require.NoError(t, err)
+
+	matches, err := fetch(root, &matcher)
+	require.NoError(t, err)
+
+	require.Len(t, matches, 1)
+	assert.Equal(t, "200", matches[0].Variables["discovery.pid"])
+}
+
+func TestFetch_KernelThreadsIgnored(t *testing.T) {
+	root := fakeProc(t, testProcesses...)
+	matcher, err := discovery.NewMatcher(map[string]string{"name": "/^kworker/"})
+	require.NoError(t, err)
+
+	matches, err := fetch(root, &matcher)
+	require.NoError(t, err)
+
+	assert.Empty(t, matches)
+}
+
+func TestFetch_PreforkedWorkersIgnored(t *testing.T) {
+	// GIVEN an nginx master process and its workers, sharing the listening sockets
+	root := fakeProc(t, append(testProcesses,
+		fakeProcess{pid: 301, comm: "nginx", cmdline: "nginx: worker process\x00", uid: "0", sockets: []string{"3001", "3002"}},
+		fakeProcess{pid: 302, comm: "nginx", cmdline: "nginx: worker process\x00", uid: "0", sockets: []string{"3002"}},
+	)...)
+	matcher, err := discovery.NewMatcher(map[string]string{"name": "nginx"})
+	require.NoError(t, err)
+
+	// WHEN they are discovered
+	matches, err := fetch(root, &matcher)
+	require.NoError(t, err)
+
+	// THEN only the master process is reported
+	require.Len(t, matches, 1)
+	assert.Equal(t, "300", matches[0].Variables["discovery.pid"])
+}
+
+func TestFetch_WorkersOfUnmatchedProcessesReported(t *testing.T) {
+	// GIVEN workers sharing the sockets of a master process that doesn't match
+	root := fakeProc(t, append(testProcesses,
+		fakeProcess{pid: 301, comm: "nginx", cmdline: "nginx: worker process\x00", uid: "0", sockets: []string{"3001"}},
+		fakeProcess{pid: 302, comm: "nginx", cmdline: "nginx: worker process\x00", uid: "0", sockets: []string{"3001"}},
+	)...)
+	matcher, err := discovery.NewMatcher(map[string]string{"cmdline": "/worker/"})
+	require.NoError(t, err)
+
+	// WHEN they are discovered
+	matches, err := fetch(root, &matcher)
+	require.NoError(t, err)
+
+	// THEN a single worker is reported
+	require.Len(t, matches, 1)
+	assert.Equal(t, "301", matches[0].Variables["discovery.pid"])
+}
+
+func TestParseAddress(t *testing.T) {
+	ip, port, err := parseAddress("0100007F:1F90")
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", ip.String())
+	assert.Equal(t, 8080, port)
+
+	ip, port, err = parseAddress("0000000000000000FFFF00000A00000A:0050")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.10", ip.String())
+	assert.Equal(t, 80, port)
+
+	ip, _, err = parseAddress("00000000000000000000000001000000:0050")
+	require.NoError(t, err)
+	assert.Equal(t, "::1", ip.String())
+
+	_, _, err = parseAddress("wrong")
+	assert.Error(t, err)
+}
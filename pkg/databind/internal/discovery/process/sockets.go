@@ -0,0 +1,150 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package process
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// socket states of the /proc/net tables accounted as listening
+const (
+	tcpListen      = "0A"
+	udpUnconnected = "07"
+)
+
+// listener is a listening socket.
+type listener struct {
+	protocol string
+	ip       string
+	port     int
+	ipv6     bool
+}
+
+// socketTable is a /proc/net table holding sockets of a given protocol.
+type socketTable struct {
+	file        string
+	protocol    string
+	listenState string
+	ipv6        bool
+}
+
+var socketTables = []socketTable{
+	{file: "tcp", protocol: "tcp", listenState: tcpListen},
+	{file: "tcp6", protocol: "tcp", listenState: tcpListen, ipv6: true},
+	{file: "udp", protocol: "udp", listenState: udpUnconnected},
+	{file: "udp6", protocol: "udp", listenState: udpUnconnected, ipv6: true},
+}
+
+// ListeningSockets returns the listening sockets of the host, indexed by inode.
+func ListeningSockets(procRoot string) (Sockets, error) {
+	sockets := Sockets{}
+	for _, table := range socketTables {
+		err := readSocketTable(filepath.Join(procRoot, "net", table.file), table, sockets)
+		// IPv6 may be disabled in the host
+		if os.IsNotExist(err) && table.ipv6 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sockets, nil
+}
+
+func readSocketTable(path string, table socketTable, sockets Sockets) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	header := true
+	for scanner.Scan() {
+		if header {
+			header = false
+			continue
+		}
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			return fmt.Errorf("'%s' file does not have the expected structure. Line fields: %s", path, fields)
+		}
+		if fields[3] != table.listenState || fields[9] == "0" {
+			continue
+		}
+		ip, port, err := parseAddress(fields[1])
+		if err != nil {
+			return fmt.Errorf("can't parse address of '%s': %s", path, err)
+		}
+		sockets[fields[9]] = listener{
+			protocol: table.protocol,
+			ip:       connectableIP(ip),
+			port:     port,
+			ipv6:     table.ipv6,
+		}
+	}
+	return scanner.Err()
+}
+
+// parseAddress parses an "address:port" entry of the /proc/net tables, where the address is
+// written as hexadecimal 32 bit words in host (little endian) byte order.
+func parseAddress(address string) (net.IP, int, error) {
+	parts := strings.Split(address, ":")
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("unexpected address %q", address)
+	}
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return nil, 0, err
+	}
+	raw, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(raw) != net.IPv4len && len(raw) != net.IPv6len {
+		return nil, 0, fmt.Errorf("unexpected address length %q", address)
+	}
+	ip := make(net.IP, len(raw))
+	for w := 0; w < len(raw); w += 4 {
+		for b := 0; b < 4; b++ {
+			ip[w+b] = raw[w+3-b]
+		}
+	}
+	return ip, int(port), nil
+}
+
+// connectableIP returns the loopback address for sockets listening on all the interfaces.
+func connectableIP(ip net.IP) string {
+	if ip.IsUnspecified() {
+		if ip.To4() != nil {
+			return net.IPv4(127, 0, 0, 1).String()
+		}
+		return net.IPv6loopback.String()
+	}
+	return ip.String()
+}
+
+// Sockets are the listening sockets of the host, indexed by inode.
+type Sockets map[string]listener
+
+// AddListeners adds the ports the process listens on to the discovery labels, with the lowest port
+// and its address as discovery.port and discovery.ip.
+func (s Sockets) AddListeners(procRoot string, pid int, labels map[string]string) {
+	listeners := processListeners(filepath.Join(procRoot, strconv.Itoa(pid)), s)
+	addPorts(listeners, labels)
+	if len(listeners) > 0 {
+		labels[data.Port] = strconv.Itoa(listeners[0].port)
+		labels[data.IP] = listeners[0].ip
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package discovery
+
+import (
+	"errors"
+)
+
+// Systemd discovery parameters
+type Systemd struct {
+	Match map[string]string `yaml:"match"`
+	// Units unit name glob patterns to look for. Default: *.service
+	Units []string `yaml:"units"`
+}
+
+func (s *Systemd) Validate() error {
+	if len(s.Match) == 0 {
+		return errors.New("missing 'match' entries")
+	}
+	return nil
+}
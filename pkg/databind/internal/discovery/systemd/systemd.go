@@ -0,0 +1,244 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	godbus "github.com/godbus/dbus/v5"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/process"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+)
+
+const (
+	defaultUnitPattern = "*.service"
+	serviceUnitType    = "Service"
+	mainPIDProperty    = "MainPID"
+	envFilesProperty   = "EnvironmentFiles"
+
+	systemBusDefaultPath       = "/run/dbus/system_bus_socket"
+	dbusSystemBusAddressEnvVar = "DBUS_SYSTEM_BUS_ADDRESS"
+)
+
+var NotSupportedErr = errors.New("systemd discovery is only supported on Linux")
+
+// dbusConn is the subset of the systemd D-Bus API used for discovery.
+type dbusConn interface {
+	Close()
+	ListUnitsByPatterns(states []string, patterns []string) ([]dbus.UnitStatus, error)
+	GetUnitProperties(unit string) (map[string]interface{}, error)
+	GetUnitTypeProperties(unit string, unitType string) (map[string]interface{}, error)
+}
+
+// Discoverer returns a systemd units discoverer from the provided configuration.
+// The fetching process will return an array of map values for each discovered unit, with the
+// keys discovery.name, discovery.instance and discovery.pid among others.
+func Discoverer(s discovery.Systemd) (fetchDiscoveries func() (discoveries []discovery.Discovery, err error), err error) {
+	if runtime.GOOS != "linux" {
+		return nil, NotSupportedErr
+	}
+	if len(s.Units) == 0 {
+		s.Units = []string{defaultUnitPattern}
+	}
+	matcher, err := discovery.NewMatcher(s.Match)
+	if err != nil {
+		return nil, err
+	}
+	properties := matchedProperties(s.Match)
+	return func() ([]discovery.Discovery, error) {
+		conn, err := connect()
+		if err != nil {
+			return nil, fmt.Errorf("can't connect to systemd: %s", err)
+		}
+		defer conn.Close()
+		return fetch(conn, helpers.HostProc(), s.Units, properties, &matcher)
+	}, nil
+}
+
+// connect opens a connection to the systemd D-Bus API through the system bus. The bus address is
+// passed explicitly, instead of through the environment, which is inherited by the integrations.
+func connect() (dbusConn, error) {
+	address := systemBusAddress()
+	return dbus.NewConnection(func() (*godbus.Conn, error) {
+		conn, err := godbus.Dial(address)
+		if err != nil {
+			return nil, err
+		}
+		// EXTERNAL authentication with the uid, to avoid a user name lookup
+		if err = conn.Auth([]godbus.Auth{godbus.AuthExternal(strconv.Itoa(os.Getuid()))}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err = conn.Hello(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	})
+}
+
+// systemBusAddress returns the D-Bus system bus address from the environment, defaulting to the
+// host system bus socket, which is mounted under HOST_VAR when the agent runs within a container.
+func systemBusAddress() string {
+	if address := os.Getenv(dbusSystemBusAddressEnvVar); address != "" {
+		return address
+	}
+	return "unix:path=" + helpers.HostVar(systemBusDefaultPath)
+}
+
+// matchedProperties returns the unit properties referenced by the match entries.
+func matchedProperties(match map[string]string) []string {
+	var properties []string
+	for field := range match {
+		if strings.HasPrefix(field, data.PropertyInfix) {
+			properties = append(properties, strings.TrimPrefix(field, data.PropertyInfix))
+		}
+	}
+	return properties
+}
+
+func fetch(conn dbusConn, procRoot string, patterns []string, properties []string, matcher *discovery.FieldsMatcher) ([]discovery.Discovery, error) {
+	units, err := conn.ListUnitsByPatterns(nil, patterns)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].Name < units[j].Name
+	})
+
+	// sockets are lazily loaded, only when any unit is running
+	var sockets process.Sockets
+
+	var matches []discovery.Discovery
+	for _, unit := range units {
+		labels := map[string]string{
+			data.Name:      unit.Name,
+			data.State:     unit.ActiveState,
+			data.SubState:  unit.SubState,
+			data.LoadState: unit.LoadState,
+		}
+		template, instance := splitName(unit.Name)
+		labels[data.Template] = template
+		labels[data.Instance] = instance
+
+		// units may disappear while being queried, so errors are ignored
+		serviceProps, err := conn.GetUnitTypeProperties(unit.Name, unitType(unit.Name))
+		if err != nil {
+			serviceProps = map[string]interface{}{}
+		}
+		var unitProps map[string]interface{}
+		for _, property := range properties {
+			value, ok := serviceProps[property]
+			if !ok {
+				if unitProps == nil {
+					if unitProps, err = conn.GetUnitProperties(unit.Name); err != nil {
+						unitProps = map[string]interface{}{}
+					}
+				}
+				value, ok = unitProps[property]
+			}
+			if ok {
+				labels[data.PropertyInfix+property] = propertyString(value)
+			}
+		}
+
+		addEnvironmentFiles(serviceProps[envFilesProperty], labels)
+
+		pid := mainPID(serviceProps[mainPIDProperty])
+		if pid > 0 {
+			labels[data.Pid] = strconv.Itoa(pid)
+			if sockets == nil {
+				if sockets, err = process.ListeningSockets(procRoot); err != nil {
+					sockets = process.Sockets{}
+				}
+			}
+			sockets.AddListeners(procRoot, pid, labels)
+		}
+
+		// only units matching all the criteria will be added
+		if matcher.All(labels) {
+			matches = append(matches, discovery.Discovery{
+				Variables:         discovery.LabelsToMap(data.DiscoveryPrefix, labels),
+				MetricAnnotations: data.InterfaceMap{},
+			})
+		}
+	}
+	return matches, nil
+}
+
+// splitName returns the template and instance name of template units (e.g. postgresql@13-main.service
+// returns postgresql and 13-main). Plain units return the name without suffix as template.
+func splitName(unitName string) (template, instance string) {
+	name := unitName
+	if idx := strings.LastIndex(name, "."); idx > 0 {
+		name = name[:idx]
+	}
+	if idx := strings.Index(name, "@"); idx >= 0 {
+		return name[:idx], name[idx+1:]
+	}
+	return name, ""
+}
+
+// unitType returns the D-Bus interface name suffix for the unit type (e.g. Service, Socket).
+func unitType(unitName string) string {
+	idx := strings.LastIndex(unitName, ".")
+	if idx < 0 || idx == len(unitName)-1 {
+		return serviceUnitType
+	}
+	suffix := unitName[idx+1:]
+	return strings.ToUpper(suffix[:1]) + suffix[1:]
+}
+
+func mainPID(value interface{}) int {
+	switch pid := value.(type) {
+	case uint32:
+		return int(pid)
+	case int:
+		return pid
+	}
+	return 0
+}
+
+// addEnvironmentFiles adds the service environment files, provided as a D-Bus array of
+// (path, ignore errors) structs.
+func addEnvironmentFiles(value interface{}, labels map[string]string) {
+	entries, ok := value.([][]interface{})
+	if !ok {
+		return
+	}
+	var files []string
+	for _, entry := range entries {
+		if len(entry) == 0 {
+			continue
+		}
+		if path, ok := entry[0].(string); ok {
+			labels[data.EnvironmentFiles+"."+strconv.Itoa(len(files))] = path
+			files = append(files, path)
+		}
+	}
+	if len(files) > 0 {
+		labels[data.EnvironmentFiles] = strings.Join(files, " ")
+	}
+}
+
+func propertyString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package systemd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+)
+
+const netTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1538 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1539 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 2001 1 0000000000000000 100 0 0 10 0
+`
+
+const netUDP = `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+`
+
+type fakeConn struct {
+	units     []dbus.UnitStatus
+	typeProps map[string]map[string]interface{}
+	unitProps map[string]map[string]interface{}
+}
+
+func (f *fakeConn) Close() {}
+
+func (f *fakeConn) ListUnitsByPatterns(_ []string, _ []string) ([]dbus.UnitStatus, error) {
+	return f.units, nil
+}
+
+func (f *fakeConn) GetUnitProperties(unit string) (map[string]interface{}, error) {
+	if props, ok := f.unitProps[unit]; ok {
+		return props, nil
+	}
+	return nil, errors.New("unit not found")
+}
+
+func (f *fakeConn) GetUnitTypeProperties(unit string, unitType string) (map[string]interface{}, error) {
+	if props, ok := f.typeProps[unit]; ok && unitType == "Service" {
+		return props, nil
+	}
+	return nil, errors.New("unit not found")
+}
+
+func fakeProc(t *testing.T, sockets map[string]string) string {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "net"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "net", "tcp"), []byte(netTCP), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "net", "udp"), []byte(netUDP), 0644))
+	for pid, inode := range sockets {
+		fdDir := filepath.Join(root, pid, "fd")
+		require.NoError(t, os.MkdirAll(fdDir, 0755))
+		require.NoError(t, os.Symlink("socket:["+inode+"]", filepath.Join(fdDir, "3")))
+	}
+	return root
+}
+
+func testConn() *fakeConn {
+	return &fakeConn{
+		units: []dbus.UnitStatus{
+			{Name: "postgresql@13-main.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			{Name: "postgresql@12-old.service", LoadState: "loaded", ActiveState: "inactive", SubState: "dead"},
+			{Name: "postgresql.service", LoadState: "loaded", ActiveState: "active", SubState: "exited"},
+			{Name: "sshd.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		},
+		typeProps: map[string]map[string]interface{}{
+			"postgresql@13-main.service": {
+				"MainPID": uint32(100),
+				"EnvironmentFiles": [][]interface{}{
+					{"/etc/default/postgresql", false},
+					{"/etc/postgresql/13/main/environment", true},
+				},
+			},
+			"postgresql@12-old.service": {"MainPID": uint32(0)},
+			"postgresql.service":        {"MainPID": uint32(0)},
+			"sshd.service":              {"MainPID": uint32(200), "User": "root"},
+		},
+		unitProps: map[string]map[string]interface{}{
+			"postgresql@13-main.service": {"Description": "PostgreSQL Cluster 13-main"},
+			"postgresql@12-old.service":  {"Description": "PostgreSQL Cluster 12-old"},
+		},
+	}
+}
+
+func TestFetch_TemplateInstances(t *testing.T) {
+	// GIVEN a host with two instances of a template unit, one of them running
+	root := fakeProc(t, map[string]string{"100": "1001", "200": "2001"})
+	matcher, err := discovery.NewMatcher(map[string]string{"template": "postgresql", "subState": "running"})
+	require.NoError(t, err)
+
+	// WHEN the units are discovered
+	matches, err := fetch(testConn(), root, []string{"*.service"}, nil, &matcher)
+	require.NoError(t, err)
+
+	// THEN only the running instance is returned, with its process and sockets information
+	require.Len(t, matches, 1)
+	vars := matches[0].Variables
+	assert.Equal(t, "postgresql@13-main.service", vars["discovery.name"])
+	assert.Equal(t, "postgresql", vars["discovery.template"])
+	assert.Equal(t, "13-main", vars["discovery.instance"])
+	assert.Equal(t, "active", vars["discovery.state"])
+	assert.Equal(t, "running", vars["discovery.subState"])
+	assert.Equal(t, "loaded", vars["discovery.loadState"])
+	assert.Equal(t, "100", vars["discovery.pid"])
+	assert.Equal(t, "5432", vars["discovery.port"])
+	assert.Equal(t, "127.0.0.1", vars["discovery.ip"])
+	assert.Equal(t, "5432", vars["discovery.ports.tcp"])
+	assert.Equal(t, "/etc/default/postgresql /etc/postgresql/13/main/environment", vars["discovery.environmentFiles"])
+	assert.Equal(t, "/etc/default/postgresql", vars["discovery.environmentFiles.0"])
+	assert.Equal(t, "/etc/postgresql/13/main/environment", vars["discovery.environmentFiles.1"])
+	assert.Empty(t, matches[0].EntityRewrites)
+}
+
+func TestFetch_ByProperty(t *testing.T) {
+	// GIVEN a matcher on a unit property, and on a service property
+	root := fakeProc(t, map[string]string{"100": "1001", "200": "2001"})
+	match := map[string]string{"property.Description": "/^PostgreSQL Cluster/"}
+	matcher, err := discovery.NewMatcher(match)
+	require.NoError(t, err)
+
+	// WHEN the units are discovered
+	matches, err := fetch(testConn(), root, []string{"*.service"}, matchedProperties(match), &matcher)
+	require.NoError(t, err)
+
+	// THEN the units having the property matching are returned, with the property as variable
+	require.Len(t, matches, 2)
+	assert.Equal(t, "12-old", matches[0].Variables["discovery.instance"])
+	assert.Equal(t, "PostgreSQL Cluster 12-old", matches[0].Variables["discovery.property.Description"])
+	assert.NotContains(t, matches[0].Variables, "discovery.pid")
+	assert.Equal(t, "13-main", matches[1].Variables["discovery.instance"])
+
+	match = map[string]string{"property.User": "root"}
+	matcher, err = discovery.NewMatcher(match)
+	require.NoError(t, err)
+	matches, err = fetch(testConn(), root, []string{"*.service"}, matchedProperties(match), &matcher)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "sshd.service", matches[0].Variables["discovery.name"])
+	assert.Equal(t, "sshd", matches[0].Variables["discovery.template"])
+	assert.Equal(t, "", matches[0].Variables["discovery.instance"])
+	assert.Equal(t, "5433", matches[0].Variables["discovery.port"])
+}
+
+func TestSplitName(t *testing.T) {
+	cases := []struct {
+		unit     string
+		template string
+		instance string
+	}{
+		{"sshd.service", "sshd", ""},
+		{"getty@tty1.service", "getty", "tty1"},
+		{"openvpn@.service", "openvpn", ""},
+		{"foo@bar.baz.service", "foo", "bar.baz"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.unit, func(t *testing.T) {
+			template, instance := splitName(tc.unit)
+			assert.Equal(t, tc.template, template)
+			assert.Equal(t, tc.instance, instance)
+		})
+	}
+}
+
+func TestUnitType(t *testing.T) {
+	assert.Equal(t, "Service", unitType("sshd.service"))
+	assert.Equal(t, "Socket", unitType("sshd.socket"))
+	assert.Equal(t, "Service", unitType("sshd"))
+}
+
+func TestSystemBusAddress(t *testing.T) {
+	t.Setenv("HOST_VAR", "/host/var")
+	t.Setenv(dbusSystemBusAddressEnvVar, "")
+
+	assert.Equal(t, "unix:path=/host/var/run/dbus/system_bus_socket", systemBusAddress())
+	// the environment inherited by the integrations is not modified
+	assert.Empty(t, os.Getenv(dbusSystemBusAddressEnvVar))
+
+	t.Setenv(dbusSystemBusAddressEnvVar, "unix:path=/custom/socket")
+	assert.Equal(t, "unix:path=/custom/socket", systemBusAddress())
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+// AWSSecretsManager defines the AWS Secrets Manager data source
+type AWSSecretsManager struct {
+	SecretID       string `yaml:"secret_id"`
+	VersionID      string `yaml:"version_id"`
+	VersionStage   string `yaml:"version_stage"`
+	CredentialFile string `yaml:"credential_file"`
+	ConfigFile     string `yaml:"config_file"`
+	Region         string `yaml:"region"`
+	Endpoint       string `yaml:"endpoint"`
+	DisableSSL     bool   `yaml:"disableSSL"`
+	Type           string `yaml:"type,omitempty"` // can be 'json', 'equal' and 'plain' (default)
+}
+
+type awsSecretsManagerGatherer struct {
+	cfg *AWSSecretsManager
+}
+
+// AWSSecretsManagerGatherer instantiates an AWS Secrets Manager variable gatherer from the given
+// configuration. The fetching process will return either a map containing access paths to the stored
+// JSON or ShortHand, or a string if the stored secret is just a string.
+func AWSSecretsManagerGatherer(sm *AWSSecretsManager) func() (interface{}, error) {
+	g := awsSecretsManagerGatherer{cfg: sm}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+func (g *awsSecretsManagerGatherer) get() (interface{}, error) {
+	secret := g.cfg
+	smSession := newAWSSession(secret.Region, secret.Endpoint, secret.DisableSSL, secret.CredentialFile, secret.ConfigFile)
+	client := secretsmanager.New(smSession)
+	params := &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secret.SecretID),
+	}
+	if secret.VersionID != "" {
+		params.VersionId = aws.String(secret.VersionID)
+	}
+	if secret.VersionStage != "" {
+		params.VersionStage = aws.String(secret.VersionStage)
+	}
+	res, err := client.GetSecretValue(params)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve secret from aws-secrets-manager: %s", err)
+	}
+	if res.SecretString != nil {
+		return handleDataType([]byte(*res.SecretString), secret.Type)
+	}
+	return handleDataType(res.SecretBinary, secret.Type)
+}
+
+// Validate checks if the AWS Secrets Manager configuration is correct
+func (s *AWSSecretsManager) Validate() error {
+	if s.SecretID == "" {
+		return errors.New("aws-secrets-manager must have a secret_id parameter in order to be set")
+	}
+	return validateDataType(s.Type)
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"encoding/json"
+	gohttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+func TestAWSSecretsManager(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	var request map[string]interface{}
+	ts := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_, _ = w.Write([]byte(`{"Name":"prod/db","SecretString":"{\"user\":\"admin\",\"password\":\"secret\"}"}`))
+	}))
+	defer ts.Close()
+
+	// GIVEN an aws-secrets-manager gatherer pointing to a local endpoint
+	g := AWSSecretsManagerGatherer(&AWSSecretsManager{
+		SecretID:     "prod/db",
+		VersionStage: "AWSCURRENT",
+		Region:       "us-east-1",
+		Endpoint:     ts.URL,
+		Type:         typeJson,
+	})
+
+	// WHEN the secret is fetched
+	value, err := g()
+	require.NoError(t, err)
+
+	// THEN the secret is requested and decoded as JSON
+	assert.Equal(t, "prod/db", request["SecretId"])
+	assert.Equal(t, "AWSCURRENT", request["VersionStage"])
+	assert.Equal(t, data.InterfaceMap{"user": "admin", "password": "secret"}, value)
+}
+
+func TestAWSSecretsManager_Validate(t *testing.T) {
+	assert.Error(t, (&AWSSecretsManager{}).Validate())
+	assert.Error(t, (&AWSSecretsManager{SecretID: "s", Type: "xml"}).Validate())
+	assert.NoError(t, (&AWSSecretsManager{SecretID: "s", Type: typeEqual}).Validate())
+}
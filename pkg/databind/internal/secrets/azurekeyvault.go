@@ -0,0 +1,196 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	azureKeyVaultAPIVersion       = "7.3"
+	azureKeyVaultResource         = "https://vault.azure.net"
+	defaultAzureAuthorityHost     = "https://login.microsoftonline.com"
+	defaultAzureIdentityEndpoint  = "http://169.254.169.254/metadata/identity/oauth2/token"
+	azureIdentityAPIVersion       = "2018-02-01"
+	azureJWTBearerAssertionType   = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	azureTenantIDEnvVar           = "AZURE_TENANT_ID"
+	azureClientIDEnvVar           = "AZURE_CLIENT_ID"
+	azureClientSecretEnvVar       = "AZURE_CLIENT_SECRET"
+	azureFederatedTokenFileEnvVar = "AZURE_FEDERATED_TOKEN_FILE"
+	azureAuthorityHostEnvVar      = "AZURE_AUTHORITY_HOST"
+)
+
+// AzureKeyVault defines the Azure Key Vault data source. Credentials are taken from the configuration or
+// the standard chain: service principal environment variables (AZURE_TENANT_ID, AZURE_CLIENT_ID and
+// AZURE_CLIENT_SECRET), workload identity (AZURE_FEDERATED_TOKEN_FILE) and managed identity.
+type AzureKeyVault struct {
+	VaultURL         string `yaml:"vault_url"`
+	Secret           string `yaml:"secret"`
+	Version          string `yaml:"version"` // default: latest
+	TenantID         string `yaml:"tenant_id"`
+	ClientID         string `yaml:"client_id"`
+	ClientSecret     string `yaml:"client_secret"`
+	AuthorityHost    string `yaml:"authority_host"`
+	IdentityEndpoint string `yaml:"identity_endpoint"`
+	Type             string `yaml:"type,omitempty"` // can be 'json', 'equal' and 'plain' (default)
+}
+
+type azureKeyVaultGatherer struct {
+	cfg    *AzureKeyVault
+	client *gohttp.Client
+	lock   sync.Mutex
+	tokens oauth2.TokenSource
+}
+
+// AzureKeyVaultGatherer instantiates an Azure Key Vault variable gatherer from the given configuration.
+// The fetching process will return either a map containing access paths to the stored JSON or ShortHand,
+// or a string if the stored secret is just a string.
+func AzureKeyVaultGatherer(kv *AzureKeyVault) func() (interface{}, error) {
+	g := azureKeyVaultGatherer{cfg: kv, client: &gohttp.Client{Timeout: cloudRequestTimeout}}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+func (g *azureKeyVaultGatherer) get() (interface{}, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.tokens == nil {
+		tokens, err := g.tokenSource()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load azure credentials: %s", err)
+		}
+		g.tokens = oauth2.ReuseTokenSource(nil, tokens)
+	}
+
+	secretURL := strings.TrimRight(g.cfg.VaultURL, "/") + "/secrets/" + url.PathEscape(g.cfg.Secret)
+	if g.cfg.Version != "" {
+		secretURL += "/" + url.PathEscape(g.cfg.Version)
+	}
+	body, err := bearerGet(g.client, g.tokens, secretURL+"?api-version="+azureKeyVaultAPIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve secret from azure-key-vault: %s", err)
+	}
+	var res struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("unable to decode azure-key-vault response: %s", err)
+	}
+	return handleDataType([]byte(res.Value), g.cfg.Type)
+}
+
+// tokenSource returns the token source of the first credentials found in the chain.
+func (g *azureKeyVaultGatherer) tokenSource() (oauth2.TokenSource, error) {
+	tenantID := valueOrEnv(g.cfg.TenantID, azureTenantIDEnvVar)
+	clientID := valueOrEnv(g.cfg.ClientID, azureClientIDEnvVar)
+	clientSecret := valueOrEnv(g.cfg.ClientSecret, azureClientSecretEnvVar)
+	authority := strings.TrimRight(valueOrEnv(g.cfg.AuthorityHost, azureAuthorityHostEnvVar), "/")
+	if authority == "" {
+		authority = defaultAzureAuthorityHost
+	}
+	tokenURL := authority + "/" + tenantID + "/oauth2/v2.0/token"
+
+	// service principal with client secret
+	if tenantID != "" && clientID != "" && clientSecret != "" {
+		return g.formTokenSource(tokenURL, func() (url.Values, error) {
+			return url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {clientID},
+				"client_secret": {clientSecret},
+				"scope":         {azureKeyVaultResource + "/.default"},
+			}, nil
+		}), nil
+	}
+
+	// workload identity: the federated token is exchanged on each request, since it is rotated
+	if tokenFile := os.Getenv(azureFederatedTokenFileEnvVar); tokenFile != "" && tenantID != "" && clientID != "" {
+		return g.formTokenSource(tokenURL, func() (url.Values, error) {
+			assertion, err := ioutil.ReadFile(tokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read federated token file: %s", err)
+			}
+			return url.Values{
+				"grant_type":            {"client_credentials"},
+				"client_id":             {clientID},
+				"client_assertion_type": {azureJWTBearerAssertionType},
+				"client_assertion":      {strings.TrimSpace(string(assertion))},
+				"scope":                 {azureKeyVaultResource + "/.default"},
+			}, nil
+		}), nil
+	}
+
+	// managed identity
+	endpoint := g.cfg.IdentityEndpoint
+	if endpoint == "" {
+		endpoint = defaultAzureIdentityEndpoint
+	}
+	query := url.Values{"api-version": {azureIdentityAPIVersion}, "resource": {azureKeyVaultResource}}
+	if clientID != "" {
+		// user-assigned identity
+		query.Set("client_id", clientID)
+	}
+	return &jsonTokenSource{
+		client: g.client,
+		newRequest: func() (*gohttp.Request, error) {
+			req, err := gohttp.NewRequest("GET", endpoint+"?"+query.Encode(), nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Metadata", "true")
+			return req, nil
+		},
+	}, nil
+}
+
+func (g *azureKeyVaultGatherer) formTokenSource(tokenURL string, form func() (url.Values, error)) oauth2.TokenSource {
+	return &jsonTokenSource{
+		client: g.client,
+		newRequest: func() (*gohttp.Request, error) {
+			values, err := form()
+			if err != nil {
+				return nil, err
+			}
+			req, err := gohttp.NewRequest("POST", tokenURL, strings.NewReader(values.Encode()))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req, nil
+		},
+	}
+}
+
+func valueOrEnv(value, envVar string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(envVar)
+}
+
+// Validate checks if the Azure Key Vault configuration is correct
+func (s *AzureKeyVault) Validate() error {
+	if s.VaultURL == "" {
+		return errors.New("azure-key-vault must have a vault_url parameter in order to be set")
+	}
+	if s.Secret == "" {
+		return errors.New("azure-key-vault must have a secret parameter in order to be set")
+	}
+	return validateDataType(s.Type)
+}
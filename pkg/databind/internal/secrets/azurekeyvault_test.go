@@ -0,0 +1,121 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"io/ioutil"
+	gohttp "net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// fakeAzure serves the identity endpoints and the Key Vault API.
+func fakeAzure(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/my-tenant/oauth2/v2.0/token":
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+			assert.Equal(t, "https://vault.azure.net/.default", r.Form.Get("scope"))
+			if r.Form.Get("client_assertion") == "federated-jwt" {
+				_, _ = w.Write([]byte(`{"access_token":"workload-token","token_type":"Bearer","expires_in":3599}`))
+				return
+			}
+			assert.Equal(t, "my-secret", r.Form.Get("client_secret"))
+			_, _ = w.Write([]byte(`{"access_token":"sp-token","token_type":"Bearer","expires_in":3599}`))
+		case "/metadata/identity/oauth2/token":
+			assert.Equal(t, "true", r.Header.Get("Metadata"))
+			assert.Equal(t, "https://vault.azure.net", r.URL.Query().Get("resource"))
+			// the instance metadata service returns expires_in as a string
+			_, _ = w.Write([]byte(`{"access_token":"msi-token","token_type":"Bearer","expires_in":"86399"}`))
+		case "/secrets/db":
+			_, _ = w.Write([]byte(`{"value":"{\"user\":\"admin\"}","id":"` + r.Header.Get("Authorization") + `"}`))
+		case "/secrets/db/v2":
+			_, _ = w.Write([]byte(`{"value":"` + r.Header.Get("Authorization") + `"}`))
+		default:
+			w.WriteHeader(gohttp.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestAzureKeyVault_ServicePrincipal(t *testing.T) {
+	ts := fakeAzure(t)
+
+	// GIVEN an azure-key-vault gatherer with service principal credentials
+	g := AzureKeyVaultGatherer(&AzureKeyVault{
+		VaultURL:      ts.URL,
+		Secret:        "db",
+		TenantID:      "my-tenant",
+		ClientID:      "my-client",
+		ClientSecret:  "my-secret",
+		AuthorityHost: ts.URL,
+		Type:          typeJson,
+	})
+
+	// WHEN the secret is fetched
+	value, err := g()
+
+	// THEN the secret is returned decoded
+	require.NoError(t, err)
+	assert.Equal(t, data.InterfaceMap{"user": "admin"}, value)
+}
+
+func TestAzureKeyVault_WorkloadIdentity(t *testing.T) {
+	ts := fakeAzure(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("federated-jwt\n"), 0600))
+	t.Setenv(azureTenantIDEnvVar, "my-tenant")
+	t.Setenv(azureClientIDEnvVar, "my-client")
+	t.Setenv(azureClientSecretEnvVar, "")
+	t.Setenv(azureFederatedTokenFileEnvVar, tokenFile)
+	t.Setenv(azureAuthorityHostEnvVar, ts.URL)
+
+	// GIVEN an azure-key-vault gatherer running with workload identity
+	g := AzureKeyVaultGatherer(&AzureKeyVault{VaultURL: ts.URL, Secret: "db", Version: "v2"})
+
+	// WHEN the secret is fetched
+	value, err := g()
+
+	// THEN the federated token is exchanged for an access token
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer workload-token", value)
+}
+
+func TestAzureKeyVault_ManagedIdentity(t *testing.T) {
+	ts := fakeAzure(t)
+	t.Setenv(azureTenantIDEnvVar, "")
+	t.Setenv(azureClientIDEnvVar, "")
+	t.Setenv(azureClientSecretEnvVar, "")
+	t.Setenv(azureFederatedTokenFileEnvVar, "")
+
+	// GIVEN an azure-key-vault gatherer without credentials
+	g := AzureKeyVaultGatherer(&AzureKeyVault{
+		VaultURL:         ts.URL,
+		Secret:           "db",
+		Version:          "v2",
+		IdentityEndpoint: ts.URL + "/metadata/identity/oauth2/token",
+	})
+
+	// WHEN the secret is fetched
+	value, err := g()
+
+	// THEN the token is taken from the managed identity endpoint
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer msi-token", value)
+}
+
+func TestAzureKeyVault_Validate(t *testing.T) {
+	assert.Error(t, (&AzureKeyVault{Secret: "db"}).Validate())
+	assert.Error(t, (&AzureKeyVault{VaultURL: "https://my.vault.azure.net"}).Validate())
+	assert.NoError(t, (&AzureKeyVault{VaultURL: "https://my.vault.azure.net", Secret: "db"}).Validate())
+}
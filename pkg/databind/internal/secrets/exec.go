@@ -0,0 +1,87 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+const defaultExecTimeout = 10 * time.Second
+
+// Exec defines a data source that runs a command and reads the secret from its standard output
+// (e.g. `pass`, `sops -d` or `op read`)
+type Exec struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+	Timeout string   `yaml:"timeout"`        // default: 10s
+	Type    string   `yaml:"type,omitempty"` // can be 'json', 'equal' and 'plain' (default)
+}
+
+type execGatherer struct {
+	cfg *Exec
+}
+
+// ExecGatherer instantiates an Exec variable gatherer from the given configuration. The fetching process
+// will return either a map containing access paths to the output JSON or ShortHand, or a string if the
+// output is just a string.
+func ExecGatherer(e *Exec) func() (interface{}, error) {
+	g := execGatherer{cfg: e}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+func (g *execGatherer) get() (interface{}, error) {
+	timeout := defaultExecTimeout
+	if g.cfg.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(g.cfg.Timeout); err != nil {
+			return nil, fmt.Errorf("wrong exec timeout: %s", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, g.cfg.Command, g.cfg.Args...)
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("secret command '%s' timed out after %s", g.cfg.Command, timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve secret from command '%s'. err: %s err msg: %s", g.cfg.Command, err, stderr.String())
+	}
+
+	// End-of-line fixup from CLI
+	output := bytes.TrimRight(out.Bytes(), "\r\n")
+	if len(output) == 0 {
+		return nil, fmt.Errorf("empty secret returned from command '%s'", g.cfg.Command)
+	}
+	return handleDataType(output, g.cfg.Type)
+}
+
+// Validate checks if the Exec configuration is correct
+func (e *Exec) Validate() error {
+	if e.Command == "" {
+		return errors.New("exec secrets must have a command parameter in order to be set")
+	}
+	if e.Timeout != "" {
+		if timeout, err := time.ParseDuration(e.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("wrong exec timeout: %q", e.Timeout)
+		}
+	}
+	return validateDataType(e.Type)
+}
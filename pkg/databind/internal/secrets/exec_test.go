@@ -0,0 +1,66 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+func TestExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test commands require a POSIX shell")
+	}
+	cases := []struct {
+		name     string
+		script   string
+		typ      string
+		expected interface{}
+	}{
+		{"plain", `echo s3cr3t`, "", "s3cr3t"},
+		{"equal", `echo user=admin,password=secret`, typeEqual, data.InterfaceMap{"user": "admin", "password": "secret"}},
+		{"json", `echo '{"user":"admin"}'`, typeJson, data.InterfaceMap{"user": "admin"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := ExecGatherer(&Exec{Command: "sh", Args: []string{"-c", tc.script}, Type: tc.typ})
+			value, err := g()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func TestExec_Errors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test commands require a POSIX shell")
+	}
+	cases := []struct {
+		name string
+		exec Exec
+	}{
+		{"failing command", Exec{Command: "sh", Args: []string{"-c", "echo not found >&2; exit 1"}}},
+		{"empty output", Exec{Command: "sh", Args: []string{"-c", "true"}}},
+		{"timeout", Exec{Command: "sh", Args: []string{"-c", "exec sleep 5"}, Timeout: "100ms"}},
+		{"missing command", Exec{Command: "/non/existing/command"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ExecGatherer(&tc.exec)()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExec_Validate(t *testing.T) {
+	assert.Error(t, (&Exec{}).Validate())
+	assert.Error(t, (&Exec{Command: "pass", Timeout: "soon"}).Validate())
+	assert.Error(t, (&Exec{Command: "pass", Type: "xml"}).Validate())
+	assert.NoError(t, (&Exec{Command: "pass", Args: []string{"show", "db"}, Timeout: "5s"}).Validate())
+}
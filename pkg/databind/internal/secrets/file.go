@@ -0,0 +1,154 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const typeYaml = "yaml" // the file will be decoded as YAML
+
+// LocalFile defines a local file data source (e.g. a secret written into a tmpfs by a config management tool)
+type LocalFile struct {
+	Path string `yaml:"path"`
+	Type string `yaml:"type,omitempty"` // can be 'json', 'yaml', 'equal' and 'plain' (default)
+	// Key is a dot-separated path to a value of a JSON or YAML file (e.g. database.password)
+	Key string `yaml:"key"`
+	// AllowInsecurePermissions allows reading files that are accessible by other users
+	AllowInsecurePermissions bool `yaml:"allow_insecure_permissions"`
+}
+
+type fileGatherer struct {
+	cfg *LocalFile
+}
+
+// LocalFileGatherer instantiates a LocalFile variable gatherer from the given configuration. The fetching process
+// will return either a map containing access paths to the stored JSON, YAML or ShortHand, or a string if
+// the stored secret is just a string (or a key to a single value is provided).
+func LocalFileGatherer(file *LocalFile) func() (interface{}, error) {
+	g := fileGatherer{cfg: file}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+func (g *fileGatherer) get() (interface{}, error) {
+	secret := g.cfg
+	if !secret.AllowInsecurePermissions {
+		if err := checkFilePermissions(secret.Path); err != nil {
+			return nil, err
+		}
+	}
+	content, err := ioutil.ReadFile(secret.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secret file '%s': %s", secret.Path, err)
+	}
+
+	if secret.Type != typeYaml && secret.Type != typeJson {
+		// text files usually end with a newline that is not part of the secret
+		return handleDataType(bytes.TrimRight(content, "\r\n"), secret.Type)
+	}
+
+	var document interface{}
+	if secret.Type == typeYaml {
+		var yamlDoc interface{}
+		if err := yaml.Unmarshal(content, &yamlDoc); err != nil {
+			return nil, fmt.Errorf("unable to decode YAML secret file '%s': %s", secret.Path, err)
+		}
+		document = stringKeys(yamlDoc)
+	} else if err := json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("unable to decode JSON secret file '%s': %s", secret.Path, err)
+	}
+
+	if secret.Key != "" {
+		for _, key := range strings.Split(secret.Key, ".") {
+			node, ok := document.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("key %q not found in secret file '%s'", secret.Key, secret.Path)
+			}
+			if document, ok = node[key]; !ok {
+				return nil, fmt.Errorf("key %q not found in secret file '%s'", secret.Key, secret.Path)
+			}
+		}
+	}
+
+	switch value := document.(type) {
+	case map[string]interface{}:
+		return data.InterfaceMap(value), nil
+	case string:
+		return value, nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+// checkFilePermissions verifies that the secret file is a regular file that can't be accessed by other
+// users, nor modified by the group. Windows ACLs are not verified.
+func checkFilePermissions(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to read secret file '%s': %s", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("secret file '%s' is not a regular file", path)
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	if perm := info.Mode().Perm(); perm&0027 != 0 {
+		return fmt.Errorf("secret file '%s' has insecure permissions %#o: it must not be accessible by other users "+
+			"nor writable by its group. Set allow_insecure_permissions to skip this check", path, perm)
+	}
+	return nil
+}
+
+// stringKeys converts the map[interface{}]interface{} YAML maps into map[string]interface{}.
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = stringKeys(val)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = stringKeys(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// Validate checks if the LocalFile configuration is correct
+func (f *LocalFile) Validate() error {
+	if f.Path == "" {
+		return errors.New("file secrets must have a path parameter in order to be set")
+	}
+	if f.Type != typeYaml {
+		if err := validateDataType(f.Type); err != nil {
+			return errors.New("type can be only " + typePlain + ", " + typeJson + ", " + typeYaml + " or " + typeEqual)
+		}
+	}
+	if f.Key != "" && f.Type != typeJson && f.Type != typeYaml {
+		return errors.New("file secrets key can be only used with " + typeJson + " or " + typeYaml + " types")
+	}
+	return nil
+}
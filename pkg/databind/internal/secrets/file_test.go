@@ -0,0 +1,81 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+func writeSecretFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestFile(t *testing.T) {
+	cases := []struct {
+		name     string
+		content  string
+		typ      string
+		key      string
+		expected interface{}
+	}{
+		{"plain", "s3cr3t\n", "", "", "s3cr3t"},
+		{"equal", "user=admin,password=secret\n", typeEqual, "", data.InterfaceMap{"user": "admin", "password": "secret"}},
+		{"json", `{"db":{"user":"admin","port":5432}}`, typeJson, "", data.InterfaceMap{"db": map[string]interface{}{"user": "admin", "port": float64(5432)}}},
+		{"json key to map", `{"db":{"user":"admin"}}`, typeJson, "db", data.InterfaceMap{"user": "admin"}},
+		{"json key to number", `{"db":{"port":5432}}`, typeJson, "db.port", "5432"},
+		{"yaml", "db:\n  user: admin\n", typeYaml, "", data.InterfaceMap{"db": map[string]interface{}{"user": "admin"}}},
+		{"yaml key", "db:\n  password: secret\n", typeYaml, "db.password", "secret"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := LocalFileGatherer(&LocalFile{Path: writeSecretFile(t, tc.content), Type: tc.typ, Key: tc.key})
+			value, err := g()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func TestFile_MissingKey(t *testing.T) {
+	g := LocalFileGatherer(&LocalFile{Path: writeSecretFile(t, `{"db":{"user":"admin"}}`), Type: typeJson, Key: "db.password"})
+	_, err := g()
+	assert.Error(t, err)
+}
+
+func TestFile_InsecurePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not checked on Windows")
+	}
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, ioutil.WriteFile(path, []byte("s3cr3t"), 0644))
+
+	// GIVEN a secret file readable by other users
+	// WHEN the secret is fetched
+	_, err := LocalFileGatherer(&LocalFile{Path: path})()
+
+	// THEN it fails
+	assert.Error(t, err)
+
+	// UNLESS insecure permissions are allowed
+	value, err := LocalFileGatherer(&LocalFile{Path: path, AllowInsecurePermissions: true})()
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", value)
+}
+
+func TestFile_Validate(t *testing.T) {
+	assert.Error(t, (&LocalFile{}).Validate())
+	assert.Error(t, (&LocalFile{Path: "/secret", Type: "xml"}).Validate())
+	assert.Error(t, (&LocalFile{Path: "/secret", Key: "db.password"}).Validate())
+	assert.NoError(t, (&LocalFile{Path: "/secret", Type: typeYaml, Key: "db.password"}).Validate())
+}
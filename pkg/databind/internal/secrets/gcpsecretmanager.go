@@ -0,0 +1,241 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	gohttp "net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+const (
+	defaultGCPSecretManagerEndpoint = "https://secretmanager.googleapis.com"
+	defaultGCPTokenURL              = "https://oauth2.googleapis.com/token"
+	defaultGCPMetadataHost          = "metadata.google.internal"
+	gcpCloudPlatformScope           = "https://www.googleapis.com/auth/cloud-platform"
+	gcpCredentialsEnvVar            = "GOOGLE_APPLICATION_CREDENTIALS"
+	gcpMetadataHostEnvVar           = "GCE_METADATA_HOST"
+)
+
+// GCPSecretManager defines the Google Cloud Secret Manager data source. Credentials are taken from the
+// standard application default credentials chain: the credentials file, the GOOGLE_APPLICATION_CREDENTIALS
+// environment variable, the gcloud CLI application default credentials and the metadata server.
+type GCPSecretManager struct {
+	Project         string `yaml:"project"`
+	Secret          string `yaml:"secret"`
+	Version         string `yaml:"version"` // default: latest
+	CredentialsFile string `yaml:"credentials_file"`
+	Endpoint        string `yaml:"endpoint"`
+	Type            string `yaml:"type,omitempty"` // can be 'json', 'equal' and 'plain' (default)
+}
+
+type gcpSecretManagerGatherer struct {
+	cfg     *GCPSecretManager
+	client  *gohttp.Client
+	lock    sync.Mutex
+	tokens  oauth2.TokenSource
+	project string
+}
+
+// gcpCredentials is the content of a service account key or an authorized user credentials file.
+type gcpCredentials struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// GCPSecretManagerGatherer instantiates a GCP Secret Manager variable gatherer from the given configuration.
+// The fetching process will return either a map containing access paths to the stored JSON or ShortHand,
+// or a string if the stored secret is just a string.
+func GCPSecretManagerGatherer(sm *GCPSecretManager) func() (interface{}, error) {
+	g := gcpSecretManagerGatherer{cfg: sm, client: &gohttp.Client{Timeout: cloudRequestTimeout}}
+	return func() (interface{}, error) {
+		dt, err := g.get()
+		if err != nil {
+			return "", err
+		}
+		return dt, err
+	}
+}
+
+func (g *gcpSecretManagerGatherer) get() (interface{}, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.tokens == nil {
+		if err := g.loadCredentials(); err != nil {
+			return nil, fmt.Errorf("unable to load gcp credentials: %s", err)
+		}
+	}
+
+	endpoint := g.cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultGCPSecretManagerEndpoint
+	}
+	version := g.cfg.Version
+	if version == "" {
+		version = "latest"
+	}
+	url := fmt.Sprintf("%s/v1/projects/%s/secrets/%s/versions/%s:access",
+		strings.TrimRight(endpoint, "/"), g.project, g.cfg.Secret, version)
+	body, err := bearerGet(g.client, g.tokens, url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve secret from gcp-secret-manager: %s", err)
+	}
+
+	var res struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("unable to decode gcp-secret-manager response: %s", err)
+	}
+	payload, err := base64.StdEncoding.DecodeString(res.Payload.Data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to base64 decode gcp-secret-manager data: %s", err)
+	}
+	return handleDataType(payload, g.cfg.Type)
+}
+
+// loadCredentials sets the token source and the project from the first credentials found in the
+// application default credentials chain.
+func (g *gcpSecretManagerGatherer) loadCredentials() error {
+	g.project = g.cfg.Project
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, g.client)
+
+	file := g.cfg.CredentialsFile
+	if file == "" {
+		file = os.Getenv(gcpCredentialsEnvVar)
+	}
+	if file == "" {
+		if wellKnown := gcloudCredentialsFile(); wellKnown != "" {
+			if _, err := os.Stat(wellKnown); err == nil {
+				file = wellKnown
+			}
+		}
+	}
+
+	if file == "" {
+		// running in Google Cloud: the metadata server provides the tokens of the attached service account
+		host := os.Getenv(gcpMetadataHostEnvVar)
+		if host == "" {
+			host = defaultGCPMetadataHost
+		}
+		if g.project == "" {
+			project, err := g.metadata(host, "project/project-id")
+			if err != nil {
+				return fmt.Errorf("missing project and unable to get it from the metadata server: %s", err)
+			}
+			g.project = project
+		}
+		g.tokens = oauth2.ReuseTokenSource(nil, &jsonTokenSource{
+			client: g.client,
+			newRequest: func() (*gohttp.Request, error) {
+				return newMetadataRequest(host, "instance/service-accounts/default/token")
+			},
+		})
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var creds gcpCredentials
+	if err := json.Unmarshal(content, &creds); err != nil {
+		return fmt.Errorf("unable to decode credentials file %q: %s", file, err)
+	}
+	if g.project == "" {
+		g.project = creds.ProjectID
+	}
+	if g.project == "" {
+		return errors.New("missing project")
+	}
+	tokenURL := creds.TokenURI
+	if tokenURL == "" {
+		tokenURL = defaultGCPTokenURL
+	}
+	switch creds.Type {
+	case "service_account":
+		cfg := &jwt.Config{
+			Email:        creds.ClientEmail,
+			PrivateKey:   []byte(creds.PrivateKey),
+			PrivateKeyID: creds.PrivateKeyID,
+			Scopes:       []string{gcpCloudPlatformScope},
+			TokenURL:     tokenURL,
+		}
+		g.tokens = cfg.TokenSource(ctx)
+	case "authorized_user":
+		cfg := &oauth2.Config{
+			ClientID:     creds.ClientID,
+			ClientSecret: creds.ClientSecret,
+			Endpoint:     oauth2.Endpoint{TokenURL: tokenURL},
+			Scopes:       []string{gcpCloudPlatformScope},
+		}
+		g.tokens = cfg.TokenSource(ctx, &oauth2.Token{RefreshToken: creds.RefreshToken})
+	default:
+		return fmt.Errorf("unsupported credentials type %q in %q", creds.Type, file)
+	}
+	return nil
+}
+
+func (g *gcpSecretManagerGatherer) metadata(host, path string) (string, error) {
+	req, err := newMetadataRequest(host, path)
+	if err != nil {
+		return "", err
+	}
+	body, err := doRequest(g.client, req)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func newMetadataRequest(host, path string) (*gohttp.Request, error) {
+	req, err := gohttp.NewRequest("GET", "http://"+host+"/computeMetadata/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return req, nil
+}
+
+// gcloudCredentialsFile returns the path of the application default credentials created by the gcloud CLI.
+func gcloudCredentialsFile() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", "application_default_credentials.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+// Validate checks if the GCP Secret Manager configuration is correct
+func (s *GCPSecretManager) Validate() error {
+	if s.Secret == "" {
+		return errors.New("gcp-secret-manager must have a secret parameter in order to be set")
+	}
+	return validateDataType(s.Type)
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// fakeGCP serves the token endpoints and the Secret Manager API.
+func fakeGCP(t *testing.T, tokenRequests *int) *httptest.Server {
+	payload := base64.StdEncoding.EncodeToString([]byte("user=admin,password=secret"))
+	ts := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/token":
+			*tokenRequests++
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.Form.Get("grant_type"))
+			_, _ = w.Write([]byte(`{"access_token":"sa-token","token_type":"Bearer","expires_in":3600}`))
+		case "/computeMetadata/v1/instance/service-accounts/default/token":
+			*tokenRequests++
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			_, _ = w.Write([]byte(`{"access_token":"metadata-token","token_type":"Bearer","expires_in":3600}`))
+		case "/computeMetadata/v1/project/project-id":
+			_, _ = w.Write([]byte("metadata-project"))
+		case "/v1/projects/my-project/secrets/db/versions/latest:access":
+			assert.Equal(t, "Bearer sa-token", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"name":"projects/1/secrets/db/versions/1","payload":{"data":"` + payload + `"}}`))
+		case "/v1/projects/metadata-project/secrets/db/versions/3:access":
+			assert.Equal(t, "Bearer metadata-token", r.Header.Get("Authorization"))
+			_, _ = w.Write([]byte(`{"name":"projects/1/secrets/db/versions/3","payload":{"data":"` + payload + `"}}`))
+		default:
+			w.WriteHeader(gohttp.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestGCPSecretManager_ServiceAccount(t *testing.T) {
+	var tokenRequests int
+	ts := fakeGCP(t, &tokenRequests)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	creds, err := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "my-project",
+		"client_email": "agent@my-project.iam.gserviceaccount.com",
+		"private_key":  string(keyPEM),
+		"token_uri":    ts.URL + "/token",
+	})
+	require.NoError(t, err)
+	credsFile := filepath.Join(t.TempDir(), "key.json")
+	require.NoError(t, ioutil.WriteFile(credsFile, creds, 0600))
+
+	// GIVEN a gcp-secret-manager gatherer with a service account key
+	g := GCPSecretManagerGatherer(&GCPSecretManager{
+		Secret:          "db",
+		CredentialsFile: credsFile,
+		Endpoint:        ts.URL,
+		Type:            typeEqual,
+	})
+
+	// WHEN the secret is fetched twice
+	value, err := g()
+	require.NoError(t, err)
+	_, err = g()
+	require.NoError(t, err)
+
+	// THEN the project is taken from the key, the payload is decoded, and the token is reused
+	assert.Equal(t, data.InterfaceMap{"user": "admin", "password": "secret"}, value)
+	assert.Equal(t, 1, tokenRequests)
+}
+
+func TestGCPSecretManager_MetadataServer(t *testing.T) {
+	var tokenRequests int
+	ts := fakeGCP(t, &tokenRequests)
+	t.Setenv(gcpCredentialsEnvVar, "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(gcpMetadataHostEnvVar, strings.TrimPrefix(ts.URL, "http://"))
+
+	// GIVEN a gcp-secret-manager gatherer running in a GCP instance without a credentials file
+	g := GCPSecretManagerGatherer(&GCPSecretManager{Secret: "db", Version: "3", Endpoint: ts.URL})
+
+	// WHEN the secret is fetched
+	value, err := g()
+	require.NoError(t, err)
+
+	// THEN the token and the project are taken from the metadata server
+	assert.Equal(t, "user=admin,password=secret", value)
+	assert.Equal(t, 1, tokenRequests)
+}
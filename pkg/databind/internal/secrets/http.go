@@ -26,25 +26,34 @@ type tlsConfig struct {
 	Ca                 string `yaml:"ca"`
 }
 
-func httpRequest(config *http, method string, body io.Reader) ([]byte, error) {
-	client := &gohttp.Client{}
+// newTLSConfig builds the client TLS configuration from the user-provided options.
+func newTLSConfig(config tlsConfig) (*tls.Config, error) {
 	tlsConfig := &tls.Config{
-		MinVersion: config.TLSConfig.MinVersion,
-		MaxVersion: config.TLSConfig.MaxVersion,
+		MinVersion: config.MinVersion,
+		MaxVersion: config.MaxVersion,
 	}
-	if config.TLSConfig.InsecureSkipVerify {
-		tlsConfig.InsecureSkipVerify = config.TLSConfig.InsecureSkipVerify
+	if config.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = config.InsecureSkipVerify
 	}
 
-	if config.TLSConfig.Ca != "" {
+	if config.Ca != "" {
 		rootCAs := x509.NewCertPool()
-		ca, err := ioutil.ReadFile(config.TLSConfig.Ca)
+		ca, err := ioutil.ReadFile(config.Ca)
 		if err != nil {
 			return nil, fmt.Errorf("unable to read certificate authority file: %s", err)
 		}
 		rootCAs.AppendCertsFromPEM(ca)
 		tlsConfig.RootCAs = rootCAs
 	}
+	return tlsConfig, nil
+}
+
+func httpRequest(config *http, method string, body io.Reader) ([]byte, error) {
+	client := &gohttp.Client{}
+	tlsConfig, err := newTLSConfig(config.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
 	client.Transport = &gohttp.Transport{
 		TLSClientConfig: tlsConfig,
 	}
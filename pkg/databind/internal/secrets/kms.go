@@ -85,10 +85,7 @@ func (k *KMS) Validate() error {
 	if k.File == "" && k.Data == "" && (k.HTTP == nil || k.HTTP.URL == "") {
 		return errors.New("aws-kms must have a file, data or http parameter in order to be set")
 	}
-	if k.Type != "" && k.Type != typeJson && k.Type != typeEqual && k.Type != typePlain {
-		return errors.New("type can be only " + typePlain + ", " + typeJson + " or " + typeEqual)
-	}
-	return nil
+	return validateDataType(k.Type)
 }
 
 func (g *kmsGatherer) retrieve(encoded []byte) (interface{}, error) {
@@ -100,53 +97,65 @@ func (g *kmsGatherer) retrieve(encoded []byte) (interface{}, error) {
 		dt = dt[:n] // remove decoder leading zeroes
 	}
 
+	kmsSession := newAWSSession(secret.Region, secret.Endpoint, secret.DisableSSL, secret.CredentialFile, secret.ConfigFile)
+	client := kms.New(kmsSession)
+	params := &kms.DecryptInput{
+		CiphertextBlob: dt,
+	}
+	res, err := client.Decrypt(params)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt secret with aws-kms: %s", err)
+	}
+	return handleDataType(res.Plaintext, g.cfg.Type)
+}
+
+// newAWSSession creates an AWS session from the standard credential chain, optionally adding the provided
+// credential and config files.
+func newAWSSession(region, endpoint string, disableSSL bool, credentialFile, configFile string) *session.Session {
 	var configFiles []string
-	if secret.CredentialFile != "" {
-		tlog := slog.WithField("CredentialFile", secret.CredentialFile)
+	if credentialFile != "" {
+		tlog := slog.WithField("CredentialFile", credentialFile)
 		tlog.Debug("Adding credentials file.")
-		_, err := os.Stat(secret.CredentialFile)
+		_, err := os.Stat(credentialFile)
 		if err != nil {
 			tlog.WithError(err).Warn("could not find credentials file so ignoring it")
 		} else {
-			configFiles = append(configFiles, secret.CredentialFile)
+			configFiles = append(configFiles, credentialFile)
 		}
 	}
-	if secret.ConfigFile != "" {
-		tlog := slog.WithField("ConfigFile", secret.ConfigFile)
+	if configFile != "" {
+		tlog := slog.WithField("ConfigFile", configFile)
 		tlog.Debug("Adding config file.")
-		_, err := os.Stat(secret.ConfigFile)
+		_, err := os.Stat(configFile)
 		if err != nil {
 			tlog.WithError(err).Warn("could not find config file so ignoring it")
 		} else {
-			configFiles = append(configFiles, secret.ConfigFile)
+			configFiles = append(configFiles, configFile)
 		}
 	}
 
-	var kmsSession *session.Session
 	cfgs := aws.NewConfig()
-	if g.cfg.Region != "" {
-		cfgs = cfgs.WithRegion(g.cfg.Region)
+	if region != "" {
+		cfgs = cfgs.WithRegion(region)
 	}
-	if g.cfg.DisableSSL {
-		cfgs = cfgs.WithDisableSSL(g.cfg.DisableSSL)
+	if disableSSL {
+		cfgs = cfgs.WithDisableSSL(disableSSL)
 	}
-	if g.cfg.Endpoint != "" {
-		cfgs = cfgs.WithEndpoint(g.cfg.Endpoint)
+	if endpoint != "" {
+		cfgs = cfgs.WithEndpoint(endpoint)
 	}
-	kmsSession = session.Must(session.NewSessionWithOptions(session.Options{
+	return session.Must(session.NewSessionWithOptions(session.Options{
 		Config:            *cfgs,
 		SharedConfigFiles: configFiles,
 	}))
+}
 
-	client := kms.New(kmsSession)
-	params := &kms.DecryptInput{
-		CiphertextBlob: dt,
-	}
-	res, err := client.Decrypt(params)
-	if err != nil {
-		return nil, fmt.Errorf("unable to decrypt secret with aws-kms: %s", err)
+// validateDataType checks that the data type is one of the supported by handleDataType
+func validateDataType(dataType string) error {
+	if dataType != "" && dataType != typeJson && dataType != typeEqual && dataType != typePlain {
+		return errors.New("type can be only " + typePlain + ", " + typeJson + " or " + typeEqual)
 	}
-	return handleDataType(res.Plaintext, g.cfg.Type)
+	return nil
 }
 
 // this function converts from the stored payload to a map (dataType json, equal)
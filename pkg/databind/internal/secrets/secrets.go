@@ -4,7 +4,16 @@
 package secrets
 
 import (
+	"time"
+
 	"github.com/newrelic/infrastructure-agent/pkg/log"
 )
 
 var slog = log.WithComponent("DatabindSecrets")
+
+// Leased is a gathered value that is only valid for a limited time (e.g. dynamic credentials). The value
+// must be fetched again after the TTL, even if the variable is configured with a longer TTL.
+type Leased struct {
+	Value interface{}
+	TTL   time.Duration
+}
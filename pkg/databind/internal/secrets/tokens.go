@@ -0,0 +1,95 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	gohttp "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const cloudRequestTimeout = 30 * time.Second
+
+// jsonTokenSource gets OAuth2 access tokens from endpoints that don't follow the standard OAuth2
+// flows (e.g. cloud instance metadata services).
+type jsonTokenSource struct {
+	client     *gohttp.Client
+	newRequest func() (*gohttp.Request, error)
+}
+
+// tokenResponse is the common format of the access token responses. The expires_in field is a number
+// for most providers, but a string for the Azure instance metadata service.
+type tokenResponse struct {
+	AccessToken string          `json:"access_token"`
+	TokenType   string          `json:"token_type"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+}
+
+func (s *jsonTokenSource) Token() (*oauth2.Token, error) {
+	req, err := s.newRequest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create token request: %s", err)
+	}
+	body, err := doRequest(s.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get access token: %s", err)
+	}
+	var res tokenResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("unable to decode access token: %s", err)
+	}
+	if res.AccessToken == "" {
+		return nil, errors.New("missing access token in response")
+	}
+	token := &oauth2.Token{AccessToken: res.AccessToken, TokenType: res.TokenType}
+	if expiresIn, err := strconv.Atoi(strings.Trim(string(res.ExpiresIn), `"`)); err == nil && expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// bearerGet performs a GET request authenticated with a token from the token source.
+func bearerGet(client *gohttp.Client, tokens oauth2.TokenSource, url string) ([]byte, error) {
+	token, err := tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+	req, err := gohttp.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create http request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return doRequest(client, req)
+}
+
+// doRequest sends the request and returns the response body, or an error if the response status is not
+// successful.
+func doRequest(client *gohttp.Client, req *gohttp.Request) ([]byte, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send http request: %s", err)
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			slog.WithError(err).Warn("Unable to close response body")
+		}
+	}()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		return nil, fmt.Errorf("error response received from server: %s", res.Status)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read http response body: %s", err)
+	}
+	return b, nil
+}
@@ -7,15 +7,89 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
 )
 
+const (
+	defaultVaultRetries      = 3
+	defaultVaultRetryBackoff = time.Second
+	defaultAppRoleMount      = "approle"
+	defaultKubernetesMount   = "kubernetes"
+	defaultCertMount         = "cert"
+)
+
+// Vault defines the HashiCorp Vault data source. The secret can be either retrieved through a raw
+// HTTP request (HTTP section) or through the Vault API (Address section), which supports authentication
+// methods, KV secrets engines and dynamic secrets with lease renewal.
 type Vault struct {
 	HTTP *http
+	// Address of the Vault server (e.g. https://vault.example.com:8200)
+	Address string `yaml:"address"`
+	// Namespace of the secret, for Vault Enterprise
+	Namespace string `yaml:"namespace"`
+	// Path of the secret, without the /v1/ API prefix (e.g. database/creds/readonly)
+	Path string `yaml:"path"`
+	// KVVersion of the KV secrets engine. When 2, the "data/" segment is added after the mount point
+	// of the path. When unset, KV v2 responses are detected automatically.
+	KVVersion    int       `yaml:"kv_version"`
+	TLSConfig    tlsConfig `yaml:"tls_config"`
+	Auth         VaultAuth `yaml:"auth"`
+	Retries      *int      `yaml:"retries"`
+	RetryBackoff string    `yaml:"retry_backoff"`
+}
+
+// VaultAuth defines the authentication method used against the Vault API. If no method is
+// provided, the token is read from the VAULT_TOKEN environment variable.
+type VaultAuth struct {
+	Token      string               `yaml:"token"`
+	TokenFile  string               `yaml:"token_file"`
+	AppRole    *VaultAppRoleAuth    `yaml:"approle"`
+	Kubernetes *VaultKubernetesAuth `yaml:"kubernetes"`
+	Cert       *VaultCertAuth       `yaml:"cert"`
+}
+
+// VaultAppRoleAuth authenticates with an AppRole role ID and secret ID.
+type VaultAppRoleAuth struct {
+	Mount        string `yaml:"mount"`
+	RoleID       string `yaml:"role_id"`
+	SecretID     string `yaml:"secret_id"`
+	SecretIDFile string `yaml:"secret_id_file"`
+}
+
+// VaultKubernetesAuth authenticates with the Kubernetes service account token of the agent.
+type VaultKubernetesAuth struct {
+	Mount     string `yaml:"mount"`
+	Role      string `yaml:"role"`
+	TokenFile string `yaml:"token_file"`
+}
+
+// VaultCertAuth authenticates with a TLS client certificate.
+type VaultCertAuth struct {
+	Mount    string `yaml:"mount"`
+	Name     string `yaml:"name"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
 }
 
 type vaultGatherer struct {
-	cfg *Vault
+	cfg    *Vault
+	client *vaultClient
+	now    func() time.Time
+	lock   sync.Mutex
+	lease  vaultLease
+}
+
+// vaultLease is the lease of the last read dynamic secret.
+type vaultLease struct {
+	id        string
+	renewable bool
+	duration  time.Duration
+	expires   time.Time
+	value     data.InterfaceMap
 }
 
 // VaultGatherer instantiates a Vault variable gatherer from the given configuration. The fetching process
@@ -24,10 +98,19 @@ type vaultGatherer struct {
 // contents will be:
 // "person.name"    -> "Matias"
 // "person.surname" -> "Burni"
+// Dynamic secrets are returned as Leased values, so they are fetched again (renewing their lease)
+// before it expires.
 func VaultGatherer(vault *Vault) func() (interface{}, error) {
-	g := vaultGatherer{cfg: vault}
+	g := vaultGatherer{cfg: vault, now: time.Now}
 	return func() (interface{}, error) {
-		dt, err := g.get()
+		if vault.HTTP != nil {
+			dt, err := g.get()
+			if err != nil {
+				return "", err
+			}
+			return dt, err
+		}
+		dt, err := g.getFromAPI()
 		if err != nil {
 			return "", err
 		}
@@ -59,12 +142,137 @@ func (g *vaultGatherer) get() (data.InterfaceMap, error) {
 	return nil, fmt.Errorf("vault returned an unexpected format from the http server: %s", string(dt))
 }
 
+// getFromAPI reads the secret from the Vault API. If the secret has a renewable lease that has not
+// expired yet, the lease is renewed instead, so the same credentials are kept.
+func (g *vaultGatherer) getFromAPI() (interface{}, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.client == nil {
+		client, err := newVaultClient(g.cfg, g.now)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create vault client: %s", err)
+		}
+		g.client = client
+	}
+
+	now := g.now()
+	if g.lease.id != "" && g.lease.renewable && now.Before(g.lease.expires) {
+		renewed, err := g.client.renewLease(g.lease.id, g.lease.duration)
+		if err != nil {
+			slog.WithError(err).WithField("path", g.cfg.Path).Debug("Unable to renew vault lease. Reading the secret again.")
+		} else if duration := renewed.leaseDuration(); duration >= g.lease.duration/2 {
+			// leases reaching their max TTL are renewed for shorter durations, so a new secret is read instead
+			g.lease.expires = now.Add(duration)
+			return Leased{Value: g.lease.value, TTL: renewalTTL(duration)}, nil
+		}
+	}
+
+	secret, err := g.client.read(g.secretPath())
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve vault secret %q: %s", g.cfg.Path, err)
+	}
+	value, err := secret.values(g.cfg.KVVersion)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode vault secret %q: %s", g.cfg.Path, err)
+	}
+
+	// static secrets (e.g. KV) are cached for the configured variable TTL
+	if secret.LeaseID == "" {
+		g.lease = vaultLease{}
+		return value, nil
+	}
+	duration := secret.leaseDuration()
+	g.lease = vaultLease{
+		id:        secret.LeaseID,
+		renewable: secret.Renewable,
+		duration:  duration,
+		expires:   now.Add(duration),
+		value:     value,
+	}
+	return Leased{Value: value, TTL: renewalTTL(duration)}, nil
+}
+
+// secretPath returns the API path of the secret, adding the "data/" segment to KV v2 paths.
+func (g *vaultGatherer) secretPath() string {
+	path := strings.Trim(g.cfg.Path, "/")
+	if g.cfg.KVVersion != 2 {
+		return path
+	}
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) < 2 {
+		return path + "/data"
+	}
+	return parts[0] + "/data/" + parts[1]
+}
+
+// renewalTTL returns the time after which a secret with the given lease duration must be renewed, leaving
+// a margin before the lease expires.
+func renewalTTL(lease time.Duration) time.Duration {
+	return lease * 2 / 3
+}
+
 func (g *Vault) Validate() error {
-	if g.HTTP == nil {
-		return errors.New("vault secrets must have an http parameter with a URL in order to be set")
+	if g.HTTP != nil {
+		if g.HTTP.URL == "" {
+			return errors.New("vault secrets must have an http URL parameter in order to be set")
+		}
+		if g.Address != "" {
+			return errors.New("vault secrets can't have both http and address parameters")
+		}
+		return nil
+	}
+	if g.Address == "" {
+		return errors.New("vault secrets must have either an address or an http parameter with a URL in order to be set")
+	}
+	if strings.Trim(g.Path, "/") == "" {
+		return errors.New("vault secrets must have a path parameter in order to be set")
+	}
+	if g.KVVersion != 0 && g.KVVersion != 1 && g.KVVersion != 2 {
+		return fmt.Errorf("vault kv_version must be 1 or 2, got %d", g.KVVersion)
+	}
+	if g.Retries != nil && *g.Retries < 0 {
+		return errors.New("vault retries can't be negative")
+	}
+	if g.RetryBackoff != "" {
+		if _, err := time.ParseDuration(g.RetryBackoff); err != nil {
+			return fmt.Errorf("wrong vault retry_backoff: %s", err)
+		}
+	}
+	return g.Auth.validate()
+}
+
+func (a *VaultAuth) validate() error {
+	methods := 0
+	if a.Token != "" || a.TokenFile != "" {
+		methods++
+		if a.Token != "" && a.TokenFile != "" {
+			return errors.New("vault auth can't have both token and token_file")
+		}
+	}
+	if a.AppRole != nil {
+		methods++
+		if a.AppRole.RoleID == "" {
+			return errors.New("vault approle auth must have a role_id")
+		}
+		if a.AppRole.SecretID != "" && a.AppRole.SecretIDFile != "" {
+			return errors.New("vault approle auth can't have both secret_id and secret_id_file")
+		}
+	}
+	if a.Kubernetes != nil {
+		methods++
+		if a.Kubernetes.Role == "" {
+			return errors.New("vault kubernetes auth must have a role")
+		}
+	}
+	if a.Cert != nil {
+		methods++
+		if a.Cert.CertFile == "" || a.Cert.KeyFile == "" {
+			return errors.New("vault cert auth must have a cert_file and a key_file")
+		}
 	}
-	if g.HTTP.URL == "" {
-		return errors.New("vault secrets must have an http URL parameter in order to be set")
+	if methods > 1 {
+		return errors.New("vault auth must have a single authentication method")
 	}
 	return nil
 }
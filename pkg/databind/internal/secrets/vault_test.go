@@ -0,0 +1,267 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	gohttp "net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+// fakeVault mimics the Vault API endpoints used by the gatherer.
+type fakeVault struct {
+	sync.Mutex
+	tokenTTL     int
+	reads        int
+	renewals     int
+	logins       int
+	failures     int // number of requests to fail with 503 before responding
+	namespaces   []string
+	loginBodies  []map[string]interface{}
+	revokedToken string
+}
+
+func (f *fakeVault) serve(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+		f.Lock()
+		defer f.Unlock()
+		f.namespaces = append(f.namespaces, r.Header.Get(vaultNamespaceHeader))
+		if f.failures > 0 {
+			f.failures--
+			w.WriteHeader(gohttp.StatusServiceUnavailable)
+			return
+		}
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		if r.URL.Path == "/v1/auth/approle/login" || r.URL.Path == "/v1/auth/kubernetes/login" {
+			f.logins++
+			f.loginBodies = append(f.loginBodies, body)
+			writeJSON(w, map[string]interface{}{"auth": map[string]interface{}{
+				"client_token": "token-" + string(rune('0'+f.logins)), "lease_duration": f.tokenTTL, "renewable": false,
+			}})
+			return
+		}
+		token := r.Header.Get(vaultTokenHeader)
+		if token == "" || token == f.revokedToken {
+			w.WriteHeader(gohttp.StatusForbidden)
+			writeJSON(w, map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/myapp":
+			f.reads++
+			writeJSON(w, map[string]interface{}{"data": map[string]interface{}{
+				"data":     map[string]interface{}{"user": "admin", "password": "secret"},
+				"metadata": map[string]interface{}{"version": 3},
+			}})
+		case "/v1/kv/myapp":
+			f.reads++
+			writeJSON(w, map[string]interface{}{"lease_duration": 2764800, "data": map[string]interface{}{"user": "admin"}})
+		case "/v1/database/creds/readonly":
+			f.reads++
+			writeJSON(w, map[string]interface{}{
+				"lease_id": "database/creds/readonly/abc", "lease_duration": 3600, "renewable": true,
+				"data": map[string]interface{}{"username": "v-user-" + string(rune('0'+f.reads)), "password": "pass"},
+			})
+		case "/v1/sys/leases/renew":
+			f.renewals++
+			assert.Equal(t, "database/creds/readonly/abc", body["lease_id"])
+			writeJSON(w, map[string]interface{}{"lease_id": body["lease_id"], "lease_duration": 3600, "renewable": true})
+		default:
+			w.WriteHeader(gohttp.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func writeJSON(w gohttp.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func noRetries() *int {
+	n := 0
+	return &n
+}
+
+func TestVault_KVv2_Token(t *testing.T) {
+	fv := &fakeVault{}
+	ts := fv.serve(t)
+
+	// GIVEN a vault gatherer with token authentication, reading a KV v2 secret from a namespace
+	g := VaultGatherer(&Vault{
+		Address:   ts.URL,
+		Namespace: "team-a",
+		Path:      "secret/myapp",
+		KVVersion: 2,
+		Auth:      VaultAuth{Token: "root"},
+	})
+
+	// WHEN the secret is fetched
+	value, err := g()
+	require.NoError(t, err)
+
+	// THEN the secret data is returned without the KV metadata
+	assert.Equal(t, data.InterfaceMap{"user": "admin", "password": "secret"}, value)
+	assert.Equal(t, []string{"team-a"}, fv.namespaces)
+}
+
+func TestVault_KVv1_NotLeased(t *testing.T) {
+	fv := &fakeVault{}
+	ts := fv.serve(t)
+	g := VaultGatherer(&Vault{Address: ts.URL, Path: "kv/myapp", Auth: VaultAuth{Token: "root"}})
+
+	value, err := g()
+	require.NoError(t, err)
+
+	// static secrets are not leased, so the configured variable TTL applies
+	assert.Equal(t, data.InterfaceMap{"user": "admin"}, value)
+}
+
+func TestVault_DynamicSecret_LeaseRenewal(t *testing.T) {
+	fv := &fakeVault{tokenTTL: 7200}
+	ts := fv.serve(t)
+	secretIDFile := filepath.Join(t.TempDir(), "secret-id")
+	require.NoError(t, ioutil.WriteFile(secretIDFile, []byte("my-secret-id\n"), 0600))
+
+	now := time.Now()
+	g := vaultGatherer{
+		now: func() time.Time { return now },
+		cfg: &Vault{
+			Address: ts.URL,
+			Path:    "database/creds/readonly",
+			Auth:    VaultAuth{AppRole: &VaultAppRoleAuth{RoleID: "my-role", SecretIDFile: secretIDFile}},
+		},
+	}
+
+	// WHEN a dynamic secret is fetched
+	value, err := g.getFromAPI()
+	require.NoError(t, err)
+
+	// THEN the gatherer logs in with AppRole
+	require.Len(t, fv.loginBodies, 1)
+	assert.Equal(t, map[string]interface{}{"role_id": "my-role", "secret_id": "my-secret-id"}, fv.loginBodies[0])
+
+	// AND the value TTL follows the lease duration
+	require.IsType(t, Leased{}, value)
+	leased := value.(Leased)
+	assert.Equal(t, 40*time.Minute, leased.TTL)
+	assert.Equal(t, "v-user-1", leased.Value.(data.InterfaceMap)["username"])
+
+	// WHEN the secret is fetched again before the lease expires
+	now = now.Add(leased.TTL)
+	value, err = g.getFromAPI()
+	require.NoError(t, err)
+
+	// THEN the lease is renewed and the same credentials are kept
+	assert.Equal(t, 1, fv.reads)
+	assert.Equal(t, 1, fv.renewals)
+	assert.Equal(t, "v-user-1", value.(Leased).Value.(data.InterfaceMap)["username"])
+
+	// WHEN the lease expires, and the token needs to be renewed
+	now = now.Add(2 * time.Hour)
+	value, err = g.getFromAPI()
+	require.NoError(t, err)
+
+	// THEN the gatherer logs in again, and new credentials are read
+	assert.Equal(t, 2, fv.logins)
+	assert.Equal(t, 2, fv.reads)
+	assert.Equal(t, "v-user-2", value.(Leased).Value.(data.InterfaceMap)["username"])
+}
+
+func TestVault_Kubernetes_ReloginOnRevokedToken(t *testing.T) {
+	fv := &fakeVault{}
+	ts := fv.serve(t)
+	jwtFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, ioutil.WriteFile(jwtFile, []byte("service-account-jwt"), 0600))
+
+	g := VaultGatherer(&Vault{
+		Address:   ts.URL,
+		Path:      "secret/myapp",
+		KVVersion: 2,
+		Auth:      VaultAuth{Kubernetes: &VaultKubernetesAuth{Role: "agent", TokenFile: jwtFile}},
+	})
+	_, err := g()
+	require.NoError(t, err)
+	require.Len(t, fv.loginBodies, 1)
+	assert.Equal(t, map[string]interface{}{"role": "agent", "jwt": "service-account-jwt"}, fv.loginBodies[0])
+
+	// GIVEN that the token is revoked
+	fv.revokedToken = "token-1"
+
+	// WHEN the secret is fetched again
+	value, err := g()
+	require.NoError(t, err)
+
+	// THEN the gatherer logs in again
+	assert.Equal(t, 2, fv.logins)
+	assert.Equal(t, data.InterfaceMap{"user": "admin", "password": "secret"}, value)
+}
+
+func TestVault_Retries(t *testing.T) {
+	fv := &fakeVault{failures: 2}
+	ts := fv.serve(t)
+
+	// GIVEN a vault server that is temporarily unavailable
+	g := VaultGatherer(&Vault{Address: ts.URL, Path: "secret/data/myapp", RetryBackoff: "1ms", Auth: VaultAuth{Token: "root"}})
+
+	// WHEN the secret is fetched
+	value, err := g()
+
+	// THEN the request is retried until it succeeds
+	require.NoError(t, err)
+	assert.Equal(t, data.InterfaceMap{"user": "admin", "password": "secret"}, value)
+	assert.Len(t, fv.namespaces, 3)
+
+	// AND WHEN the retries are disabled
+	fv.failures = 1
+	g = VaultGatherer(&Vault{Address: ts.URL, Path: "secret/data/myapp", Retries: noRetries(), Auth: VaultAuth{Token: "root"}})
+	_, err = g()
+
+	// THEN the error is returned
+	assert.Error(t, err)
+}
+
+func TestVault_Validate(t *testing.T) {
+	cases := []struct {
+		name  string
+		vault Vault
+		valid bool
+	}{
+		{"http", Vault{HTTP: &http{URL: "http://vault"}}, true},
+		{"address", Vault{Address: "http://vault", Path: "secret/myapp"}, true},
+		{"approle", Vault{Address: "http://vault", Path: "secret/myapp", Auth: VaultAuth{AppRole: &VaultAppRoleAuth{RoleID: "r"}}}, true},
+		{"missing source", Vault{}, false},
+		{"http and address", Vault{HTTP: &http{URL: "http://vault"}, Address: "http://vault"}, false},
+		{"missing path", Vault{Address: "http://vault"}, false},
+		{"wrong kv version", Vault{Address: "http://vault", Path: "secret/myapp", KVVersion: 3}, false},
+		{"wrong backoff", Vault{Address: "http://vault", Path: "secret/myapp", RetryBackoff: "soon"}, false},
+		{"missing role_id", Vault{Address: "http://vault", Path: "p", Auth: VaultAuth{AppRole: &VaultAppRoleAuth{}}}, false},
+		{"missing k8s role", Vault{Address: "http://vault", Path: "p", Auth: VaultAuth{Kubernetes: &VaultKubernetesAuth{}}}, false},
+		{"missing cert files", Vault{Address: "http://vault", Path: "p", Auth: VaultAuth{Cert: &VaultCertAuth{}}}, false},
+		{"two auth methods", Vault{Address: "http://vault", Path: "p", Auth: VaultAuth{Token: "t", Kubernetes: &VaultKubernetesAuth{Role: "r"}}}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.vault.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
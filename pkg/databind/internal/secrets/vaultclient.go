@@ -0,0 +1,359 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	gohttp "net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+const (
+	vaultTokenEnvVar         = "VAULT_TOKEN"
+	vaultTokenHeader         = "X-Vault-Token"
+	vaultNamespaceHeader     = "X-Vault-Namespace"
+	vaultRequestTimeout      = 30 * time.Second
+	defaultKubernetesJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// vaultClient performs authenticated requests to the Vault HTTP API, logging in again or renewing the
+// token before it expires.
+type vaultClient struct {
+	address   string
+	namespace string
+	auth      VaultAuth
+	http      *gohttp.Client
+	retries   int
+	backoff   time.Duration
+	now       func() time.Time
+
+	token          string
+	tokenRenewable bool
+	tokenRenewAt   time.Time // zero for tokens that are not renewed (e.g. static tokens)
+	tokenExpires   time.Time
+}
+
+// vaultSecret is the common response of the Vault API, for both secrets and logins.
+type vaultSecret struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *vaultSecretAuth       `json:"auth"`
+}
+
+type vaultSecretAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+// vaultError is an error response of the Vault API.
+type vaultError struct {
+	status int
+	errors []string
+}
+
+func (e *vaultError) Error() string {
+	if len(e.errors) == 0 {
+		return fmt.Sprintf("error response received from vault: %d", e.status)
+	}
+	return fmt.Sprintf("error response received from vault: %d: %s", e.status, strings.Join(e.errors, ", "))
+}
+
+func newVaultClient(cfg *Vault, now func() time.Time) (*vaultClient, error) {
+	tlsConfig, err := newTLSConfig(cfg.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Auth.Cert != nil {
+		cert, err := tls.LoadX509KeyPair(cfg.Auth.Cert.CertFile, cfg.Auth.Cert.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	retries := defaultVaultRetries
+	if cfg.Retries != nil {
+		retries = *cfg.Retries
+	}
+	backoff := defaultVaultRetryBackoff
+	if cfg.RetryBackoff != "" {
+		if backoff, err = time.ParseDuration(cfg.RetryBackoff); err != nil {
+			return nil, err
+		}
+	}
+
+	return &vaultClient{
+		address:   strings.TrimRight(cfg.Address, "/"),
+		namespace: cfg.Namespace,
+		auth:      cfg.Auth,
+		http: &gohttp.Client{
+			Timeout:   vaultRequestTimeout,
+			Transport: &gohttp.Transport{TLSClientConfig: tlsConfig},
+		},
+		retries: retries,
+		backoff: backoff,
+		now:     now,
+	}, nil
+}
+
+// read returns the secret stored in the given path.
+func (c *vaultClient) read(path string) (*vaultSecret, error) {
+	return c.authenticated("GET", path, nil)
+}
+
+// renewLease extends the lease of a dynamic secret for the given duration.
+func (c *vaultClient) renewLease(leaseID string, increment time.Duration) (*vaultSecret, error) {
+	return c.authenticated("PUT", "sys/leases/renew", map[string]interface{}{
+		"lease_id":  leaseID,
+		"increment": int(increment.Seconds()),
+	})
+}
+
+// authenticated performs a request with a valid token. If the token is rejected (e.g. it has been
+// revoked), the client logs in again and retries the request once.
+func (c *vaultClient) authenticated(method, path string, body interface{}) (*vaultSecret, error) {
+	if err := c.ensureToken(); err != nil {
+		return nil, err
+	}
+	secret, err := c.request(method, path, c.token, body)
+	if verr, ok := err.(*vaultError); ok && verr.status == gohttp.StatusForbidden {
+		previous := c.token
+		if lerr := c.login(); lerr != nil || c.token == previous {
+			return nil, err
+		}
+		return c.request(method, path, c.token, body)
+	}
+	return secret, err
+}
+
+// ensureToken logs in, or renews the current token, when there is no valid token.
+func (c *vaultClient) ensureToken() error {
+	now := c.now()
+	if c.token != "" && (c.tokenRenewAt.IsZero() || now.Before(c.tokenRenewAt)) {
+		return nil
+	}
+	if c.token != "" && c.tokenRenewable && now.Before(c.tokenExpires) {
+		secret, err := c.request("PUT", "auth/token/renew-self", c.token, nil)
+		if err == nil && secret.Auth != nil && secret.Auth.ClientToken != "" {
+			c.setToken(secret.Auth)
+			return nil
+		}
+		slog.WithError(err).Debug("Unable to renew vault token. Logging in again.")
+	}
+	return c.login()
+}
+
+func (c *vaultClient) setToken(auth *vaultSecretAuth) {
+	c.token = auth.ClientToken
+	c.tokenRenewable = auth.Renewable
+	c.tokenRenewAt, c.tokenExpires = time.Time{}, time.Time{}
+	if auth.LeaseDuration > 0 {
+		lease := time.Duration(auth.LeaseDuration) * time.Second
+		now := c.now()
+		c.tokenRenewAt = now.Add(renewalTTL(lease))
+		c.tokenExpires = now.Add(lease)
+	}
+}
+
+// login gets a new token from the configured authentication method.
+func (c *vaultClient) login() error {
+	var mount string
+	var body map[string]interface{}
+	switch {
+	case c.auth.AppRole != nil:
+		mount = mountOrDefault(c.auth.AppRole.Mount, defaultAppRoleMount)
+		secretID := c.auth.AppRole.SecretID
+		if c.auth.AppRole.SecretIDFile != "" {
+			var err error
+			if secretID, err = readTrimmed(c.auth.AppRole.SecretIDFile); err != nil {
+				return fmt.Errorf("unable to read approle secret_id_file: %s", err)
+			}
+		}
+		body = map[string]interface{}{"role_id": c.auth.AppRole.RoleID, "secret_id": secretID}
+	case c.auth.Kubernetes != nil:
+		mount = mountOrDefault(c.auth.Kubernetes.Mount, defaultKubernetesMount)
+		tokenFile := c.auth.Kubernetes.TokenFile
+		if tokenFile == "" {
+			tokenFile = defaultKubernetesJWTFile
+		}
+		jwt, err := readTrimmed(tokenFile)
+		if err != nil {
+			return fmt.Errorf("unable to read kubernetes service account token: %s", err)
+		}
+		body = map[string]interface{}{"role": c.auth.Kubernetes.Role, "jwt": jwt}
+	case c.auth.Cert != nil:
+		mount = mountOrDefault(c.auth.Cert.Mount, defaultCertMount)
+		body = map[string]interface{}{}
+		if c.auth.Cert.Name != "" {
+			body["name"] = c.auth.Cert.Name
+		}
+	default:
+		return c.staticToken()
+	}
+
+	secret, err := c.request("POST", "auth/"+mount+"/login", "", body)
+	if err != nil {
+		return fmt.Errorf("unable to login into vault: %s", err)
+	}
+	if secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return errors.New("unable to login into vault: missing client token in response")
+	}
+	c.setToken(secret.Auth)
+	return nil
+}
+
+// staticToken reads the token from the configuration, the token file or the environment.
+func (c *vaultClient) staticToken() error {
+	token := c.auth.Token
+	if c.auth.TokenFile != "" {
+		var err error
+		if token, err = readTrimmed(c.auth.TokenFile); err != nil {
+			return fmt.Errorf("unable to read vault token_file: %s", err)
+		}
+	}
+	if token == "" {
+		token = os.Getenv(vaultTokenEnvVar)
+	}
+	if token == "" {
+		return errors.New("missing vault token")
+	}
+	c.setToken(&vaultSecretAuth{ClientToken: token})
+	return nil
+}
+
+// request performs a Vault API request, retrying with exponential backoff on connection errors,
+// throttling and server errors.
+func (c *vaultClient) request(method, path, token string, body interface{}) (*vaultSecret, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		secret, err := c.do(method, path, token, payload)
+		if err == nil || attempt >= c.retries || !retryable(err) {
+			return secret, err
+		}
+		slog.WithError(err).WithField("path", path).WithField("attempt", attempt+1).
+			Debug("Vault request failed. Retrying.")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (c *vaultClient) do(method, path, token string, payload []byte) (*vaultSecret, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := gohttp.NewRequest(method, c.address+"/v1/"+strings.TrimLeft(path, "/"), body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create http request: %s", err)
+	}
+	if token != "" {
+		req.Header.Set(vaultTokenHeader, token)
+	}
+	if c.namespace != "" {
+		req.Header.Set(vaultNamespaceHeader, c.namespace)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send http request: %s", err)
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			slog.WithError(err).Warn("Unable to close response body")
+		}
+	}()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read http response body: %s", err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		verr := &vaultError{status: res.StatusCode}
+		var errResponse struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(b, &errResponse) == nil {
+			verr.errors = errResponse.Errors
+		}
+		return nil, verr
+	}
+
+	secret := &vaultSecret{}
+	if len(b) == 0 {
+		return secret, nil
+	}
+	if err := json.Unmarshal(b, secret); err != nil {
+		return nil, fmt.Errorf("unable to decode vault response: %s", err)
+	}
+	return secret, nil
+}
+
+// retryable returns whether the failed request can succeed if retried.
+func retryable(err error) bool {
+	verr, ok := err.(*vaultError)
+	if !ok {
+		// connection errors
+		return true
+	}
+	return verr.status == gohttp.StatusTooManyRequests || verr.status >= 500
+}
+
+// values returns the secret data. Data from KV v2 engines is returned without its metadata.
+func (s *vaultSecret) values(kvVersion int) (data.InterfaceMap, error) {
+	if s.Data == nil {
+		return nil, errors.New("missing secret data")
+	}
+	inner, isMap := s.Data["data"].(map[string]interface{})
+	_, hasMetadata := s.Data["metadata"]
+	if kvVersion == 2 || (kvVersion == 0 && isMap && hasMetadata) {
+		if !isMap {
+			return nil, errors.New("missing kv v2 secret data")
+		}
+		return inner, nil
+	}
+	return s.Data, nil
+}
+
+func (s *vaultSecret) leaseDuration() time.Duration {
+	return time.Duration(s.LeaseDuration) * time.Second
+}
+
+func mountOrDefault(mount, def string) string {
+	mount = strings.Trim(mount, "/")
+	if mount == "" {
+		return def
+	}
+	return mount
+}
+
+func readTrimmed(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
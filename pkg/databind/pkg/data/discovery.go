@@ -12,6 +12,8 @@ import (
 const (
 	DiscoveryPrefix             = "discovery."
 	LabelInfix                  = "label."
+	AnnotationInfix             = "annotation."
+	PropertyInfix               = "property."
 	ContainerReplaceFieldPrefix = "container:"
 
 	Port                       = "port"
@@ -28,6 +30,17 @@ const (
 	Label                      = "label"
 	Command                    = "command"
 	DockerContainerName        = "dockerContainerName"
+	PodName                    = "podName"
+	Namespace                  = "namespace"
+	Pid                        = "pid"
+	Cmdline                    = "cmdline"
+	User                       = "user"
+	Template                   = "template"
+	Instance                   = "instance"
+	State                      = "state"
+	SubState                   = "subState"
+	LoadState                  = "loadState"
+	EnvironmentFiles           = "environmentFiles"
 	EntityRewriteActionReplace = "replace"
 )
 
@@ -4,6 +4,9 @@
 package databind
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
 	"time"
 
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
@@ -47,6 +50,54 @@ func (v *Values) VarsLen() int {
 	return len(v.vars)
 }
 
+// VarsHash returns a hash of the values of the provided variables (e.g. the ones referenced by an integration),
+// which allows detecting when they change (e.g. a rotated password) without keeping nor logging the values
+// themselves.
+func (v *Values) VarsHash(names []string) string {
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+	sort.Strings(sorted)
+	h := sha256.New()
+	for i, name := range sorted {
+		if i > 0 && name == sorted[i-1] {
+			continue
+		}
+		value, ok := v.vars[name]
+		if !ok {
+			continue
+		}
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(value))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Discovered returns the variables of each discovered match (e.g. "discovery.containerId"), for the
+// consumers that need them without a template to replace.
+func (v *Values) Discovered() []data.Map {
+	discovered := make([]data.Map, 0, len(v.discov))
+	for _, d := range v.discov {
+		discovered = append(discovered, d.Variables)
+	}
+	return discovered
+}
+
+// VarsTTL returns the shortest Time-To-Live of the user-defined variables, or zero if there
+// are no variables. The TTL of leased values (e.g. Vault dynamic secrets) is used when it's shorter
+// than the configured one.
+func (s *Sources) VarsTTL() time.Duration {
+	var ttl time.Duration
+	for _, g := range s.variables {
+		gttl := g.ttl()
+		if ttl == 0 || gttl < ttl {
+			ttl = gttl
+		}
+	}
+	return ttl
+}
+
 // Fetch queries the Sources for discovery data and user-defined variables, and returns the
 // acquired Values.
 func Fetch(ctx *Sources) (Values, error) {
@@ -60,15 +111,27 @@ func Fetch(ctx *Sources) (Values, error) {
 		vals.discov = matches
 	}
 
+	err := fetchVars(ctx, now, vals.vars)
+	return vals, err
+}
+
+// FetchVars queries only the user-defined variables, without running the discovery, and returns the
+// acquired Values.
+func FetchVars(ctx *Sources) (Values, error) {
+	vals := NewValues(data.Map{})
+	err := fetchVars(ctx, ctx.clock(), vals.vars)
+	return vals, err
+}
+
+func fetchVars(ctx *Sources, now time.Time, vars data.Map) error {
 	for varName, gatherer := range ctx.variables {
 		value, err := gatherer.do(now)
 		if err != nil {
-			return vals, err
+			return err
 		}
-		data.AddValues(vals.vars, varName, value)
+		data.AddValues(vars, varName, value)
 	}
-
-	return vals, nil
+	return nil
 }
 
 // Binder wraps the functions provided by this package
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/secrets"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
 
 	"github.com/stretchr/testify/assert"
@@ -98,3 +99,169 @@ func TestContextCache(t *testing.T) {
 	result = fetch()
 	assert.Equal(t, fetched{"bye", "bye", "bye"}, result)
 }
+
+func TestContextCache_Leased(t *testing.T) {
+	now := time.Now()
+	value := "user-1"
+	leasedFetch := func() (interface{}, error) {
+		return secrets.Leased{Value: map[string]string{"user": value}, TTL: 10 * time.Minute}, nil
+	}
+	fetch := func(ctx *Sources) string {
+		b := New()
+		vals, err := b.Fetch(ctx)
+		require.NoError(t, err)
+		matches, err := b.Replace(&vals, "${creds.user}")
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+		return matches[0].Variables.(string)
+	}
+
+	// GIVEN a variable with a 1-hour TTL whose value is leased for 10 minutes
+	ctx := Sources{
+		clock: func() time.Time { return now },
+		variables: map[string]*gatherer{
+			"creds": {cache: cachedEntry{ttl: time.Hour}, fetch: leasedFetch},
+		},
+	}
+	// WHEN the data is fetched for the first time
+	// THEN the leased value is returned
+	assert.Equal(t, "user-1", fetch(&ctx))
+
+	// AND when the data is fetched before the lease TTL expires
+	value = "user-2"
+	now = now.Add(9 * time.Minute)
+	// THEN the value is not updated
+	assert.Equal(t, "user-1", fetch(&ctx))
+
+	// AND when the lease TTL expires
+	now = now.Add(2 * time.Minute)
+	// THEN the value is fetched again, even if the variable TTL hasn't expired
+	assert.Equal(t, "user-2", fetch(&ctx))
+
+	// AND when the lease is longer than the variable TTL
+	ctx.variables["creds"] = &gatherer{cache: cachedEntry{ttl: 5 * time.Minute}, fetch: leasedFetch}
+	assert.Equal(t, "user-2", fetch(&ctx))
+	value = "user-3"
+	now = now.Add(6 * time.Minute)
+	// THEN the variable TTL applies
+	assert.Equal(t, "user-3", fetch(&ctx))
+}
+
+func TestValues_VarsHash(t *testing.T) {
+	names := []string{"user", "password"}
+	// GIVEN two values with the same variables inserted in different order
+	a := NewValues(data.Map{"user": "admin", "password": "secret"})
+	b := NewValues(data.Map{"password": "secret", "user": "admin"})
+	// THEN their hashes are equal
+	assert.Equal(t, a.VarsHash(names), b.VarsHash(names))
+	// AND the hash doesn't contain the values
+	assert.NotContains(t, a.VarsHash(names), "secret")
+
+	// WHEN a variable value changes
+	c := NewValues(data.Map{"user": "admin", "password": "rotated"})
+	// THEN the hash changes
+	assert.NotEqual(t, a.VarsHash(names), c.VarsHash(names))
+	// BUT NOT the hash of the other variables
+	assert.Equal(t, a.VarsHash([]string{"user"}), c.VarsHash([]string{"user"}))
+
+	// AND the hash doesn't mix up names and values
+	d := NewValues(data.Map{"a": "bc"})
+	e := NewValues(data.Map{"ab": "c"})
+	assert.NotEqual(t, d.VarsHash([]string{"a", "ab"}), e.VarsHash([]string{"a", "ab"}))
+}
+
+func TestSources_VarsTTL(t *testing.T) {
+	// GIVEN sources without variables
+	// THEN they have no variables TTL
+	assert.Zero(t, (&Sources{}).VarsTTL())
+
+	// GIVEN sources with many variables
+	ctx := Sources{
+		variables: map[string]*gatherer{
+			"a": {cache: cachedEntry{ttl: time.Hour}},
+			"b": {cache: cachedEntry{ttl: 5 * time.Minute}},
+			"c": {cache: cachedEntry{ttl: 30 * time.Minute}},
+		},
+	}
+	// THEN the variables TTL is the shortest one
+	assert.Equal(t, 5*time.Minute, ctx.VarsTTL())
+
+	// WHEN a variable is leased for a shorter time
+	ctx.variables["a"].cache.setFor("value", time.Now(), time.Minute)
+	// THEN the lease TTL is the variables TTL
+	assert.Equal(t, time.Minute, ctx.VarsTTL())
+}
+
+func TestFetchVars(t *testing.T) {
+	// GIVEN sources with discovery and variables
+	discoveries := 0
+	ctx := Sources{
+		clock: time.Now,
+		discoverer: &discoverer{fetch: func() ([]discovery.Discovery, error) {
+			discoveries++
+			return nil, nil
+		}},
+		variables: map[string]*gatherer{
+			"creds": {fetch: func() (interface{}, error) {
+				return map[string]interface{}{"user": "admin"}, nil
+			}},
+		},
+	}
+
+	// WHEN only the variables are fetched
+	vals, err := FetchVars(&ctx)
+	require.NoError(t, err)
+
+	// THEN the variables are returned
+	assert.Equal(t, data.Map{"creds.user": "admin"}, vals.vars)
+	// AND the discovery is not run
+	assert.Zero(t, discoveries)
+}
+
+func TestFetch_Concurrent(t *testing.T) {
+	// GIVEN sources with discovery and short-lived variables
+	ctx := Sources{
+		clock: time.Now,
+		discoverer: &discoverer{fetch: func() ([]discovery.Discovery, error) {
+			return []discovery.Discovery{NewDiscovery(data.Map{"discovery.ip": "1.2.3.4"}, nil, nil)}, nil
+		}},
+		variables: map[string]*gatherer{
+			"creds": {cache: cachedEntry{ttl: time.Nanosecond}, fetch: func() (interface{}, error) {
+				return map[string]interface{}{"user": "admin"}, nil
+			}},
+		},
+	}
+
+	// WHEN the variables are fetched while the sources are also being fetched (e.g. to watch their rotation)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, err := FetchVars(&ctx)
+			assert.NoError(t, err)
+			ctx.VarsTTL()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_, err := Fetch(&ctx)
+		assert.NoError(t, err)
+	}
+	<-done
+
+	// THEN the cached values are not raced (verified with -race)
+	vals, err := FetchVars(&ctx)
+	require.NoError(t, err)
+	assert.Equal(t, data.Map{"creds.user": "admin"}, vals.vars)
+}
+
+func TestValues_Discovered(t *testing.T) {
+	vals := NewValues(data.Map{"secret": "value"},
+		NewDiscovery(data.Map{"discovery.containerId": "abc", "discovery.name": "nginx"}, nil, nil),
+		NewDiscovery(data.Map{"discovery.containerId": "def"}, nil, nil),
+	)
+
+	assert.Equal(t, []data.Map{
+		{"discovery.containerId": "abc", "discovery.name": "nginx"},
+		{"discovery.containerId": "def"},
+	}, vals.Discovered())
+}
@@ -4,21 +4,24 @@
 package databind
 
 import (
+	"sync"
 	"time"
 
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/secrets"
 )
 
 // cachedEntry allows storing a value for a given Time-To-Leave
 type cachedEntry struct {
-	ttl    time.Duration
-	time   time.Time // time the object has been stored
-	stored interface{}
+	ttl      time.Duration
+	time     time.Time     // time the object has been stored
+	validFor time.Duration // ttl of the stored object
+	stored   interface{}
 }
 
 //
 func (c *cachedEntry) get(now time.Time) (interface{}, bool) {
-	if c.stored != nil && c.time.Add(c.ttl).After(now) {
+	if c.stored != nil && c.time.Add(c.validFor).After(now) {
 		return c.stored, true
 	}
 	c.stored = nil
@@ -26,18 +29,27 @@ func (c *cachedEntry) get(now time.Time) (interface{}, bool) {
 }
 
 func (c *cachedEntry) set(value interface{}, now time.Time) {
+	c.setFor(value, now, c.ttl)
+}
+
+// setFor stores a value for a ttl other than the configured one
+func (c *cachedEntry) setFor(value interface{}, now time.Time, ttl time.Duration) {
 	c.stored = value
 	c.time = now
+	c.validFor = ttl
 }
 
 // discoverer is any source discovering multiple matches from a source (e.g. containers)
 type discoverer struct {
+	lock  sync.Mutex // guards the cache, as the sources may be fetched concurrently
 	cache cachedEntry
 	// any discovery source must provide a function of this signature
 	fetch func() ([]discovery.Discovery, error)
 }
 
 func (d *discoverer) do(now time.Time) ([]discovery.Discovery, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
 	if vals, ok := d.cache.get(now); ok {
 		return vals.([]discovery.Discovery), nil
 	}
@@ -51,12 +63,15 @@ func (d *discoverer) do(now time.Time) ([]discovery.Discovery, error) {
 
 // gatherer is any source fetching a single match from a variables source (e.g. a vault key)
 type gatherer struct {
+	lock  sync.Mutex // guards the cache, as the sources may be fetched concurrently
 	cache cachedEntry
 	// can return a single string, but also maps or arrays
 	fetch func() (interface{}, error)
 }
 
 func (d *gatherer) do(now time.Time) (interface{}, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
 	if vals, ok := d.cache.get(now); ok {
 		return vals, nil
 	}
@@ -64,6 +79,25 @@ func (d *gatherer) do(now time.Time) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	// leased values (e.g. dynamic secrets) must be fetched again before their lease expires
+	if leased, ok := vals.(secrets.Leased); ok {
+		vals = leased.Value
+		if leased.TTL < d.cache.ttl {
+			d.cache.setFor(vals, now, leased.TTL)
+			return vals, nil
+		}
+	}
 	d.cache.set(vals, now)
 	return vals, nil
 }
+
+// ttl returns the Time-To-Live of the gathered values, which is the lease TTL of the stored value
+// when it's shorter than the configured one.
+func (d *gatherer) ttl() time.Duration {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.cache.stored != nil && d.cache.validFor > 0 && d.cache.validFor < d.cache.ttl {
+		return d.cache.validFor
+	}
+	return d.cache.ttl
+}
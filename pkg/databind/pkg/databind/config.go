@@ -12,8 +12,13 @@ import (
 	yaml "gopkg.in/yaml.v2"
 
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/cri"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/docker"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/fargate"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/kubernetes"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/podman"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/process"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery/systemd"
 	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/secrets"
 )
 
@@ -29,10 +34,15 @@ type YAMLAgentConfig struct {
 type YAMLConfig struct {
 	YAMLAgentConfig `yaml:",inline"`
 	Discovery       struct {
-		TTL     string               `yaml:"ttl,omitempty"`
-		Docker  *discovery.Container `yaml:"docker,omitempty"`
-		Fargate *discovery.Container `yaml:"fargate,omitempty"`
-		Command *discovery.Command   `yaml:"command,omitempty"`
+		TTL        string               `yaml:"ttl,omitempty"`
+		Docker     *discovery.Container `yaml:"docker,omitempty"`
+		Fargate    *discovery.Container `yaml:"fargate,omitempty"`
+		Command    *discovery.Command   `yaml:"command,omitempty"`
+		Kubernetes *discovery.Kubelet   `yaml:"kubernetes,omitempty"`
+		Process    *discovery.Process   `yaml:"process,omitempty"`
+		CRI        *discovery.Container `yaml:"cri,omitempty"`
+		Podman     *discovery.Container `yaml:"podman,omitempty"`
+		Systemd    *discovery.Systemd   `yaml:"systemd,omitempty"`
 	} `yaml:"discovery"`
 }
 
@@ -40,7 +50,12 @@ func (y *YAMLConfig) Enabled() bool {
 	return len(y.Variables) > 0 ||
 		y.Discovery.Docker != nil ||
 		y.Discovery.Fargate != nil ||
-		y.Discovery.Command != nil
+		y.Discovery.Command != nil ||
+		y.Discovery.Kubernetes != nil ||
+		y.Discovery.Process != nil ||
+		y.Discovery.CRI != nil ||
+		y.Discovery.Podman != nil ||
+		y.Discovery.Systemd != nil
 }
 
 type varEntry struct {
@@ -51,6 +66,12 @@ type varEntry struct {
 	CyberArkCLI *secrets.CyberArkCLI `yaml:"cyberark-cli,omitempty" json:"cyberark-cli,omitempty"`
 	CyberArkAPI *secrets.CyberArkAPI `yaml:"cyberark-api,omitempty" json:"cyberark-api,omitempty"`
 	Obfuscated  *secrets.Obfuscated  `yaml:"obfuscated,omitempty" json:"obfuscated,omitempty"`
+
+	AWSSecretsManager *secrets.AWSSecretsManager `yaml:"aws-secrets-manager,omitempty" json:"aws-secrets-manager,omitempty"`
+	GCPSecretManager  *secrets.GCPSecretManager  `yaml:"gcp-secret-manager,omitempty" json:"gcp-secret-manager,omitempty"`
+	AzureKeyVault     *secrets.AzureKeyVault     `yaml:"azure-key-vault,omitempty" json:"azure-key-vault,omitempty"`
+	File              *secrets.LocalFile         `yaml:"file,omitempty" json:"file,omitempty"`
+	Exec              *secrets.Exec              `yaml:"exec,omitempty" json:"exec,omitempty"`
 }
 
 // Test for testing purposes until providers get decoupled.
@@ -155,6 +176,41 @@ func (dc *YAMLConfig) selectDiscoverer(ttl time.Duration) (*discoverer, error) {
 			fetch: fetch,
 		}, err
 
+	} else if dc.Discovery.Kubernetes != nil {
+		fetch, err := kubernetes.Discoverer(*dc.Discovery.Kubernetes)
+		return &discoverer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: fetch,
+		}, err
+
+	} else if dc.Discovery.Process != nil {
+		fetch, err := process.Discoverer(*dc.Discovery.Process)
+		return &discoverer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: fetch,
+		}, err
+
+	} else if dc.Discovery.CRI != nil {
+		fetch, err := cri.Discoverer(*dc.Discovery.CRI)
+		return &discoverer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: fetch,
+		}, err
+
+	} else if dc.Discovery.Podman != nil {
+		fetch, err := podman.Discoverer(*dc.Discovery.Podman)
+		return &discoverer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: fetch,
+		}, err
+
+	} else if dc.Discovery.Systemd != nil {
+		fetch, err := systemd.Discoverer(*dc.Discovery.Systemd)
+		return &discoverer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: fetch,
+		}, err
+
 	}
 	return nil, nil
 }
@@ -181,6 +237,41 @@ func (y *YAMLConfig) validate() error {
 		}
 	}
 
+	if y.Discovery.Kubernetes != nil {
+		sections++
+		if err := y.Discovery.Kubernetes.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if y.Discovery.Process != nil {
+		sections++
+		if err := y.Discovery.Process.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if y.Discovery.CRI != nil {
+		sections++
+		if err := y.Discovery.CRI.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if y.Discovery.Podman != nil {
+		sections++
+		if err := y.Discovery.Podman.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if y.Discovery.Systemd != nil {
+		sections++
+		if err := y.Discovery.Systemd.Validate(); err != nil {
+			return err
+		}
+	}
+
 	if sections > 1 {
 		return errors.New("only one discovery source allowed")
 	}
@@ -236,8 +327,38 @@ func (v *varEntry) validate() error {
 			return err
 		}
 	}
+	if v.AWSSecretsManager != nil {
+		sections++
+		if err := v.AWSSecretsManager.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.GCPSecretManager != nil {
+		sections++
+		if err := v.GCPSecretManager.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.AzureKeyVault != nil {
+		sections++
+		if err := v.AzureKeyVault.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.File != nil {
+		sections++
+		if err := v.File.Validate(); err != nil {
+			return err
+		}
+	}
+	if v.Exec != nil {
+		sections++
+		if err := v.Exec.Validate(); err != nil {
+			return err
+		}
+	}
 	if sections == 0 {
-		return errors.New("you should specify one source to gather the variable: aws-kms or vault or cyberark-cli")
+		return errors.New("you should specify one source to gather the variable: aws-kms, aws-secrets-manager, gcp-secret-manager, azure-key-vault, vault, cyberark-cli, file or exec")
 	}
 	if sections > 1 {
 		return errors.New("you can't specify more than one source into a single variable. Use another variable")
@@ -274,6 +395,31 @@ func (v *varEntry) selectGatherer(ttl time.Duration) *gatherer {
 			cache: cachedEntry{ttl: ttl},
 			fetch: secrets.ObfuscateGatherer(v.Obfuscated),
 		}
+	} else if v.AWSSecretsManager != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.AWSSecretsManagerGatherer(v.AWSSecretsManager),
+		}
+	} else if v.GCPSecretManager != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.GCPSecretManagerGatherer(v.GCPSecretManager),
+		}
+	} else if v.AzureKeyVault != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.AzureKeyVaultGatherer(v.AzureKeyVault),
+		}
+	} else if v.File != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.LocalFileGatherer(v.File),
+		}
+	} else if v.Exec != nil {
+		return &gatherer{
+			cache: cachedEntry{ttl: ttl},
+			fetch: secrets.ExecGatherer(v.Exec),
+		}
 	} else if v.Test != nil {
 		return &gatherer{
 			cache: cachedEntry{ttl: ttl},
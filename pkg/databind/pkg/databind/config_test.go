@@ -26,6 +26,55 @@ variables:
     vault:
       http:
         url: http://www.example.com
+`}, {"vault api variable", `
+variables:
+  myData:
+    vault:
+      address: https://vault.example.com:8200
+      namespace: team-a
+      path: database/creds/readonly
+      retries: 5
+      retry_backoff: 500ms
+      auth:
+        approle:
+          role_id: my-role
+          secret_id_file: /etc/newrelic-infra/vault-secret-id
+`}, {"aws-secrets-manager variable", `
+variables:
+  myData:
+    aws-secrets-manager:
+      secret_id: prod/db
+      region: us-east-1
+      type: json
+`}, {"gcp-secret-manager variable", `
+variables:
+  myData:
+    gcp-secret-manager:
+      project: my-project
+      secret: db-password
+`}, {"azure-key-vault variable", `
+variables:
+  myData:
+    azure-key-vault:
+      vault_url: https://my-vault.vault.azure.net
+      secret: db-password
+      type: equal
+`}, {"file variable", `
+variables:
+  myData:
+    file:
+      path: /run/secrets/db.yml
+      type: yaml
+      key: database.password
+`}, {"exec variable", `
+variables:
+  myData:
+    ttl: 10m
+    exec:
+      command: sops
+      args: ["-d", "--output-type", "json", "/etc/secrets/db.enc.json"]
+      timeout: 5s
+      type: json
 `}, {"simple cyberark-cli variable", `
 variables:
   myData:
@@ -41,6 +90,36 @@ variables:
     cyberark-api:
       http:
         url: https://10.1.0.5/AIMWebService/api/Accounts?AppID=NewRelic&Query=Safe=ALL-NERE-WIN-A-NEWRELIC-UP;Object=ALL-localhost-testuser
+`}, {"kubernetes discovery", `
+discovery:
+  kubernetes:
+    url: https://10.0.0.1:10250
+    insecure_skip_verify: true
+    match:
+      label.app: redis
+`}, {"process discovery", `
+discovery:
+  process:
+    match:
+      name: redis-server
+`}, {"cri discovery", `
+discovery:
+  cri:
+    socket: /run/containerd/containerd.sock
+    match:
+      name: redis
+`}, {"podman discovery", `
+discovery:
+  podman:
+    match:
+      image: /redis/
+`}, {"systemd discovery", `
+discovery:
+  systemd:
+    units:
+      - postgresql@*.service
+    match:
+      state: active
 `}}
 	for _, input := range inputs {
 		t.Run(input.description, func(t *testing.T) {
@@ -97,7 +176,70 @@ variables:
     cyberark-api:
       http:
         url: 
-      `}}
+      `}, {"vault variable without path", `
+variables:
+  myData:
+    vault:
+      address: https://vault.example.com:8200
+`}, {"vault variable with two auth methods", `
+variables:
+  myData:
+    vault:
+      address: https://vault.example.com:8200
+      path: secret/myapp
+      auth:
+        token: s.1234
+        kubernetes:
+          role: agent
+`}, {"aws-secrets-manager variable without secret_id", `
+variables:
+  myData:
+    aws-secrets-manager:
+      region: us-east-1
+`}, {"azure-key-vault variable without vault_url", `
+variables:
+  myData:
+    azure-key-vault:
+      secret: db-password
+`}, {"gcp-secret-manager variable with wrong type", `
+variables:
+  myData:
+    gcp-secret-manager:
+      secret: db-password
+      type: xml
+`}, {"file variable with key and plain type", `
+variables:
+  myData:
+    file:
+      path: /run/secrets/db
+      key: password
+`}, {"exec variable without command", `
+variables:
+  myData:
+    exec:
+      args: ["show", "db"]
+`}, {"kubernetes discovery without match", `
+discovery:
+  kubernetes:
+    url: https://10.0.0.1:10250
+`}, {"process discovery without match", `
+discovery:
+  process:
+    match: {}
+`}, {"systemd discovery without match", `
+discovery:
+  systemd:
+    units:
+      - postgresql@*.service
+`}, {"kubernetes and docker discovery", `
+discovery:
+  kubernetes:
+    match:
+      label.app: redis
+  docker:
+    match:
+      image: redis
+`}}
 	for _, input := range inputs {
 		t.Run(input.description, func(t *testing.T) {
 			_, err := LoadYAML([]byte(input.yaml))
@@ -0,0 +1,191 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package databind
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templateFunction transforms a variable value. If the variable has not been found, the received
+// value is empty and found is false.
+type templateFunction func(value string, found bool, args []string) (result string, resFound bool, err error)
+
+// functions that can be applied to a variable through the ${variable | function "arg"} syntax
+var templateFunctions = map[string]templateFunction{
+	"default":  defaultFunc,
+	"upper":    transformFunc(strings.ToUpper),
+	"lower":    transformFunc(strings.ToLower),
+	"trim":     transformFunc(strings.TrimSpace),
+	"urlquery": transformFunc(url.QueryEscape),
+	"base64": transformFunc(func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	}),
+	"jsonpath": jsonPathFunc,
+}
+
+// functionCall is each of the functions in a ${variable | function "arg" | ...} pipeline
+type functionCall struct {
+	name string
+	args []string
+}
+
+// parseExpression splits the content of a variable mark into the variable name and the pipeline of
+// functions that are applied to its value.
+func parseExpression(expression string) (varName string, pipeline []functionCall, err error) {
+	segments, err := splitPipeline(expression)
+	if err != nil {
+		return "", nil, err
+	}
+	varName = strings.TrimSpace(segments[0])
+	for _, segment := range segments[1:] {
+		tokens, err := tokenize(segment)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(tokens) == 0 {
+			return "", nil, fmt.Errorf("empty function in %q", expression)
+		}
+		if _, ok := templateFunctions[tokens[0]]; !ok {
+			return "", nil, fmt.Errorf("unknown function %q in %q", tokens[0], expression)
+		}
+		pipeline = append(pipeline, functionCall{name: tokens[0], args: tokens[1:]})
+	}
+	return varName, pipeline, nil
+}
+
+// applyPipeline passes the variable value through all the functions of the pipeline
+func applyPipeline(value string, found bool, pipeline []functionCall) (string, bool, error) {
+	var err error
+	for _, call := range pipeline {
+		value, found, err = templateFunctions[call.name](value, found, call.args)
+		if err != nil {
+			return "", false, fmt.Errorf("function %q: %s", call.name, err)
+		}
+	}
+	return value, found, nil
+}
+
+// splitPipeline splits the expression by the '|' characters that are not quoted
+func splitPipeline(expression string) ([]string, error) {
+	var segments []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(expression); i++ {
+		switch expression[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '|':
+			if !quoted {
+				segments = append(segments, expression[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quoted string in %q", expression)
+	}
+	return append(segments, expression[start:]), nil
+}
+
+var tokenRegex = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|[^\s"]+`)
+
+// tokenize splits a function call into its name and arguments, which may be quoted strings
+func tokenize(call string) ([]string, error) {
+	tokens := tokenRegex.FindAllString(call, -1)
+	for i, token := range tokens {
+		if strings.HasPrefix(token, `"`) {
+			unquoted, err := strconv.Unquote(token)
+			if err != nil {
+				return nil, fmt.Errorf("wrong quoted string %s: %s", token, err)
+			}
+			tokens[i] = unquoted
+		}
+	}
+	return tokens, nil
+}
+
+// transformFunc applies the transformation to found variables. Not found variables are left unresolved,
+// so a later "default" function can provide a value for them.
+func transformFunc(transform func(string) string) templateFunction {
+	return func(value string, found bool, args []string) (string, bool, error) {
+		if len(args) != 0 {
+			return "", false, errors.New("no arguments expected")
+		}
+		if !found {
+			return value, found, nil
+		}
+		return transform(value), true, nil
+	}
+}
+
+// defaultFunc returns the argument when the variable has not been found or its value is empty.
+func defaultFunc(value string, found bool, args []string) (string, bool, error) {
+	if len(args) != 1 {
+		return "", false, errors.New("a single default value is expected")
+	}
+	if !found || value == "" {
+		return args[0], true, nil
+	}
+	return value, true, nil
+}
+
+// jsonPathFunc parses the value as JSON and returns the element in the dot-separated path passed as
+// argument. Array elements are accessed by their index (e.g. "servers[0].host" or "servers.0.host").
+func jsonPathFunc(value string, found bool, args []string) (string, bool, error) {
+	if len(args) != 1 {
+		return "", false, errors.New("a single path is expected")
+	}
+	if !found {
+		return value, found, nil
+	}
+	var node interface{}
+	if err := json.Unmarshal([]byte(value), &node); err != nil {
+		return "", false, fmt.Errorf("value is not valid JSON: %s", err)
+	}
+	path := strings.NewReplacer("[", ".", "]", "").Replace(args[0])
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+		switch n := node.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if node, ok = n[key]; !ok {
+				// not found values can still be resolved by a later "default" function
+				return "", false, nil
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(n) {
+				return "", false, nil
+			}
+			node = n[index]
+		default:
+			return "", false, nil
+		}
+	}
+	switch n := node.(type) {
+	case string:
+		return n, true, nil
+	case nil:
+		return "", false, nil
+	default:
+		result, err := json.Marshal(n)
+		if err != nil {
+			return "", false, err
+		}
+		return string(result), true, nil
+	}
+}
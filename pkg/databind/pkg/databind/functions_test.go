@@ -0,0 +1,118 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package databind
+
+import (
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/internal/discovery"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplace_Functions(t *testing.T) {
+	vals := NewValues(data.Map{
+		"secret.user":  "admin",
+		"secret.pass":  "p@ss word&",
+		"secret.json":  `{"a":{"b":"nested","list":[1,{"c":true}]}}`,
+		"secret.space": "  padded\n",
+		"empty":        "",
+	}, discovery.Discovery{Variables: data.Map{"discovery.ip": "10.0.0.1"}})
+
+	cases := []struct {
+		template string
+		expected string
+	}{
+		{`${secret.user | upper}`, "ADMIN"},
+		{`${secret.user | upper | lower}`, "admin"},
+		{`${secret.space | trim}`, "padded"},
+		{`${secret.user | base64}`, "YWRtaW4="},
+		{`${secret.pass | urlquery}`, "p%40ss+word%26"},
+		{`${secret.json | jsonpath "a.b"}`, "nested"},
+		{`${secret.json | jsonpath "a.list[1].c"}`, "true"},
+		{`${secret.json | jsonpath "a.list"}`, `[1,{"c":true}]`},
+		{`${secret.json | jsonpath "a.missing" | default "none"}`, "none"},
+		{`${discovery.port | default "6379"}`, "6379"},
+		{`${discovery.port|default "6379"}`, "6379"},
+		{`${discovery.ip | default "127.0.0.1"}`, "10.0.0.1"},
+		{`${empty | default "filled"}`, "filled"},
+		{`${discovery.port | upper | default "a|b}c"}`, "a|b}c"},
+		{`${discovery.port | default "say \"hi\""}`, `say "hi"`},
+		{`redis://${discovery.ip}:${discovery.port | default "6379"}/${secret.user | upper}`, "redis://10.0.0.1:6379/ADMIN"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.template, func(t *testing.T) {
+			ret, err := Replace(&vals, tc.template)
+			require.NoError(t, err)
+			require.Len(t, ret, 1)
+			assert.Equal(t, tc.expected, ret[0].Variables)
+		})
+	}
+}
+
+func TestReplace_Functions_Errors(t *testing.T) {
+	vals := NewValues(data.Map{"secret.user": "admin", "secret.json": `{"a":1}`})
+
+	cases := []struct {
+		name     string
+		template string
+	}{
+		{"unresolved without default", `${discovery.port | upper}`},
+		{"unknown function", `${secret.user | reverse}`},
+		{"missing default argument", `${discovery.port | default}`},
+		{"unexpected argument", `${secret.user | upper "arg"}`},
+		{"empty function", `${secret.user | }`},
+		{"invalid JSON", `${secret.user | jsonpath "a"}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Replace(&vals, tc.template)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestReplace_Functions_DefaultsWithoutValues(t *testing.T) {
+	// GIVEN a configuration whose variables all have default values
+	cfg := struct {
+		Host string
+		Port string
+	}{"${discovery.ip | default \"localhost\"}", "${discovery.port | default \"6379\"}"}
+
+	// WHEN it is invoked with an empty context
+	ret, err := Replace(&Values{}, cfg)
+	require.NoError(t, err)
+
+	// THEN the configuration is returned with the default values
+	require.Len(t, ret, 1)
+	assert.Equal(t, "localhost", ret[0].Variables.(struct {
+		Host string
+		Port string
+	}).Host)
+
+	// AND the same happens for byte templates
+	bytes, err := ReplaceBytes(&Values{}, []byte(`port: ${discovery.port | default "6379"}`))
+	require.NoError(t, err)
+	require.Len(t, bytes, 1)
+	assert.Equal(t, "port: 6379", string(bytes[0]))
+}
+
+func TestReplace_Functions_DefaultsDoNotDiscardMatches(t *testing.T) {
+	// GIVEN discovery matches where only some of them provide a variable
+	vals := NewValues(nil,
+		discovery.Discovery{Variables: data.Map{"discovery.ip": "10.0.0.1", "discovery.port": "6380"}},
+		discovery.Discovery{Variables: data.Map{"discovery.ip": "10.0.0.2"}},
+	)
+
+	// WHEN a template with a default value for that variable is replaced
+	ret, err := ReplaceBytes(&vals, []byte(`${discovery.ip}:${discovery.port | default "6379"}`))
+	require.NoError(t, err)
+
+	// THEN all the matches are returned, using the default value when the variable is missing
+	require.Len(t, ret, 2)
+	assert.Equal(t, "10.0.0.1:6380", string(ret[0]))
+	assert.Equal(t, "10.0.0.2:6379", string(ret[1]))
+}
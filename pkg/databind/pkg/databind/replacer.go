@@ -4,8 +4,8 @@
 package databind
 
 import (
-	"bytes"
 	"errors"
+	"fmt"
 	"reflect"
 	"regexp"
 	"unsafe"
@@ -22,8 +22,9 @@ type replaceConfig struct {
 // Option provide extra behaviour configuration to the replacement process.
 type ReplaceOption func(rc *replaceConfig)
 
-// This regular expression matches any variable mark ${...} with dots and index marks [ ]
-var regex = regexp.MustCompile(`\$\{[\w\d\._\s\[\]-]*\}`)
+// This regular expression matches any variable mark ${...} with dots and index marks [ ], optionally
+// followed by a pipeline of functions with quoted arguments: ${variable | function "arg"}
+var regex = regexp.MustCompile(`\$\{[\w\d\._\s\[\]-]*(?:\|(?:"(?:[^"\\]|\\.)*"|[^"}])*)?\}`)
 
 // Replace receives one template, which may be a map or a struct whose string fields may
 // contain ${variable} placeholders, and returns an array of items of the same type of the
@@ -42,15 +43,15 @@ func Replace(vals *Values, template interface{}, options ...ReplaceOption) (tran
 			// if no discovery nor variables, we use this invocation not to replace anything, but
 			// to check if there are variable placeholders in the template (observe that we are passing
 			// an empty discovery source in the second argument)
-			_, err := replaceAllSources(template, []discovery.Discovery{{}}, data.Map{}, rc)
+			replaced, err := replaceAllSources(template, []discovery.Discovery{{}}, data.Map{}, rc)
 			// if the above returned error, it means it has variables. So since discovery returned
 			// no results, we will to return an empty array
 			if err != nil {
 				return transformedData, nil
 			}
-			// otherwise, it means it does not have variables, so we will return the template as it was
-			// since it was not bounded to any discovery process
-			return []data.Transformed{{Variables: template}}, nil
+			// otherwise, it means it does not have variables (or all of them have default values), so
+			// we will return the template as it was since it was not bounded to any discovery process
+			return replaced, nil
 		}
 		// if no discovery data but variables, we just replace variables as if they were
 		// a discovery source and leave the "common" values as empty
@@ -63,6 +64,56 @@ func Replace(vals *Values, template interface{}, options ...ReplaceOption) (tran
 	return replaceAllSources(template, discoverySources, varSrc, rc)
 }
 
+// ReferencedVars returns the names of the variables referenced by the ${variable} placeholders of a
+// template, which may be a map or a struct as accepted by Replace, in order of appearance.
+func ReferencedVars(template interface{}) []string {
+	var names []string
+	found := map[string]bool{}
+	collectVars(reflect.ValueOf(template), func(name string) {
+		if !found[name] {
+			found[name] = true
+			names = append(names, name)
+		}
+	})
+	return names
+}
+
+func collectVars(val reflect.Value, add func(name string)) {
+	switch val.Kind() {
+	case reflect.Slice:
+		if val.Type().Elem().Kind() == reflect.Uint8 {
+			collectBytesVars(val.Bytes(), add)
+			return
+		}
+		for i := 0; i < val.Len(); i++ {
+			collectVars(val.Index(i), add)
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !val.IsNil() {
+			collectVars(val.Elem(), add)
+		}
+	case reflect.String:
+		collectBytesVars([]byte(val.String()), add)
+	case reflect.Map:
+		for _, k := range val.MapKeys() {
+			collectVars(val.MapIndex(k), add)
+		}
+	case reflect.Struct:
+		for i := 0; i < val.NumField(); i++ {
+			collectVars(val.Field(i), add)
+		}
+	}
+}
+
+func collectBytesVars(template []byte, add func(name string)) {
+	for _, match := range regex.FindAll(template, -1) {
+		// removing ${...}
+		if varName, _, err := parseExpression(string(match[2 : len(match)-1])); err == nil {
+			add(varName)
+		}
+	}
+}
+
 // ReplaceBytes receives a byte array that may  contain ${variable} placeholders,
 // and returns an array of byte arrays replacing the variable placeholders from the respective Values.
 func ReplaceBytes(vals *Values, template []byte, options ...ReplaceOption) ([][]byte, error) {
@@ -73,11 +124,11 @@ func ReplaceBytes(vals *Values, template []byte, options ...ReplaceOption) ([][]
 	if len(vals.discov) == 0 {
 		if len(vals.vars) == 0 {
 			// the same tricky logic as for "Replace" function
-			_, err := replaceAllBytes(template, []discovery.Discovery{{}}, data.Map{}, rc)
+			replaced, err := replaceAllBytes(template, []discovery.Discovery{{}}, data.Map{}, rc)
 			if err != nil {
 				return [][]byte{}, nil
 			}
-			return [][]byte{template}, nil
+			return replaced, nil
 		}
 		// if no discovery data but variables, we just replace variables as if they were
 		// a discovery source and leave the "common" values as empty
@@ -267,24 +318,44 @@ func replaceBytes(values []data.Map, template []byte, rc replaceConfig, nMatches
 	return replace, err
 }
 
-// replaces a variable mark from its corresponding variable or discovered item.
+// replaces a variable mark from its corresponding variable or discovered item, applying the
+// functions of the mark, if any.
 func variable(values []data.Map, match []byte, rc replaceConfig) ([]byte, error) {
 	// removing ${...}
-	varName := string(bytes.Trim(match, "${}\n\r\t "))
+	varName, pipeline, err := parseExpression(string(match[2 : len(match)-1]))
+	if err != nil {
+		return match, err
+	}
+
+	value, found := lookup(values, varName, rc)
+	if len(pipeline) > 0 {
+		var result string
+		result, found, err = applyPipeline(string(value), found, pipeline)
+		if err != nil {
+			return match, fmt.Errorf("can't apply functions to %s: %s", varName, err)
+		}
+		value = []byte(result)
+	}
+	if found {
+		return value, nil
+	}
 
+	// if the value is not found, returns the match itself
+	return match, errors.New("value not found: " + varName)
+}
+
+func lookup(values []data.Map, varName string, rc replaceConfig) ([]byte, bool) {
 	for _, vmap := range values {
 		if value, ok := vmap[varName]; ok {
-			return []byte(value), nil
+			return []byte(value), true
 		}
 	}
 
 	// if not found in the discovered/variables static sources, we ask dynamically for it
 	for _, onDemand := range rc.onDemand {
 		if value, ok := onDemand(varName); ok {
-			return value, nil
+			return value, true
 		}
 	}
-
-	// if the value is not found, returns the match itself
-	return match, errors.New("value not found: " + varName)
+	return nil, false
 }
@@ -675,3 +675,20 @@ func TestReplace_EntityRewrite(t *testing.T) {
 		}
 	})
 }
+
+func TestReferencedVars(t *testing.T) {
+	type config struct {
+		Args     []string
+		Env      map[string]string
+		Template []byte
+		Ignored  int
+	}
+
+	names := ReferencedVars(config{
+		Args:     []string{"--user", "${creds.user}", "${discovery.ip}:${discovery.port}"},
+		Env:      map[string]string{"PASSWORD": `${creds.password | trim | default "none"}`},
+		Template: []byte("token: ${token}\nuser: ${creds.user}"),
+	})
+
+	assert.ElementsMatch(t, []string{"creds.user", "discovery.ip", "discovery.port", "creds.password", "token"}, names)
+}
@@ -23,6 +23,15 @@ type ConfigEntry struct {
 	WorkDir      string            `yaml:"working_dir" json:"working_dir"`
 	Labels       map[string]string `yaml:"labels" json:"labels"`
 	When         EnableConditions  `yaml:"when" json:"when"`
+	// StrictValidation overrides the agent "strict_integration_validation" setting for this integration
+	StrictValidation *bool `yaml:"strict_validation" json:"strict_validation"`
+	// Record enables storing the integration output into a file for offline debugging
+	Record *RecordConfig `yaml:"record" json:"record"`
+	// Replay feeds the output stored in a recording file instead of executing the integration
+	Replay string `yaml:"replay" json:"replay"`
+	// InventoryTTLIntervals removes the inventory items that aren't reported again within this amount of
+	// integration intervals. Zero keeps the default behaviour: each report replaces the previous inventory.
+	InventoryTTLIntervals int `yaml:"inventory_ttl_intervals" json:"inventory_ttl_intervals"`
 
 	// Legacy definition commands
 	Command         string            `yaml:"command" json:"command"`
@@ -36,6 +45,14 @@ type ConfigEntry struct {
 	TemplatePath string `yaml:"config_template_path" json:"config_template_path"`
 }
 
+// RecordConfig defines where and how much integration output is recorded
+type RecordConfig struct {
+	// Path of the recording file
+	Path string `yaml:"path" json:"path"`
+	// MaxSize in bytes of the recording file before being rotated. Default: 10MB
+	MaxSize int64 `yaml:"max_size" json:"max_size"`
+}
+
 // EnableConditions condition the execution of an integration to the trueness of ALL the conditions
 type EnableConditions struct {
 	// Feature allows enabling/disabling the OHI via agent cfg "feature" or cmd-channel Feature Flag
@@ -100,6 +117,18 @@ func (cf *ConfigEntry) Sanitize() error {
 		return errors.New("use either 'exec' or 'cli_args' but not both")
 	}
 
+	if cf.Replay != "" && (len(cf.Exec) > 0 || len(cf.CLIArgs) > 0 || cf.IntegrationName != "") {
+		return errors.New("'replay' can't be used together with 'exec', 'cli_args' or 'integration_name'")
+	}
+
+	if cf.Record != nil && cf.Record.Path == "" {
+		return errors.New("'record' requires a non-empty 'path' field")
+	}
+
+	if cf.InventoryTTLIntervals < 0 {
+		return errors.New("'inventory_ttl_intervals' can't be negative")
+	}
+
 	// Checking if there is any configuration file or path to be passed externally to the integration
 	if cf.Config != nil && cf.TemplatePath != "" {
 		return fmt.Errorf("only 'config' or 'config_template_path' is allowed, not both at the same time")
@@ -70,6 +70,8 @@ type emitter struct {
 	registerMaxBatchTime      time.Duration
 	verboseLogLevel           int
 	measure                   instrumentation.Measure
+	validationLog             *validationLogger
+	inventory                 *inventoryTracker
 }
 
 type Emitter interface {
@@ -98,6 +100,8 @@ func NewEmitter(
 		registerMaxBatchTime:      defaultRegisterBatchSecs * time.Second,
 		verboseLogLevel:           agentContext.Config().Verbose,
 		measure:                   measure,
+		validationLog:             newValidationLogger(),
+		inventory:                 newInventoryTracker(),
 	}
 }
 
@@ -116,6 +120,7 @@ func (e *emitter) lazyLoadProcessor() {
 
 		go e.runFwReqConsumer(ctx)
 		go e.runReqsRegisteredConsumer(ctx)
+		go e.runInventoryExpiry(ctx)
 		for w := 0; w < e.registerWorkers; w++ {
 			config := register.WorkerConfig{
 				MaxBatchSize:      e.registerMaxBatchSize,
@@ -149,6 +154,9 @@ func (e *emitter) runFwReqConsumer(ctx context.Context) {
 
 		case req := <-e.reqsQueue:
 			e.measure(instrumentation.Counter, instrumentation.DMDatasetsReceived, int64(len(req.Data.DataSets)))
+			if req.Definition.StrictValidationEnabled(e.agentContext.Config().StrictIntegrationValidation) {
+				e.validate(&req)
+			}
 			for _, ds := range req.Data.DataSets {
 				select {
 				case _ = <-ctx.Done():
@@ -166,13 +174,23 @@ func (e *emitter) runFwReqConsumer(ctx context.Context) {
 	}
 }
 
+// validate removes or sanitises the request payload items not complying with the protocol.
+func (e *emitter) validate(req *fwrequest.FwRequest) {
+	errs := req.Data.Validate()
+	if len(errs) == 0 {
+		return
+	}
+	measureValidationErrors(e.measure, errs)
+	e.validationLog.log(req.Definition.Name, errs)
+}
+
 func (e *emitter) emitDataset(r fwrequest.EntityFwRequest) {
 
 	labels, annos := r.LabelsAndExtraAnnotations()
 
 	plugin := agent.NewExternalPluginCommon(r.Definition.PluginID(r.Integration.Name), e.agentContext, r.Definition.Name)
 
-	emitInventory(&plugin, r.Definition, r.Integration, r.ID(), r.Data, labels)
+	emitInventory(&plugin, e.inventory, r.Definition, r.Integration, r.ID(), r.Data, labels)
 
 	emitEvent(&plugin, r.Definition, r.Data, labels, annos, r.ID())
 
@@ -197,6 +215,7 @@ func emitMetrics(metricSender MetricsSender,
 
 func emitInventory(
 	emitter agent.PluginEmitter,
+	tracker *inventoryTracker,
 	metadata integration.Definition,
 	integrationMetadata protocol.IntegrationMetadata,
 	entityID entity.ID,
@@ -206,12 +225,51 @@ func emitInventory(
 
 	integrationUser := metadata.ExecutorConfig.User
 
+	if len(dataSet.Inventory) == 0 && len(dataSet.InventoryRemove) == 0 && metadata.InventoryTTL <= 0 {
+		return
+	}
+
+	var inventoryDataSet agent.PluginInventoryDataset
 	if len(dataSet.Inventory) > 0 {
-		inventoryDataSet := legacy.BuildInventoryDataSet(
+		inventoryDataSet = legacy.BuildInventoryDataSet(
 			logEntry, dataSet.Inventory, labels, integrationUser, integrationMetadata.Name,
 			dataSet.Entity.Name)
-		entityKey := entity.Key(dataSet.Entity.Name)
-		emitter.EmitInventory(inventoryDataSet, entity.New(entityKey, entityID))
+	}
+	ent := entity.New(entity.Key(dataSet.Entity.Name), entityID)
+	inventoryDataSet, emit := tracker.update(
+		metadata.Name,
+		metadata.PluginID(integrationMetadata.Name),
+		ent,
+		inventoryDataSet,
+		dataSet.InventoryRemove,
+		metadata.InventoryTTL,
+		metadata.Interval,
+	)
+	if emit {
+		emitter.EmitInventory(inventoryDataSet, ent)
+	}
+}
+
+// runInventoryExpiry periodically emits the inventory of the entities with expired items, so
+// they're removed from the inventory store.
+func (e *emitter) runInventoryExpiry(ctx context.Context) {
+	ticker := time.NewTicker(inventoryExpiryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, expired := range e.inventory.expire() {
+				elog.
+					WithField("integration_name", expired.integrationName).
+					WithField("entity_key", expired.entity.Key.String()).
+					Debug("Removing expired inventory items.")
+				plugin := agent.NewExternalPluginCommon(expired.plugin, e.agentContext, expired.integrationName)
+				plugin.EmitInventory(expired.data, expired.entity)
+			}
+		}
 	}
 }
 
@@ -0,0 +1,181 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package dm
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+)
+
+const (
+	// inventoryExpiryCheckInterval how often expired inventory items are looked for.
+	inventoryExpiryCheckInterval = 15 * time.Second
+	// inventoryStateIdleTimeout minimum time after which the inventory state of an entity that isn't
+	// reported anymore is discarded, when no inventory TTL is configured.
+	inventoryStateIdleTimeout = time.Hour
+	// inventoryStateIdleIntervals integration intervals after which the inventory state of an entity
+	// that isn't reported anymore is discarded, when they last longer than inventoryStateIdleTimeout.
+	inventoryStateIdleIntervals = 3
+)
+
+// inventoryKey identifies the inventory of a plugin for an entity.
+type inventoryKey struct {
+	plugin    ids.PluginID
+	entityKey entity.Key
+}
+
+type trackedItem struct {
+	data     agent.Sortable
+	lastSeen time.Time
+}
+
+// trackedInventory holds the inventory items last emitted for an entity.
+type trackedInventory struct {
+	integrationName string
+	entity          entity.Entity
+	ttl             time.Duration
+	idleTimeout     time.Duration
+	items           map[string]trackedItem
+	lastUpdate      time.Time
+}
+
+func (t *trackedInventory) dataset() agent.PluginInventoryDataset {
+	dataset := make(agent.PluginInventoryDataset, 0, len(t.items))
+	for _, item := range t.items {
+		dataset = append(dataset, item.data)
+	}
+	sort.Sort(dataset)
+	return dataset
+}
+
+// expire removes the items not reported within the TTL and returns whether any was removed.
+func (t *trackedInventory) expire(now time.Time) (expired bool) {
+	if t.ttl <= 0 {
+		return false
+	}
+	for key, item := range t.items {
+		if now.Sub(item.lastSeen) > t.ttl {
+			delete(t.items, key)
+			expired = true
+		}
+	}
+	return
+}
+
+// expiredInventory is the remaining inventory of an entity after some of its items expired.
+type expiredInventory struct {
+	integrationName string
+	plugin          ids.PluginID
+	entity          entity.Entity
+	data            agent.PluginInventoryDataset
+}
+
+// inventoryTracker keeps the inventory reported by v4 integrations, so it can be emitted again
+// without the items that expired or were explicitly removed.
+type inventoryTracker struct {
+	lock     sync.Mutex
+	entities map[inventoryKey]*trackedInventory
+	now      func() time.Time
+}
+
+func newInventoryTracker() *inventoryTracker {
+	return &inventoryTracker{
+		entities: make(map[inventoryKey]*trackedInventory),
+		now:      time.Now,
+	}
+}
+
+// update registers the reported inventory items, removes the requested keys and returns the
+// inventory to be emitted for the entity. Without TTL the reported items replace the previous
+// ones, otherwise they're merged with the previously reported items that didn't expire yet.
+// The interval of the integration sets how long the inventory state is kept when it's not reported.
+// Returned emit is false when there is nothing to be emitted.
+func (t *inventoryTracker) update(
+	integrationName string,
+	plugin ids.PluginID,
+	ent entity.Entity,
+	reported agent.PluginInventoryDataset,
+	remove []string,
+	ttl time.Duration,
+	interval time.Duration,
+) (dataset agent.PluginInventoryDataset, emit bool) {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.now()
+	key := inventoryKey{plugin: plugin, entityKey: ent.Key}
+	tracked, ok := t.entities[key]
+	if !ok {
+		if len(reported) == 0 {
+			// nothing known to be removed from
+			return nil, false
+		}
+		tracked = &trackedInventory{items: make(map[string]trackedItem)}
+		t.entities[key] = tracked
+	}
+	tracked.integrationName = integrationName
+	tracked.entity = ent
+	tracked.ttl = ttl
+	tracked.idleTimeout = inventoryStateIdleTimeout
+	if idle := inventoryStateIdleIntervals * interval; idle > tracked.idleTimeout {
+		tracked.idleTimeout = idle
+	}
+	tracked.lastUpdate = now
+
+	if ttl <= 0 && len(reported) > 0 {
+		tracked.items = make(map[string]trackedItem, len(reported))
+	}
+	for _, item := range reported {
+		tracked.items[item.SortKey()] = trackedItem{data: item, lastSeen: now}
+	}
+
+	expired := tracked.expire(now)
+	removed := false
+	for _, itemKey := range remove {
+		if _, ok := tracked.items[itemKey]; ok {
+			delete(tracked.items, itemKey)
+			removed = true
+		}
+	}
+
+	if !expired && !removed {
+		if len(reported) == 0 {
+			return nil, false
+		}
+		if ttl <= 0 {
+			// inventory replaced as reported
+			return reported, true
+		}
+	}
+	return tracked.dataset(), true
+}
+
+// expire looks for inventory items not reported within their TTL, returning the remaining inventory
+// of the entities that got any item removed. Entities without items are not tracked anymore.
+func (t *inventoryTracker) expire() (expired []expiredInventory) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.now()
+	for key, tracked := range t.entities {
+		if tracked.expire(now) {
+			expired = append(expired, expiredInventory{
+				integrationName: tracked.integrationName,
+				plugin:          key.plugin,
+				entity:          tracked.entity,
+				data:            tracked.dataset(),
+			})
+		}
+		if len(tracked.items) == 0 ||
+			(tracked.ttl <= 0 && now.Sub(tracked.lastUpdate) > tracked.idleTimeout) {
+			delete(t.entities, key)
+		}
+	}
+	return
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package dm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/newrelic/infrastructure-agent/pkg/plugins/ids"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testInventoryPlugin = ids.PluginID{Category: "integration", Term: "nri-test"}
+	testInventoryEntity = entity.New("entity:key", 1)
+)
+
+func inventoryItem(key, value string) protocol.InventoryData {
+	return protocol.InventoryData{"id": key, "value": value}
+}
+
+func TestInventoryTracker_NoTTL_ReplacesInventory(t *testing.T) {
+	tracker := newInventoryTracker()
+
+	ds, emit := tracker.update("nri-test", testInventoryPlugin, testInventoryEntity,
+		agent.PluginInventoryDataset{inventoryItem("a", "1"), inventoryItem("b", "2")}, nil, 0, 0)
+	require.True(t, emit)
+	assert.Len(t, ds, 2)
+
+	ds, emit = tracker.update("nri-test", testInventoryPlugin, testInventoryEntity,
+		agent.PluginInventoryDataset{inventoryItem("a", "1")}, nil, 0, 0)
+	require.True(t, emit)
+	assert.Equal(t, agent.PluginInventoryDataset{inventoryItem("a", "1")}, ds)
+
+	_, emit = tracker.update("nri-test", testInventoryPlugin, testInventoryEntity, nil, nil, 0, 0)
+	assert.False(t, emit)
+}
+
+func TestInventoryTracker_TTL_MergesAndExpires(t *testing.T) {
+	now := time.Now()
+	tracker := newInventoryTracker()
+	tracker.now = func() time.Time { return now }
+	ttl := 3 * time.Minute
+
+	// GIVEN an inventory reported with two items
+	_, emit := tracker.update("nri-test", testInventoryPlugin, testInventoryEntity,
+		agent.PluginInventoryDataset{inventoryItem("a", "1"), inventoryItem("b", "2")}, nil, ttl, 0)
+	require.True(t, emit)
+
+	// WHEN only one of them is reported later on
+	now = now.Add(2 * time.Minute)
+	ds, emit := tracker.update("nri-test", testInventoryPlugin, testInventoryEntity,
+		agent.PluginInventoryDataset{inventoryItem("a", "1")}, nil, ttl, 0)
+
+	// THEN the not reported item is kept until its TTL expires
+	require.True(t, emit)
+	assert.Equal(t, agent.PluginInventoryDataset{inventoryItem("a", "1"), inventoryItem("b", "2")}, ds)
+	assert.Empty(t, tracker.expire())
+
+	now = now.Add(2 * time.Minute)
+	expired := tracker.expire()
+	require.Len(t, expired, 1)
+	assert.Equal(t, testInventoryPlugin, expired[0].plugin)
+	assert.Equal(t, testInventoryEntity, expired[0].entity)
+	assert.Equal(t, "nri-test", expired[0].integrationName)
+	assert.Equal(t, agent.PluginInventoryDataset{inventoryItem("a", "1")}, expired[0].data)
+
+	// AND once all the items expire, an empty inventory is emitted and the entity isn't tracked anymore
+	now = now.Add(2 * time.Minute)
+	expired = tracker.expire()
+	require.Len(t, expired, 1)
+	assert.Empty(t, expired[0].data)
+	assert.Empty(t, tracker.entities)
+}
+
+func TestInventoryTracker_Remove(t *testing.T) {
+	tracker := newInventoryTracker()
+
+	_, emit := tracker.update("nri-test", testInventoryPlugin, testInventoryEntity,
+		agent.PluginInventoryDataset{inventoryItem("a", "1"), inventoryItem("b", "2")}, nil, 0, 0)
+	require.True(t, emit)
+
+	// WHEN a payload only contains a removal directive
+	ds, emit := tracker.update("nri-test", testInventoryPlugin, testInventoryEntity,
+		nil, []string{"b", "unknown"}, 0, 0)
+
+	// THEN the remaining inventory is emitted
+	require.True(t, emit)
+	assert.Equal(t, agent.PluginInventoryDataset{inventoryItem("a", "1")}, ds)
+
+	// AND removing unknown items doesn't emit anything
+	_, emit = tracker.update("nri-test", testInventoryPlugin, testInventoryEntity,
+		nil, []string{"unknown"}, 0, 0)
+	assert.False(t, emit)
+}
+
+func TestInventoryTracker_RemoveUnknownEntity(t *testing.T) {
+	tracker := newInventoryTracker()
+
+	_, emit := tracker.update("nri-test", testInventoryPlugin, testInventoryEntity,
+		nil, []string{"a"}, time.Minute, 0)
+
+	assert.False(t, emit)
+	assert.Empty(t, tracker.entities)
+}
+
+func TestInventoryTracker_IdleTimeoutOfLongIntervals(t *testing.T) {
+	now := time.Now()
+	tracker := newInventoryTracker()
+	tracker.now = func() time.Time { return now }
+
+	// GIVEN an integration without TTL whose interval is longer than the idle timeout
+	interval := 2 * inventoryStateIdleTimeout
+	tracker.update("nri-test", testInventoryPlugin, testInventoryEntity,
+		agent.PluginInventoryDataset{inventoryItem("a", "1"), inventoryItem("b", "2")}, nil, 0, interval)
+
+	// WHEN its next execution removes an item
+	now = now.Add(interval)
+	assert.Empty(t, tracker.expire())
+	dataset, emit := tracker.update("nri-test", testInventoryPlugin, testInventoryEntity,
+		nil, []string{"b"}, 0, interval)
+
+	// THEN the item is removed from the previously reported inventory
+	require.True(t, emit)
+	assert.Equal(t, agent.PluginInventoryDataset{inventoryItem("a", "1")}, dataset)
+
+	// AND the state is discarded once the integration isn't reported for many intervals
+	now = now.Add(inventoryStateIdleIntervals*interval + time.Second)
+	tracker.expire()
+	assert.Empty(t, tracker.entities)
+}
+
+func TestInventoryTracker_IdleEntitiesWithoutTTLAreDiscarded(t *testing.T) {
+	now := time.Now()
+	tracker := newInventoryTracker()
+	tracker.now = func() time.Time { return now }
+
+	tracker.update("nri-test", testInventoryPlugin, testInventoryEntity,
+		agent.PluginInventoryDataset{inventoryItem("a", "1")}, nil, 0, 0)
+
+	now = now.Add(inventoryStateIdleTimeout + time.Second)
+	assert.Empty(t, tracker.expire())
+	assert.Empty(t, tracker.entities)
+}
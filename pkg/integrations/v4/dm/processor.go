@@ -12,6 +12,10 @@ const (
 	// These two constants can be found in old integrations as well
 	labelPrefix     = "label."
 	labelPrefixTrim = 6
+
+	// Attribute names used to forward the optional metric unit and description
+	unitAttribute        = "unit"
+	descriptionAttribute = "description"
 )
 
 type IntegrationProcessor struct {
@@ -30,6 +34,7 @@ func (p *IntegrationProcessor) ProcessMetrics(
 	for i := range metrics {
 		p.addTimestamp(&metrics[i], common.Timestamp, &now)
 		p.addInterval(&metrics[i], common.Interval)
+		p.addUnitAndDescription(&metrics[i])
 		p.addAttributes(&metrics[i], common.Attributes)
 		p.addLabels(&metrics[i])
 		p.addExtraAnnotations(&metrics[i])
@@ -97,3 +102,30 @@ func (p *IntegrationProcessor) addExtraAnnotations(metric *protocol.Metric) {
 		}
 	}
 }
+
+// Add metric unit and description as attributes when provided. Invalid values are
+// discarded so the metric is still submitted. If a key is already defined at the
+// metric level, it won't be overridden, but they take precedence over the common
+// and entity attributes
+func (p *IntegrationProcessor) addUnitAndDescription(metric *protocol.Metric) {
+	if metric.Unit == "" && metric.Description == "" {
+		return
+	}
+
+	if err := metric.ValidateMetadata(); err != nil {
+		elog.WithField("name", metric.Name).WithError(err).Warn("discarding metric unit and description")
+		metric.Unit = ""
+		metric.Description = ""
+		return
+	}
+
+	if metric.Attributes == nil {
+		metric.Attributes = make(map[string]interface{})
+	}
+	if _, ok := metric.Attributes[unitAttribute]; !ok && metric.Unit != "" {
+		metric.Attributes[unitAttribute] = metric.Unit
+	}
+	if _, ok := metric.Attributes[descriptionAttribute]; !ok && metric.Description != "" {
+		metric.Attributes[descriptionAttribute] = metric.Description
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package dm
+
+import (
+	"testing"
+
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegrationProcessor_ProcessMetrics_UnitAndDescription(t *testing.T) {
+	metrics := []protocol.Metric{
+		{Name: "no.metadata", Type: "gauge"},
+		{Name: "with.metadata", Type: "gauge", Unit: "By", Description: "Used memory"},
+		{Name: "invalid.unit", Type: "gauge", Unit: "kilo bytes", Description: "Used memory"},
+		{Name: "attribute.precedence", Type: "gauge", Unit: "ms", Attributes: map[string]interface{}{"unit": "s"}},
+	}
+
+	p := IntegrationProcessor{}
+	processed := p.ProcessMetrics(metrics, protocol.Common{}, entity.Fields{})
+
+	assert.NotContains(t, processed[0].Attributes, unitAttribute)
+	assert.NotContains(t, processed[0].Attributes, descriptionAttribute)
+
+	assert.Equal(t, "By", processed[1].Attributes[unitAttribute])
+	assert.Equal(t, "Used memory", processed[1].Attributes[descriptionAttribute])
+
+	assert.Empty(t, processed[2].Unit)
+	assert.NotContains(t, processed[2].Attributes, unitAttribute)
+	assert.NotContains(t, processed[2].Attributes, descriptionAttribute)
+
+	assert.Equal(t, "s", processed[3].Attributes[unitAttribute])
+}
+
+func TestIntegrationProcessor_ProcessMetrics_UnitAndDescriptionPrecedence(t *testing.T) {
+	// GIVEN a metric declaring its unit and description
+	metrics := []protocol.Metric{{Name: "with.metadata", Type: "gauge", Unit: "By", Description: "Used memory"}}
+	// AND common and entity attributes with the same names
+	common := protocol.Common{Attributes: map[string]interface{}{"unit": "common-unit"}}
+	fields := entity.Fields{Metadata: map[string]interface{}{"unit": "entity-unit", "description": "entity description"}}
+
+	// WHEN the metrics are processed
+	p := IntegrationProcessor{}
+	processed := p.ProcessMetrics(metrics, common, fields)
+
+	// THEN the metric unit and description are kept
+	assert.Equal(t, "By", processed[0].Attributes[unitAttribute])
+	assert.Equal(t, "Used memory", processed[0].Attributes[descriptionAttribute])
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package dm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/instrumentation"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// validationLogInterval minimum time between validation diagnostics of the same integration.
+	validationLogInterval = time.Minute
+	// validationLogMaxErrors max amount of validation errors logged at once per integration.
+	validationLogMaxErrors = 10
+)
+
+// validationLogger logs payload validation errors limiting the amount of log lines per integration.
+type validationLogger struct {
+	lock       sync.Mutex
+	interval   time.Duration
+	maxErrors  int
+	lastLog    map[string]time.Time
+	suppressed map[string]int
+	now        func() time.Time
+}
+
+func newValidationLogger() *validationLogger {
+	return &validationLogger{
+		interval:   validationLogInterval,
+		maxErrors:  validationLogMaxErrors,
+		lastLog:    make(map[string]time.Time),
+		suppressed: make(map[string]int),
+		now:        time.Now,
+	}
+}
+
+// log writes the validation errors for the integration, unless it already logged within the interval,
+// in which case the errors are accounted as suppressed and reported with the next log.
+func (l *validationLogger) log(integrationName string, errs []protocol.ValidationError) {
+	if len(errs) == 0 {
+		return
+	}
+
+	l.lock.Lock()
+	now := l.now()
+	if last, ok := l.lastLog[integrationName]; ok && now.Sub(last) < l.interval {
+		l.suppressed[integrationName] += len(errs)
+		l.lock.Unlock()
+		return
+	}
+	l.lastLog[integrationName] = now
+	suppressed := l.suppressed[integrationName]
+	delete(l.suppressed, integrationName)
+	l.lock.Unlock()
+
+	for i, err := range errs {
+		if i == l.maxErrors {
+			suppressed += len(errs) - l.maxErrors
+			break
+		}
+		action := "rejected"
+		if err.Sanitised {
+			action = "sanitised"
+		}
+		elog.WithFields(logrus.Fields{
+			"integration_name": integrationName,
+			"dataset_index":    err.DatasetIndex,
+			"item":             err.Item,
+			"name":             err.Name,
+			"reason":           err.Reason,
+		}).Warn("invalid integration payload item " + action)
+	}
+
+	if suppressed > 0 {
+		elog.WithFields(logrus.Fields{
+			"integration_name": integrationName,
+			"suppressed":       suppressed,
+		}).Warn("suppressed integration payload validation errors")
+	}
+}
+
+// measureValidationErrors accounts rejected and sanitised items.
+func measureValidationErrors(measure instrumentation.Measure, errs []protocol.ValidationError) {
+	counts := make(map[instrumentation.MetricName]int64)
+	for _, err := range errs {
+		switch {
+		case err.Sanitised:
+			counts[instrumentation.DMItemsSanitised]++
+		case err.Item == protocol.ItemDataset:
+			counts[instrumentation.DMDatasetsRejected]++
+		case err.Item == protocol.ItemMetric:
+			counts[instrumentation.DMMetricsRejected]++
+		case err.Item == protocol.ItemEvent:
+			counts[instrumentation.DMEventsRejected]++
+		}
+	}
+	for name, val := range counts {
+		measure(instrumentation.Counter, name, val)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package dm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/instrumentation"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/protocol"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationLogger_RateLimited(t *testing.T) {
+	hook := new(test.Hook)
+	log.AddHook(hook)
+	log.SetLevel(logrus.WarnLevel)
+
+	now := time.Now()
+	l := newValidationLogger()
+	l.now = func() time.Time { return now }
+	errs := []protocol.ValidationError{{Item: protocol.ItemMetric, Name: "m", Reason: "invalid"}}
+
+	l.log("nri-test", errs)
+	assert.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, "m", hook.LastEntry().Data["name"])
+
+	hook.Reset()
+	l.log("nri-test", errs)
+	l.log("nri-test", errs)
+	assert.Empty(t, hook.AllEntries())
+
+	now = now.Add(validationLogInterval)
+	l.log("nri-test", errs)
+	entries := hook.AllEntries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, 2, entries[1].Data["suppressed"])
+}
+
+func TestMeasureValidationErrors(t *testing.T) {
+	measured := map[instrumentation.MetricName]int64{}
+	measure := func(_ instrumentation.MetricType, name instrumentation.MetricName, val int64) {
+		measured[name] += val
+	}
+
+	measureValidationErrors(measure, []protocol.ValidationError{
+		{Item: protocol.ItemDataset},
+		{Item: protocol.ItemMetric},
+		{Item: protocol.ItemMetric},
+		{Item: protocol.ItemMetric, Sanitised: true},
+		{Item: protocol.ItemEvent},
+	})
+
+	assert.Equal(t, map[instrumentation.MetricName]int64{
+		instrumentation.DMDatasetsRejected: 1,
+		instrumentation.DMMetricsRejected:  2,
+		instrumentation.DMItemsSanitised:   1,
+		instrumentation.DMEventsRejected:   1,
+	}, measured)
+}
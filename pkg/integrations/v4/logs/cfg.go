@@ -21,6 +21,7 @@ var cfgLogger = log.WithComponent("integrations.Supervisor.Config").WithField("p
 
 // FluentBit default values.
 const (
+	usEndpoint              = "https://log-api.newrelic.com/log/v1"
 	euEndpoint              = "https://log-api.eu.newrelic.com/log/v1"
 	fedrampEndpoint         = "https://gov-log-api.newrelic.com/log/v1"
 	stagingEndpoint         = "https://staging-log-api.newrelic.com/log/v1"
@@ -77,6 +78,12 @@ const (
 	fbGrepFieldForTcpPlain = "log"
 )
 
+// Log forwarders that can be selected by each configuration block.
+const (
+	ForwarderFluentBit = "fluentbit" // default
+	ForwarderNative    = "native"    // in-process forwarder, only supporting "file" and "tcp" inputs
+)
+
 // LogsCfg stores logging product configuration split by block entries.
 type LogsCfg []LogCfg
 
@@ -87,17 +94,27 @@ type YAML struct {
 
 // LogCfg logging integration config from customer defined YAML.
 type LogCfg struct {
-	Name       string            `yaml:"name"`
-	File       string            `yaml:"file"`        // ...
-	MaxLineKb  int               `yaml:"max_line_kb"` // Setup the max value of the buffer while reading lines.
-	Systemd    string            `yaml:"systemd"`     // ...
-	Pattern    string            `yaml:"pattern"`
-	Attributes map[string]string `yaml:"attributes"`
-	Syslog     *LogSyslogCfg     `yaml:"syslog"`
-	Tcp        *LogTcpCfg        `yaml:"tcp"`
-	Fluentbit  *LogExternalFBCfg `yaml:"fluentbit"`
-	Winlog     *LogWinlogCfg     `yaml:"winlog"`
-	Winevtlog  *LogWinevtlogCfg  `yaml:"winevtlog"`
+	Name           string                `yaml:"name"`
+	File           string                `yaml:"file"`        // ...
+	MaxLineKb      int                   `yaml:"max_line_kb"` // Setup the max value of the buffer while reading lines.
+	Systemd        string                `yaml:"systemd"`     // ...
+	Pattern        string                `yaml:"pattern"`
+	ExcludePattern StringList            `yaml:"exclude_pattern"` // records matching any of these patterns are discarded
+	Journald       *LogJournaldCfg       `yaml:"journald"`
+	Severity       string                `yaml:"severity"` // minimum severity, for systemd, journald and syslog sources
+	Attributes     map[string]string     `yaml:"attributes"`
+	Syslog         *LogSyslogCfg         `yaml:"syslog"`
+	Tcp            *LogTcpCfg            `yaml:"tcp"`
+	Fluentbit      *LogExternalFBCfg     `yaml:"fluentbit"`
+	Winlog         *LogWinlogCfg         `yaml:"winlog"`
+	Winevtlog      *LogWinevtlogCfg      `yaml:"winevtlog"`
+	Forwarder      string                `yaml:"forwarder"` // "fluentbit" (default) or "native"
+	Multiline      *LogMultilineCfg      `yaml:"multiline"` // plugin: tail
+	Parser         *LogParserCfg         `yaml:"parser"`    // file, systemd, journald and tcp (plain format) sources
+	Mask           *config.LogMaskConfig `yaml:"mask"`      // applied along with the agent-wide logging_mask
+	RateLimit      *LogRateLimitCfg      `yaml:"rate_limit"`
+	SampleRatio    float64               `yaml:"sample_ratio"` // ratio of records to forward, between 0 and 1
+	Containers     *LogContainersCfg     `yaml:"containers"`   // always handled by the native forwarder
 }
 
 // LogSyslogCfg logging integration config from customer defined YAML, specific for the Syslog input plugin
@@ -130,17 +147,124 @@ type LogExternalFBCfg struct {
 	ParsersPath string `yaml:"parsers_file"`
 }
 
-// IsValid validates struct as there's no constructor to enforce it.
+// IsValid validates struct as there's no constructor to enforce it. Invalid blocks are logged, so they can be
+// ignored.
 func (l *LogCfg) IsValid() bool {
-	return l.Name != "" && (l.File != "" || l.Systemd != "" || l.Syslog != nil || l.Tcp != nil || l.Fluentbit != nil || l.Winlog != nil || l.Winevtlog != nil)
+	if err := l.Validate(); err != nil {
+		cfgLogger.WithError(err).WithField("name", l.Name).Warn("invalid log configuration, ignoring log source")
+		return false
+	}
+	return true
+}
+
+// Validate returns the reason why the configuration block can't be forwarded, if any.
+func (l *LogCfg) Validate() error {
+	if l.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if err := l.validateFilters(); err != nil {
+		return errors.Wrap(err, "invalid log filters")
+	}
+	if err := l.validateThrottling(); err != nil {
+		return errors.Wrap(err, "invalid rate limiting or sampling")
+	}
+	if l.isThrottled() && l.Fluentbit != nil {
+		return fmt.Errorf("rate limiting and sampling are not supported by external Fluent Bit configurations")
+	}
+	if l.Mask != nil {
+		if l.Fluentbit != nil {
+			return fmt.Errorf("mask is not supported by external Fluent Bit configurations")
+		}
+		if _, err := newMaskRules(*l.Mask); err != nil {
+			return errors.Wrap(err, "invalid mask configuration")
+		}
+	}
+	if l.Containers != nil {
+		if l.Forwarder == ForwarderFluentBit {
+			return fmt.Errorf("containers log sources are only supported by the native forwarder")
+		}
+		if err := l.Containers.validate(); err != nil {
+			return errors.Wrap(err, "invalid containers configuration")
+		}
+	}
+	if len(l.sources()) == 0 {
+		return fmt.Errorf("missing log source")
+	}
+	switch {
+	case l.IsNative():
+		if l.File == "" && l.Tcp == nil && l.Containers == nil {
+			return fmt.Errorf("the native forwarder only supports file, tcp and containers log sources")
+		}
+		if l.Multiline != nil || l.Parser != nil || l.isThrottled() {
+			return fmt.Errorf("multiline, parser, rate_limit and sample_ratio are not supported by the native forwarder")
+		}
+		return nil
+	case l.Forwarder == "", l.Forwarder == ForwarderFluentBit:
+	default:
+		return fmt.Errorf("unknown forwarder %q, valid values are: %s, %s", l.Forwarder, ForwarderFluentBit, ForwarderNative)
+	}
+	if l.Parser != nil {
+		if l.File == "" && l.Systemd == "" && l.Journald == nil && (l.Tcp == nil || l.Tcp.Format != "none") {
+			return fmt.Errorf("parser is only supported by file, systemd, journald and tcp (plain format) log sources")
+		}
+		if err := l.Parser.validate(); err != nil {
+			return errors.Wrap(err, "invalid parser configuration")
+		}
+	}
+	if l.Multiline != nil {
+		if l.File == "" {
+			return fmt.Errorf("multiline is only supported by file log sources")
+		}
+		if err := l.Multiline.validate(); err != nil {
+			return errors.Wrap(err, "invalid multiline configuration")
+		}
+	}
+	return nil
+}
+
+// sources returns the log sources set in the block. Journald matches are applied along with the systemd source.
+func (l *LogCfg) sources() (sources []string) {
+	if l.File != "" {
+		sources = append(sources, "file")
+	}
+	if l.Systemd != "" || l.Journald != nil {
+		sources = append(sources, "systemd")
+	}
+	if l.Syslog != nil {
+		sources = append(sources, "syslog")
+	}
+	if l.Tcp != nil {
+		sources = append(sources, "tcp")
+	}
+	if l.Fluentbit != nil {
+		sources = append(sources, "fluentbit")
+	}
+	if l.Winlog != nil {
+		sources = append(sources, "winlog")
+	}
+	if l.Winevtlog != nil {
+		sources = append(sources, "winevtlog")
+	}
+	if l.Containers != nil {
+		sources = append(sources, "containers")
+	}
+	return sources
+}
+
+// IsNative returns true if the block is handled by the in-process forwarder instead of Fluent Bit.
+func (l *LogCfg) IsNative() bool {
+	return l.Forwarder == ForwarderNative || (l.Forwarder == "" && l.Containers != nil)
 }
 
 // FBCfg FluentBit automatically generated configuration.
 type FBCfg struct {
-	Inputs      []FBCfgInput
-	Filters     []FBCfgFilter
-	ExternalCfg FBCfgExternal
-	Output      FBCfgOutput
+	Service          FBCfgService
+	Inputs           []FBCfgInput
+	Filters          []FBCfgFilter
+	ExternalCfg      FBCfgExternal
+	Output           FBCfgOutput
+	MultilineParsers []FBCfgMultilineParser // written into the generated parsers file
+	Parsers          []FBCfgParser          // written into the generated parsers file
 }
 
 // Format will return the FBCfg in the fluent bit config file format.
@@ -175,24 +299,27 @@ type FBCfgInput struct {
 	Name                  string
 	Tag                   string
 	DB                    string
-	Path                  string // plugin: tail
-	BufferMaxSize         string // plugin: tail
-	PathKey               string // plugin: tail
-	SkipLongLines         string // always on
-	Systemd_Filter        string // plugin: systemd
-	Channels              string // plugin: winlog
-	SyslogMode            string // plugin: syslog
-	SyslogListen          string // plugin: syslog
-	SyslogPort            int    // plugin: syslog
-	SyslogParser          string // plugin: syslog
-	SyslogUnixPath        string // plugin: syslog
-	SyslogUnixPermissions string // plugin: syslog
-	BufferChunkSize       string // plugin: syslog udp/udp_unix
-	TcpListen             string // plugin: tcp
-	TcpPort               int    // plugin: tcp
-	TcpFormat             string // plugin: tcp
-	TcpSeparator          string // plugin: tcp
-	TcpBufferSize         int    // plugin: tcp (note that the "tcp" plugin uses Buffer_Size (without "k"s!) instead of Buffer_Max_Size (with "k"s!))
+	Path                  string   // plugin: tail
+	BufferMaxSize         string   // plugin: tail
+	PathKey               string   // plugin: tail
+	MultilineParser       string   // plugin: tail
+	SkipLongLines         string   // always on
+	Systemd_Filter        string   // plugin: systemd
+	SystemdMatches        []string // plugin: systemd, additional Systemd_Filter entries
+	Systemd_Filter_Type   string   // plugin: systemd, "And" to match all the Systemd_Filter entries
+	Channels              string   // plugin: winlog
+	SyslogMode            string   // plugin: syslog
+	SyslogListen          string   // plugin: syslog
+	SyslogPort            int      // plugin: syslog
+	SyslogParser          string   // plugin: syslog
+	SyslogUnixPath        string   // plugin: syslog
+	SyslogUnixPermissions string   // plugin: syslog
+	BufferChunkSize       string   // plugin: syslog udp/udp_unix
+	TcpListen             string   // plugin: tcp
+	TcpPort               int      // plugin: tcp
+	TcpFormat             string   // plugin: tcp
+	TcpSeparator          string   // plugin: tcp
+	TcpBufferSize         int      // plugin: tcp (note that the "tcp" plugin uses Buffer_Size (without "k"s!) instead of Buffer_Max_Size (with "k"s!))
 }
 
 // FBCfgFilter FluentBit FILTER config block, only "grep" plugin supported.
@@ -201,13 +328,22 @@ type FBCfgInput struct {
 //    Match  nri-service
 //    Regex  MESSAGE info
 type FBCfgFilter struct {
-	Name      string
-	Match     string
-	Regex     string            // plugin: grep
-	Records   map[string]string // plugin: record_modifier
-	Script    string            // plugin:lua-Script
-	Call      string            // plugin:lua-Script
-	Modifiers map[string]string //plugin: modify filter
+	Name        string
+	Match       string
+	Alias       string            // name of the filter in the Fluent Bit metrics
+	Regex       string            // plugin: grep
+	Exclude     []string          // plugin: grep
+	KeyName     string            // plugin: parser
+	Parser      string            // plugin: parser
+	ReserveData string            // plugin: parser
+	PreserveKey string            // plugin: parser
+	Records     map[string]string // plugin: record_modifier
+	Script      string            // plugin:lua-Script
+	Call        string            // plugin:lua-Script
+	Modifiers   map[string]string //plugin: modify filter
+	Rate        int               // plugin: throttle
+	Window      int               // plugin: throttle
+	Interval    string            // plugin: throttle
 }
 
 // FBCfgOutput FluentBit Output config block, supporting NR output plugin.
@@ -259,15 +395,40 @@ func NewFBConf(loggingCfgs LogsCfg, logFwdCfg *config.LogForward, entityGUID, ho
 	}
 
 	for _, block := range loggingCfgs {
+		if block.IsNative() {
+			continue
+		}
 		input, filters, external, err := parseConfigBlock(block, logFwdCfg.HomeDir)
 		if err != nil {
 			return
 		}
-		if (input != FBCfgInput{}) {
+		if input.Name != "" {
 			fb.Inputs = append(fb.Inputs, input)
 		}
+		if parser, ok := newMultilineParser(block); ok {
+			fb.addMultilineParser(parser)
+		}
+		if parser, ok := newParser(block); ok {
+			fb.addParser(parser)
+		}
 
 		fb.Filters = append(fb.Filters, filters...)
+		if block.isThrottled() && input.Name != "" {
+			throttleFilters, err := newThrottleFilters(block)
+			if err != nil {
+				return fb, err
+			}
+			fb.Filters = append(fb.Filters, throttleFilters...)
+			// dropped records are reported from the Fluent Bit metrics
+			fb.Service = FBCfgService{HTTPListen: fbMetricsListen, HTTPPort: logFwdCfg.MetricsPort}
+		}
+		if block.Mask != nil && input.Name != "" {
+			maskFilter, err := newMaskFilter(block.Name, *block.Mask)
+			if err != nil {
+				return fb, err
+			}
+			fb.Filters = append(fb.Filters, maskFilter)
+		}
 
 		if (external != FBCfgExternal{} && fb.ExternalCfg != FBCfgExternal{}) {
 			cfgLogger.Warn("External Fluent Bit configuration specified more than once. Only first one is considered, please remove any duplicates from the configuration.")
@@ -291,6 +452,15 @@ func NewFBConf(loggingCfgs LogsCfg, logFwdCfg *config.LogForward, entityGUID, ho
 		},
 	})
 
+	// Agent-wide masking is applied to all the log records, right before being sent
+	if !logFwdCfg.Mask.IsEmpty() {
+		maskFilter, err := newMaskFilter("*", logFwdCfg.Mask)
+		if err != nil {
+			return fb, errors.Wrap(err, "invalid logging_mask, logs won't be forwarded")
+		}
+		fb.Filters = append(fb.Filters, maskFilter)
+	}
+
 	// Newrelic OUTPUT plugin will send all the collected logs to Vortex
 	fb.Output = newNROutput(logFwdCfg)
 
@@ -307,7 +477,7 @@ func parseConfigBlock(l LogCfg, logsHomeDir string) (input FBCfgInput, filters [
 
 	if l.File != "" {
 		input, filters = parseFileInput(l, dbPath)
-	} else if l.Systemd != "" {
+	} else if l.Systemd != "" || l.Journald != nil {
 		input, filters = parseSystemdInput(l, dbPath)
 	} else if l.Syslog != nil {
 		input, filters, err = parseSyslogInput(l)
@@ -323,7 +493,7 @@ func parseConfigBlock(l LogCfg, logsHomeDir string) (input FBCfgInput, filters [
 		return
 	}
 
-	if (input == FBCfgInput{}) {
+	if input.Name == "" {
 		err = fmt.Errorf("invalid log integration config")
 		return
 	} else {
@@ -334,7 +504,9 @@ func parseConfigBlock(l LogCfg, logsHomeDir string) (input FBCfgInput, filters [
 // Single file
 func parseFileInput(l LogCfg, dbPath string) (input FBCfgInput, filters []FBCfgFilter) {
 	input = newFileInput(l.File, dbPath, l.Name, getBufferMaxSize(l))
+	input.MultilineParser = multilineParserName(l)
 	filters = append(filters, newRecordModifierFilterForInput(l.Name, fbInputTypeTail, l.Attributes))
+	filters = parseParser(l, fbGrepFieldForTail, filters)
 	filters = parsePattern(l, fbGrepFieldForTail, filters)
 	return input, filters
 }
@@ -342,7 +514,13 @@ func parseFileInput(l LogCfg, dbPath string) (input FBCfgInput, filters []FBCfgF
 // Systemd service: "system" plugin input
 func parseSystemdInput(l LogCfg, dbPath string) (input FBCfgInput, filters []FBCfgFilter) {
 	input = newSystemdInput(l.Systemd, dbPath, l.Name)
+	input.SystemdMatches = journaldMatches(l)
+	if len(input.SystemdMatches) > 0 && (input.Systemd_Filter != "" || len(input.SystemdMatches) > 1) {
+		input.Systemd_Filter_Type = fbSystemdFilterTypeAnd
+	}
 	filters = append(filters, newRecordModifierFilterForInput(l.Name, fbInputTypeSystemd, l.Attributes))
+	filters = append(filters, newJournaldFilters(l)...)
+	filters = parseParser(l, fbGrepFieldForSystemd, filters)
 	filters = parsePattern(l, fbGrepFieldForSystemd, filters)
 	return input, filters
 }
@@ -355,6 +533,9 @@ func parseSyslogInput(l LogCfg) (input FBCfgInput, filters []FBCfgFilter, err er
 	}
 	input = slIn
 	filters = append(filters, newRecordModifierFilterForInput(l.Name, fbInputTypeSyslog, l.Attributes))
+	if severityFilter, ok := newSyslogSeverityFilter(l); ok {
+		filters = append(filters, severityFilter)
+	}
 	filters = parsePattern(l, fbGrepFieldForSyslog, filters)
 	return input, filters, nil
 }
@@ -369,6 +550,7 @@ func parseTcpInput(l LogCfg) (input FBCfgInput, filters []FBCfgFilter, err error
 	input = tcpIn
 	filters = append(filters, newRecordModifierFilterForInput(l.Name, fbInputTypeTcp, l.Attributes))
 	if l.Tcp.Format == "none" {
+		filters = parseParser(l, fbGrepFieldForTcpPlain, filters)
 		filters = parsePattern(l, fbGrepFieldForTcpPlain, filters)
 	}
 	return input, filters, nil
@@ -472,7 +654,10 @@ func saveToTempFile(config []byte) (string, error) {
 
 func parsePattern(l LogCfg, fluentBitGrepField string, filters []FBCfgFilter) []FBCfgFilter {
 	if l.Pattern != "" {
-		return append(filters, newGrepFilter(l, fluentBitGrepField))
+		filters = append(filters, newGrepFilter(l, fluentBitGrepField))
+	}
+	if len(l.ExcludePattern) > 0 {
+		filters = append(filters, newExcludeFilter(l, fluentBitGrepField))
 	}
 	return filters
 }
@@ -497,12 +682,15 @@ func newFileInput(filePath string, dbPath string, tag string, bufSize int) FBCfg
 }
 
 func newSystemdInput(service string, dbPath string, tag string) FBCfgInput {
-	return FBCfgInput{
-		Name:           fbInputTypeSystemd,
-		Systemd_Filter: fmt.Sprintf("_SYSTEMD_UNIT=%s.service", service),
-		Tag:            tag,
-		DB:             dbPath,
+	input := FBCfgInput{
+		Name: fbInputTypeSystemd,
+		Tag:  tag,
+		DB:   dbPath,
+	}
+	if service != "" {
+		input.Systemd_Filter = fmt.Sprintf("_SYSTEMD_UNIT=%s.service", service)
 	}
+	return input
 }
 
 func newWinlogInput(winlog LogWinlogCfg, dbPath string, tag string) FBCfgInput {
@@ -664,6 +852,14 @@ func newNROutput(cfg *config.LogForward) FBCfgOutput {
 	return ret
 }
 
+// logAPIEndpoint returns the Log API endpoint for the account region and environment.
+func logAPIEndpoint(cfg *config.LogForward) string {
+	if endpoint := newNROutput(cfg).Endpoint; endpoint != "" {
+		return endpoint
+	}
+	return usEndpoint
+}
+
 func getBufferMaxSize(l LogCfg) int {
 	bufferSize := l.MaxLineKb
 	if bufferSize == 0 {
@@ -0,0 +1,192 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Journald priority field, the systemd input filter type matching all the filters, and the field the syslog
+// parsers store the message priority into.
+const (
+	journaldPriorityField  = "PRIORITY"
+	fbSystemdFilterTypeAnd = "And"
+	fbSyslogPriorityField  = "pri"
+	syslogMaxFacility      = 23
+)
+
+var journaldFieldRegex = regexp.MustCompile(`^[A-Z0-9_]+$`)
+
+// severities maps the accepted severity names to their syslog/journald priority level.
+var severities = map[string]int{
+	"emerg":     0,
+	"emergency": 0,
+	"alert":     1,
+	"crit":      2,
+	"critical":  2,
+	"err":       3,
+	"error":     3,
+	"warning":   4,
+	"warn":      4,
+	"notice":    5,
+	"info":      6,
+	"debug":     7,
+}
+
+// StringList is a YAML field accepting either a single string or a list of strings.
+type StringList []string
+
+// UnmarshalYAML accepts both a scalar and a sequence of scalars.
+func (s *StringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*s = StringList{single}
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// LogJournaldCfg selects the journald entries by arbitrary fields. Entries must match all the fields, and
+// any of the values provided for each field.
+//
+//	journald:
+//	  matches:
+//	    _COMM: nginx
+//	    SYSLOG_IDENTIFIER: [sshd, sudo]
+type LogJournaldCfg struct {
+	Matches map[string]StringList `yaml:"matches"`
+}
+
+// validateFilters returns an error if the exclude patterns, journald matches or severity are not valid for the
+// log block.
+func (l *LogCfg) validateFilters() error {
+	for _, exclude := range l.ExcludePattern {
+		if exclude == "" {
+			return fmt.Errorf("exclude_pattern: empty pattern")
+		}
+	}
+	if l.Journald != nil {
+		if len(l.Journald.Matches) == 0 {
+			return fmt.Errorf("journald: at least one field match must be provided")
+		}
+		for field, values := range l.Journald.Matches {
+			if !journaldFieldRegex.MatchString(field) {
+				return fmt.Errorf("journald: invalid field name %q, only uppercase letters, digits and underscores are allowed", field)
+			}
+			if len(values) == 0 {
+				return fmt.Errorf("journald: no values provided for field %q", field)
+			}
+		}
+	}
+	if l.Severity != "" {
+		if _, ok := severities[strings.ToLower(l.Severity)]; !ok {
+			return fmt.Errorf("severity: unknown value %q, valid values are: emerg, alert, crit, err, warning, notice, info, debug", l.Severity)
+		}
+		if l.Systemd == "" && l.Journald == nil && l.Syslog == nil {
+			return fmt.Errorf("severity: only supported by systemd, journald and syslog log sources")
+		}
+	}
+	return nil
+}
+
+// severityLevel returns the maximum priority level to forward (lower values are more severe), or -1 if
+// all the records are forwarded.
+func severityLevel(l LogCfg) int {
+	level, ok := severities[strings.ToLower(l.Severity)]
+	if !ok {
+		return -1
+	}
+	return level
+}
+
+// journaldMatches returns the "FIELD=value" journald matches for the fields with a single value, sorted to
+// provide a stable configuration. They are applied by the systemd input, which must AND them along with the
+// service match. Fields with many values are filtered by newJournaldFilters instead.
+func journaldMatches(l LogCfg) []string {
+	var matches []string
+	if l.Journald != nil {
+		for field, values := range l.Journald.Matches {
+			if len(values) == 1 {
+				matches = append(matches, fmt.Sprintf("%s=%s", field, values[0]))
+			}
+		}
+		sort.Strings(matches)
+	}
+	return matches
+}
+
+// newJournaldFilters returns the grep filters keeping only the journald records matching any of the values of
+// the fields with many values, as well as the ones whose priority is at least the configured severity.
+func newJournaldFilters(l LogCfg) []FBCfgFilter {
+	var filters []FBCfgFilter
+	if l.Journald != nil {
+		var fields []string
+		for field, values := range l.Journald.Matches {
+			if len(values) > 1 {
+				fields = append(fields, field)
+			}
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			var quoted []string
+			for _, value := range l.Journald.Matches[field] {
+				quoted = append(quoted, regexp.QuoteMeta(value))
+			}
+			filters = append(filters, FBCfgFilter{
+				Name:  fbFilterTypeGrep,
+				Match: l.Name,
+				Regex: fmt.Sprintf("%s ^(%s)$", field, strings.Join(quoted, "|")),
+			})
+		}
+	}
+	if level := severityLevel(l); level >= 0 {
+		filters = append(filters, FBCfgFilter{
+			Name:  fbFilterTypeGrep,
+			Match: l.Name,
+			Regex: fmt.Sprintf("%s ^[0-%d]$", journaldPriorityField, level),
+		})
+	}
+	return filters
+}
+
+// newExcludeFilter returns a grep filter discarding the records matching any of the exclude patterns.
+func newExcludeFilter(l LogCfg, fluentBitGrepField string) FBCfgFilter {
+	filter := FBCfgFilter{
+		Name:  fbFilterTypeGrep,
+		Match: l.Name,
+	}
+	for _, exclude := range l.ExcludePattern {
+		filter.Exclude = append(filter.Exclude, fmt.Sprintf("%s %s", fluentBitGrepField, exclude))
+	}
+	return filter
+}
+
+// newSyslogSeverityFilter returns a grep filter keeping only the syslog records whose severity is at least the
+// configured one. The severity is the lower 3 bits of the message priority, so all the matching priorities
+// are listed.
+func newSyslogSeverityFilter(l LogCfg) (FBCfgFilter, bool) {
+	level := severityLevel(l)
+	if level < 0 {
+		return FBCfgFilter{}, false
+	}
+	var priorities []string
+	for facility := 0; facility <= syslogMaxFacility; facility++ {
+		for severity := 0; severity <= level; severity++ {
+			priorities = append(priorities, strconv.Itoa(facility*8+severity))
+		}
+	}
+	return FBCfgFilter{
+		Name:  fbFilterTypeGrep,
+		Match: l.Name,
+		Regex: fmt.Sprintf("%s ^(%s)$", fbSyslogPriorityField, strings.Join(priorities, "|")),
+	}, true
+}
@@ -0,0 +1,485 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/pkg/errors"
+)
+
+// Lua Script calling function for masking
+const fbLuaFnNameMask = "maskRecord"
+
+// Built-in mask detectors.
+const (
+	maskDetectorEmail       = "email"
+	maskDetectorCreditCard  = "credit_card"
+	maskDetectorIP          = "ip"
+	maskDetectorBearerToken = "bearer_token"
+)
+
+// maskRule is a replacement applied to the log records, both as a Lua pattern (for the Fluent Bit Lua filter)
+// and as a regular expression (for the native forwarder).
+type maskRule struct {
+	luaPattern     string
+	luaReplacement string
+	regex          *regexp.Regexp
+	replacement    string
+}
+
+// maskDetectors are the built-in rules. Lua patterns don't support alternations nor counted repetitions, so
+// they are expanded, and "%f[%w_]" frontiers are the equivalent of the "\b" boundaries.
+var maskDetectors = map[string][]maskRule{
+	maskDetectorEmail: {{
+		luaPattern:     `[%w_%.%%%+%-]+@[%w%.%-]+%.%a%a+`,
+		luaReplacement: "[MASKED_EMAIL]",
+		regex:          regexp.MustCompile(`[A-Za-z0-9_.%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		replacement:    "[MASKED_EMAIL]",
+	}},
+	maskDetectorCreditCard: {{
+		luaPattern:     `%f[%w_]%d%d%d%d[ %-]?%d%d%d%d[ %-]?%d%d%d%d[ %-]?%d%d%d%d%d?%d?%d?%f[^%w_]`,
+		luaReplacement: "[MASKED_CREDIT_CARD]",
+		regex:          regexp.MustCompile(`\b\d{4}[ \-]?\d{4}[ \-]?\d{4}[ \-]?\d{4}\d?\d?\d?\b`),
+		replacement:    "[MASKED_CREDIT_CARD]",
+	}, {
+		// American Express: 4-6-5 digits
+		luaPattern:     `%f[%w_]3[47]%d%d[ %-]?%d%d%d%d%d%d[ %-]?%d%d%d%d%d%f[^%w_]`,
+		luaReplacement: "[MASKED_CREDIT_CARD]",
+		regex:          regexp.MustCompile(`\b3[47]\d{2}[ \-]?\d{6}[ \-]?\d{5}\b`),
+		replacement:    "[MASKED_CREDIT_CARD]",
+	}},
+	maskDetectorIP: {{
+		luaPattern:     `%f[%w_]%d%d?%d?%.%d%d?%d?%.%d%d?%d?%.%d%d?%d?%f[^%w_]`,
+		luaReplacement: "[MASKED_IP]",
+		regex:          regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`),
+		replacement:    "[MASKED_IP]",
+	}},
+	maskDetectorBearerToken: {{
+		luaPattern:     `([Bb]earer%s+)[%w%-%._~%+/]+=*`,
+		luaReplacement: "%1[MASKED_TOKEN]",
+		regex:          regexp.MustCompile(`([Bb]earer\s+)[A-Za-z0-9\-._~+/]+=*`),
+		replacement:    "${1}[MASKED_TOKEN]",
+	}},
+}
+
+// FBMaskLuaScript is the Lua script masking the records, referenced by a "lua" filter.
+type FBMaskLuaScript struct {
+	FnName string
+	Rules  []FBMaskLuaRule
+}
+
+// FBMaskLuaRule is a Lua pattern replacement.
+type FBMaskLuaRule struct {
+	Pattern     string
+	Replacement string
+}
+
+// Format will return the formatted lua script that fluent bit config is pointing to.
+func (script FBMaskLuaScript) Format() (result string, err error) {
+	buf := new(bytes.Buffer)
+	tpl, err := template.New("fb lua mask").Funcs(template.FuncMap{"quote": luaQuote}).Parse(fbLuaMaskScriptFormat)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot parse log-forwarder template")
+	}
+	err = tpl.Execute(buf, script)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot write log-forwarder template")
+	}
+	return buf.String(), nil
+}
+
+// newMaskRules compiles the mask configuration into the rules to apply, in order: built-in detectors first,
+// then custom rules.
+func newMaskRules(m config.LogMaskConfig) (rules []maskRule, err error) {
+	for _, detector := range m.Detectors {
+		detectorRules, ok := maskDetectors[detector]
+		if !ok {
+			return nil, fmt.Errorf("mask: unknown detector %q, valid values are: email, credit_card, ip, bearer_token", detector)
+		}
+		rules = append(rules, detectorRules...)
+	}
+	for _, r := range m.Rules {
+		rule, err := newCustomMaskRule(r)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func newCustomMaskRule(r config.LogMaskRule) (maskRule, error) {
+	if r.Regex == "" {
+		return maskRule{}, fmt.Errorf("mask: empty regex")
+	}
+	regex, err := regexp.Compile(r.Regex)
+	if err != nil {
+		return maskRule{}, fmt.Errorf("mask: invalid regex %q: %s", r.Regex, err)
+	}
+	luaPattern, err := regexToLuaPattern(r.Regex)
+	if err != nil {
+		return maskRule{}, fmt.Errorf("mask: unsupported regex %q: %s", r.Regex, err)
+	}
+	luaReplacement, err := replacementToLua(r.Replacement)
+	if err != nil {
+		return maskRule{}, fmt.Errorf("mask: unsupported replacement %q: %s", r.Replacement, err)
+	}
+	return maskRule{
+		luaPattern:     luaPattern,
+		luaReplacement: luaReplacement,
+		regex:          regex,
+		replacement:    r.Replacement,
+	}, nil
+}
+
+// newMaskFilter creates the Lua script masking the records of the given tag, and the filter referencing it.
+func newMaskFilter(tag string, m config.LogMaskConfig) (FBCfgFilter, error) {
+	rules, err := newMaskRules(m)
+	if err != nil {
+		return FBCfgFilter{}, err
+	}
+	scriptContent, err := createLuaMaskScript(rules)
+	if err != nil {
+		return FBCfgFilter{}, err
+	}
+	scriptName, err := saveToTempFile([]byte(scriptContent))
+	if err != nil {
+		return FBCfgFilter{}, err
+	}
+	return FBCfgFilter{
+		Name:   fbFilterTypeLua,
+		Match:  tag,
+		Script: scriptName,
+		Call:   fbLuaFnNameMask,
+	}, nil
+}
+
+func createLuaMaskScript(rules []maskRule) (string, error) {
+	script := FBMaskLuaScript{FnName: fbLuaFnNameMask}
+	for _, r := range rules {
+		script.Rules = append(script.Rules, FBMaskLuaRule{Pattern: r.luaPattern, Replacement: r.luaReplacement})
+	}
+	return script.Format()
+}
+
+// recordMasker applies the mask rules in the native forwarder.
+type recordMasker []maskRule
+
+// mask returns the value with all the rules applied.
+func (m recordMasker) mask(value string) string {
+	for _, r := range m {
+		value = r.regex.ReplaceAllString(value, r.replacement)
+	}
+	return value
+}
+
+// maskValue masks the string values, including the ones nested in JSON objects and arrays.
+func maskValue(m recordMasker, value interface{}) interface{} {
+	if len(m) == 0 {
+		return value
+	}
+	switch v := value.(type) {
+	case string:
+		return m.mask(v)
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			masked[key] = maskValue(m, nested)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, nested := range v {
+			masked[i] = maskValue(m, nested)
+		}
+		return masked
+	}
+	return value
+}
+
+// luaMagicChars need to be escaped with "%" in Lua patterns.
+const luaMagicChars = `^$()%.[]*+-?`
+
+// regexToLuaPattern translates a regular expression into a Lua pattern. Lua patterns are simpler than regular
+// expressions, so only a subset of them can be translated: alternations, quantified groups and word boundaries
+// are not supported.
+func regexToLuaPattern(regex string) (string, error) {
+	var out strings.Builder
+	// lastItem is the last single character item, that a quantifier can be applied to
+	lastItem := ""
+	var groups []bool // open groups, true if capturing
+	runes := []rune(regex)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '^' && i == 0:
+			out.WriteRune('^')
+			lastItem = ""
+			continue
+		case c == '$' && i == len(runes)-1:
+			out.WriteString(lastItem)
+			lastItem = "$"
+			continue
+		case c == '^' || c == '$':
+			return "", fmt.Errorf("anchors are only supported at the beginning and end")
+		case c == '|':
+			return "", fmt.Errorf("alternations are not supported")
+		case c == '(':
+			out.WriteString(lastItem)
+			lastItem = ""
+			if strings.HasPrefix(string(runes[i:]), "(?:") {
+				// non-capturing: the group isn't needed without alternations nor quantifiers
+				groups = append(groups, false)
+				i += 2
+				continue
+			}
+			if i+1 < len(runes) && runes[i+1] == '?' {
+				return "", fmt.Errorf("group flags and named groups are not supported")
+			}
+			groups = append(groups, true)
+			out.WriteRune('(')
+			continue
+		case c == ')':
+			out.WriteString(lastItem)
+			lastItem = ""
+			if len(groups) == 0 {
+				return "", fmt.Errorf("unbalanced parenthesis")
+			}
+			if i+1 < len(runes) && strings.ContainsRune("*+?{", runes[i+1]) {
+				return "", fmt.Errorf("quantified groups are not supported")
+			}
+			if capturing := groups[len(groups)-1]; capturing {
+				out.WriteRune(')')
+			}
+			groups = groups[:len(groups)-1]
+			continue
+		case c == '*' || c == '+' || c == '?' || c == '{':
+			if lastItem == "" {
+				return "", fmt.Errorf("quantifier without a preceding character")
+			}
+			quantified, consumed, err := luaQuantifier(lastItem, runes[i:])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(quantified)
+			lastItem = ""
+			i += consumed - 1
+			continue
+		}
+
+		out.WriteString(lastItem)
+		item, consumed, err := luaItem(runes[i:])
+		if err != nil {
+			return "", err
+		}
+		lastItem = item
+		i += consumed - 1
+	}
+	if len(groups) != 0 {
+		return "", fmt.Errorf("unbalanced parenthesis")
+	}
+	out.WriteString(lastItem)
+	return out.String(), nil
+}
+
+// luaItem translates the single character item (a literal, escape, "." or character class) at the beginning of
+// the runes, returning the number of runes consumed.
+func luaItem(runes []rune) (string, int, error) {
+	switch c := runes[0]; c {
+	case '.':
+		return ".", 1, nil
+	case '\\':
+		if len(runes) < 2 {
+			return "", 0, fmt.Errorf("trailing backslash")
+		}
+		item, err := luaEscape(runes[1], false)
+		return item, 2, err
+	case '[':
+		return luaSet(runes)
+	default:
+		return luaLiteral(c), 1, nil
+	}
+}
+
+// luaEscape translates a regex escape sequence (without the backslash).
+func luaEscape(c rune, inSet bool) (string, error) {
+	classes := map[rune]string{'d': "%d", 'D': "%D", 's': "%s", 'S': "%S", 'w': "[%w_]", 'W': "[^%w_]"}
+	if inSet {
+		classes['w'] = "%w_"
+		delete(classes, 'W')
+	}
+	if class, ok := classes[c]; ok {
+		return class, nil
+	}
+	switch c {
+	case 't':
+		return "\t", nil
+	case 'n':
+		return "\n", nil
+	case 'r':
+		return "\r", nil
+	}
+	if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+		return "", fmt.Errorf(`escape sequence \%c is not supported`, c)
+	}
+	return luaLiteral(c), nil
+}
+
+// luaSet translates a character class, like [a-z0-9_], returning the number of runes consumed.
+func luaSet(runes []rune) (string, int, error) {
+	var out strings.Builder
+	out.WriteRune('[')
+	i := 1
+	if i < len(runes) && runes[i] == '^' {
+		out.WriteRune('^')
+		i++
+	}
+	for first := true; i < len(runes); i, first = i+1, false {
+		c := runes[i]
+		switch {
+		case c == ']' && !first:
+			out.WriteRune(']')
+			return out.String(), i + 1, nil
+		case c == '[' && i+1 < len(runes) && runes[i+1] == ':':
+			return "", 0, fmt.Errorf("POSIX character classes are not supported")
+		case c == '\\':
+			if i+1 >= len(runes) {
+				return "", 0, fmt.Errorf("trailing backslash")
+			}
+			i++
+			escaped, err := luaEscape(runes[i], true)
+			if err != nil {
+				return "", 0, err
+			}
+			out.WriteString(escaped)
+		case c == '-' && !first && i+1 < len(runes) && runes[i+1] != ']':
+			// range
+			out.WriteRune('-')
+		default:
+			out.WriteString(luaLiteral(c))
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated character class")
+}
+
+// luaQuantifier applies the quantifier at the beginning of the runes to the item, returning the number of runes
+// consumed. Counted repetitions are expanded.
+func luaQuantifier(item string, runes []rune) (string, int, error) {
+	lazy := len(runes) > 1 && runes[1] == '?'
+	switch runes[0] {
+	case '*':
+		if lazy {
+			return item + "-", 2, nil
+		}
+		return item + "*", 1, nil
+	case '+':
+		if lazy {
+			return item + item + "-", 2, nil
+		}
+		return item + "+", 1, nil
+	case '?':
+		if lazy {
+			return "", 0, fmt.Errorf("lazy optional quantifiers are not supported")
+		}
+		return item + "?", 1, nil
+	}
+
+	end := strings.IndexRune(string(runes), '}')
+	if end < 0 {
+		return "", 0, fmt.Errorf("unterminated repetition")
+	}
+	end = len([]rune(string(runes)[:end]))
+	bounds := strings.SplitN(string(runes[1:end]), ",", 2)
+	min, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid repetition")
+	}
+	max := min
+	unbounded := false
+	if len(bounds) == 2 {
+		if bounds[1] == "" {
+			unbounded = true
+		} else if max, err = strconv.Atoi(bounds[1]); err != nil || max < min {
+			return "", 0, fmt.Errorf("invalid repetition")
+		}
+	}
+	consumed := end + 1
+	if consumed < len(runes) && runes[consumed] == '?' {
+		return "", 0, fmt.Errorf("lazy repetitions are not supported")
+	}
+	quantified := strings.Repeat(item, min)
+	if unbounded {
+		quantified += item + "*"
+	} else {
+		quantified += strings.Repeat(item+"?", max-min)
+	}
+	return quantified, consumed, nil
+}
+
+func luaLiteral(c rune) string {
+	if strings.ContainsRune(luaMagicChars, c) {
+		return "%" + string(c)
+	}
+	return string(c)
+}
+
+// replacementToLua translates a regex replacement ($1 group references) into a Lua gsub replacement (%1).
+func replacementToLua(replacement string) (string, error) {
+	var out strings.Builder
+	runes := []rune(replacement)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '%':
+			out.WriteString("%%")
+		case c == '$' && i+1 < len(runes) && runes[i+1] == '$':
+			out.WriteRune('$')
+			i++
+		case c == '$' && i+1 < len(runes) && runes[i+1] >= '1' && runes[i+1] <= '9' &&
+			(i+2 >= len(runes) || !isWordRune(runes[i+2])):
+			out.WriteRune('%')
+			out.WriteRune(runes[i+1])
+			i++
+		case c == '$' && strings.HasPrefix(string(runes[i:]), "${") && len(runes) > i+3 &&
+			runes[i+2] >= '1' && runes[i+2] <= '9' && runes[i+3] == '}':
+			out.WriteRune('%')
+			out.WriteRune(runes[i+2])
+			i += 3
+		case c == '$':
+			return "", fmt.Errorf("only $1 to $9 group references are supported")
+		default:
+			out.WriteRune(c)
+		}
+	}
+	return out.String(), nil
+}
+
+func isWordRune(c rune) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// luaQuote returns the string as a Lua double-quoted string literal.
+func luaQuote(s string) string {
+	var out strings.Builder
+	out.WriteRune('"')
+	for _, b := range []byte(s) {
+		switch {
+		case b == '"' || b == '\\':
+			out.WriteByte('\\')
+			out.WriteByte(b)
+		case b < 0x20 || b == 0x7f:
+			out.WriteString(fmt.Sprintf("\\%03d", b))
+		default:
+			out.WriteByte(b)
+		}
+	}
+	out.WriteRune('"')
+	return out.String()
+}
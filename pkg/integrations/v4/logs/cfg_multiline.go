@@ -0,0 +1,159 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// Multiline presets, mapped to the Fluent Bit built-in multiline parsers.
+const (
+	multilinePresetJava   = "java"
+	multilinePresetPython = "python"
+	multilinePresetGo     = "go"
+)
+
+// Fluent Bit multiline parser rule states.
+const (
+	fbMultilineStateStart = "start_state"
+	fbMultilineStateCont  = "cont"
+)
+
+const fbMultilineParserPrefix = "multiline-"
+
+var fbParserNameInvalidChars = regexp.MustCompile(`[^\w-]`)
+
+// generatedParserName returns the name of a parser generated for a log block: its sanitized name followed by
+// a hash of the parser definition, so blocks whose names only differ in the sanitized characters (or have the
+// same name) don't share a parser unless their definitions are the same.
+func generatedParserName(prefix, logName string, definition ...string) string {
+	h := fnv.New32a()
+	for _, d := range definition {
+		_, _ = h.Write([]byte(d))
+		_, _ = h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s%s-%08x", prefix, fbParserNameInvalidChars.ReplaceAllString(logName, "_"), h.Sum32())
+}
+
+// LogMultilineCfg joins the lines of a multi-line record (e.g. stack traces) into a single record.
+// Either a preset or a start_pattern must be provided.
+type LogMultilineCfg struct {
+	Preset              string `yaml:"preset"`               // java, python or go
+	StartPattern        string `yaml:"start_pattern"`        // regex matching the first line of a record
+	ContinuationPattern string `yaml:"continuation_pattern"` // regex matching the rest of lines. Default: lines not matching start_pattern
+	FlushTimeout        int    `yaml:"flush_timeout"`        // milliseconds to wait for new lines before sending a record
+}
+
+// FBCfgMultilineParser FluentBit MULTILINE_PARSER config block, written into the generated parsers file.
+//
+//	[MULTILINE_PARSER]
+//	  name          multiline-java-app
+//	  type          regex
+//	  flush_timeout 1000
+//	  rule          "start_state" "/^\d{4}-\d{2}-\d{2}/" "cont"
+//	  rule          "cont" "/^\s+at /" "cont"
+type FBCfgMultilineParser struct {
+	Name         string
+	FlushTimeout int
+	Rules        []FBCfgMultilineRule
+}
+
+// FBCfgMultilineRule is a state transition of a FluentBit multiline parser.
+type FBCfgMultilineRule struct {
+	State     string
+	Regex     string
+	NextState string
+}
+
+// FormatParsers will return the parsers and multiline parsers in the fluent bit parsers file format, or an
+// empty string if there aren't any.
+func (c FBCfg) FormatParsers() (string, error) {
+	if len(c.Parsers) == 0 && len(c.MultilineParsers) == 0 {
+		return "", nil
+	}
+	buf := new(bytes.Buffer)
+	tpl, err := template.New("fb parsers").Funcs(template.FuncMap{"quote": quoteFBRuleRegex}).Parse(fbParsersFormat)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot parse log-forwarder parsers template")
+	}
+	if err = tpl.Execute(buf, c); err != nil {
+		return "", errors.Wrap(err, "cannot write log-forwarder parsers template")
+	}
+	return buf.String(), nil
+}
+
+// validate returns an error if the multiline configuration can't be translated into a Fluent Bit parser.
+func (m *LogMultilineCfg) validate() error {
+	if m.FlushTimeout < 0 {
+		return fmt.Errorf("multiline: flush_timeout must be a positive number of milliseconds")
+	}
+	if m.Preset != "" {
+		switch m.Preset {
+		case multilinePresetJava, multilinePresetPython, multilinePresetGo:
+		default:
+			return fmt.Errorf("multiline: unknown preset %q, valid values are: java, python, go", m.Preset)
+		}
+		if m.StartPattern != "" || m.ContinuationPattern != "" || m.FlushTimeout != 0 {
+			return fmt.Errorf("multiline: preset %q can't be combined with custom patterns or flush_timeout", m.Preset)
+		}
+		return nil
+	}
+	if m.StartPattern == "" {
+		return fmt.Errorf("multiline: either preset or start_pattern must be provided")
+	}
+	if _, err := regexp.Compile(m.StartPattern); err != nil {
+		return fmt.Errorf("multiline: invalid start_pattern: %s", err)
+	}
+	if m.ContinuationPattern != "" {
+		if _, err := regexp.Compile(m.ContinuationPattern); err != nil {
+			return fmt.Errorf("multiline: invalid continuation_pattern: %s", err)
+		}
+	}
+	return nil
+}
+
+// multilineParserName returns the name of the Fluent Bit multiline parser for the log block: the built-in
+// parser for presets, or a generated parser otherwise.
+func multilineParserName(l LogCfg) string {
+	if l.Multiline == nil {
+		return ""
+	}
+	if l.Multiline.Preset != "" {
+		return l.Multiline.Preset
+	}
+	m := l.Multiline
+	return generatedParserName(fbMultilineParserPrefix, l.Name, m.StartPattern, m.ContinuationPattern, strconv.Itoa(m.FlushTimeout))
+}
+
+// newMultilineParser returns the multiline parser that has to be generated for the log block, if any.
+func newMultilineParser(l LogCfg) (FBCfgMultilineParser, bool) {
+	if l.Multiline == nil || l.Multiline.Preset != "" {
+		return FBCfgMultilineParser{}, false
+	}
+	continuation := l.Multiline.ContinuationPattern
+	if continuation == "" {
+		// any line that doesn't start a new record
+		continuation = fmt.Sprintf("^(?!.*(?:%s)).*", l.Multiline.StartPattern)
+	}
+	return FBCfgMultilineParser{
+		Name:         multilineParserName(l),
+		FlushTimeout: l.Multiline.FlushTimeout,
+		Rules: []FBCfgMultilineRule{
+			{State: fbMultilineStateStart, Regex: l.Multiline.StartPattern, NextState: fbMultilineStateCont},
+			{State: fbMultilineStateCont, Regex: continuation, NextState: fbMultilineStateCont},
+		},
+	}, true
+}
+
+// quoteFBRuleRegex formats a regex as a multiline parser rule argument.
+func quoteFBRuleRegex(regex string) string {
+	return `"/` + strings.Replace(regex, `"`, `\"`, -1) + `/"`
+}
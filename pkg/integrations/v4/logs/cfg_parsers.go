@@ -0,0 +1,215 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Fluent Bit parser formats.
+const (
+	fbParserFormatRegex  = "regex"
+	fbParserFormatJSON   = "json"
+	fbParserFormatLogfmt = "logfmt"
+)
+
+const (
+	fbFilterTypeParser     = "parser"
+	fbParserNamePrefix     = "nri-"
+	fbCustomParserPrefix   = "nri-custom-"
+	fbParserTimeKey        = "time"
+	httpAccessLogTimestamp = "%d/%b/%Y:%H:%M:%S %z"
+)
+
+// bundledParsers are the parsers that can be referenced by name from the "parser" option. They are written into
+// the generated parsers file with the "nri-" prefix, to not collide with the Fluent Bit default parsers.
+var bundledParsers = map[string]FBCfgParser{
+	"apache": {
+		Format:     fbParserFormatRegex,
+		Regex:      `^(?<host>[^ ]*) [^ ]* (?<user>[^ ]*) \[(?<time>[^\]]*)\] "(?<method>\S+)(?: +(?<path>[^\"]*?)(?: +\S*)?)?" (?<code>[^ ]*) (?<size>[^ ]*)(?: "(?<referer>[^\"]*)" "(?<agent>[^\"]*)")?$`,
+		TimeKey:    fbParserTimeKey,
+		TimeFormat: httpAccessLogTimestamp,
+	},
+	"apache_error": {
+		Format: fbParserFormatRegex,
+		Regex:  `^\[[^ ]* (?<time>[^\]]*)\] \[(?<level>[^\]]*)\](?: \[pid (?<pid>[^\]]*)\])?(?: \[client (?<client>[^\]]*)\])?`,
+	},
+	"nginx": {
+		Format:     fbParserFormatRegex,
+		Regex:      `^(?<remote>[^ ]*) (?<host>[^ ]*) (?<user>[^ ]*) \[(?<time>[^\]]*)\] "(?<method>\S+)(?: +(?<path>[^\"]*?)(?: +\S*)?)?" (?<code>[^ ]*) (?<size>[^ ]*)(?: "(?<referer>[^\"]*)" "(?<agent>[^\"]*)")?`,
+		TimeKey:    fbParserTimeKey,
+		TimeFormat: httpAccessLogTimestamp,
+	},
+	"nginx_error": {
+		Format:     fbParserFormatRegex,
+		Regex:      `^(?<time>\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \[(?<level>\w+)\] (?<pid>\d+)#(?<tid>\d+):`,
+		TimeKey:    fbParserTimeKey,
+		TimeFormat: "%Y/%m/%d %H:%M:%S",
+	},
+	"json": {
+		Format: fbParserFormatJSON,
+	},
+	"logfmt": {
+		Format: fbParserFormatLogfmt,
+	},
+	"timestamp_iso8601": {
+		Format:     fbParserFormatRegex,
+		Regex:      `^(?<time>\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d+(?:Z|[+-]\d{2}:?\d{2}))`,
+		TimeKey:    fbParserTimeKey,
+		TimeFormat: "%Y-%m-%dT%H:%M:%S.%L%z",
+	},
+	"timestamp_rfc3164": {
+		Format:     fbParserFormatRegex,
+		Regex:      `^(?<time>[A-Z][a-z]{2} +\d{1,2} \d{2}:\d{2}:\d{2})`,
+		TimeKey:    fbParserTimeKey,
+		TimeFormat: "%b %d %H:%M:%S",
+	},
+	"timestamp_epoch": {
+		Format:     fbParserFormatRegex,
+		Regex:      `^(?<time>\d{10})`,
+		TimeKey:    fbParserTimeKey,
+		TimeFormat: "%s",
+	},
+}
+
+var namedGroupRegex = regexp.MustCompile(`\(\?<(\w+)>`)
+
+// LogParserCfg parses the log records into structured attributes. It accepts either the name of a bundled parser:
+//
+//	parser: nginx
+//
+// or an inline parser, whose regex captures the attributes in named groups:
+//
+//	parser:
+//	  regex: '^(?<time>[^ ]+) (?<level>\w+)'
+//	  time_key: time
+//	  time_format: '%Y-%m-%dT%H:%M:%S%z'
+type LogParserCfg struct {
+	Name       string `yaml:"name"`
+	Regex      string `yaml:"regex"`
+	TimeKey    string `yaml:"time_key"`
+	TimeFormat string `yaml:"time_format"`
+}
+
+// UnmarshalYAML accepts both a bundled parser name and an inline parser definition.
+func (p *LogParserCfg) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*p = LogParserCfg{Name: name}
+		return nil
+	}
+	type inline LogParserCfg
+	return unmarshal((*inline)(p))
+}
+
+// FBCfgParser FluentBit PARSER config block, written into the generated parsers file.
+//
+//	[PARSER]
+//	  Name        nri-nginx
+//	  Format      regex
+//	  Regex       ^(?<remote>[^ ]*) ...
+//	  Time_Key    time
+//	  Time_Format %d/%b/%Y:%H:%M:%S %z
+type FBCfgParser struct {
+	Name       string
+	Format     string
+	Regex      string
+	TimeKey    string
+	TimeFormat string
+}
+
+// validate returns an error if the parser doesn't exist or can't be translated into a Fluent Bit parser.
+func (p *LogParserCfg) validate() error {
+	if p.Name != "" {
+		if p.Regex != "" || p.TimeKey != "" || p.TimeFormat != "" {
+			return fmt.Errorf("parser: bundled parser %q can't be combined with regex, time_key or time_format", p.Name)
+		}
+		if _, ok := bundledParsers[p.Name]; !ok {
+			return fmt.Errorf("parser: unknown parser %q, valid values are: %s", p.Name, strings.Join(bundledParserNames(), ", "))
+		}
+		return nil
+	}
+	if p.Regex == "" {
+		return fmt.Errorf("parser: either a bundled parser name or a regex must be provided")
+	}
+	// Fluent Bit (Onigmo) named groups syntax is only supported by recent Go versions
+	if _, err := regexp.Compile(namedGroupRegex.ReplaceAllString(p.Regex, "(?P<$1>")); err != nil {
+		return fmt.Errorf("parser: invalid regex: %s", err)
+	}
+	if !namedGroupRegex.MatchString(p.Regex) {
+		return fmt.Errorf("parser: regex must capture the attributes in named groups, e.g. (?<level>\\w+)")
+	}
+	if (p.TimeKey == "") != (p.TimeFormat == "") {
+		return fmt.Errorf("parser: time_key and time_format must be provided together")
+	}
+	return nil
+}
+
+func bundledParserNames() []string {
+	names := make([]string, 0, len(bundledParsers))
+	for name := range bundledParsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newParser returns the parser to be written into the generated parsers file for the log block.
+func newParser(l LogCfg) (FBCfgParser, bool) {
+	if l.Parser == nil {
+		return FBCfgParser{}, false
+	}
+	if l.Parser.Name != "" {
+		parser, ok := bundledParsers[l.Parser.Name]
+		parser.Name = fbParserNamePrefix + l.Parser.Name
+		return parser, ok
+	}
+	return FBCfgParser{
+		Name:       generatedParserName(fbCustomParserPrefix, l.Name, l.Parser.Regex, l.Parser.TimeKey, l.Parser.TimeFormat),
+		Format:     fbParserFormatRegex,
+		Regex:      l.Parser.Regex,
+		TimeKey:    l.Parser.TimeKey,
+		TimeFormat: l.Parser.TimeFormat,
+	}, true
+}
+
+// parseParser appends the parser filter for the log block, if any. The original record is kept, so the
+// attributes are added to the log message.
+func parseParser(l LogCfg, fluentBitKeyField string, filters []FBCfgFilter) []FBCfgFilter {
+	parser, ok := newParser(l)
+	if !ok {
+		return filters
+	}
+	return append(filters, FBCfgFilter{
+		Name:        fbFilterTypeParser,
+		Match:       l.Name,
+		KeyName:     fluentBitKeyField,
+		Parser:      parser.Name,
+		ReserveData: "On",
+		PreserveKey: "On",
+	})
+}
+
+// addParser adds the parser to the configuration, unless it has already been added by another log block.
+func (c *FBCfg) addParser(parser FBCfgParser) {
+	for _, p := range c.Parsers {
+		if p.Name == parser.Name {
+			return
+		}
+	}
+	c.Parsers = append(c.Parsers, parser)
+}
+
+// addMultilineParser adds the multiline parser to the configuration, unless it has already been added by
+// another log block.
+func (c *FBCfg) addMultilineParser(parser FBCfgMultilineParser) {
+	for _, p := range c.MultilineParsers {
+		if p.Name == parser.Name {
+			return
+		}
+	}
+	c.MultilineParsers = append(c.MultilineParsers, parser)
+}
@@ -0,0 +1,183 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFBConf_Parsers(t *testing.T) {
+	// GIVEN log sources using bundled and inline parsers
+	cfgs := LogsCfg{
+		{Name: "nginx-access", File: "/var/log/nginx/access.log", Parser: &LogParserCfg{Name: "nginx"}},
+		{Name: "nginx-other", File: "/var/log/nginx/other.log", Parser: &LogParserCfg{Name: "nginx"}, Pattern: "GET"},
+		{Name: "my service", Systemd: "my-service", Parser: &LogParserCfg{Regex: `^(?<level>\w+) (?<component>\S+)`}},
+		{Name: "tcp-logfmt", Tcp: &LogTcpCfg{Uri: "tcp://0.0.0.0:5170", Format: "none"}, Parser: &LogParserCfg{Name: "logfmt"}},
+	}
+	for _, cfg := range cfgs {
+		require.True(t, cfg.IsValid())
+	}
+
+	// WHEN the Fluent Bit configuration is generated
+	fbConf, err := NewFBConf(cfgs, logFwdCfg, "0", "")
+	require.NoError(t, err)
+
+	// THEN a parser filter is added for each source, on the field containing the raw message
+	assert.Equal(t, []FBCfgFilter{
+		inputRecordModifier("tail", "nginx-access"),
+		{Name: "parser", Match: "nginx-access", KeyName: "log", Parser: "nri-nginx", ReserveData: "On", PreserveKey: "On"},
+		inputRecordModifier("tail", "nginx-other"),
+		{Name: "parser", Match: "nginx-other", KeyName: "log", Parser: "nri-nginx", ReserveData: "On", PreserveKey: "On"},
+		{Name: "grep", Match: "nginx-other", Regex: "log GET"},
+		inputRecordModifier("systemd", "my service"),
+		{Name: "parser", Match: "my service", KeyName: "MESSAGE", Parser: "nri-custom-my_service-fea3e98c", ReserveData: "On", PreserveKey: "On"},
+		inputRecordModifier("tcp", "tcp-logfmt"),
+		{Name: "parser", Match: "tcp-logfmt", KeyName: "log", Parser: "nri-logfmt", ReserveData: "On", PreserveKey: "On"},
+		filterEntityBlock,
+	}, fbConf.Filters)
+
+	// AND each parser is written once into the parsers file
+	bundledNginx := bundledParsers["nginx"]
+	bundledNginx.Name = "nri-nginx"
+	assert.Equal(t, []FBCfgParser{
+		bundledNginx,
+		{Name: "nri-custom-my_service-fea3e98c", Format: "regex", Regex: `^(?<level>\w+) (?<component>\S+)`},
+		{Name: "nri-logfmt", Format: "logfmt"},
+	}, fbConf.Parsers)
+}
+
+func TestNewFBConf_GeneratedParserNamesDontCollide(t *testing.T) {
+	// GIVEN log sources whose names only differ in the characters that aren't valid in parser names
+	cfgs := LogsCfg{
+		{Name: "a.b", File: "/var/log/a.log", Parser: &LogParserCfg{Regex: `^(?<level>\w+)`}, Multiline: &LogMultilineCfg{StartPattern: `^\d`}},
+		{Name: "a_b", File: "/var/log/b.log", Parser: &LogParserCfg{Regex: `^(?<component>\S+)`}, Multiline: &LogMultilineCfg{StartPattern: `^\[`}},
+		{Name: "a_b", File: "/var/log/c.log", Parser: &LogParserCfg{Regex: `^(?<component>\S+)`}, Multiline: &LogMultilineCfg{StartPattern: `^\[`}},
+	}
+	for _, cfg := range cfgs {
+		require.True(t, cfg.IsValid())
+	}
+
+	// WHEN the Fluent Bit configuration is generated
+	fbConf, err := NewFBConf(cfgs, logFwdCfg, "0", "")
+	require.NoError(t, err)
+
+	// THEN the sources with different definitions get their own parsers, and the same definitions are written once
+	require.Len(t, fbConf.Parsers, 2)
+	assert.NotEqual(t, fbConf.Parsers[0].Name, fbConf.Parsers[1].Name)
+	assert.Equal(t, `^(?<level>\w+)`, fbConf.Parsers[0].Regex)
+	assert.Equal(t, `^(?<component>\S+)`, fbConf.Parsers[1].Regex)
+	require.Len(t, fbConf.MultilineParsers, 2)
+	assert.NotEqual(t, fbConf.MultilineParsers[0].Name, fbConf.MultilineParsers[1].Name)
+
+	// AND each source refers to the parsers of its own definition
+	require.Len(t, fbConf.Inputs, 3)
+	assert.Equal(t, fbConf.MultilineParsers[0].Name, fbConf.Inputs[0].MultilineParser)
+	assert.Equal(t, fbConf.MultilineParsers[1].Name, fbConf.Inputs[1].MultilineParser)
+	assert.Equal(t, fbConf.MultilineParsers[1].Name, fbConf.Inputs[2].MultilineParser)
+	var parsers []string
+	for _, f := range fbConf.Filters {
+		if f.Name == fbFilterTypeParser {
+			parsers = append(parsers, f.Parser)
+		}
+	}
+	assert.Equal(t, []string{fbConf.Parsers[0].Name, fbConf.Parsers[1].Name, fbConf.Parsers[1].Name}, parsers)
+}
+
+func TestFBCfgFormatParsers_Parsers(t *testing.T) {
+	fbCfg := FBCfg{
+		Parsers: []FBCfgParser{
+			{Name: "nri-custom-app", Format: "regex", Regex: `^(?<time>[^ ]+) (?<level>\w+)`, TimeKey: "time", TimeFormat: "%Y-%m-%dT%H:%M:%S%z"},
+			{Name: "nri-json", Format: "json"},
+		},
+		MultilineParsers: []FBCfgMultilineParser{
+			{Name: "multiline-app", Rules: []FBCfgMultilineRule{{State: "start_state", Regex: `^\d`, NextState: "cont"}}},
+		},
+	}
+	expected := `
+[PARSER]
+    Name        nri-custom-app
+    Format      regex
+    Regex       ^(?<time>[^ ]+) (?<level>\w+)
+    Time_Key    time
+    Time_Format %Y-%m-%dT%H:%M:%S%z
+
+[PARSER]
+    Name        nri-json
+    Format      json
+
+[MULTILINE_PARSER]
+    name          multiline-app
+    type          regex
+    rule          "start_state" "/^\d/" "cont"
+`
+	parsers, err := fbCfg.FormatParsers()
+	require.NoError(t, err)
+	assert.Equal(t, expected, parsers)
+
+	cfg, _, err := FBCfg{Filters: []FBCfgFilter{
+		{Name: "parser", Match: "app", KeyName: "log", Parser: "nri-json", ReserveData: "On", PreserveKey: "On"},
+	}}.Format()
+	require.NoError(t, err)
+	assert.Contains(t, cfg, `
+[FILTER]
+    Name  parser
+    Match app
+    Key_Name log
+    Parser nri-json
+    Reserve_Data On
+    Preserve_Key On
+`)
+}
+
+func TestLogCfg_IsValid_Parser(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   LogCfg
+		valid bool
+	}{
+		{"bundled", LogCfg{Name: "n", File: "/f", Parser: &LogParserCfg{Name: "apache"}}, true},
+		{"inline", LogCfg{Name: "n", File: "/f", Parser: &LogParserCfg{Regex: `^(?<level>\w+)`, TimeKey: "t", TimeFormat: "%s"}}, true},
+		{"journald", LogCfg{Name: "n", Journald: &LogJournaldCfg{Matches: map[string]StringList{"_COMM": {"a"}}}, Parser: &LogParserCfg{Name: "json"}}, true},
+		{"unknown bundled", LogCfg{Name: "n", File: "/f", Parser: &LogParserCfg{Name: "tomcat"}}, false},
+		{"bundled with regex", LogCfg{Name: "n", File: "/f", Parser: &LogParserCfg{Name: "json", Regex: `^(?<a>.)`}}, false},
+		{"empty", LogCfg{Name: "n", File: "/f", Parser: &LogParserCfg{}}, false},
+		{"invalid regex", LogCfg{Name: "n", File: "/f", Parser: &LogParserCfg{Regex: `^(?<level>\w+`}}, false},
+		{"regex without named groups", LogCfg{Name: "n", File: "/f", Parser: &LogParserCfg{Regex: `^\w+`}}, false},
+		{"time key without format", LogCfg{Name: "n", File: "/f", Parser: &LogParserCfg{Regex: `^(?<t>\d+)`, TimeKey: "t"}}, false},
+		{"tcp json", LogCfg{Name: "n", Tcp: &LogTcpCfg{Uri: "tcp://0.0.0.0:1", Format: "json"}, Parser: &LogParserCfg{Name: "json"}}, false},
+		{"syslog", LogCfg{Name: "n", Syslog: &LogSyslogCfg{URI: "udp://0.0.0.0:1"}, Parser: &LogParserCfg{Name: "json"}}, false},
+		{"native", LogCfg{Name: "n", File: "/f", Forwarder: ForwarderNative, Parser: &LogParserCfg{Name: "json"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valid, tt.cfg.IsValid())
+		})
+	}
+}
+
+func TestBundledParsers(t *testing.T) {
+	samples := map[string]string{
+		"apache":            `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/4.08"`,
+		"apache_error":      `[Wed Oct 11 14:32:52.123456 2000] [core:error] [pid 1234] [client 127.0.0.1:5000] File does not exist`,
+		"nginx":             `192.168.1.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 612 "-" "curl/7.68.0"`,
+		"nginx_error":       `2021/03/11 10:00:00 [error] 1234#5678: *1 open() failed`,
+		"timestamp_iso8601": `2021-03-11T10:00:00.123Z starting`,
+		"timestamp_rfc3164": `Mar  1 10:00:00 starting`,
+		"timestamp_epoch":   `1615456800 starting`,
+	}
+	for name, parser := range bundledParsers {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, (&LogParserCfg{Name: name}).validate())
+			if parser.Format != fbParserFormatRegex {
+				return
+			}
+			regex, err := regexp.Compile(namedGroupRegex.ReplaceAllString(parser.Regex, "(?P<$1>"))
+			require.NoError(t, err)
+			assert.Regexp(t, regex, samples[name])
+		})
+	}
+}
@@ -2,7 +2,14 @@
 // SPDX-License-Identifier: Apache-2.0
 package logs
 
-var fbConfigFormat = `{{- range .Inputs }}
+var fbConfigFormat = `{{- if .Service.HTTPPort }}
+[SERVICE]
+    HTTP_Server On
+    HTTP_Listen {{ .Service.HTTPListen }}
+    HTTP_Port   {{ .Service.HTTPPort }}
+{{ end -}}
+
+{{- range .Inputs }}
 [INPUT]
     Name {{ .Name }}
     {{- if .Path }}
@@ -20,6 +27,9 @@ var fbConfigFormat = `{{- range .Inputs }}
     {{- if .PathKey }}
     Path_Key {{ .PathKey }}
     {{- end }}
+    {{- if .MultilineParser }}
+    multiline.parser {{ .MultilineParser }}
+    {{- end }}
     {{- if .Tag }}
     Tag  {{ .Tag }}
     {{- end }}
@@ -29,6 +39,12 @@ var fbConfigFormat = `{{- range .Inputs }}
     {{- if .Systemd_Filter }}
     Systemd_Filter {{ .Systemd_Filter }}
     {{- end }}
+    {{- range .SystemdMatches }}
+    Systemd_Filter {{ . }}
+    {{- end }}
+    {{- if .Systemd_Filter_Type }}
+    Systemd_Filter_Type {{ .Systemd_Filter_Type }}
+    {{- end }}
     {{- if .Channels }}
     Channels {{ .Channels }}
     {{- end }}
@@ -75,9 +91,27 @@ var fbConfigFormat = `{{- range .Inputs }}
     {{- if .Match }}
     Match {{ .Match }}
     {{- end }}
+    {{- if .Alias }}
+    Alias {{ .Alias }}
+    {{- end }}
     {{- if .Regex }}
     Regex {{ .Regex }}
     {{- end }}
+    {{- range .Exclude }}
+    Exclude {{ . }}
+    {{- end }}
+    {{- if .KeyName }}
+    Key_Name {{ .KeyName }}
+    {{- end }}
+    {{- if .Parser }}
+    Parser {{ .Parser }}
+    {{- end }}
+    {{- if .ReserveData }}
+    Reserve_Data {{ .ReserveData }}
+    {{- end }}
+    {{- if .PreserveKey }}
+    Preserve_Key {{ .PreserveKey }}
+    {{- end }}
     {{- if .Records }}
         {{- range $key, $value := .Records }}
     Record {{ $key }} {{ $value }}
@@ -94,6 +128,11 @@ var fbConfigFormat = `{{- range .Inputs }}
     {{- if .Call }}
     call {{ .Call }}
     {{- end }}
+    {{- if .Rate }}
+    Rate     {{ .Rate }}
+    Window   {{ .Window }}
+    Interval {{ .Interval }}
+    {{- end }}
 {{ end -}}
 
 {{- if .Output }}
@@ -140,3 +179,64 @@ var fbLuaScriptFormat = `function {{ .FnName }}(tag, timestamp, record)
     -- If there is not any matching conditions discard everything
     return -1, 0, 0
  end`
+
+var fbParsersFormat = `{{- range .Parsers }}
+[PARSER]
+    Name        {{ .Name }}
+    Format      {{ .Format }}
+    {{- if .Regex }}
+    Regex       {{ .Regex }}
+    {{- end }}
+    {{- if .TimeKey }}
+    Time_Key    {{ .TimeKey }}
+    {{- end }}
+    {{- if .TimeFormat }}
+    Time_Format {{ .TimeFormat }}
+    {{- end }}
+{{ end -}}
+
+{{- range .MultilineParsers }}
+[MULTILINE_PARSER]
+    name          {{ .Name }}
+    type          regex
+    {{- if .FlushTimeout }}
+    flush_timeout {{ .FlushTimeout }}
+    {{- end }}
+    {{- range .Rules }}
+    rule          "{{ .State }}" {{ quote .Regex }} "{{ .NextState }}"
+    {{- end }}
+{{ end -}}`
+
+var fbLuaMaskScriptFormat = `local rules = {
+{{- range .Rules }}
+    { {{ quote .Pattern }}, {{ quote .Replacement }} },
+{{- end }}
+}
+
+local function mask(value)
+    if type(value) == "string" then
+        for _, rule in ipairs(rules) do
+            value = string.gsub(value, rule[1], rule[2])
+        end
+    elseif type(value) == "table" then
+        for key, nested in pairs(value) do
+            value[key] = mask(nested)
+        end
+    end
+    return value
+end
+
+function {{ .FnName }}(tag, timestamp, record)
+    -- Mask all the string values of the record, keeping the original timestamp
+    return 2, timestamp, mask(record)
+end`
+
+var fbLuaSampleScriptFormat = `math.randomseed(os.time())
+
+function {{ .FnName }}(tag, timestamp, record)
+    -- Keep the record with the configured probability, drop it otherwise
+    if math.random() < {{ printf "%g" .Ratio }} then
+        return 0, timestamp, record
+    end
+    return -1, timestamp, record
+end`
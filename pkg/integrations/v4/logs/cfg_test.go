@@ -4,11 +4,14 @@ package logs
 
 import (
 	"os"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/newrelic/infrastructure-agent/pkg/config"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var logFwdCfg = &config.LogForward{
@@ -644,6 +647,93 @@ func TestNewFBConf(t *testing.T) {
 			},
 			Output: outputBlock,
 		}, false},
+		{"input file with multiline preset", LogsCfg{
+			{
+				Name:      "java-app",
+				File:      "file.path",
+				Multiline: &LogMultilineCfg{Preset: "java"},
+			},
+		}, FBCfg{
+			Inputs: []FBCfgInput{
+				{
+					Name:            "tail",
+					Tag:             "java-app",
+					DB:              dbDbPath,
+					Path:            "file.path",
+					BufferMaxSize:   "128k",
+					SkipLongLines:   "On",
+					PathKey:         "filePath",
+					MultilineParser: "java",
+				},
+			},
+			Filters: []FBCfgFilter{
+				inputRecordModifier("tail", "java-app"),
+				filterEntityBlock,
+			},
+			Output: outputBlock,
+		}, false},
+		{"input file with multiline patterns", LogsCfg{
+			{
+				Name: "my app",
+				File: "file.path",
+				Multiline: &LogMultilineCfg{
+					StartPattern:        `^\d{4}-\d{2}-\d{2}`,
+					ContinuationPattern: `^\s+`,
+					FlushTimeout:        2000,
+				},
+			},
+			{
+				Name:      "other-app",
+				File:      "other.path",
+				Multiline: &LogMultilineCfg{StartPattern: `^\[`},
+			},
+		}, FBCfg{
+			Inputs: []FBCfgInput{
+				{
+					Name:            "tail",
+					Tag:             "my app",
+					DB:              dbDbPath,
+					Path:            "file.path",
+					BufferMaxSize:   "128k",
+					SkipLongLines:   "On",
+					PathKey:         "filePath",
+					MultilineParser: "multiline-my_app-a523c451",
+				},
+				{
+					Name:            "tail",
+					Tag:             "other-app",
+					DB:              dbDbPath,
+					Path:            "other.path",
+					BufferMaxSize:   "128k",
+					SkipLongLines:   "On",
+					PathKey:         "filePath",
+					MultilineParser: "multiline-other-app-e11cf144",
+				},
+			},
+			Filters: []FBCfgFilter{
+				inputRecordModifier("tail", "my app"),
+				inputRecordModifier("tail", "other-app"),
+				filterEntityBlock,
+			},
+			Output: outputBlock,
+			MultilineParsers: []FBCfgMultilineParser{
+				{
+					Name:         "multiline-my_app-a523c451",
+					FlushTimeout: 2000,
+					Rules: []FBCfgMultilineRule{
+						{State: "start_state", Regex: `^\d{4}-\d{2}-\d{2}`, NextState: "cont"},
+						{State: "cont", Regex: `^\s+`, NextState: "cont"},
+					},
+				},
+				{
+					Name: "multiline-other-app-e11cf144",
+					Rules: []FBCfgMultilineRule{
+						{State: "start_state", Regex: `^\[`, NextState: "cont"},
+						{State: "cont", Regex: `^(?!.*(?:^\[)).*`, NextState: "cont"},
+					},
+				},
+			},
+		}, false},
 	}
 
 	for _, tt := range tests {
@@ -1210,3 +1300,269 @@ func TestCreateConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestFBCfgFormatParsers(t *testing.T) {
+	// GIVEN a configuration without multiline parsers
+	fbCfg := FBCfg{Inputs: []FBCfgInput{{Name: "tail", MultilineParser: "java"}}}
+
+	// THEN no parsers file is generated
+	parsers, err := fbCfg.FormatParsers()
+	require.NoError(t, err)
+	assert.Empty(t, parsers)
+
+	// GIVEN a configuration with multiline parsers
+	fbCfg.MultilineParsers = []FBCfgMultilineParser{
+		{
+			Name:         "multiline-app",
+			FlushTimeout: 1000,
+			Rules: []FBCfgMultilineRule{
+				{State: "start_state", Regex: `^\d{4} "quoted"`, NextState: "cont"},
+				{State: "cont", Regex: `^\s+at `, NextState: "cont"},
+			},
+		},
+		{
+			Name: "multiline-other",
+			Rules: []FBCfgMultilineRule{
+				{State: "start_state", Regex: `^\[`, NextState: "cont"},
+			},
+		},
+	}
+	expected := `
+[MULTILINE_PARSER]
+    name          multiline-app
+    type          regex
+    flush_timeout 1000
+    rule          "start_state" "/^\d{4} \"quoted\"/" "cont"
+    rule          "cont" "/^\s+at /" "cont"
+
+[MULTILINE_PARSER]
+    name          multiline-other
+    type          regex
+    rule          "start_state" "/^\[/" "cont"
+`
+
+	// THEN they are formatted as Fluent Bit multiline parsers
+	parsers, err = fbCfg.FormatParsers()
+	require.NoError(t, err)
+	assert.Equal(t, expected, parsers)
+
+	// AND the tail input references them
+	cfg, _, err := fbCfg.Format()
+	require.NoError(t, err)
+	assert.Contains(t, cfg, "multiline.parser java")
+}
+
+func TestLogCfg_IsValid_Sources(t *testing.T) {
+	// blocks without source are ignored
+	assert.False(t, (&LogCfg{Name: "app"}).IsValid())
+	// blocks with many sources are forwarded, using only the first one
+	assert.True(t, (&LogCfg{Name: "app", File: "/app.log", Systemd: "app"}).IsValid())
+	assert.True(t, (&LogCfg{Name: "app", File: "/app.log", Tcp: &LogTcpCfg{Uri: "tcp://0.0.0.0:2222", Format: "none"}, Forwarder: ForwarderNative}).IsValid())
+	// syslog and tcp URIs are parsed when generating the Fluent Bit configuration
+	assert.True(t, (&LogCfg{Name: "app", Syslog: &LogSyslogCfg{URI: "http://0.0.0.0:5140"}}).IsValid())
+	assert.True(t, (&LogCfg{Name: "app", Tcp: &LogTcpCfg{Uri: "http://0.0.0.0:2222", Format: "none"}}).IsValid())
+}
+
+func TestLogCfg_IsValid_Multiline(t *testing.T) {
+	tests := []struct {
+		name      string
+		multiline LogMultilineCfg
+		valid     bool
+	}{
+		{"preset", LogMultilineCfg{Preset: "python"}, true},
+		{"start pattern", LogMultilineCfg{StartPattern: `^\d+`}, true},
+		{"start and continuation patterns", LogMultilineCfg{StartPattern: `^\d+`, ContinuationPattern: `^\s`, FlushTimeout: 500}, true},
+		{"unknown preset", LogMultilineCfg{Preset: "ruby"}, false},
+		{"preset and patterns", LogMultilineCfg{Preset: "java", StartPattern: `^\d+`}, false},
+		{"preset and flush timeout", LogMultilineCfg{Preset: "java", FlushTimeout: 500}, false},
+		{"missing start pattern", LogMultilineCfg{ContinuationPattern: `^\s`}, false},
+		{"invalid start pattern", LogMultilineCfg{StartPattern: `^(\d+`}, false},
+		{"invalid continuation pattern", LogMultilineCfg{StartPattern: `^\d+`, ContinuationPattern: `[`}, false},
+		{"negative flush timeout", LogMultilineCfg{StartPattern: `^\d+`, FlushTimeout: -1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			multiline := tt.multiline
+			l := LogCfg{Name: "app", File: "/app.log", Multiline: &multiline}
+			assert.Equal(t, tt.valid, l.IsValid())
+		})
+	}
+
+	// multiline is only supported by the Fluent Bit file inputs
+	assert.False(t, (&LogCfg{Name: "app", Systemd: "app", Multiline: &LogMultilineCfg{Preset: "go"}}).IsValid())
+	assert.False(t, (&LogCfg{Name: "app", File: "/app.log", Forwarder: ForwarderNative, Multiline: &LogMultilineCfg{Preset: "go"}}).IsValid())
+}
+
+func TestNewFBConf_Filters(t *testing.T) {
+	tests := []struct {
+		name    string
+		ohiCfg  LogCfg
+		input   FBCfgInput
+		filters []FBCfgFilter
+	}{
+		{"file with exclude patterns",
+			LogCfg{Name: "app", File: "file.path", Pattern: "ERROR", ExcludePattern: StringList{"healthcheck", "DEBUG"}},
+			FBCfgInput{Name: "tail", Tag: "app", DB: dbDbPath, Path: "file.path", BufferMaxSize: "128k", SkipLongLines: "On", PathKey: "filePath"},
+			[]FBCfgFilter{
+				inputRecordModifier("tail", "app"),
+				{Name: "grep", Match: "app", Regex: "log ERROR"},
+				{Name: "grep", Match: "app", Exclude: []string{"log healthcheck", "log DEBUG"}},
+			}},
+		{"systemd with journald matches and severity",
+			LogCfg{Name: "ssh", Systemd: "sshd", Journald: &LogJournaldCfg{Matches: map[string]StringList{
+				"SYSLOG_IDENTIFIER": {"sshd", "sudo"},
+				"_COMM":             {"sshd"},
+			}}, Severity: "warning", ExcludePattern: StringList{"Accepted"}},
+			FBCfgInput{Name: "systemd", Tag: "ssh", DB: dbDbPath, Systemd_Filter: "_SYSTEMD_UNIT=sshd.service",
+				SystemdMatches: []string{"_COMM=sshd"}, Systemd_Filter_Type: "And"},
+			[]FBCfgFilter{
+				inputRecordModifier("systemd", "ssh"),
+				{Name: "grep", Match: "ssh", Regex: "SYSLOG_IDENTIFIER ^(sshd|sudo)$"},
+				{Name: "grep", Match: "ssh", Regex: "PRIORITY ^[0-4]$"},
+				{Name: "grep", Match: "ssh", Exclude: []string{"MESSAGE Accepted"}},
+			}},
+		{"journald without service",
+			LogCfg{Name: "kernel", Journald: &LogJournaldCfg{Matches: map[string]StringList{"_TRANSPORT": {"kernel"}}}},
+			FBCfgInput{Name: "systemd", Tag: "kernel", DB: dbDbPath, SystemdMatches: []string{"_TRANSPORT=kernel"}},
+			[]FBCfgFilter{
+				inputRecordModifier("systemd", "kernel"),
+			}},
+		{"syslog with severity",
+			LogCfg{Name: "syslog", Syslog: &LogSyslogCfg{URI: "udp://0.0.0.0:5140"}, Severity: "crit"},
+			FBCfgInput{Name: "syslog", Tag: "syslog", SyslogMode: "udp", SyslogListen: "0.0.0.0", SyslogPort: 5140, SyslogParser: "rfc3164", BufferChunkSize: "128k"},
+			[]FBCfgFilter{
+				inputRecordModifier("syslog", "syslog"),
+				{Name: "grep", Match: "syslog", Regex: "pri ^(0|1|2|8|9|10|16|17|18|24|25|26|32|33|34|40|41|42|48|49|50|56|57|58|64|65|66|72|73|74|80|81|82|88|89|90|96|97|98|104|105|106|112|113|114|120|121|122|128|129|130|136|137|138|144|145|146|152|153|154|160|161|162|168|169|170|176|177|178|184|185|186)$"},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.True(t, tt.ohiCfg.IsValid())
+			fbConf, err := NewFBConf(LogsCfg{tt.ohiCfg}, logFwdCfg, "0", "")
+			require.NoError(t, err)
+			assert.Equal(t, FBCfg{
+				Inputs:  []FBCfgInput{tt.input},
+				Filters: append(tt.filters, filterEntityBlock),
+				Output:  outputBlock,
+			}, fbConf)
+		})
+	}
+}
+
+// forwardedBySystemd evaluates a journald record against the systemd input filters and the grep filters the
+// way Fluent Bit does: Systemd_Filter entries are ORed unless Systemd_Filter_Type is And, and each grep filter
+// must match for the record to be forwarded.
+func forwardedBySystemd(fbConf FBCfg, record map[string]string) bool {
+	input := fbConf.Inputs[0]
+	var systemdFilters []string
+	if input.Systemd_Filter != "" {
+		systemdFilters = append(systemdFilters, input.Systemd_Filter)
+	}
+	systemdFilters = append(systemdFilters, input.SystemdMatches...)
+	if len(systemdFilters) > 0 {
+		matched := 0
+		for _, filter := range systemdFilters {
+			fieldValue := strings.SplitN(filter, "=", 2)
+			if record[fieldValue[0]] == fieldValue[1] {
+				matched++
+			}
+		}
+		if matched == 0 || (input.Systemd_Filter_Type == "And" && matched < len(systemdFilters)) {
+			return false
+		}
+	}
+	for _, filter := range fbConf.Filters {
+		if filter.Name != "grep" || filter.Regex == "" {
+			continue
+		}
+		keyRegex := strings.SplitN(filter.Regex, " ", 2)
+		if !regexp.MustCompile(keyRegex[1]).MatchString(record[keyRegex[0]]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNewFBConf_JournaldMatchSemantics(t *testing.T) {
+	// GIVEN a systemd source with journald matches and a severity
+	cfg := LogCfg{Name: "ssh", Systemd: "sshd", Journald: &LogJournaldCfg{Matches: map[string]StringList{
+		"SYSLOG_IDENTIFIER": {"sshd", "sudo"},
+		"_COMM":             {"sshd"},
+	}}, Severity: "err"}
+	require.True(t, cfg.IsValid())
+
+	// WHEN the Fluent Bit configuration is generated
+	fbConf, err := NewFBConf(LogsCfg{cfg}, logFwdCfg, "0", "")
+	require.NoError(t, err)
+
+	// THEN only the records of the unit matching all the fields, any of their values, and the severity are forwarded
+	tests := []struct {
+		name      string
+		record    map[string]string
+		forwarded bool
+	}{
+		{"all matching", map[string]string{"_SYSTEMD_UNIT": "sshd.service", "_COMM": "sshd", "SYSLOG_IDENTIFIER": "sudo", "PRIORITY": "3"}, true},
+		{"more severe", map[string]string{"_SYSTEMD_UNIT": "sshd.service", "_COMM": "sshd", "SYSLOG_IDENTIFIER": "sshd", "PRIORITY": "0"}, true},
+		{"less severe", map[string]string{"_SYSTEMD_UNIT": "sshd.service", "_COMM": "sshd", "SYSLOG_IDENTIFIER": "sshd", "PRIORITY": "6"}, false},
+		{"other unit", map[string]string{"_SYSTEMD_UNIT": "cron.service", "_COMM": "sshd", "SYSLOG_IDENTIFIER": "sshd", "PRIORITY": "3"}, false},
+		{"other command", map[string]string{"_SYSTEMD_UNIT": "sshd.service", "_COMM": "bash", "SYSLOG_IDENTIFIER": "sshd", "PRIORITY": "3"}, false},
+		{"other identifier", map[string]string{"_SYSTEMD_UNIT": "sshd.service", "_COMM": "sshd", "SYSLOG_IDENTIFIER": "su", "PRIORITY": "3"}, false},
+		{"any other unit severe record", map[string]string{"_SYSTEMD_UNIT": "cron.service", "PRIORITY": "0"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.forwarded, forwardedBySystemd(fbConf, tt.record))
+		})
+	}
+}
+
+func TestFBCfgFormat_Filters(t *testing.T) {
+	fbCfg := FBCfg{
+		Inputs: []FBCfgInput{
+			{Name: "systemd", Tag: "ssh", Systemd_Filter: "_SYSTEMD_UNIT=sshd.service", SystemdMatches: []string{"_COMM=sshd"}, Systemd_Filter_Type: "And"},
+		},
+		Filters: []FBCfgFilter{
+			{Name: "grep", Match: "ssh", Exclude: []string{"MESSAGE Accepted", "MESSAGE session"}},
+		},
+	}
+	expected := `
+[INPUT]
+    Name systemd
+    Tag  ssh
+    Systemd_Filter _SYSTEMD_UNIT=sshd.service
+    Systemd_Filter _COMM=sshd
+    Systemd_Filter_Type And
+
+[FILTER]
+    Name  grep
+    Match ssh
+    Exclude MESSAGE Accepted
+    Exclude MESSAGE session
+`
+	result, _, err := fbCfg.Format()
+	require.NoError(t, err)
+	assert.Contains(t, result, expected)
+}
+
+func TestLogCfg_IsValid_Filters(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   LogCfg
+		valid bool
+	}{
+		{"journald only", LogCfg{Name: "n", Journald: &LogJournaldCfg{Matches: map[string]StringList{"_COMM": {"nginx"}}}}, true},
+		{"journald without matches", LogCfg{Name: "n", Journald: &LogJournaldCfg{}}, false},
+		{"journald lowercase field", LogCfg{Name: "n", Journald: &LogJournaldCfg{Matches: map[string]StringList{"comm": {"nginx"}}}}, false},
+		{"journald field without values", LogCfg{Name: "n", Journald: &LogJournaldCfg{Matches: map[string]StringList{"_COMM": {}}}}, false},
+		{"systemd severity", LogCfg{Name: "n", Systemd: "svc", Severity: "WARNING"}, true},
+		{"unknown severity", LogCfg{Name: "n", Systemd: "svc", Severity: "verbose"}, false},
+		{"file severity", LogCfg{Name: "n", File: "/f", Severity: "error"}, false},
+		{"empty exclude pattern", LogCfg{Name: "n", File: "/f", ExcludePattern: StringList{""}}, false},
+		{"native exclude pattern", LogCfg{Name: "n", File: "/f", Forwarder: ForwarderNative, ExcludePattern: StringList{"x"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valid, tt.cfg.IsValid())
+		})
+	}
+}
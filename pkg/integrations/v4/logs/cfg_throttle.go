@@ -0,0 +1,175 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// Lua Script calling function for sampling
+const fbLuaFnNameSample = "sampleRecord"
+
+const (
+	fbFilterTypeThrottle  = "throttle"
+	fbThrottleInterval    = "1s"
+	fbThrottleAliasPrefix = "nri-throttle-"
+	fbSampleAliasPrefix   = "nri-sample-"
+	fbMetricsListen       = "127.0.0.1"
+)
+
+// LogRateLimitCfg limits the amount of records forwarded per second. Records over the limit are dropped.
+//
+//	rate_limit:
+//	  records_per_second: 100
+//	  burst: 500
+type LogRateLimitCfg struct {
+	RecordsPerSecond int `yaml:"records_per_second"`
+	Burst            int `yaml:"burst"` // records allowed in a second after a quiet period. Default: records_per_second
+}
+
+// FBCfgService FluentBit SERVICE config block. The HTTP server exposes the internal metrics, which are used to
+// report the dropped records.
+type FBCfgService struct {
+	HTTPListen string
+	HTTPPort   int
+}
+
+// FBSampleLuaScript is the Lua script keeping a random sample of the records, referenced by a "lua" filter.
+type FBSampleLuaScript struct {
+	FnName string
+	Ratio  float64
+}
+
+// Format will return the formatted lua script that fluent bit config is pointing to.
+func (script FBSampleLuaScript) Format() (result string, err error) {
+	buf := new(bytes.Buffer)
+	tpl, err := template.New("fb lua sample").Parse(fbLuaSampleScriptFormat)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot parse log-forwarder template")
+	}
+	err = tpl.Execute(buf, script)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot write log-forwarder template")
+	}
+	return buf.String(), nil
+}
+
+// validateThrottling returns an error if the rate limit or the sample ratio are not valid.
+func (l *LogCfg) validateThrottling() error {
+	if l.RateLimit != nil {
+		if l.RateLimit.RecordsPerSecond <= 0 {
+			return fmt.Errorf("rate_limit: records_per_second must be a positive number")
+		}
+		if l.RateLimit.Burst != 0 && l.RateLimit.Burst < l.RateLimit.RecordsPerSecond {
+			return fmt.Errorf("rate_limit: burst can't be lower than records_per_second")
+		}
+	}
+	if l.SampleRatio < 0 || l.SampleRatio > 1 {
+		return fmt.Errorf("sample_ratio: must be between 0 and 1")
+	}
+	return nil
+}
+
+// isThrottled returns true if the records of the log block can be dropped by rate limiting or sampling.
+func (l *LogCfg) isThrottled() bool {
+	return l.RateLimit != nil || l.isSampled()
+}
+
+// isSampled returns true if only a part of the records of the log block is forwarded. A ratio of 0 means
+// sampling isn't configured.
+func (l *LogCfg) isSampled() bool {
+	return l.SampleRatio > 0 && l.SampleRatio < 1
+}
+
+// newThrottleFilters returns the sampling and rate limiting filters for the log block, if any. Filters are
+// aliased after the log block, so their dropped records can be reported by source.
+func newThrottleFilters(l LogCfg) (filters []FBCfgFilter, err error) {
+	name := escapeAliasName(l.Name)
+	if l.isSampled() {
+		scriptContent, err := FBSampleLuaScript{FnName: fbLuaFnNameSample, Ratio: l.SampleRatio}.Format()
+		if err != nil {
+			return nil, err
+		}
+		scriptName, err := saveToTempFile([]byte(scriptContent))
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, FBCfgFilter{
+			Name:   fbFilterTypeLua,
+			Match:  l.Name,
+			Alias:  fbSampleAliasPrefix + name,
+			Script: scriptName,
+			Call:   fbLuaFnNameSample,
+		})
+	}
+	if l.RateLimit != nil {
+		// the throttle filter limits the average rate over a sliding window of intervals, so the window size
+		// sets how many records can be sent in a single interval after a quiet period
+		window := 1
+		if l.RateLimit.Burst > l.RateLimit.RecordsPerSecond {
+			window = (l.RateLimit.Burst + l.RateLimit.RecordsPerSecond - 1) / l.RateLimit.RecordsPerSecond
+		}
+		filters = append(filters, FBCfgFilter{
+			Name:     fbFilterTypeThrottle,
+			Match:    l.Name,
+			Alias:    fbThrottleAliasPrefix + name,
+			Rate:     l.RateLimit.RecordsPerSecond,
+			Window:   window,
+			Interval: fbThrottleInterval,
+		})
+	}
+	return filters, nil
+}
+
+// ThrottledSource returns the name of the log source a rate limiting or sampling filter belongs to, from its
+// alias in the Fluent Bit metrics.
+func ThrottledSource(filterAlias string) (string, bool) {
+	for _, prefix := range []string{fbThrottleAliasPrefix, fbSampleAliasPrefix} {
+		if strings.HasPrefix(filterAlias, prefix) {
+			return unescapeAliasName(strings.TrimPrefix(filterAlias, prefix))
+		}
+	}
+	return "", false
+}
+
+// escapeAliasName replaces the characters of a log block name that aren't letters, digits or dashes by their
+// "_xx" hexadecimal code, so the name can be used in a filter alias and recovered from it.
+func escapeAliasName(name string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' {
+			escaped.WriteByte(c)
+		} else {
+			fmt.Fprintf(&escaped, "_%02x", c)
+		}
+	}
+	return escaped.String()
+}
+
+// unescapeAliasName returns the log block name escaped by escapeAliasName.
+func unescapeAliasName(escaped string) (string, bool) {
+	var name strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] != '_' {
+			name.WriteByte(escaped[i])
+			continue
+		}
+		if i+3 > len(escaped) {
+			return "", false
+		}
+		c, err := strconv.ParseUint(escaped[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false
+		}
+		name.WriteByte(byte(c))
+		i += 2
+	}
+	return name.String(), true
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFBConf_Throttling(t *testing.T) {
+	// GIVEN a log block with rate limiting and sampling
+	fwdCfg := *logFwdCfg
+	fwdCfg.MetricsPort = 8004
+	block := LogCfg{Name: "my app", File: "file.path", RateLimit: &LogRateLimitCfg{RecordsPerSecond: 100, Burst: 250}, SampleRatio: 0.25}
+	require.True(t, block.IsValid())
+
+	// WHEN the Fluent Bit configuration is generated
+	fbConf, err := NewFBConf(LogsCfg{block}, &fwdCfg, "0", "")
+	require.NoError(t, err)
+
+	// THEN the records are sampled and then rate limited
+	require.Len(t, fbConf.Filters, 4)
+	assert.Equal(t, inputRecordModifier("tail", "my app"), fbConf.Filters[0])
+	sampleFilter := fbConf.Filters[1]
+	assert.Equal(t, "lua", sampleFilter.Name)
+	assert.Equal(t, "my app", sampleFilter.Match)
+	assert.Equal(t, "nri-sample-my_20app", sampleFilter.Alias)
+	assert.Equal(t, "sampleRecord", sampleFilter.Call)
+	script, err := ioutil.ReadFile(sampleFilter.Script)
+	require.NoError(t, err)
+	assert.Contains(t, string(script), "if math.random() < 0.25 then")
+	assert.Equal(t, FBCfgFilter{
+		Name:     "throttle",
+		Match:    "my app",
+		Alias:    "nri-throttle-my_20app",
+		Rate:     100,
+		Window:   3,
+		Interval: "1s",
+	}, fbConf.Filters[2])
+	assert.Equal(t, filterEntityBlock, fbConf.Filters[3])
+
+	// AND the Fluent Bit metrics are served locally, to report the dropped records
+	assert.Equal(t, FBCfgService{HTTPListen: "127.0.0.1", HTTPPort: 8004}, fbConf.Service)
+}
+
+func TestNewFBConf_NoThrottling(t *testing.T) {
+	fwdCfg := *logFwdCfg
+	fwdCfg.MetricsPort = 8004
+
+	fbConf, err := NewFBConf(LogsCfg{{Name: "app", File: "file.path", SampleRatio: 1}}, &fwdCfg, "0", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, []FBCfgFilter{inputRecordModifier("tail", "app"), filterEntityBlock}, fbConf.Filters)
+	assert.Equal(t, FBCfgService{}, fbConf.Service)
+}
+
+func TestFBCfgFormat_Throttling(t *testing.T) {
+	fbCfg := FBCfg{
+		Service: FBCfgService{HTTPListen: "127.0.0.1", HTTPPort: 8004},
+		Inputs:  []FBCfgInput{{Name: "tail", Tag: "app", Path: "/var/log/app.log"}},
+		Filters: []FBCfgFilter{{Name: "throttle", Match: "app", Alias: "nri-throttle-app", Rate: 100, Window: 5, Interval: "1s"}},
+	}
+
+	result, _, err := fbCfg.Format()
+	require.NoError(t, err)
+
+	assert.Contains(t, result, `[SERVICE]
+    HTTP_Server On
+    HTTP_Listen 127.0.0.1
+    HTTP_Port   8004
+
+[INPUT]
+    Name tail`)
+	assert.Contains(t, result, `[FILTER]
+    Name  throttle
+    Match app
+    Alias nri-throttle-app
+    Rate     100
+    Window   5
+    Interval 1s
+`)
+}
+
+func TestLogCfg_IsValid_Throttling(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   LogCfg
+		valid bool
+	}{
+		{"rate limit", LogCfg{Name: "n", File: "/f", RateLimit: &LogRateLimitCfg{RecordsPerSecond: 10}}, true},
+		{"rate limit with burst", LogCfg{Name: "n", File: "/f", RateLimit: &LogRateLimitCfg{RecordsPerSecond: 10, Burst: 100}}, true},
+		{"rate limit without rate", LogCfg{Name: "n", File: "/f", RateLimit: &LogRateLimitCfg{Burst: 100}}, false},
+		{"burst lower than rate", LogCfg{Name: "n", File: "/f", RateLimit: &LogRateLimitCfg{RecordsPerSecond: 10, Burst: 5}}, false},
+		{"sample ratio", LogCfg{Name: "n", Systemd: "svc", SampleRatio: 0.1}, true},
+		{"negative sample ratio", LogCfg{Name: "n", File: "/f", SampleRatio: -0.1}, false},
+		{"sample ratio over 1", LogCfg{Name: "n", File: "/f", SampleRatio: 1.5}, false},
+		{"native forwarder", LogCfg{Name: "n", File: "/f", Forwarder: ForwarderNative, SampleRatio: 0.5}, false},
+		{"external fluent bit config", LogCfg{Name: "n", Fluentbit: &LogExternalFBCfg{CfgPath: "/c", ParsersPath: "/p"}, RateLimit: &LogRateLimitCfg{RecordsPerSecond: 10}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valid, tt.cfg.IsValid())
+		})
+	}
+}
+
+func TestThrottledSource(t *testing.T) {
+	source, ok := ThrottledSource("nri-throttle-app")
+	assert.True(t, ok)
+	assert.Equal(t, "app", source)
+
+	source, ok = ThrottledSource("nri-sample-app")
+	assert.True(t, ok)
+	assert.Equal(t, "app", source)
+
+	_, ok = ThrottledSource("grep.0")
+	assert.False(t, ok)
+}
+
+func TestThrottledSource_RealBlockName(t *testing.T) {
+	for _, name := range []string{"my.app", "my_app", "my app", "app-1", "logs/été"} {
+		// GIVEN a throttled log block whose name isn't valid for a filter alias
+		filters, err := newThrottleFilters(LogCfg{Name: name, RateLimit: &LogRateLimitCfg{RecordsPerSecond: 1}})
+		require.NoError(t, err)
+		require.Len(t, filters, 1)
+
+		// THEN the real block name is returned from the alias of its filter
+		source, ok := ThrottledSource(filters[0].Alias)
+		assert.True(t, ok)
+		assert.Equal(t, name, source)
+	}
+
+	// AND malformed aliases are ignored
+	_, ok := ThrottledSource("nri-throttle-app_2")
+	assert.False(t, ok)
+}
@@ -59,7 +59,14 @@ func (l *CfgLoader) LoadAll() (c FBCfg, ok bool) {
 		allFilesCfgs = append(allFilesCfgs, *t)
 	}
 
-	if len(allFilesCfgs) == 0 {
+	// blocks handled by the in-process forwarder don't require Fluent Bit
+	fbCfgs := 0
+	for _, cfg := range allFilesCfgs {
+		if !cfg.IsNative() {
+			fbCfgs++
+		}
+	}
+	if fbCfgs == 0 {
 		loaderLogger.Debug("Could not find any configuration for logging forwarder.")
 		return FBCfg{}, false
 	}
@@ -80,6 +87,41 @@ func (l *CfgLoader) LoadAll() (c FBCfg, ok bool) {
 	return
 }
 
+// NativeCfg configuration of the in-process log forwarder: the log sources that are handled by it and the
+// attributes that are common to all their records.
+type NativeCfg struct {
+	Inputs     LogsCfg
+	EntityGUID string
+	Hostname   string
+}
+
+// LoadNative loads the logging configuration blocks that selected the in-process forwarder. It returns ok=false
+// when there are no such blocks, or an error occurred while loading any of the files.
+func (l *CfgLoader) LoadNative() (c NativeCfg, ok bool) {
+	if l.config.ConfigsDir == "" {
+		return NativeCfg{}, false
+	}
+
+	allFilesCfgs, ok := l.loadFolderCfgs()
+	if !ok {
+		return NativeCfg{}, false
+	}
+
+	for _, cfg := range allFilesCfgs {
+		if cfg.IsNative() {
+			c.Inputs = append(c.Inputs, cfg)
+		}
+	}
+	if len(c.Inputs) == 0 {
+		loaderLogger.Debug("Could not find any configuration for the native log forwarder.")
+		return NativeCfg{}, false
+	}
+
+	c.EntityGUID = l.agentIDFn().GUID.String() // blocks until ID is available
+	_, c.Hostname, _ = l.hostnameResolver.Query()
+	return c, true
+}
+
 // loadFolderCfgs loads all YAML logging configuration files from the logging configuration folder and parses them
 // into a slice of LogCfg (LogsCfg). It returns ok=true upon success, or ok=false in case that an error occurred while
 // loading any of the files, or if no valid configurations were found.
@@ -157,12 +199,20 @@ func (l *CfgLoader) loadTroubleshootCfg() *LogCfg {
 	return nil
 }
 
-func (l *CfgLoader) LoadAndFormat() (string, FBCfgExternal, error) {
+// LoadAndFormat returns the Fluent Bit configuration, along with the generated parsers (empty if there aren't
+// any) and the external Fluent Bit configuration to be included.
+func (l *CfgLoader) LoadAndFormat() (cfg string, parsers string, external FBCfgExternal, err error) {
 	fbConfig, ok := l.LoadAll()
 	if !ok {
-		return "", FBCfgExternal{}, errors.New("failed to load log configs")
+		return "", "", FBCfgExternal{}, errors.New("failed to load log configs")
+	}
+	if cfg, external, err = fbConfig.Format(); err != nil {
+		return "", "", FBCfgExternal{}, err
+	}
+	if parsers, err = fbConfig.FormatParsers(); err != nil {
+		return "", "", FBCfgExternal{}, err
 	}
-	return fbConfig.Format()
+	return cfg, parsers, external, nil
 }
 
 func (l *CfgLoader) parseYAML(content []byte) (c LogsCfg, err error) {
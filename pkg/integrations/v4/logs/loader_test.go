@@ -413,3 +413,149 @@ func addFile(t *testing.T, dir, name, contents string) {
 	filePath := filepath.Join(dir, name)
 	require.NoError(t, ioutil.WriteFile(filePath, []byte(contents), 0666))
 }
+
+func TestCfgLoader_LoadNative(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-load-native")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// GIVEN a configuration with native and Fluent Bit blocks
+	addFile(t, dir, "mixed.yml", `
+logs:
+  - name: fb
+    file: /fb/path
+  - name: native
+    file: /native/path
+    forwarder: native
+`)
+	loader := NewFolderLoader(newTestConf(dir, disabledTroubleshootCfg), idnProvide, hostnameProvider)
+
+	// WHEN the native configuration is loaded
+	cfg, ok := loader.LoadNative()
+
+	// THEN only the native blocks are returned, along with the entity decoration values
+	require.True(t, ok)
+	assert.Equal(t, NativeCfg{
+		Inputs:     LogsCfg{{Name: "native", File: "/native/path", Forwarder: ForwarderNative}},
+		EntityGUID: "FOOBAR",
+		Hostname:   hostName,
+	}, cfg)
+
+	// AND the Fluent Bit configuration doesn't include them
+	fbCfg, ok := loader.LoadAll()
+	require.True(t, ok)
+	require.Len(t, fbCfg.Inputs, 1)
+	assert.Equal(t, "/fb/path", fbCfg.Inputs[0].Path)
+}
+
+func TestCfgLoader_LoadAll_OnlyNative(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-load-native")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// GIVEN a configuration with only native blocks
+	addFile(t, dir, "native.yml", `
+logs:
+  - name: native
+    tcp:
+      uri: tcp://127.0.0.1:5170
+    forwarder: native
+`)
+	loader := NewFolderLoader(newTestConf(dir, disabledTroubleshootCfg), idnProvide, hostnameProvider)
+
+	// WHEN the Fluent Bit configuration is loaded
+	_, ok := loader.LoadAll()
+
+	// THEN it is not available, so Fluent Bit is not started
+	assert.False(t, ok)
+}
+
+func TestLogCfg_IsValid_Forwarder(t *testing.T) {
+	assert.True(t, (&LogCfg{Name: "n", File: "/f", Forwarder: ForwarderNative}).IsValid())
+	assert.True(t, (&LogCfg{Name: "n", File: "/f", Forwarder: ForwarderFluentBit}).IsValid())
+	assert.False(t, (&LogCfg{Name: "n", Systemd: "svc", Forwarder: ForwarderNative}).IsValid(), "native only supports file and tcp")
+	assert.False(t, (&LogCfg{Name: "n", File: "/f", Forwarder: "unknown"}).IsValid())
+}
+
+func TestCfgLoader_parseYAML_Filters(t *testing.T) {
+	// GIVEN exclude patterns and journald matches provided either as a single value or a list
+	content := []byte(`
+logs:
+  - name: single
+    file: /file/path
+    exclude_pattern: DEBUG
+  - name: multiple
+    systemd: sshd
+    exclude_pattern:
+      - DEBUG
+      - healthcheck
+    journald:
+      matches:
+        _COMM: sshd
+        SYSLOG_IDENTIFIER: [sshd, sudo]
+    severity: warning
+`)
+
+	// WHEN they are parsed
+	cfgs, err := NewFolderLoader(newTestConf("", disabledTroubleshootCfg), idnProvide, hostnameProvider).parseYAML(content)
+
+	// THEN both forms are accepted
+	require.NoError(t, err)
+	assert.Equal(t, LogsCfg{
+		{Name: "single", File: "/file/path", ExcludePattern: StringList{"DEBUG"}},
+		{Name: "multiple", Systemd: "sshd", ExcludePattern: StringList{"DEBUG", "healthcheck"}, Severity: "warning",
+			Journald: &LogJournaldCfg{Matches: map[string]StringList{
+				"_COMM":             {"sshd"},
+				"SYSLOG_IDENTIFIER": {"sshd", "sudo"},
+			}},
+		},
+	}, cfgs)
+}
+
+func TestCfgLoader_parseYAML_Parser(t *testing.T) {
+	// GIVEN a bundled parser and an inline parser
+	content := []byte(`
+logs:
+  - name: nginx
+    file: /var/log/nginx/access.log
+    parser: nginx
+  - name: app
+    file: /var/log/app.log
+    parser:
+      regex: '^(?<time>[^ ]+) (?<level>\w+)'
+      time_key: time
+      time_format: '%Y-%m-%dT%H:%M:%S%z'
+`)
+
+	// WHEN they are parsed
+	cfgs, err := NewFolderLoader(newTestConf("", disabledTroubleshootCfg), idnProvide, hostnameProvider).parseYAML(content)
+
+	// THEN both forms are accepted
+	require.NoError(t, err)
+	assert.Equal(t, LogsCfg{
+		{Name: "nginx", File: "/var/log/nginx/access.log", Parser: &LogParserCfg{Name: "nginx"}},
+		{Name: "app", File: "/var/log/app.log", Parser: &LogParserCfg{Regex: `^(?<time>[^ ]+) (?<level>\w+)`, TimeKey: "time", TimeFormat: "%Y-%m-%dT%H:%M:%S%z"}},
+	}, cfgs)
+}
+
+func TestCfgLoader_parseYAML_Throttling(t *testing.T) {
+	// GIVEN a log block with rate limiting and sampling
+	content := []byte(`
+logs:
+  - name: app
+    file: /var/log/app.log
+    rate_limit:
+      records_per_second: 100
+      burst: 500
+    sample_ratio: 0.5
+`)
+
+	// WHEN it is parsed
+	cfgs, err := NewFolderLoader(newTestConf("", disabledTroubleshootCfg), idnProvide, hostnameProvider).parseYAML(content)
+
+	// THEN both options are loaded
+	require.NoError(t, err)
+	assert.Equal(t, LogsCfg{
+		{Name: "app", File: "/var/log/app.log", RateLimit: &LogRateLimitCfg{RecordsPerSecond: 100, Burst: 500}, SampleRatio: 0.5},
+	}, cfgs)
+}
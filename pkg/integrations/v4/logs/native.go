@@ -0,0 +1,209 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+const nativeRecordsBuffer = 1000
+
+// NativeForwarder is an in-process alternative to Fluent Bit for the logging configuration blocks that set
+// "forwarder: native". It supports the "file", "tcp" and "containers" inputs, and submits the records to the Log API.
+type NativeForwarder struct {
+	cfgLoader *CfgLoader
+	config    config.LogForward
+	client    *http.Client
+	offsets   *offsetStore
+}
+
+// NewNativeForwarder creates an in-process log forwarder, which submits the records through the provided transport.
+func NewNativeForwarder(cfgLoader *CfgLoader, transport http.RoundTripper) *NativeForwarder {
+	return &NativeForwarder{
+		cfgLoader: cfgLoader,
+		config:    cfgLoader.config,
+		client:    &http.Client{Timeout: nativeHTTPTimeout, Transport: transport},
+		offsets:   loadOffsetStore(filepath.Join(cfgLoader.config.AgentDir, nativeOffsetsDir, nativeOffsetsFile)),
+	}
+}
+
+// Run forwards the logs until the context is cancelled, reloading the configuration when it changes.
+func (f *NativeForwarder) Run(ctx context.Context) {
+	changes := make(chan struct{}, 1)
+	NewConfigChangesWatcher(f.cfgLoader.GetConfigDir()).Watch(ctx, changes)
+
+	for {
+		runCtx, cancel := context.WithCancel(ctx)
+		finished := make(chan struct{})
+		if cfg, ok := f.cfgLoader.LoadNative(); ok {
+			go func() {
+				defer close(finished)
+				f.forward(runCtx, cfg)
+			}()
+		} else {
+			close(finished)
+		}
+
+		select {
+		case <-ctx.Done():
+			cancel()
+			<-finished
+			return
+		case <-changes:
+			cfgLogger.Debug("Logging configuration changed, restarting native log forwarder.")
+			cancel()
+			<-finished
+		}
+	}
+}
+
+// forward runs all the inputs of the configuration until the context is cancelled. Then, the remaining
+// records are sent.
+func (f *NativeForwarder) forward(ctx context.Context, cfg NativeCfg) {
+	// records can't be forwarded without masking them as configured
+	agentMask, err := newMaskRules(f.config.Mask)
+	if err != nil {
+		cfgLogger.WithError(err).Error("invalid logging_mask, logs won't be forwarded")
+		return
+	}
+
+	records := make(chan nativeRecord, nativeRecordsBuffer)
+	sender := &logSender{
+		client:   f.client,
+		endpoint: logAPIEndpoint(&f.config),
+		license:  f.config.License,
+		common: map[string]interface{}{
+			rAttEntityGUID: cfg.EntityGUID,
+			rAttPluginType: logRecordModifierSource,
+			rAttHostname:   cfg.Hostname,
+		},
+		offsets:       f.offsets,
+		flushInterval: nativeFlushInterval,
+		retryBackoff:  nativeFirstRetryBackoff,
+	}
+	senderDone := make(chan struct{})
+	go func() {
+		defer close(senderDone)
+		sender.run(ctx, records)
+	}()
+
+	inputs := sync.WaitGroup{}
+	for _, block := range cfg.Inputs {
+		clog := cfgLogger.WithField("name", block.Name)
+		filter, err := newLineFilter(block)
+		if err != nil {
+			clog.WithError(err).Warn("invalid log pattern, ignoring log source")
+			continue
+		}
+		masker, err := newRecordMasker(block, agentMask)
+		if err != nil {
+			clog.WithError(err).Warn("invalid mask configuration, ignoring log source")
+			continue
+		}
+		if block.Containers != nil {
+			tailer := newContainersTailer(block, filter, masker, f.offsets, records)
+			inputs.Add(1)
+			go func() {
+				defer inputs.Done()
+				tailer.run(ctx)
+			}()
+		} else if block.File != "" {
+			tailer := newFileTailer(block, filter, masker, f.offsets, records)
+			inputs.Add(1)
+			go func() {
+				defer inputs.Done()
+				tailer.run(ctx)
+			}()
+		} else if block.Tcp != nil {
+			listener := newTcpListener(block, filter, masker, records)
+			ln, err := listener.listen()
+			if err != nil {
+				clog.WithError(err).Warn("cannot listen for logs, ignoring log source")
+				continue
+			}
+			inputs.Add(1)
+			go func() {
+				defer inputs.Done()
+				listener.run(ctx, ln)
+			}()
+		}
+	}
+
+	inputs.Wait()
+	close(records)
+	<-senderDone
+}
+
+// recordAttributes returns the attributes that are added to all the records of a log source, mimicking the
+// attributes added by the Fluent Bit forwarder.
+func recordAttributes(l LogCfg, inputType string) map[string]interface{} {
+	attributes := map[string]interface{}{rAttFbInput: inputType}
+	for key, value := range l.Attributes {
+		if !isReserved(key) {
+			attributes[key] = value
+		} else {
+			cfgLogger.WithField("attribute", key).Warn("attribute name is a reserved keyword and will be ignored, please use a different name")
+		}
+	}
+	return attributes
+}
+
+// lineFilter selects the records to forward, from the pattern and the exclude patterns of a log source.
+type lineFilter struct {
+	include  *regexp.Regexp // nil if all the records are included
+	excludes []*regexp.Regexp
+}
+
+func newLineFilter(l LogCfg) (*lineFilter, error) {
+	filter := &lineFilter{}
+	if l.Pattern != "" {
+		var err error
+		if filter.include, err = regexp.Compile(l.Pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, pattern := range l.ExcludePattern {
+		exclude, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.excludes = append(filter.excludes, exclude)
+	}
+	return filter, nil
+}
+
+// matches returns true if the record must be forwarded.
+func (f *lineFilter) matches(line string) bool {
+	if f == nil {
+		return true
+	}
+	if f.include != nil && !f.include.MatchString(line) {
+		return false
+	}
+	for _, exclude := range f.excludes {
+		if exclude.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// newRecordMasker returns the masker for the records of a log source: its own mask rules, followed by the
+// agent-wide ones.
+func newRecordMasker(l LogCfg, agentMask []maskRule) (recordMasker, error) {
+	var masker recordMasker
+	if l.Mask != nil {
+		rules, err := newMaskRules(*l.Mask)
+		if err != nil {
+			return nil, err
+		}
+		masker = append(masker, rules...)
+	}
+	return append(masker, agentMask...), nil
+}
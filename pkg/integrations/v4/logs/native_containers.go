@@ -0,0 +1,331 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
+)
+
+// Container runtimes whose discovery provides the containers metadata.
+const (
+	containerRuntimeDocker = "docker"
+	containerRuntimeCRI    = "cri"
+)
+
+const (
+	defaultDockerRoot          = "/var/lib/docker"
+	defaultPodsLogDir          = "/var/log/containers"
+	containerDiscoveryInterval = 10 * time.Second
+	maxContainerRecordBytes    = 1024 * 1024 // records split across more lines are sent in several parts
+	nativeInputTypeContainers  = "containers"
+)
+
+// Container record attributes.
+const (
+	rAttContainerID    = "containerId"
+	rAttContainerName  = "containerName"
+	rAttContainerImage = "containerImage"
+	rAttPodName        = "podName"
+	rAttNamespace      = "namespace"
+	rAttStream         = "stream"
+	rAttLabelPrefix    = "label."
+)
+
+// Kubernetes container labels, used when the logs are not read from the pods log directory.
+const (
+	k8sPodNameLabel   = "io.kubernetes.pod.name"
+	k8sNamespaceLabel = "io.kubernetes.pod.namespace"
+)
+
+// podLogFileRegex matches the kubelet log files layout: <pod>_<namespace>_<container>-<container id>.log
+var podLogFileRegex = regexp.MustCompile(`^([^_]+)_([^_]+)_(.+)-([0-9a-f]{64})\.log$`)
+
+// criLogLineRegex matches the CRI log format: <time> <stream> <P(artial)|F(ull)> <message>
+var criLogLineRegex = regexp.MustCompile(`^(\S+) (stdout|stderr) ([PF]) ?(.*)$`)
+
+// LogContainersCfg forwards the logs of the containers running in the host, along with their metadata. The
+// containers are discovered through the container runtime, and their logs are read from the kubelet pods
+// log directory or the docker containers directory. Only supported by the native forwarder.
+//
+//	containers:
+//	  runtime: docker
+//	  match:
+//	    image: /nginx/
+//	    label.app: frontend
+type LogContainersCfg struct {
+	Runtime    string            `yaml:"runtime"`      // docker (default) or cri
+	Socket     string            `yaml:"socket"`       // container runtime socket, when not using the default one
+	Match      map[string]string `yaml:"match"`        // discovery fields (name, image, label.<name>...). Default: all
+	DockerRoot string            `yaml:"docker_root"`  // Default: /var/lib/docker
+	PodsLogDir string            `yaml:"pods_log_dir"` // Default: /var/log/containers
+}
+
+// validate returns an error if the containers discovery can't be configured.
+func (c *LogContainersCfg) validate() error {
+	switch c.Runtime {
+	case "", containerRuntimeDocker, containerRuntimeCRI:
+	default:
+		return fmt.Errorf("containers: unknown runtime %q, valid values are: docker, cri", c.Runtime)
+	}
+	for field, value := range c.Match {
+		if len(value) > 1 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+			if _, err := regexp.Compile(value[1 : len(value)-1]); err != nil {
+				return fmt.Errorf("containers: invalid match regex for %q: %s", field, err)
+			}
+		}
+	}
+	return nil
+}
+
+// containerLogs keeps track of the log files of the discovered containers and their metadata.
+type containerLogs struct {
+	cfg           LogContainersCfg
+	discover      func() ([]data.Map, error) // discovered containers variables
+	lastDiscovery time.Time
+	files         map[string]map[string]interface{} // key: path, value: container attributes
+}
+
+func newContainerLogs(cfg LogContainersCfg) *containerLogs {
+	if cfg.DockerRoot == "" {
+		cfg.DockerRoot = defaultDockerRoot
+	}
+	if cfg.PodsLogDir == "" {
+		cfg.PodsLogDir = defaultPodsLogDir
+	}
+	return &containerLogs{
+		cfg:      cfg,
+		discover: runtimeDiscoverer(cfg),
+		files:    map[string]map[string]interface{}{},
+	}
+}
+
+// newContainersTailer returns a file tailer following the log files of the discovered containers.
+func newContainersTailer(cfg LogCfg, filter *lineFilter, masker recordMasker, offsets *offsetStore, records chan<- nativeRecord) *fileTailer {
+	tailer := newFileTailer(cfg, filter, masker, offsets, records)
+	containers := newContainerLogs(*cfg.Containers)
+	tailer.attributes = recordAttributes(cfg, nativeInputTypeContainers)
+	tailer.listFiles = containers.paths
+	tailer.fileAttributes = containers.attributes
+	tailer.decode = decodeContainerLine
+	return tailer
+}
+
+// runtimeDiscoverer returns the discovery of the containers through the data binding discovery sources.
+func runtimeDiscoverer(cfg LogContainersCfg) func() ([]data.Map, error) {
+	runtime := cfg.Runtime
+	if runtime == "" {
+		runtime = containerRuntimeDocker
+	}
+	match := cfg.Match
+	if len(match) == 0 {
+		// discovery requires at least one criteria
+		match = map[string]string{data.ContainerID: "/.+/"}
+	}
+	section := map[string]interface{}{"match": match}
+	if cfg.Socket != "" {
+		section["socket"] = cfg.Socket
+	}
+	content, err := yaml.Marshal(map[string]interface{}{
+		"discovery": map[string]interface{}{
+			"ttl":   containerDiscoveryInterval.String(),
+			runtime: section,
+		},
+	})
+	var sources *databind.Sources
+	if err == nil {
+		sources, err = databind.LoadYAML(content)
+	}
+	return func() ([]data.Map, error) {
+		if err != nil {
+			return nil, err
+		}
+		values, err := databind.Fetch(sources)
+		if err != nil {
+			return nil, err
+		}
+		return values.Discovered(), nil
+	}
+}
+
+// paths returns the log files of the containers, refreshing the discovery periodically.
+func (c *containerLogs) paths() ([]string, error) {
+	if time.Since(c.lastDiscovery) >= containerDiscoveryInterval {
+		c.lastDiscovery = time.Now()
+		files, err := c.discoverFiles()
+		if err != nil {
+			cfgLogger.WithError(err).Warn("cannot discover containers, following the previously discovered ones")
+		} else {
+			c.files = files
+		}
+	}
+	paths := make([]string, 0, len(c.files))
+	for path := range c.files {
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// attributes returns the metadata of the container writing the log file.
+func (c *containerLogs) attributes(path string) map[string]interface{} {
+	return c.files[path]
+}
+
+// discoverFiles returns the log files of the matching containers. If the container runtime can't be queried,
+// the containers in the pods log directory are followed with the metadata from their file names, unless
+// specific containers were selected.
+func (c *containerLogs) discoverFiles() (map[string]map[string]interface{}, error) {
+	podLogs := c.podLogFiles()
+	discovered, err := c.discover()
+	if err != nil {
+		if len(c.cfg.Match) > 0 {
+			return nil, fmt.Errorf("cannot discover containers: %s", err)
+		}
+		cfgLogger.WithError(err).Debug("Cannot discover containers, forwarding the pods log directory files.")
+		files := map[string]map[string]interface{}{}
+		for _, pod := range podLogs {
+			files[pod.path] = pod.attributes()
+		}
+		return files, nil
+	}
+
+	files := map[string]map[string]interface{}{}
+	for _, variables := range discovered {
+		id := variables[data.DiscoveryPrefix+data.ContainerID]
+		if id == "" {
+			continue
+		}
+		attributes := containerAttributes(variables)
+		if pod, ok := podLogs[id]; ok {
+			// the pods log directory names the containers after their pod spec
+			for key, value := range pod.attributes() {
+				attributes[key] = value
+			}
+			files[pod.path] = attributes
+			continue
+		}
+		dockerLog := filepath.Join(c.cfg.DockerRoot, "containers", id, id+"-json.log")
+		if _, err := os.Stat(dockerLog); err == nil {
+			files[dockerLog] = attributes
+		}
+	}
+	return files, nil
+}
+
+// podLogFile is a container log file in the kubelet pods log directory.
+type podLogFile struct {
+	path      string
+	podName   string
+	namespace string
+	container string
+	id        string
+}
+
+func (p podLogFile) attributes() map[string]interface{} {
+	return map[string]interface{}{
+		rAttContainerID:   p.id,
+		rAttContainerName: p.container,
+		rAttPodName:       p.podName,
+		rAttNamespace:     p.namespace,
+	}
+}
+
+// podLogFiles returns the log files of the pods log directory, by container ID.
+func (c *containerLogs) podLogFiles() map[string]podLogFile {
+	files := map[string]podLogFile{}
+	entries, err := filepath.Glob(filepath.Join(c.cfg.PodsLogDir, "*.log"))
+	if err != nil {
+		return files
+	}
+	for _, path := range entries {
+		parts := podLogFileRegex.FindStringSubmatch(filepath.Base(path))
+		if parts == nil {
+			continue
+		}
+		files[parts[4]] = podLogFile{path: path, podName: parts[1], namespace: parts[2], container: parts[3], id: parts[4]}
+	}
+	return files
+}
+
+// containerAttributes returns the record attributes from the discovered container variables.
+func containerAttributes(variables data.Map) map[string]interface{} {
+	attributes := map[string]interface{}{
+		rAttContainerID:    variables[data.DiscoveryPrefix+data.ContainerID],
+		rAttContainerName:  variables[data.DiscoveryPrefix+data.Name],
+		rAttContainerImage: variables[data.DiscoveryPrefix+data.Image],
+	}
+	labelPrefix := data.DiscoveryPrefix + data.LabelInfix
+	for key, value := range variables {
+		if strings.HasPrefix(key, labelPrefix) {
+			attributes[rAttLabelPrefix+strings.TrimPrefix(key, labelPrefix)] = value
+		}
+	}
+	if podName, ok := variables[data.DiscoveryPrefix+data.PodName]; ok {
+		attributes[rAttPodName] = podName
+	} else if podName, ok := variables[labelPrefix+k8sPodNameLabel]; ok {
+		attributes[rAttPodName] = podName
+	}
+	if namespace, ok := variables[data.DiscoveryPrefix+data.Namespace]; ok {
+		attributes[rAttNamespace] = namespace
+	} else if namespace, ok := variables[labelPrefix+k8sNamespaceLabel]; ok {
+		attributes[rAttNamespace] = namespace
+	}
+	return attributes
+}
+
+// dockerLogLine is a line of the docker json-file logging driver.
+type dockerLogLine struct {
+	Log    string `json:"log"`
+	Stream string `json:"stream"`
+	Time   string `json:"time"`
+}
+
+// decodeContainerLine extracts the log record from a docker JSON or CRI log line. Records split across
+// several lines are joined.
+func decodeContainerLine(tf *tailedFile, line string) (message string, attributes map[string]interface{}, timestamp time.Time, ok bool) {
+	var stream, logTime string
+	partial := false
+	if strings.HasPrefix(line, "{") {
+		var dl dockerLogLine
+		if err := json.Unmarshal([]byte(line), &dl); err != nil {
+			return line, nil, time.Now(), true
+		}
+		// docker splits the long records into several lines, and only the last one ends with a newline
+		partial = !strings.HasSuffix(dl.Log, "\n")
+		message, stream, logTime = strings.TrimRight(dl.Log, "\r\n"), dl.Stream, dl.Time
+	} else if parts := criLogLineRegex.FindStringSubmatch(line); parts != nil {
+		partial = parts[3] == "P"
+		message, stream, logTime = parts[4], parts[2], parts[1]
+	} else {
+		return line, nil, time.Now(), true
+	}
+
+	// the stdout and stderr records can be interleaved, so their fragments are joined separately
+	pending := tf.pending[stream]
+	if partial && len(pending)+len(message) < maxContainerRecordBytes {
+		if tf.pending == nil {
+			tf.pending = map[string][]byte{}
+		}
+		tf.pending[stream] = append(pending, message...)
+		return "", nil, time.Time{}, false
+	}
+	if len(pending) > 0 {
+		message = string(pending) + message
+		delete(tf.pending, stream)
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, logTime)
+	if err != nil {
+		timestamp = time.Now()
+	}
+	return message, map[string]interface{}{rAttStream: stream}, timestamp, true
+}
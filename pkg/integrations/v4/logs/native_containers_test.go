@@ -0,0 +1,279 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/data"
+)
+
+var (
+	podContainerID    = strings.Repeat("a", 64)
+	dockerContainerID = strings.Repeat("b", 64)
+)
+
+// containersDirs creates a pods log directory and a docker root with a log file each.
+func containersDirs(t *testing.T) (dir string, podLog string, dockerLog string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "native-containers")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "pods"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "docker", "containers", dockerContainerID), 0755))
+	podLog = filepath.Join(dir, "pods", "frontend-7d9f_shop_nginx-"+podContainerID+".log")
+	dockerLog = filepath.Join(dir, "docker", "containers", dockerContainerID, dockerContainerID+"-json.log")
+	appendTo(t, podLog, "")
+	appendTo(t, dockerLog, "")
+	appendTo(t, filepath.Join(dir, "pods", "not-a-container.log"), "")
+	return dir, podLog, dockerLog
+}
+
+func testContainerLogs(dir string, match map[string]string, discover func() ([]data.Map, error)) *containerLogs {
+	c := newContainerLogs(LogContainersCfg{
+		Match:      match,
+		DockerRoot: filepath.Join(dir, "docker"),
+		PodsLogDir: filepath.Join(dir, "pods"),
+	})
+	c.discover = discover
+	return c
+}
+
+func TestContainerLogs_Discovered(t *testing.T) {
+	dir, podLog, dockerLog := containersDirs(t)
+	defer os.RemoveAll(dir)
+
+	// GIVEN two discovered containers: one from a kubernetes pod and a plain docker one
+	containers := testContainerLogs(dir, nil, func() ([]data.Map, error) {
+		return []data.Map{{
+			"discovery.containerId": podContainerID,
+			"discovery.name":        "k8s_nginx_frontend-7d9f_shop_0",
+			"discovery.image":       "nginx:1.19",
+			"discovery.label.app":   "frontend",
+		}, {
+			"discovery.containerId":                       dockerContainerID,
+			"discovery.name":                              "redis",
+			"discovery.image":                             "redis:6",
+			"discovery.label.io.kubernetes.pod.name":      "cache",
+			"discovery.label.io.kubernetes.pod.namespace": "shop",
+		}, {
+			"discovery.containerId": strings.Repeat("c", 64), // without log file
+		}}, nil
+	})
+
+	// WHEN their log files are looked for
+	paths, err := containers.paths()
+	require.NoError(t, err)
+
+	// THEN the files of both are found, along with their metadata
+	assert.ElementsMatch(t, []string{podLog, dockerLog}, paths)
+	assert.Equal(t, map[string]interface{}{
+		"containerId":    podContainerID,
+		"containerName":  "nginx",
+		"containerImage": "nginx:1.19",
+		"podName":        "frontend-7d9f",
+		"namespace":      "shop",
+		"label.app":      "frontend",
+	}, containers.attributes(podLog))
+	assert.Equal(t, map[string]interface{}{
+		"containerId":                       dockerContainerID,
+		"containerName":                     "redis",
+		"containerImage":                    "redis:6",
+		"podName":                           "cache",
+		"namespace":                         "shop",
+		"label.io.kubernetes.pod.name":      "cache",
+		"label.io.kubernetes.pod.namespace": "shop",
+	}, containers.attributes(dockerLog))
+}
+
+func TestContainerLogs_RuntimeUnavailable(t *testing.T) {
+	dir, podLog, _ := containersDirs(t)
+	defer os.RemoveAll(dir)
+	unavailable := func() ([]data.Map, error) { return nil, errors.New("cannot connect to the docker daemon") }
+
+	// WHEN the container runtime can't be queried
+	containers := testContainerLogs(dir, nil, unavailable)
+	paths, err := containers.paths()
+	require.NoError(t, err)
+
+	// THEN the pods log directory files are followed, with the metadata from their names
+	assert.Equal(t, []string{podLog}, paths)
+	assert.Equal(t, map[string]interface{}{
+		"containerId":   podContainerID,
+		"containerName": "nginx",
+		"podName":       "frontend-7d9f",
+		"namespace":     "shop",
+	}, containers.attributes(podLog))
+
+	// UNLESS specific containers were selected
+	containers = testContainerLogs(dir, map[string]string{"image": "/nginx/"}, unavailable)
+	paths, err = containers.paths()
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+}
+
+func TestDecodeContainerLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []string
+		message   string
+		stream    string
+		timestamp string
+	}{
+		{"docker",
+			[]string{`{"log":"GET /index.html 200\n","stream":"stdout","time":"2021-03-04T10:20:30.123456789Z"}`},
+			"GET /index.html 200", "stdout", "2021-03-04T10:20:30.123456789Z"},
+		{"docker split record",
+			[]string{`{"log":"first part, ","stream":"stderr","time":"2021-03-04T10:20:30Z"}`, `{"log":"second part\n","stream":"stderr","time":"2021-03-04T10:20:31Z"}`},
+			"first part, second part", "stderr", "2021-03-04T10:20:31Z"},
+		{"cri",
+			[]string{`2021-03-04T10:20:30.5Z stdout F GET /index.html 200`},
+			"GET /index.html 200", "stdout", "2021-03-04T10:20:30.5Z"},
+		{"cri split record",
+			[]string{`2021-03-04T10:20:30Z stderr P first part, `, `2021-03-04T10:20:31Z stderr F second part`},
+			"first part, second part", "stderr", "2021-03-04T10:20:31Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tf := &tailedFile{}
+			for _, line := range tt.lines[:len(tt.lines)-1] {
+				_, _, _, ok := decodeContainerLine(tf, line)
+				assert.False(t, ok)
+			}
+
+			message, attributes, timestamp, ok := decodeContainerLine(tf, tt.lines[len(tt.lines)-1])
+
+			require.True(t, ok)
+			assert.Equal(t, tt.message, message)
+			assert.Equal(t, map[string]interface{}{"stream": tt.stream}, attributes)
+			expected, err := time.Parse(time.RFC3339Nano, tt.timestamp)
+			require.NoError(t, err)
+			assert.True(t, expected.Equal(timestamp))
+			assert.Empty(t, tf.pending)
+		})
+	}
+}
+
+func TestDecodeContainerLine_InterleavedStreams(t *testing.T) {
+	for name, lines := range map[string][]string{
+		"docker": {
+			`{"log":"out first, ","stream":"stdout","time":"2021-03-04T10:20:30Z"}`,
+			`{"log":"err first, ","stream":"stderr","time":"2021-03-04T10:20:31Z"}`,
+			`{"log":"out second\n","stream":"stdout","time":"2021-03-04T10:20:32Z"}`,
+			`{"log":"err second\n","stream":"stderr","time":"2021-03-04T10:20:33Z"}`,
+		},
+		"cri": {
+			`2021-03-04T10:20:30Z stdout P out first, `,
+			`2021-03-04T10:20:31Z stderr P err first, `,
+			`2021-03-04T10:20:32Z stdout F out second`,
+			`2021-03-04T10:20:33Z stderr F err second`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// GIVEN the partial lines of a stdout and a stderr record written at the same time
+			tf := &tailedFile{}
+			for _, line := range lines[:2] {
+				_, _, _, ok := decodeContainerLine(tf, line)
+				assert.False(t, ok)
+			}
+
+			// WHEN each record is completed
+			outMessage, outAttributes, _, outOk := decodeContainerLine(tf, lines[2])
+			errMessage, errAttributes, _, errOk := decodeContainerLine(tf, lines[3])
+
+			// THEN the fragments of each stream are joined separately
+			require.True(t, outOk)
+			assert.Equal(t, "out first, out second", outMessage)
+			assert.Equal(t, map[string]interface{}{"stream": "stdout"}, outAttributes)
+			require.True(t, errOk)
+			assert.Equal(t, "err first, err second", errMessage)
+			assert.Equal(t, map[string]interface{}{"stream": "stderr"}, errAttributes)
+			assert.Empty(t, tf.pending)
+		})
+	}
+}
+
+func TestDecodeContainerLine_UnknownFormat(t *testing.T) {
+	message, attributes, _, ok := decodeContainerLine(&tailedFile{}, "plain line")
+
+	assert.True(t, ok)
+	assert.Equal(t, "plain line", message)
+	assert.Nil(t, attributes)
+}
+
+func TestContainersTailer(t *testing.T) {
+	dir, podLog, _ := containersDirs(t)
+	defer os.RemoveAll(dir)
+
+	// GIVEN a tailer for the logs of the containers
+	records := make(chan nativeRecord, 100)
+	cfg := LogCfg{Name: "containers", Containers: &LogContainersCfg{}, Attributes: map[string]string{"team": "infra"}}
+	tailer := newContainersTailer(cfg, nil, nil, loadOffsetStore(filepath.Join(dir, "offsets.json")), records)
+	containers := testContainerLogs(dir, nil, func() ([]data.Map, error) {
+		return []data.Map{{"discovery.containerId": podContainerID, "discovery.image": "nginx:1.19"}}, nil
+	})
+	tailer.listFiles = containers.paths
+	tailer.fileAttributes = containers.attributes
+	ctx := context.Background()
+	tailer.poll(ctx)
+
+	// WHEN a container writes a record
+	appendTo(t, podLog, "2021-03-04T10:20:30Z stdout F ready to accept connections\n")
+	tailer.poll(ctx)
+
+	// THEN it is forwarded with the container metadata
+	r := receive(t, records)
+	assert.Equal(t, "ready to accept connections", r.message)
+	assert.Equal(t, "2021-03-04T10:20:30Z", r.timestamp.UTC().Format(time.RFC3339))
+	assert.Equal(t, "containers", r.attributes[rAttFbInput])
+	assert.Equal(t, "infra", r.attributes["team"])
+	assert.Equal(t, "nginx:1.19", r.attributes["containerImage"])
+	assert.Equal(t, "nginx", r.attributes["containerName"])
+	assert.Equal(t, "frontend-7d9f", r.attributes["podName"])
+	assert.Equal(t, "shop", r.attributes["namespace"])
+	assert.Equal(t, "stdout", r.attributes["stream"])
+	assert.Equal(t, podLog, r.attributes[nativePathKey])
+}
+
+func TestLogCfg_IsValid_Containers(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    LogCfg
+		valid  bool
+		native bool
+	}{
+		{"default forwarder", LogCfg{Name: "n", Containers: &LogContainersCfg{}}, true, true},
+		{"native forwarder", LogCfg{Name: "n", Forwarder: ForwarderNative, Containers: &LogContainersCfg{Runtime: "cri", Match: map[string]string{"image": "/nginx/"}}}, true, true},
+		{"fluent bit forwarder", LogCfg{Name: "n", Forwarder: ForwarderFluentBit, Containers: &LogContainersCfg{}}, false, false},
+		{"unknown runtime", LogCfg{Name: "n", Containers: &LogContainersCfg{Runtime: "lxc"}}, false, true},
+		{"invalid match regex", LogCfg{Name: "n", Containers: &LogContainersCfg{Match: map[string]string{"image": "/ngin(x/"}}}, false, true},
+		{"multiline", LogCfg{Name: "n", Containers: &LogContainersCfg{}, Multiline: &LogMultilineCfg{Preset: "java"}}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valid, tt.cfg.IsValid())
+			assert.Equal(t, tt.native, tt.cfg.IsNative())
+		})
+	}
+}
+
+func TestRuntimeDiscoverer_UnavailableRuntime(t *testing.T) {
+	for _, runtime := range []string{"", "docker", "cri"} {
+		t.Run(runtime, func(t *testing.T) {
+			discover := runtimeDiscoverer(LogContainersCfg{Runtime: runtime, Socket: "/non-existing/runtime.sock"})
+
+			_, err := discover()
+
+			assert.Error(t, err)
+		})
+	}
+}
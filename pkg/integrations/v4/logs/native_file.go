@@ -0,0 +1,288 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	nativePollInterval = time.Second
+	nativeReadChunk    = 64 * 1024
+	nativePathKey      = "filePath"
+)
+
+// fileTailer follows the files matching a glob, emitting a record per each new line.
+type fileTailer struct {
+	cfg            LogCfg
+	filter         *lineFilter
+	masker         recordMasker
+	maxLineBytes   int
+	attributes     map[string]interface{}
+	listFiles      func() ([]string, error)                 // files to follow
+	fileAttributes func(path string) map[string]interface{} // optional, added to the records of each file
+	decode         lineDecoder                              // optional, for lines wrapping the log records
+	offsets        *offsetStore
+	records        chan<- nativeRecord
+	files          map[string]*tailedFile // key: path
+	initialized    bool                   // false until the first glob evaluation
+}
+
+// lineDecoder extracts the log record from a line. It returns false if the line doesn't complete a record.
+type lineDecoder func(tf *tailedFile, line string) (message string, attributes map[string]interface{}, timestamp time.Time, ok bool)
+
+// tailedFile is an open file that is being followed.
+type tailedFile struct {
+	path     string
+	file     *os.File
+	info     os.FileInfo
+	id       uint64
+	offset   int64             // position after the last complete line read
+	partial  []byte            // incomplete line, waiting for its end
+	skipping bool              // the current line is longer than the maximum and is being discarded
+	pending  map[string][]byte // decoded messages split across several lines, waiting for their end. key: stream
+}
+
+func newFileTailer(cfg LogCfg, filter *lineFilter, masker recordMasker, offsets *offsetStore, records chan<- nativeRecord) *fileTailer {
+	return &fileTailer{
+		cfg:          cfg,
+		filter:       filter,
+		masker:       masker,
+		maxLineBytes: getBufferMaxSize(cfg) * 1024,
+		attributes:   recordAttributes(cfg, fbInputTypeTail),
+		listFiles: func() ([]string, error) {
+			return filepath.Glob(cfg.File)
+		},
+		offsets: offsets,
+		records: records,
+		files:   map[string]*tailedFile{},
+	}
+}
+
+// run polls the files until the context is cancelled.
+func (t *fileTailer) run(ctx context.Context) {
+	ticker := time.NewTicker(nativePollInterval)
+	defer ticker.Stop()
+	defer t.close()
+	for {
+		t.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll looks for new, rotated, truncated and removed files, and reads the new lines of all of them.
+func (t *fileTailer) poll(ctx context.Context) {
+	paths, err := t.listFiles()
+	if err != nil {
+		cfgLogger.WithError(err).WithField("name", t.cfg.Name).Warn("cannot list log files")
+		return
+	}
+
+	matched := map[string]bool{}
+	for _, path := range paths {
+		matched[path] = true
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		tf, ok := t.files[path]
+		rotated := ok && !os.SameFile(tf.info, info)
+		if rotated {
+			// finishing the old file before following the new one
+			t.read(ctx, tf)
+			t.flush(ctx, tf)
+			tf.file.Close()
+			t.offsets.forget(path, tf.id)
+			ok = false
+		}
+		if !ok {
+			if tf, ok = t.open(path, info, rotated); !ok {
+				continue
+			}
+			t.files[path] = tf
+		}
+		if info.Size() < tf.offset {
+			cfgLogger.WithField("file", path).Debug("Log file truncated, reading from the beginning.")
+			tf.offset = 0
+			tf.partial = nil
+			tf.skipping = false
+			if _, err := tf.file.Seek(0, io.SeekStart); err != nil {
+				cfgLogger.WithError(err).WithField("file", path).Warn("cannot read truncated log file")
+			}
+		}
+		tf.info = info
+		t.read(ctx, tf)
+	}
+
+	for path, tf := range t.files {
+		if !matched[path] {
+			// removed or renamed: the remaining lines are read before forgetting the file
+			t.read(ctx, tf)
+			t.flush(ctx, tf)
+			tf.file.Close()
+			delete(t.files, path)
+			t.offsets.forget(path, tf.id)
+		}
+	}
+	t.initialized = true
+}
+
+// open starts following a file from its stored offset. Rotated files and files without offset are read
+// from the beginning, unless they already existed when the forwarder started. Files whose stored offset
+// belongs to another file (rotated while the forwarder was stopped) or is beyond their size (truncated)
+// are read from the beginning too.
+func (t *fileTailer) open(path string, info os.FileInfo, rotated bool) (*tailedFile, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		cfgLogger.WithError(err).WithField("file", path).Warn("cannot open log file")
+		return nil, false
+	}
+	tf := &tailedFile{path: path, file: file, info: info, id: fileID(info)}
+	t.offsets.follow(path, tf.id)
+
+	if rotated {
+		// stored at once, so a restart before its first commit doesn't read the new file from the end
+		tf.offset = 0
+		t.offsets.set(path, fileOffset{ID: tf.id})
+	} else if stored, ok := t.offsets.get(path); ok {
+		if stored.ID == tf.id && stored.Offset <= info.Size() {
+			tf.offset = stored.Offset
+		}
+	} else if !t.initialized {
+		tf.offset = info.Size()
+	}
+	if _, err := file.Seek(tf.offset, io.SeekStart); err != nil {
+		cfgLogger.WithError(err).WithField("file", path).Warn("cannot read log file")
+		file.Close()
+		return nil, false
+	}
+	return tf, true
+}
+
+// read emits a record for each complete line available in the file.
+func (t *fileTailer) read(ctx context.Context, tf *tailedFile) {
+	buf := make([]byte, nativeReadChunk)
+	for {
+		n, err := tf.file.Read(buf)
+		if n > 0 {
+			t.lines(ctx, tf, buf[:n])
+		}
+		if err != nil || n == 0 || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// flush emits the incomplete last line of a file that is not followed anymore (e.g. rotated), as it
+// won't be finished.
+func (t *fileTailer) flush(ctx context.Context, tf *tailedFile) {
+	if len(tf.partial) == 0 || tf.skipping {
+		return
+	}
+	line := tf.partial
+	tf.offset += int64(len(line))
+	tf.partial = nil
+	t.emit(ctx, tf, string(bytes.TrimRight(line, "\r")))
+}
+
+func (t *fileTailer) lines(ctx context.Context, tf *tailedFile, chunk []byte) {
+	// position of the first byte of the chunk
+	pos := tf.offset + int64(len(tf.partial))
+	if tf.skipping {
+		pos = tf.offset
+	}
+	for len(chunk) > 0 {
+		end := bytes.IndexByte(chunk, '\n')
+		if end < 0 {
+			if !tf.skipping {
+				tf.partial = append(tf.partial, chunk...)
+				if len(tf.partial) > t.maxLineBytes {
+					cfgLogger.WithField("file", tf.path).Debug("Skipping log line longer than the maximum size.")
+					tf.partial = nil
+					tf.skipping = true
+				}
+			}
+			if tf.skipping {
+				// skipped bytes are accounted in the offset, so they are not read again
+				tf.offset = pos + int64(len(chunk))
+			}
+			return
+		}
+		line := chunk[:end]
+		pos += int64(end + 1)
+		chunk = chunk[end+1:]
+		if tf.skipping {
+			tf.skipping = false
+			tf.offset = pos
+			continue
+		}
+		if len(tf.partial) > 0 {
+			line = append(tf.partial, line...)
+			tf.partial = nil
+		}
+		tf.offset = pos
+		if len(line) > t.maxLineBytes {
+			cfgLogger.WithField("file", tf.path).Debug("Skipping log line longer than the maximum size.")
+			continue
+		}
+		t.emit(ctx, tf, string(bytes.TrimRight(line, "\r")))
+	}
+}
+
+func (t *fileTailer) emit(ctx context.Context, tf *tailedFile, line string) {
+	timestamp := time.Now()
+	var lineAttributes map[string]interface{}
+	if t.decode != nil {
+		var ok bool
+		if line, lineAttributes, timestamp, ok = t.decode(tf, line); !ok {
+			return
+		}
+	}
+	// the offsets of the filtered out lines are committed along with the next sent record
+	if !t.filter.matches(line) {
+		return
+	}
+	attributes := make(map[string]interface{}, len(t.attributes)+len(lineAttributes)+1)
+	for k, v := range t.attributes {
+		attributes[k] = maskValue(t.masker, v)
+	}
+	if t.fileAttributes != nil {
+		for k, v := range t.fileAttributes(tf.path) {
+			attributes[k] = maskValue(t.masker, v)
+		}
+	}
+	for k, v := range lineAttributes {
+		attributes[k] = maskValue(t.masker, v)
+	}
+	attributes[nativePathKey] = tf.path
+
+	path, id, offset := tf.path, tf.id, tf.offset
+	record := nativeRecord{
+		timestamp:  timestamp,
+		message:    t.masker.mask(line),
+		attributes: attributes,
+		commit: func() {
+			t.offsets.set(path, fileOffset{ID: id, Offset: offset})
+		},
+	}
+	select {
+	case t.records <- record:
+	case <-ctx.Done():
+	}
+}
+
+func (t *fileTailer) close() {
+	for path, tf := range t.files {
+		tf.file.Close()
+		delete(t.files, path)
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+//go:build !windows
+// +build !windows
+
+package logs
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID returns the inode of the file, which allows detecting when a path is rotated.
+func fileID(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
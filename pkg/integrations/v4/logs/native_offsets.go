@@ -0,0 +1,114 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	nativeOffsetsDir  = "log-forwarder"
+	nativeOffsetsFile = "offsets.json"
+)
+
+// fileOffset is the position up to which the records of a file have been sent.
+type fileOffset struct {
+	ID     uint64 `json:"id"` // inode, or 0 if not available
+	Offset int64  `json:"offset"`
+}
+
+// offsetStore persists the offsets of the tailed files, so they are resumed after agent restarts.
+type offsetStore struct {
+	path    string
+	lock    sync.Mutex
+	offsets map[string]fileOffset // key: file path
+	dirty   bool
+	// forgotten holds the ID of the files that are no longer tailed, so the offsets of their records that are
+	// still being sent are not stored back. key: file path
+	forgotten map[string]uint64
+}
+
+// loadOffsetStore loads the offsets stored in the given path, if any.
+func loadOffsetStore(path string) *offsetStore {
+	s := &offsetStore{path: path, offsets: map[string]fileOffset{}, forgotten: map[string]uint64{}}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			cfgLogger.WithError(err).WithField("file", path).Warn("cannot read log offsets, files will be read from the end")
+		}
+		return s
+	}
+	if err := json.Unmarshal(content, &s.offsets); err != nil {
+		cfgLogger.WithError(err).WithField("file", path).Warn("cannot parse log offsets, files will be read from the end")
+		s.offsets = map[string]fileOffset{}
+	}
+	return s
+}
+
+func (s *offsetStore) get(file string) (fileOffset, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	o, ok := s.offsets[file]
+	return o, ok
+}
+
+// set stores the offset of a file, unless the file has been forgotten.
+func (s *offsetStore) set(file string, o fileOffset) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if id, ok := s.forgotten[file]; ok && id == o.ID {
+		return
+	}
+	s.offsets[file] = o
+	s.dirty = true
+}
+
+// forget removes the offset of a file that is no longer tailed and ignores the further offsets of its records.
+func (s *offsetStore) forget(file string, id uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.forgotten[file] = id
+	if o, ok := s.offsets[file]; ok && o.ID == id {
+		delete(s.offsets, file)
+		s.dirty = true
+	}
+}
+
+// follow accepts again the offsets of a file that is tailed, in case it reuses the ID of a forgotten one.
+func (s *offsetStore) follow(file string, id uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if forgotten, ok := s.forgotten[file]; ok && forgotten == id {
+		delete(s.forgotten, file)
+	}
+}
+
+// save writes the offsets into disk, if they have changed since the last save.
+func (s *offsetStore) save() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	content, err := json.Marshal(s.offsets)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	// writing into a temporary file first, so a crash never leaves a corrupt offsets file
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
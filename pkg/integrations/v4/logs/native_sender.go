@@ -0,0 +1,204 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Native forwarder batching and delivery defaults.
+const (
+	nativeMaxBatchRecords   = 1000
+	nativeMaxBatchBytes     = 1000000 // the Log API rejects payloads bigger than 1MB
+	nativeRecordOverhead    = 64      // estimated JSON size of a record besides its message and attributes
+	nativeFlushInterval     = 5 * time.Second
+	nativeFirstRetryBackoff = time.Second
+	nativeMaxRetryBackoff   = time.Minute
+	nativeHTTPTimeout       = 30 * time.Second
+	logAPILicenseHeader     = "X-License-Key"
+)
+
+// nativeRecord log record read by the in-process forwarder inputs.
+type nativeRecord struct {
+	timestamp  time.Time
+	message    string
+	attributes map[string]interface{}
+	// commit is invoked once the record has been delivered (e.g. to store the file offset). It can be nil.
+	commit func()
+}
+
+// logAPIRecord is each of the records in a Log API payload.
+type logAPIRecord struct {
+	Timestamp  int64                  `json:"timestamp"`
+	Message    string                 `json:"message"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// logAPIPayload is a Log API detailed JSON payload.
+type logAPIPayload struct {
+	Common struct {
+		Attributes map[string]interface{} `json:"attributes"`
+	} `json:"common"`
+	Logs []logAPIRecord `json:"logs"`
+}
+
+// logSender batches the records and posts them, gzipped, to the Log API.
+type logSender struct {
+	client        *http.Client
+	endpoint      string
+	license       string
+	common        map[string]interface{}
+	offsets       *offsetStore // nil if no offsets must be stored
+	flushInterval time.Duration
+	retryBackoff  time.Duration
+}
+
+// run sends the received records until the records channel is closed. The context cancellation only
+// interrupts the retries of the failed submissions.
+func (s *logSender) run(ctx context.Context, records <-chan nativeRecord) {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	var batch []nativeRecord
+	batchBytes := 0
+	flush := func() {
+		if len(batch) > 0 {
+			s.send(ctx, batch)
+			batch = nil
+			batchBytes = 0
+		}
+	}
+	for {
+		select {
+		case record, ok := <-records:
+			if !ok {
+				flush()
+				return
+			}
+			size := recordSize(record)
+			if batchBytes+size > nativeMaxBatchBytes {
+				flush()
+			}
+			batch = append(batch, record)
+			batchBytes += size
+			if len(batch) >= nativeMaxBatchRecords {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// send posts the batch, retrying with a capped exponential backoff on network errors, throttling and server
+// errors until it is delivered or the context is cancelled. Once the batch is delivered (or discarded, if
+// rejected by the Log API), their records are committed. Batches interrupted by the cancellation are left
+// uncommitted, so they are read again after restarting.
+func (s *logSender) send(ctx context.Context, batch []nativeRecord) {
+	body, err := s.payload(batch)
+	if err != nil {
+		cfgLogger.WithError(err).Warn("cannot encode log records, discarding them")
+		s.commit(batch)
+		return
+	}
+
+	backoff := s.retryBackoff
+	for {
+		retry, err := s.post(body)
+		if err == nil {
+			s.commit(batch)
+			return
+		}
+		if !retry {
+			cfgLogger.WithError(err).WithField("records", len(batch)).Warn("log records rejected, discarding them")
+			s.commit(batch)
+			return
+		}
+		cfgLogger.WithError(err).WithField("retryIn", backoff).Debug("Cannot send log records, retrying.")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > nativeMaxRetryBackoff {
+			backoff = nativeMaxRetryBackoff
+		}
+	}
+}
+
+// payload returns the gzipped Log API payload for the batch.
+func (s *logSender) payload(batch []nativeRecord) ([]byte, error) {
+	payload := logAPIPayload{Logs: make([]logAPIRecord, 0, len(batch))}
+	payload.Common.Attributes = s.common
+	for _, r := range batch {
+		payload.Logs = append(payload.Logs, logAPIRecord{
+			Timestamp:  r.timestamp.UnixNano() / int64(time.Millisecond),
+			Message:    r.message,
+			Attributes: r.attributes,
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	if err := json.NewEncoder(zw).Encode([]logAPIPayload{payload}); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// post submits the payload. In case of error, it returns whether the submission can be retried.
+func (s *logSender) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set(logAPILicenseHeader, s.license)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("unexpected Log API response status: %s", resp.Status)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 ||
+		resp.StatusCode == http.StatusRequestTimeout, err
+}
+
+func (s *logSender) commit(batch []nativeRecord) {
+	for _, r := range batch {
+		if r.commit != nil {
+			r.commit()
+		}
+	}
+	if s.offsets != nil {
+		if err := s.offsets.save(); err != nil {
+			cfgLogger.WithError(err).Warn("cannot store log offsets")
+		}
+	}
+}
+
+func recordSize(r nativeRecord) int {
+	size := len(r.message) + nativeRecordOverhead
+	for k, v := range r.attributes {
+		size += len(k) + len(fmt.Sprint(v)) + 6
+	}
+	return size
+}
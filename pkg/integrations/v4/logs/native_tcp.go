@@ -0,0 +1,170 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tcpFormatNone        = "none"
+	defaultTcpSeparator  = "\n"
+	tcpJSONMessageField  = "message"
+	tcpJSONLogFieldAlias = "log"
+)
+
+// tcpListener receives log records from TCP connections, either as JSON objects or as plain text
+// separated by a configurable separator.
+type tcpListener struct {
+	cfg        LogCfg
+	filter     *lineFilter // only applies to the "none" format
+	masker     recordMasker
+	bufSize    int
+	attributes map[string]interface{}
+	records    chan<- nativeRecord
+	address    string // resolved once listening
+}
+
+func newTcpListener(cfg LogCfg, filter *lineFilter, masker recordMasker, records chan<- nativeRecord) *tcpListener {
+	return &tcpListener{
+		cfg:        cfg,
+		filter:     filter,
+		masker:     masker,
+		bufSize:    getBufferMaxSize(cfg) * 1024,
+		attributes: recordAttributes(cfg, fbInputTypeTcp),
+		records:    records,
+	}
+}
+
+// listen starts listening in the configured address.
+func (t *tcpListener) listen() (net.Listener, error) {
+	input, err := newTcpInput(*t.cfg.Tcp, t.cfg.Name, getBufferMaxSize(t.cfg))
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf("%s:%d", input.TcpListen, input.TcpPort))
+	if err != nil {
+		return nil, err
+	}
+	t.address = ln.Addr().String()
+	return ln, nil
+}
+
+// run accepts connections until the context is cancelled.
+func (t *tcpListener) run(ctx context.Context, ln net.Listener) {
+	var conns sync.Map
+	wg := sync.WaitGroup{}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+		conns.Range(func(conn, _ interface{}) bool {
+			conn.(net.Conn).Close()
+			return true
+		})
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				cfgLogger.WithError(err).WithField("address", t.address).Warn("cannot accept log connection")
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			wg.Wait()
+			return
+		}
+		conns.Store(conn, struct{}{})
+		if ctx.Err() != nil {
+			// accepted while stopping: closed as the rest of connections
+			conn.Close()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conns.Delete(conn)
+			defer conn.Close()
+			if t.cfg.Tcp.Format == tcpFormatNone {
+				t.readPlain(ctx, conn)
+			} else {
+				t.readJSON(ctx, conn)
+			}
+		}()
+	}
+}
+
+func (t *tcpListener) readPlain(ctx context.Context, conn net.Conn) {
+	separator := strings.Replace(t.cfg.Tcp.Separator, `\\`, `\`, -1)
+	separator = strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t").Replace(separator)
+	if separator == "" {
+		separator = defaultTcpSeparator
+	}
+	sep := []byte(separator)
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), t.bufSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.Index(data, sep); i >= 0 {
+			return i + len(sep), data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || !t.filter.matches(line) {
+			continue
+		}
+		t.emit(ctx, line, nil)
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		cfgLogger.WithError(err).WithField("address", t.address).Debug("Closing log connection.")
+	}
+}
+
+func (t *tcpListener) readJSON(ctx context.Context, conn net.Conn) {
+	decoder := json.NewDecoder(bufio.NewReaderSize(conn, t.bufSize))
+	for {
+		var fields map[string]interface{}
+		if err := decoder.Decode(&fields); err != nil {
+			if ctx.Err() == nil && err != io.EOF {
+				cfgLogger.WithError(err).WithField("address", t.address).Debug("Closing log connection.")
+			}
+			return
+		}
+		message := ""
+		for _, key := range []string{tcpJSONMessageField, tcpJSONLogFieldAlias} {
+			if m, ok := fields[key].(string); ok {
+				message = m
+				delete(fields, key)
+				break
+			}
+		}
+		t.emit(ctx, message, fields)
+	}
+}
+
+func (t *tcpListener) emit(ctx context.Context, message string, fields map[string]interface{}) {
+	attributes := make(map[string]interface{}, len(t.attributes)+len(fields))
+	for k, v := range fields {
+		attributes[k] = maskValue(t.masker, v)
+	}
+	for k, v := range t.attributes {
+		attributes[k] = maskValue(t.masker, v)
+	}
+	select {
+	case t.records <- nativeRecord{timestamp: time.Now(), message: t.masker.mask(message), attributes: attributes}:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,499 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, records <-chan nativeRecord) nativeRecord {
+	t.Helper()
+	select {
+	case r := <-records:
+		return r
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timeout waiting for a log record")
+	}
+	return nativeRecord{}
+}
+
+func assertNoRecords(t *testing.T, records <-chan nativeRecord) {
+	t.Helper()
+	select {
+	case r := <-records:
+		assert.Failf(t, "unexpected log record", "%+v", r)
+	default:
+	}
+}
+
+func appendTo(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestFileTailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-tailer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "app.log")
+
+	// GIVEN a log file with previous content
+	appendTo(t, logFile, "old line\n")
+	records := make(chan nativeRecord, 100)
+	offsets := loadOffsetStore(filepath.Join(dir, "offsets.json"))
+	cfg := LogCfg{Name: "app", File: filepath.Join(dir, "*.log"), Attributes: map[string]string{"team": "infra"}}
+	tailer := newFileTailer(cfg, &lineFilter{include: regexp.MustCompile("ERROR|WARN")}, nil, offsets, records)
+	ctx := context.Background()
+
+	// WHEN the tailer starts
+	tailer.poll(ctx)
+	// THEN the previous content is not sent
+	assertNoRecords(t, records)
+
+	// WHEN new lines are appended, including an incomplete one
+	appendTo(t, logFile, "ERROR first\nINFO filtered\nWARN sec")
+	tailer.poll(ctx)
+
+	// THEN the complete lines matching the pattern are sent, with the source attributes
+	r := receive(t, records)
+	assert.Equal(t, "ERROR first", r.message)
+	assert.Equal(t, logFile, r.attributes[nativePathKey])
+	assert.Equal(t, fbInputTypeTail, r.attributes[rAttFbInput])
+	assert.Equal(t, "infra", r.attributes["team"])
+	assertNoRecords(t, records)
+
+	// WHEN the incomplete line is finished
+	appendTo(t, logFile, "ond\n")
+	tailer.poll(ctx)
+
+	// THEN it is sent
+	r = receive(t, records)
+	assert.Equal(t, "WARN second", r.message)
+
+	// AND its commit stores the offset after the line
+	r.commit()
+	stored, ok := offsets.get(logFile)
+	require.True(t, ok)
+	info, err := os.Stat(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), stored.Offset)
+
+	// WHEN the file is truncated
+	require.NoError(t, os.Truncate(logFile, 0))
+	appendTo(t, logFile, "ERROR after truncation\n")
+	tailer.poll(ctx)
+
+	// THEN it is read from the beginning
+	assert.Equal(t, "ERROR after truncation", receive(t, records).message)
+
+	// WHEN the file is rotated
+	appendTo(t, logFile, "ERROR before rotation\n")
+	require.NoError(t, os.Rename(logFile, filepath.Join(dir, "app.log.1")))
+	appendTo(t, logFile, "ERROR after rotation\n")
+	tailer.poll(ctx)
+
+	// THEN the remaining lines of the old file and the new file are read
+	assert.Equal(t, "ERROR before rotation", receive(t, records).message)
+	assert.Equal(t, "ERROR after rotation", receive(t, records).message)
+
+	// WHEN a new file matches the glob
+	appendTo(t, filepath.Join(dir, "other.log"), "WARN new file\n")
+	tailer.poll(ctx)
+
+	// THEN it is read from the beginning
+	assert.Equal(t, "WARN new file", receive(t, records).message)
+	tailer.close()
+}
+
+func TestFileTailer_ResumesFromStoredOffset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-tailer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "app.log")
+	offsetsFile := filepath.Join(dir, "offsets", "offsets.json")
+
+	// GIVEN a file whose records have been partially sent
+	appendTo(t, logFile, "sent\n")
+	records := make(chan nativeRecord, 100)
+	offsets := loadOffsetStore(offsetsFile)
+	tailer := newFileTailer(LogCfg{Name: "app", File: logFile}, nil, nil, offsets, records)
+	tailer.poll(context.Background())
+	appendTo(t, logFile, "also sent\n")
+	tailer.poll(context.Background())
+	receive(t, records).commit()
+	require.NoError(t, offsets.save())
+	tailer.close()
+
+	// WHEN new lines are written while the forwarder is stopped
+	appendTo(t, logFile, "not sent\n")
+
+	// THEN the new lines are read after restarting
+	tailer = newFileTailer(LogCfg{Name: "app", File: logFile}, nil, nil, loadOffsetStore(offsetsFile), records)
+	tailer.poll(context.Background())
+	assert.Equal(t, "not sent", receive(t, records).message)
+	assertNoRecords(t, records)
+	tailer.close()
+}
+
+func TestFileTailer_RotatedWhileStopped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-tailer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "app.log")
+	offsetsFile := filepath.Join(dir, "offsets", "offsets.json")
+
+	// GIVEN a file whose records have been sent
+	appendTo(t, logFile, "")
+	records := make(chan nativeRecord, 100)
+	offsets := loadOffsetStore(offsetsFile)
+	tailer := newFileTailer(LogCfg{Name: "app", File: logFile}, nil, nil, offsets, records)
+	tailer.poll(context.Background())
+	appendTo(t, logFile, "sent\n")
+	tailer.poll(context.Background())
+	receive(t, records).commit()
+	require.NoError(t, offsets.save())
+	tailer.close()
+
+	// WHEN the file is rotated while the forwarder is stopped
+	require.NoError(t, os.Rename(logFile, logFile+".1"))
+	appendTo(t, logFile, "written after rotation\n")
+
+	// THEN the new file is read from the beginning after restarting
+	tailer = newFileTailer(LogCfg{Name: "app", File: logFile}, nil, nil, loadOffsetStore(offsetsFile), records)
+	tailer.poll(context.Background())
+	assert.Equal(t, "written after rotation", receive(t, records).message)
+	assertNoRecords(t, records)
+	tailer.close()
+}
+
+func TestFileTailer_RemovedWhileBatchInFlight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-tailer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "app.log")
+	offsetsFile := filepath.Join(dir, "offsets", "offsets.json")
+
+	// GIVEN a record of a followed file that is still being sent
+	appendTo(t, logFile, "")
+	records := make(chan nativeRecord, 100)
+	offsets := loadOffsetStore(offsetsFile)
+	tailer := newFileTailer(LogCfg{Name: "app", File: logFile}, nil, nil, offsets, records)
+	tailer.poll(context.Background())
+	appendTo(t, logFile, "in flight\n")
+	tailer.poll(context.Background())
+	inFlight := receive(t, records)
+
+	// WHEN the file is removed before the record is committed
+	require.NoError(t, os.Remove(logFile))
+	tailer.poll(context.Background())
+	inFlight.commit()
+	require.NoError(t, offsets.save())
+
+	// THEN the offset of the removed file is not stored
+	_, ok := offsets.get(logFile)
+	assert.False(t, ok)
+	content, err := ioutil.ReadFile(offsetsFile)
+	if err == nil {
+		assert.NotContains(t, string(content), "app.log")
+	}
+
+	// AND a new file in the same path is followed again
+	appendTo(t, logFile, "new file\n")
+	tailer.poll(context.Background())
+	r := receive(t, records)
+	assert.Equal(t, "new file", r.message)
+	r.commit()
+	_, ok = offsets.get(logFile)
+	assert.True(t, ok)
+	tailer.close()
+}
+
+func TestFileTailer_RotatedWhileBatchInFlight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-tailer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "app.log")
+
+	// GIVEN a record of a followed file that is still being sent
+	appendTo(t, logFile, "")
+	records := make(chan nativeRecord, 100)
+	offsets := loadOffsetStore("")
+	tailer := newFileTailer(LogCfg{Name: "app", File: logFile}, nil, nil, offsets, records)
+	tailer.poll(context.Background())
+	appendTo(t, logFile, "old file\n")
+	tailer.poll(context.Background())
+	inFlight := receive(t, records)
+
+	// WHEN the file is rotated and the record is committed after the records of the new file
+	require.NoError(t, os.Rename(logFile, logFile+".1"))
+	appendTo(t, logFile, "new file\n")
+	tailer.poll(context.Background())
+	receive(t, records).commit()
+	inFlight.commit()
+
+	// THEN the stored offset belongs to the new file
+	info, err := os.Stat(logFile)
+	require.NoError(t, err)
+	stored, ok := offsets.get(logFile)
+	require.True(t, ok)
+	assert.Equal(t, fileID(info), stored.ID)
+	assert.Equal(t, info.Size(), stored.Offset)
+	tailer.close()
+}
+
+func TestFileTailer_FlushesIncompleteLineOfRotatedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-tailer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "app.log")
+
+	// GIVEN a followed file
+	appendTo(t, logFile, "")
+	records := make(chan nativeRecord, 100)
+	tailer := newFileTailer(LogCfg{Name: "app", File: logFile}, nil, nil, loadOffsetStore(""), records)
+	tailer.poll(context.Background())
+
+	// WHEN it's rotated with an incomplete last line
+	appendTo(t, logFile, "complete\nincomplete")
+	require.NoError(t, os.Rename(logFile, logFile+".1"))
+	appendTo(t, logFile, "new file\n")
+	tailer.poll(context.Background())
+
+	// THEN the incomplete line is sent before the lines of the new file
+	assert.Equal(t, "complete", receive(t, records).message)
+	assert.Equal(t, "incomplete", receive(t, records).message)
+	assert.Equal(t, "new file", receive(t, records).message)
+	tailer.close()
+}
+
+func TestFileTailer_SkipsLongLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native-tailer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "app.log")
+	appendTo(t, logFile, "")
+
+	// GIVEN a tailer with a maximum line size of 1KB
+	records := make(chan nativeRecord, 100)
+	tailer := newFileTailer(LogCfg{Name: "app", File: logFile, MaxLineKb: 1}, nil, nil, loadOffsetStore(""), records)
+	tailer.poll(context.Background())
+
+	// WHEN a line longer than the maximum is written
+	appendTo(t, logFile, fmt.Sprintf("%02000d\nshort\n", 0))
+	tailer.poll(context.Background())
+
+	// THEN it is skipped
+	assert.Equal(t, "short", receive(t, records).message)
+	assertNoRecords(t, records)
+	tailer.close()
+}
+
+func TestTcpListener(t *testing.T) {
+	cases := []struct {
+		name     string
+		tcp      LogTcpCfg
+		input    string
+		mask     []string
+		expected []string
+	}{
+		{"plain", LogTcpCfg{Uri: "tcp://127.0.0.1:0", Format: tcpFormatNone}, "ERROR one\nINFO two\nERROR three\n", nil, []string{"ERROR one", "ERROR three"}},
+		{"separator", LogTcpCfg{Uri: "tcp://127.0.0.1:0", Format: tcpFormatNone, Separator: `\t`}, "ERROR one\tERROR two", nil, []string{"ERROR one", "ERROR two"}},
+		{"json", LogTcpCfg{Uri: "tcp://127.0.0.1:0", Format: "json"}, `{"message":"one","level":"info"}{"log":"two"}`, nil, []string{"one", "two"}},
+		{"masked", LogTcpCfg{Uri: "tcp://127.0.0.1:0", Format: tcpFormatNone}, "ERROR from 10.0.0.1\n", []string{"ip"}, []string{"ERROR from [MASKED_IP]"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// GIVEN a TCP log listener
+			records := make(chan nativeRecord, 100)
+			tcp := tc.tcp
+			masker, err := newMaskRules(config.LogMaskConfig{Detectors: tc.mask})
+			require.NoError(t, err)
+			listener := newTcpListener(LogCfg{Name: "tcp", Tcp: &tcp}, &lineFilter{include: regexp.MustCompile("ERROR")}, masker, records)
+			ln, err := listener.listen()
+			require.NoError(t, err)
+			ctx, cancel := context.WithCancel(context.Background())
+			finished := make(chan struct{})
+			go func() {
+				defer close(finished)
+				listener.run(ctx, ln)
+			}()
+
+			// WHEN log records are submitted
+			conn, err := net.Dial("tcp", listener.address)
+			require.NoError(t, err)
+			_, err = conn.Write([]byte(tc.input))
+			require.NoError(t, err)
+			require.NoError(t, conn.Close())
+
+			// THEN they are received
+			for _, expected := range tc.expected {
+				r := receive(t, records)
+				assert.Equal(t, expected, r.message)
+				assert.Equal(t, fbInputTypeTcp, r.attributes[rAttFbInput])
+			}
+
+			// AND the listener stops when the context is cancelled
+			cancel()
+			select {
+			case <-finished:
+			case <-time.After(5 * time.Second):
+				assert.Fail(t, "tcp listener didn't stop")
+			}
+		})
+	}
+}
+
+func TestLogSender(t *testing.T) {
+	// GIVEN a Log API that fails the first request
+	var requests int32
+	received := make(chan []logAPIPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "license", r.Header.Get(logAPILicenseHeader))
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		zr, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		var payload []logAPIPayload
+		require.NoError(t, json.NewDecoder(zr).Decode(&payload))
+		w.WriteHeader(http.StatusAccepted)
+		received <- payload
+	}))
+	defer server.Close()
+
+	sender := &logSender{
+		client:        server.Client(),
+		endpoint:      server.URL,
+		license:       "license",
+		common:        map[string]interface{}{rAttHostname: "my-host"},
+		flushInterval: time.Hour,
+		retryBackoff:  time.Millisecond,
+	}
+
+	// WHEN records are sent
+	records := make(chan nativeRecord, 2)
+	committed := int32(0)
+	commit := func() { atomic.AddInt32(&committed, 1) }
+	records <- nativeRecord{timestamp: time.Unix(1, 0), message: "one", attributes: map[string]interface{}{"a": "b"}, commit: commit}
+	records <- nativeRecord{timestamp: time.Unix(2, 0), message: "two", commit: commit}
+	close(records)
+	sender.run(context.Background(), records)
+
+	// THEN they are submitted after retrying
+	assert.EqualValues(t, 2, atomic.LoadInt32(&requests))
+	payload := <-received
+	require.Len(t, payload, 1)
+	assert.Equal(t, "my-host", payload[0].Common.Attributes[rAttHostname])
+	require.Len(t, payload[0].Logs, 2)
+	assert.Equal(t, logAPIRecord{Timestamp: 1000, Message: "one", Attributes: map[string]interface{}{"a": "b"}}, payload[0].Logs[0])
+	assert.Equal(t, "two", payload[0].Logs[1].Message)
+
+	// AND the records are committed
+	assert.EqualValues(t, 2, atomic.LoadInt32(&committed))
+}
+
+func TestLogSender_DiscardsRejectedRecords(t *testing.T) {
+	// GIVEN a Log API that rejects the requests
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	sender := &logSender{client: server.Client(), endpoint: server.URL, flushInterval: time.Hour, retryBackoff: time.Millisecond}
+
+	// WHEN a record is sent
+	committed := false
+	records := make(chan nativeRecord, 1)
+	records <- nativeRecord{message: "one", commit: func() { committed = true }}
+	close(records)
+	sender.run(context.Background(), records)
+
+	// THEN it is not retried, but committed to not being read again
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+	assert.True(t, committed)
+}
+
+func TestLogSender_KeepsRetryingUndeliveredRecords(t *testing.T) {
+	// GIVEN a Log API that fails many consecutive requests
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 8 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	sender := &logSender{client: server.Client(), endpoint: server.URL, flushInterval: time.Hour, retryBackoff: time.Millisecond}
+
+	// WHEN a record is sent
+	committed := false
+	records := make(chan nativeRecord, 1)
+	records <- nativeRecord{message: "one", commit: func() { committed = true }}
+	close(records)
+	sender.run(context.Background(), records)
+
+	// THEN it is retried until delivered, and then committed
+	assert.EqualValues(t, 9, atomic.LoadInt32(&requests))
+	assert.True(t, committed)
+}
+
+func TestLogSender_LeavesUndeliveredRecordsUncommittedOnShutdown(t *testing.T) {
+	// GIVEN a Log API that fails all the requests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	sender := &logSender{client: server.Client(), endpoint: server.URL, flushInterval: time.Hour, retryBackoff: time.Millisecond}
+
+	// WHEN a record is sent while the forwarder is stopped
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	committed := false
+	records := make(chan nativeRecord, 1)
+	records <- nativeRecord{message: "one", commit: func() { committed = true }}
+	close(records)
+	sender.run(ctx, records)
+
+	// THEN it is not committed, to be read again after restarting
+	assert.False(t, committed)
+}
+
+func TestLineFilter(t *testing.T) {
+	filter, err := newLineFilter(LogCfg{Pattern: "ERROR|WARN", ExcludePattern: StringList{"healthcheck", "^WARN deprecated"}})
+	require.NoError(t, err)
+
+	assert.True(t, filter.matches("ERROR connection refused"))
+	assert.True(t, filter.matches("WARN slow query"))
+	assert.False(t, filter.matches("INFO started"), "not matching the pattern")
+	assert.False(t, filter.matches("ERROR healthcheck failed"), "matching an exclude pattern")
+	assert.False(t, filter.matches("WARN deprecated option"), "matching an exclude pattern")
+
+	_, err = newLineFilter(LogCfg{ExcludePattern: StringList{"("}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,210 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/fs"
+)
+
+// Validate checks all the logging configuration files, reporting to w the reason why any of their blocks can't
+// be forwarded, along with the Fluent Bit configuration, parsers and Lua scripts generated for the valid ones.
+// It returns false if any error was found.
+func (l *CfgLoader) Validate(w io.Writer) (ok bool) {
+	if l.config.ConfigsDir == "" {
+		fmt.Fprintln(w, "ERROR: logging_configs_dir is not set")
+		return false
+	}
+	fmt.Fprintf(w, "Validating log forwarding configuration files in %s\n", l.config.ConfigsDir)
+
+	files, err := l.loadFilesFn(l.config.ConfigsDir)
+	if err != nil && err != fs.ErrFilesNotFound {
+		fmt.Fprintf(w, "ERROR: cannot read the configuration directory: %s\n", err)
+		return false
+	}
+	sort.Strings(files)
+
+	ok = true
+	var valid LogsCfg
+	names := map[string]string{} // key: block name, value: file defining it
+	for _, file := range files {
+		if ext := filepath.Ext(file); ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s\n", file)
+		blocks, err := readBlocks(file)
+		if err != nil {
+			fmt.Fprintf(w, "  ERROR: %s\n", err)
+			ok = false
+			continue
+		}
+		for i, block := range blocks {
+			name := block.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			err := block.Validate()
+			if err == nil {
+				err = checkInputs(block)
+			}
+			if err == nil {
+				err = checkPaths(block)
+			}
+			if err == nil {
+				if other, found := names[block.Name]; found {
+					err = fmt.Errorf("name already used in %s", other)
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(w, "  ERROR %s: %s\n", name, err)
+				ok = false
+				continue
+			}
+			names[block.Name] = file
+			if block.IsNative() {
+				fmt.Fprintf(w, "  OK    %s (native forwarder)\n", name)
+			} else {
+				fmt.Fprintf(w, "  OK    %s\n", name)
+			}
+			if sources := block.sources(); len(sources) > 1 {
+				fmt.Fprintf(w, "  WARN  %s: only one log source is forwarded per block, found: %s\n", name, strings.Join(sources, ", "))
+			}
+			valid = append(valid, block)
+		}
+	}
+
+	return printFBConf(w, valid, l) && ok
+}
+
+// readBlocks returns all the configuration blocks of a logging configuration file, valid or not.
+func readBlocks(file string) (LogsCfg, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file: %s", err)
+	}
+	var y YAML
+	if err := yaml.Unmarshal(content, &y); err != nil {
+		return nil, fmt.Errorf("cannot parse YAML: %s", err)
+	}
+	return y.Logs, nil
+}
+
+// checkInputs returns an error if the syslog or tcp URI of the configuration block can't be parsed, which
+// prevents generating the Fluent Bit configuration.
+func checkInputs(l LogCfg) error {
+	if l.Syslog != nil {
+		if _, err := newSyslogInput(*l.Syslog, l.Name, 0); err != nil {
+			return err
+		}
+	}
+	if l.Tcp != nil {
+		if _, err := newTcpInput(*l.Tcp, l.Name, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPaths returns an error if the files read by the configuration block are not available.
+func checkPaths(l LogCfg) error {
+	if l.File != "" {
+		if !strings.ContainsAny(l.File, "*?[") {
+			return checkReadable(l.File)
+		}
+		matches, err := filepath.Glob(l.File)
+		if err != nil {
+			return fmt.Errorf("unreadable glob %q: %s", l.File, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("no files match %q", l.File)
+		}
+		for _, match := range matches {
+			if err := checkReadable(match); err != nil {
+				return err
+			}
+		}
+	}
+	if l.Fluentbit != nil {
+		if err := checkReadable(l.Fluentbit.CfgPath); err != nil {
+			return err
+		}
+		if l.Fluentbit.ParsersPath != "" {
+			return checkReadable(l.Fluentbit.ParsersPath)
+		}
+	}
+	return nil
+}
+
+func checkReadable(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("missing file %q", path)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read file %q: %s", path, err)
+	}
+	return f.Close()
+}
+
+// printFBConf reports the Fluent Bit configuration generated for the valid blocks. Lua scripts are removed
+// once printed, as they won't be used by any Fluent Bit instance. Entity and hostname decorations are left
+// empty, as they're only known by a running agent.
+func printFBConf(w io.Writer, blocks LogsCfg, l *CfgLoader) bool {
+	fbConf, err := NewFBConf(blocks, &l.config, "", "")
+	defer func() {
+		for _, filter := range fbConf.Filters {
+			if filter.Script != "" {
+				_ = os.Remove(filter.Script)
+			}
+		}
+	}()
+	if err != nil {
+		fmt.Fprintf(w, "\nERROR: cannot generate the Fluent Bit configuration: %s\n", err)
+		return false
+	}
+	if len(fbConf.Inputs) == 0 && fbConf.ExternalCfg == (FBCfgExternal{}) {
+		fmt.Fprintln(w, "\nNo Fluent Bit configuration is required.")
+		return true
+	}
+
+	cfg, external, err := fbConf.Format()
+	if err != nil {
+		fmt.Fprintf(w, "\nERROR: cannot format the Fluent Bit configuration: %s\n", err)
+		return false
+	}
+	parsers, err := fbConf.FormatParsers()
+	if err != nil {
+		fmt.Fprintf(w, "\nERROR: cannot format the Fluent Bit parsers: %s\n", err)
+		return false
+	}
+
+	fmt.Fprintf(w, "\n==> Fluent Bit configuration\n%s\n", cfg)
+	if parsers != "" {
+		fmt.Fprintf(w, "\n==> Fluent Bit parsers\n%s\n", parsers)
+	}
+	for _, filter := range fbConf.Filters {
+		if filter.Script == "" {
+			continue
+		}
+		script, err := ioutil.ReadFile(filter.Script)
+		if err != nil {
+			fmt.Fprintf(w, "\nERROR: cannot read the Lua script %s: %s\n", filter.Script, err)
+			return false
+		}
+		fmt.Fprintf(w, "\n==> Lua script %s (%s)\n%s\n", filter.Script, filter.Match, script)
+	}
+	if external != (FBCfgExternal{}) {
+		fmt.Fprintf(w, "\n==> External Fluent Bit configuration\nconfig_file: %s\nparsers_file: %s\n",
+			external.CfgFilePath, external.ParsersFilePath)
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package logs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+)
+
+func validationLoader(t *testing.T, files map[string]string) (loader *CfgLoader, dir string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "validate-logs")
+	require.NoError(t, err)
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	loader = NewFolderLoader(config.LogForward{ConfigsDir: dir, HomeDir: dir, License: "license"}, nil, nil)
+	return loader, dir
+}
+
+func TestCfgLoader_Validate(t *testing.T) {
+	// GIVEN a logging configuration file with valid blocks
+	logDir, err := ioutil.TempDir("", "validate-logs-files")
+	require.NoError(t, err)
+	defer os.RemoveAll(logDir)
+	logFile := filepath.Join(logDir, "app.log")
+	appendTo(t, logFile, "")
+	loader, dir := validationLoader(t, map[string]string{
+		"app.yml": `
+logs:
+  - name: app
+    file: ` + logFile + `
+    mask:
+      rules:
+        - regex: 'token=\w+'
+          replacement: 'token=****'
+  - name: events
+    tcp:
+      uri: tcp://127.0.0.1:5170
+      format: json
+    forwarder: native
+  - name: conflicting
+    file: ` + logFile + `
+    systemd: app
+`,
+		"README.md": "not a configuration file",
+	})
+	defer os.RemoveAll(dir)
+
+	// WHEN it's validated
+	out := &bytes.Buffer{}
+	ok := loader.Validate(out)
+
+	// THEN no errors are reported
+	assert.True(t, ok, out.String())
+	assert.Contains(t, out.String(), "OK    app\n")
+	assert.Contains(t, out.String(), "OK    events (native forwarder)\n")
+	// AND blocks with many sources are reported, as only one of them is forwarded
+	assert.Contains(t, out.String(), "OK    conflicting\n  WARN  conflicting: only one log source is forwarded per block, found: file, systemd\n")
+	assert.NotContains(t, out.String(), "README.md")
+
+	// AND the generated Fluent Bit configuration and Lua scripts are printed
+	assert.Contains(t, out.String(), "==> Fluent Bit configuration\n")
+	assert.Contains(t, out.String(), logFile)
+	script := regexp.MustCompile(`==> Lua script (\S+) \(app\)`).FindStringSubmatch(out.String())
+	require.Len(t, script, 2)
+	assert.Contains(t, out.String(), "token=****")
+
+	// AND the generated Lua scripts are removed
+	_, err = os.Stat(script[1])
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCfgLoader_Validate_Errors(t *testing.T) {
+	// GIVEN logging configuration files with invalid blocks
+	loader, dir := validationLoader(t, map[string]string{
+		"invalid.yml": `
+logs:
+  - name: bad-syslog
+    syslog:
+      uri: http://127.0.0.1:5140
+  - name: missing-file
+    file: /non/existing/file.log
+  - name: bad-glob
+    file: /var/log/[app.log
+  - name: no-match
+    file: /non/existing/*.log
+  - file: /var/log/app.log
+`,
+		"broken.yml": "logs: [",
+	})
+	defer os.RemoveAll(dir)
+
+	// WHEN they're validated
+	out := &bytes.Buffer{}
+	ok := loader.Validate(out)
+
+	// THEN all the errors are reported along with their reason
+	assert.False(t, ok)
+	assert.Regexp(t, `broken.yml\n  ERROR: cannot parse YAML`, out.String())
+	assert.Contains(t, out.String(), "ERROR bad-syslog: syslog: wrong uri format or unsupported protocol")
+	assert.Contains(t, out.String(), `ERROR missing-file: missing file "/non/existing/file.log"`)
+	assert.Contains(t, out.String(), `ERROR bad-glob: unreadable glob "/var/log/[app.log"`)
+	assert.Contains(t, out.String(), `ERROR no-match: no files match "/non/existing/*.log"`)
+	assert.Contains(t, out.String(), "ERROR #5: missing name")
+	assert.Contains(t, out.String(), "No Fluent Bit configuration is required.")
+}
+
+func TestCfgLoader_Validate_DuplicatedName(t *testing.T) {
+	loader, dir := validationLoader(t, map[string]string{
+		"a.yml": "logs:\n  - name: app\n    systemd: app\n",
+		"b.yml": "logs:\n  - name: app\n    systemd: other\n",
+	})
+	defer os.RemoveAll(dir)
+
+	out := &bytes.Buffer{}
+	ok := loader.Validate(out)
+
+	assert.False(t, ok)
+	assert.Contains(t, out.String(), "ERROR app: name already used in "+filepath.Join(dir, "a.yml"))
+}
@@ -5,15 +5,18 @@ package v4
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/newrelic/infrastructure-agent/pkg/entity/host"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/constants"
 	"github.com/newrelic/infrastructure-agent/pkg/config/envvar"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/cmdrequest"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/configrequest"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/track"
@@ -116,36 +119,95 @@ type Manager struct {
 	idLookup                 host.IDLookup
 }
 
-// groupContext pairs a runner.Group with its cancellation context
+// groupContext pairs a runner.Group with the cancellation contexts of its running integrations
 type groupContext struct {
-	l      sync.RWMutex
-	cancel func() // nil when there's no cancellable context
-	runner runner.Group
+	l       sync.RWMutex
+	cfgPath string
+	// per running definition instance, keyed by instanceKeys
+	cancels map[string]context.CancelFunc
+	runner  runner.Group
+	// databind discovery configuration shared by all the group definitions
+	databind databind.YAMLConfig
 }
 
-func newGroupContext(gr runner.Group) *groupContext {
+func newGroupContext(cfgPath string, gr runner.Group, databindCfg databind.YAMLConfig) *groupContext {
 	return &groupContext{
-		runner: gr,
-		l:      sync.RWMutex{},
+		cfgPath:  cfgPath,
+		runner:   gr,
+		databind: databindCfg,
+		cancels:  make(map[string]context.CancelFunc),
+		l:        sync.RWMutex{},
 	}
 }
 
+// instanceKeys returns a key for each definition, made of its hash and its position among the identical
+// definitions in the same file, so each copy of a duplicated definition is tracked separately.
+func instanceKeys(defs []integration.Definition) []string {
+	seen := map[string]int{}
+	keys := make([]string, 0, len(defs))
+	for _, def := range defs {
+		hash := def.Hash()
+		keys = append(keys, fmt.Sprintf("%s#%d", hash, seen[hash]))
+		seen[hash]++
+	}
+	return keys
+}
+
 func (g *groupContext) start(ctx context.Context) {
 	g.l.Lock()
 	defer g.l.Unlock()
 
-	cctx, cancel := context.WithCancel(ctx)
-	if g.runner.Run(cctx) {
-		g.cancel = cancel
+	defs := g.runner.Definitions()
+	for i, key := range instanceKeys(defs) {
+		g.runDefinition(ctx, key, defs[i])
 	}
 }
 
+// reload replaces the group runner by the provided one, stopping the definitions that have been
+// removed or changed, and starting the new or changed ones. Unchanged definitions keep running.
+func (g *groupContext) reload(ctx context.Context, gr runner.Group) (started, stopped int) {
+	g.l.Lock()
+	defer g.l.Unlock()
+
+	defs := gr.Definitions()
+	keys := instanceKeys(defs)
+	wanted := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		wanted[key] = struct{}{}
+	}
+
+	for key, cancel := range g.cancels {
+		if _, ok := wanted[key]; !ok {
+			cancel()
+			delete(g.cancels, key)
+			stopped++
+		}
+	}
+
+	g.runner = gr
+	for i, key := range keys {
+		if _, ok := g.cancels[key]; !ok {
+			g.runDefinition(ctx, key, defs[i])
+			started++
+		}
+	}
+
+	return
+}
+
+func (g *groupContext) runDefinition(ctx context.Context, key string, def integration.Definition) {
+	cctx, cancel := context.WithCancel(ctx)
+	g.runner.RunDefinition(cctx, def)
+	g.cancels[key] = cancel
+}
+
 func (g *groupContext) stop() {
 	g.l.Lock()
 	defer g.l.Unlock()
 
-	if g.cancel != nil {
-		g.cancel()
+	for key, cancel := range g.cancels {
+		cancel()
+		delete(g.cancels, key)
 	}
 }
 
@@ -153,7 +215,7 @@ func (g *groupContext) isRunning() bool {
 	g.l.RLock()
 	defer g.l.RUnlock()
 
-	return g.cancel != nil
+	return len(g.cancels) > 0
 }
 
 type Configuration struct {
@@ -308,16 +370,26 @@ func (mgr *Manager) loadEnabledRunnerGroups(cfgs map[string]config2.YAML) {
 }
 
 func (mgr *Manager) loadRunnerGroup(path string, cfg config2.YAML, cmdFF *runner.CmdFF) (*groupContext, error) {
+	gr, err := mgr.newRunnerGroup(path, cfg, cmdFF)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGroupContext(path, gr, cfg.Databind), nil
+}
+
+// newRunnerGroup builds the runner group for the integrations of a config file, caching its features.
+func (mgr *Manager) newRunnerGroup(path string, cfg config2.YAML, cmdFF *runner.CmdFF) (runner.Group, error) {
 	f := runner.NewFeatures(mgr.config.AgentFeatures, cmdFF)
 	loader := runner.NewLoadFn(cfg, f)
 	gr, fc, err := runner.NewGroup(loader, mgr.lookup, mgr.config.PassthroughEnvironment, mgr.emitter, mgr.handleCmdReq, mgr.handleConfig, path, mgr.terminateDefinitionQueue, mgr.idLookup)
 	if err != nil {
-		return nil, err
+		return runner.Group{}, err
 	}
 
 	mgr.featuresCache.Update(fc)
 
-	return newGroupContext(gr), nil
+	return gr, nil
 }
 
 func (mgr *Manager) handleRequestsQueue(ctx context.Context) {
@@ -419,6 +491,11 @@ func (mgr *Manager) handleFileEvent(ctx context.Context, event *fsnotify.Event)
 		return
 	}
 
+	if !isDelete && mgr.runners.isGroupRunning(event.Name) {
+		mgr.reloadRunnerGroup(ctx, event.Name, &elog)
+		return
+	}
+
 	mgr.stopRunnerGroup(event.Name)
 
 	if isDelete {
@@ -469,6 +546,48 @@ func (mgr *Manager) runIntegrationFromPath(ctx context.Context, cfgPath string,
 	rc.start(ctx)
 }
 
+// reloadRunnerGroup applies the changes of a modified configuration file to its running group, only
+// restarting the integrations whose definition changed. Whole group is restarted when the discovery
+// configuration changes, as it's shared by all the integrations of the file.
+func (mgr *Manager) reloadRunnerGroup(ctx context.Context, cfgPath string, elog *log.Entry) {
+	rc, ok := mgr.runners.Get(cfgPath)
+	if !ok {
+		return
+	}
+
+	cfg, err := loadConfig(cfgPath)
+	if err == nil && !reflect.DeepEqual(cfg.Databind, rc.databind) {
+		elog.Debug("Discovery configuration changed. Restarting all the integrations in the file.")
+		mgr.stopRunnerGroup(cfgPath)
+		mgr.runIntegrationFromPath(ctx, cfgPath, false, elog, nil)
+		return
+	}
+	if err != nil {
+		if err != legacyYAML {
+			elog.WithError(err).Warn("can't load integrations file. This may happen if you are editing a file and saving intermediate changes")
+		}
+		mgr.stopRunnerGroup(cfgPath)
+		return
+	}
+
+	gr, err := mgr.newRunnerGroup(cfgPath, cfg, nil)
+	if err != nil {
+		elog.WithError(err).Warn("can't instantiate integrations from file. This may happen if you are editing a file and saving intermediate changes")
+		mgr.stopRunnerGroup(cfgPath)
+		return
+	}
+
+	started, stopped := rc.reload(ctx, gr)
+	illog.WithField("file", cfgPath).
+		WithField("started", started).
+		WithField("stopped", stopped).
+		Info("integration file modified. Restarted changed integrations, if any")
+
+	if !rc.isRunning() {
+		mgr.runners.Remove(cfgPath)
+	}
+}
+
 func (mgr *Manager) stopRunnerGroup(fileName string) {
 	if ctx, ok := mgr.runners.Get(fileName); ok && ctx != nil && ctx.isRunning() {
 		illog.WithField("file", fileName).
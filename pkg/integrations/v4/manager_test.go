@@ -17,10 +17,12 @@ import (
 
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/constants"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/integration"
+	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/runner"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/testhelp/testemit"
 	"github.com/newrelic/infrastructure-agent/internal/integrations/v4/v3legacy"
 	"github.com/newrelic/infrastructure-agent/internal/testhelpers"
+	"github.com/newrelic/infrastructure-agent/pkg/databind/pkg/databind"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/configrequest"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/track"
 	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/config"
@@ -390,6 +392,51 @@ func TestManager_HotReload_Modify(t *testing.T) {
 	require.Equal(t, "modifiedValue", metric["value"])
 }
 
+func TestManager_HotReload_ModifyKeepsUnchangedIntegrations(t *testing.T) {
+	skipIfWindows(t)
+	// GIVEN a file with two integrations
+	dir, err := tempFiles(map[string]string{
+		"integration.yaml": `---
+integrations:
+  - name: unchanged-test
+    exec: ` + getExe(testhelp.GoRun(fixtures.LongTimeGoFile, "unchanged")) + "\n" +
+			strings.TrimPrefix(v4AppendableConfig, "---\nintegrations:\n"),
+	})
+	require.NoError(t, err)
+	defer removeTempFiles(t, dir)
+
+	emitter := &testemit.RecordEmitter{}
+	mgr := NewManager(Configuration{ConfigFolders: []string{dir}, PassthroughEnvironment: passthroughEnv}, emitter, integration.ErrLookup, definitionQ, terminateDefinitionQ, configEntryQ, track.NewTracker(nil), host.IDLookup{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mgr.Start(ctx)
+
+	// THAT are correctly running
+	metric := expectOneMetric(t, emitter, "unchanged-test")
+	require.Equal(t, "first", metric["value"])
+	metric = expectOneMetric(t, emitter, "hotreload-test")
+	require.Equal(t, "first", metric["value"])
+	metric = expectOneMetric(t, emitter, "hotreload-test")
+	require.Equal(t, "unset", metric["value"])
+
+	// WHEN we modify only one of the integrations at runtime
+	require.NoError(t, fileAppend(
+		filepath.Join(dir, "integration.yaml"),
+		"      - modifiedValue\n"))
+
+	// THEN the modified integration is restarted
+	testhelpers.Eventually(t, 5*time.Second, func(t require.TestingT) {
+		metric = expectOneMetric(t, emitter, "hotreload-test")
+		require.Equal(t, "modifiedValue", metric["value"])
+	})
+
+	// AND the unchanged integration keeps running without being restarted
+	for i := 0; i < 200; i++ {
+		metric = expectOneMetric(t, emitter, "unchanged-test")
+		require.Equal(t, "unchanged", metric["value"])
+	}
+}
+
 // this test is used to make sure we see file changes on K8s
 func TestManager_HotReload_ModifyLinkFile(t *testing.T) {
 	skipIfWindows(t)
@@ -484,6 +531,79 @@ func TestManager_HotReload_Delete(t *testing.T) {
 	require.NoError(t, emitter.ExpectTimeout("longtime", 100*time.Millisecond))
 }
 
+func TestManager_HotReload_DeleteDuplicatedIntegrations(t *testing.T) {
+	skipIfWindows(t)
+	// GIVEN a file with two identical integrations
+	dir, err := tempFiles(map[string]string{
+		"to-be-deleted.yaml": v4LongTimeConfig + strings.TrimPrefix(v4LongTimeConfig, "---\nintegrations:\n"),
+	})
+	require.NoError(t, err)
+	defer removeTempFiles(t, dir)
+
+	emitter := &testemit.RecordEmitter{}
+	mgr := NewManager(Configuration{ConfigFolders: []string{dir}, PassthroughEnvironment: passthroughEnv}, emitter, integration.ErrLookup, definitionQ, terminateDefinitionQ, configEntryQ, track.NewTracker(nil), host.IDLookup{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go mgr.Start(ctx)
+
+	// THAT are running
+	expectOneMetric(t, emitter, "longtime")
+	expectOneMetric(t, emitter, "longtime")
+
+	// WHEN we delete the integrations file at runtime
+	require.NoError(t, os.Remove(filepath.Join(dir, "to-be-deleted.yaml")))
+
+	// THEN both integrations eventually stop reporting
+	testhelpers.Eventually(t, 5*time.Second, func(t require.TestingT) {
+		require.NoError(t, emitter.ExpectTimeout("longtime", 200*time.Millisecond))
+	})
+	// and do not report ever again
+	require.NoError(t, emitter.ExpectTimeout("longtime", 1*time.Second))
+}
+
+func TestGroupContext_Reload_DuplicatedIntegrations(t *testing.T) {
+	skipIfWindows(t)
+	single := v4LongTimeConfig
+	duplicated := v4LongTimeConfig + strings.TrimPrefix(v4LongTimeConfig, "---\nintegrations:\n")
+	dir, err := tempFiles(map[string]string{"single.yaml": single, "duplicated.yaml": duplicated})
+	require.NoError(t, err)
+	defer removeTempFiles(t, dir)
+
+	groupFor := func(file string) runner.Group {
+		cfg, err := loadConfig(filepath.Join(dir, file))
+		require.NoError(t, err)
+		gr, _, err := runner.NewGroup(runner.NewLoadFn(cfg, nil), integration.ErrLookup, nil, &testemit.RecordEmitter{}, nil, nil, file, nil, host.IDLookup{})
+		require.NoError(t, err)
+		return gr
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// GIVEN a file with two identical integrations
+	gc := newGroupContext("integrations.yaml", groupFor("duplicated.yaml"), databind.YAMLConfig{})
+	gc.start(ctx)
+	require.Len(t, gc.cancels, 2)
+
+	// WHEN one of them is removed
+	started, stopped := gc.reload(ctx, groupFor("single.yaml"))
+
+	// THEN only the removed one is stopped
+	assert.Equal(t, 0, started)
+	assert.Equal(t, 1, stopped)
+	assert.Len(t, gc.cancels, 1)
+
+	// AND WHEN it's added again
+	started, stopped = gc.reload(ctx, groupFor("duplicated.yaml"))
+
+	// THEN only the added one is started
+	assert.Equal(t, 1, started)
+	assert.Equal(t, 0, stopped)
+	assert.Len(t, gc.cancels, 2)
+
+	gc.stop()
+	assert.False(t, gc.isRunning())
+}
+
 func TestManager_PassthroughEnv(t *testing.T) {
 	// GIVEN an integration
 	niDir, err := ioutil.TempDir("", "newrelic-integrations")
@@ -10,6 +10,7 @@ import (
 	"github.com/newrelic/infrastructure-agent/pkg/event"
 
 	"time"
+	"unicode/utf8"
 )
 
 type MetricType string
@@ -27,6 +28,18 @@ const (
 
 const millisSinceJanuaryFirst1978 = 252489600000
 
+// Metric metadata limits
+const (
+	MaxMetricUnitLength        = 64
+	MaxMetricDescriptionLength = 1024
+)
+
+// Metric metadata errors
+var (
+	InvalidMetricUnitErr        = errors.New("invalid metric unit")
+	InvalidMetricDescriptionErr = errors.New("invalid metric description")
+)
+
 type DataV4 struct {
 	PluginProtocolVersion
 	Integration IntegrationMetadata `json:"integration"`
@@ -39,12 +52,14 @@ type IntegrationMetadata struct {
 }
 
 type Dataset struct {
-	Common       Common                   `json:"common"`
-	Metrics      []Metric                 `json:"metrics"`
-	Entity       entity.Fields            `json:"entity"`
-	Inventory    map[string]InventoryData `json:"inventory"`
-	Events       []EventData              `json:"events"`
-	IgnoreEntity bool                     `json:"ignore_entity"`
+	Common    Common                   `json:"common"`
+	Metrics   []Metric                 `json:"metrics"`
+	Entity    entity.Fields            `json:"entity"`
+	Inventory map[string]InventoryData `json:"inventory"`
+	// InventoryRemove keys of the inventory items to be removed from the entity inventory
+	InventoryRemove []string    `json:"inventory_remove"`
+	Events          []EventData `json:"events"`
+	IgnoreEntity    bool        `json:"ignore_entity"`
 }
 
 type Common struct {
@@ -60,6 +75,10 @@ type Metric struct {
 	Interval   *int64                 `json:"interval.ms"`
 	Attributes map[string]interface{} `json:"attributes"`
 	Value      json.RawMessage        `json:"value"`
+	// Unit is an optional UCUM-style unit for the metric value (ie: "By", "ms", "{request}/s").
+	Unit string `json:"unit,omitempty"`
+	// Description is an optional human readable description of the metric.
+	Description string `json:"description,omitempty"`
 }
 
 type SummaryValue struct {
@@ -353,6 +372,26 @@ func (m *Metric) GetPrometheusHistogramValue() (PrometheusHistogramValue, error)
 	return PrometheusHistogramValue{}, fmt.Errorf("metric type %v is not prometheus-histogram", m.Type)
 }
 
+// ValidateMetadata checks the optional unit and description fields. Units follow UCUM
+// case-sensitive syntax so only printable ASCII characters without spaces are accepted.
+func (m *Metric) ValidateMetadata() error {
+	if len(m.Unit) > MaxMetricUnitLength {
+		return fmt.Errorf("%w: longer than %d characters", InvalidMetricUnitErr, MaxMetricUnitLength)
+	}
+	for _, c := range m.Unit {
+		if c <= ' ' || c > '~' {
+			return fmt.Errorf("%w: unexpected character %q", InvalidMetricUnitErr, c)
+		}
+	}
+	if len(m.Description) > MaxMetricDescriptionLength {
+		return fmt.Errorf("%w: longer than %d characters", InvalidMetricDescriptionErr, MaxMetricDescriptionLength)
+	}
+	if !utf8.ValidString(m.Description) {
+		return fmt.Errorf("%w: not valid UTF-8", InvalidMetricDescriptionErr)
+	}
+	return nil
+}
+
 // CopyAttrs returns a (shallow) copy of the passed attrs.
 func (m *Metric) CopyAttrs() map[string]interface{} {
 	duplicate := make(map[string]interface{}, len(m.Attributes))
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/newrelic/infrastructure-agent/pkg/entity"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -111,3 +112,32 @@ func assertEventDataValues(t *testing.T, want EventData, n EventData) {
 		assert.Equal(t, value, v)
 	}
 }
+
+func TestMetric_ValidateMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		unit        string
+		description string
+		wantErr     error
+	}{
+		{"empty", "", "", nil},
+		{"valid", "By/s", "Bytes transferred per second", nil},
+		{"annotation", "{request}", "", nil},
+		{"unit with spaces", "kilo bytes", "", InvalidMetricUnitErr},
+		{"unit non ascii", "µs", "", InvalidMetricUnitErr},
+		{"unit too long", strings.Repeat("s", MaxMetricUnitLength+1), "", InvalidMetricUnitErr},
+		{"description too long", "", strings.Repeat("d", MaxMetricDescriptionLength+1), InvalidMetricDescriptionErr},
+		{"description invalid utf8", "", "\xff", InvalidMetricDescriptionErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Metric{Name: "metric", Unit: tt.unit, Description: tt.description}
+			err := m.ValidateMetadata()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package protocol
+
+import (
+	"fmt"
+)
+
+// Kinds of payload items a validation error can refer to.
+const (
+	ItemDataset = "dataset"
+	ItemMetric  = "metric"
+	ItemEvent   = "event"
+)
+
+// ValidationError describes a single payload item that didn't comply with the protocol rules.
+type ValidationError struct {
+	// DatasetIndex position of the dataset within the payload "data" array.
+	DatasetIndex int
+	// Item kind of item the error refers to: dataset, metric or event.
+	Item string
+	// Name of the metric, or entity for datasets, when available.
+	Name string
+	// Reason human readable description of the broken rule.
+	Reason string
+	// Sanitised is true when the item was fixed instead of rejected.
+	Sanitised bool
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("dataset %d: %s %q: %s", e.DatasetIndex, e.Item, e.Name, e.Reason)
+}
+
+// Validate checks every dataset of the payload against the protocol v4 rules. Invalid datasets,
+// metrics and events are removed individually from the payload, whereas recoverable issues are
+// sanitised in place. The returned errors describe every rejected or sanitised item.
+func (d *DataV4) Validate() (errs []ValidationError) {
+	valid := d.DataSets[:0]
+	for i := range d.DataSets {
+		ds := d.DataSets[i]
+		if reason := ds.entityError(); reason != "" {
+			errs = append(errs, ValidationError{DatasetIndex: i, Item: ItemDataset, Name: ds.Entity.Name, Reason: reason})
+			continue
+		}
+
+		metrics := ds.Metrics[:0]
+		for _, m := range ds.Metrics {
+			if reason := m.valueError(); reason != "" {
+				errs = append(errs, ValidationError{DatasetIndex: i, Item: ItemMetric, Name: m.Name, Reason: reason})
+				continue
+			}
+			if err := m.ValidateMetadata(); err != nil {
+				errs = append(errs, ValidationError{DatasetIndex: i, Item: ItemMetric, Name: m.Name, Reason: err.Error(), Sanitised: true})
+				m.Unit = ""
+				m.Description = ""
+			}
+			for k, v := range m.Attributes {
+				if !isScalar(v) {
+					errs = append(errs, ValidationError{DatasetIndex: i, Item: ItemMetric, Name: m.Name, Reason: fmt.Sprintf("attribute %q is not a scalar value", k), Sanitised: true})
+					delete(m.Attributes, k)
+				}
+			}
+			metrics = append(metrics, m)
+		}
+		ds.Metrics = metrics
+
+		events := ds.Events[:0]
+		for _, e := range ds.Events {
+			if _, ok := e["summary"]; !ok {
+				errs = append(errs, ValidationError{DatasetIndex: i, Item: ItemEvent, Reason: "missing required 'summary' field"})
+				continue
+			}
+			events = append(events, e)
+		}
+		ds.Events = events
+
+		valid = append(valid, ds)
+	}
+	d.DataSets = valid
+
+	return
+}
+
+// entityError returns the reason why the dataset entity is not valid, or empty if it is.
+func (ds *Dataset) entityError() string {
+	if ds.IgnoreEntity {
+		return ""
+	}
+	if ds.Entity.Name == "" && (ds.Entity.Type != "" || ds.Entity.DisplayName != "" || len(ds.Entity.IDAttributes) > 0) {
+		return "entity name is required when ignore_entity is false"
+	}
+	if ds.Entity.Name != "" && ds.Entity.Type == "" {
+		return "entity type is required when ignore_entity is false"
+	}
+	return ""
+}
+
+// valueError returns the reason why the metric name, type or value are not valid, or empty if they are.
+func (m *Metric) valueError() string {
+	if m.Name == "" {
+		return "missing metric name"
+	}
+	if len(m.Value) == 0 {
+		return "missing metric value"
+	}
+
+	switch m.Type {
+	case MetricTypeGauge, MetricTypeRate, "cumulative-rate":
+		if _, err := m.NumericValue(); err != nil {
+			return fmt.Sprintf("invalid %s value: %s", m.Type, err)
+		}
+	case MetricTypeCount, "cumulative-count":
+		v, err := m.NumericValue()
+		if err != nil {
+			return fmt.Sprintf("invalid %s value: %s", m.Type, err)
+		}
+		if v < 0 {
+			return fmt.Sprintf("negative %s value", m.Type)
+		}
+	case MetricTypeSummary:
+		v, err := m.SummaryValue()
+		if err != nil {
+			return fmt.Sprintf("invalid summary value: %s", err)
+		}
+		if v.Count < 0 {
+			return "negative summary count"
+		}
+		if v.Count > 0 && v.Min > v.Max {
+			return "summary min is greater than max"
+		}
+	case MetricTypePrometheusSummary:
+		v, err := m.GetPrometheusSummaryValue()
+		if err != nil {
+			return fmt.Sprintf("invalid prometheus-summary value: %s", err)
+		}
+		if v.SampleCount < 0 {
+			return "negative prometheus-summary sample_count"
+		}
+	case MetricTypePrometheusHistogram:
+		v, err := m.GetPrometheusHistogramValue()
+		if err != nil {
+			return fmt.Sprintf("invalid prometheus-histogram value: %s", err)
+		}
+		if v.SampleCount == nil {
+			return "missing prometheus-histogram sample_count"
+		}
+		for _, b := range v.Buckets {
+			if b == nil || b.UpperBound == nil || b.CumulativeCount == nil {
+				return "prometheus-histogram bucket requires upper_bound and cumulative_count"
+			}
+			if *b.CumulativeCount < 0 {
+				return "negative prometheus-histogram bucket cumulative_count"
+			}
+		}
+	default:
+		return fmt.Sprintf("unknown metric type %q", m.Type)
+	}
+
+	return ""
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case string, bool, float64, float32, int, int64, int32, uint, uint64, uint32, nil:
+		return true
+	}
+	return false
+}
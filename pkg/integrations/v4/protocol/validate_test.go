@@ -0,0 +1,77 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package protocol
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataV4_Validate(t *testing.T) {
+	payload := `{
+  "protocol_version": "4",
+  "integration": {"name": "test", "version": "1.0"},
+  "data": [
+    {
+      "entity": {"type": "RedisInstance", "displayName": "no name"},
+      "metrics": [{"name": "dropped", "type": "gauge", "value": 1}]
+    },
+    {
+      "entity": {"name": "redis:1234", "type": "RedisInstance"},
+      "metrics": [
+        {"name": "valid.gauge", "type": "gauge", "value": 1.5},
+        {"name": "string.gauge", "type": "gauge", "value": "1.5"},
+        {"name": "negative.count", "type": "count", "value": -3},
+        {"name": "bad.summary", "type": "summary", "value": 3},
+        {"name": "unknown.type", "type": "foo", "value": 3},
+        {"name": "", "type": "gauge", "value": 3},
+        {"name": "bad.histogram", "type": "prometheus-histogram", "value": {"sample_count": 1, "buckets": [{"upper_bound": 1}]}},
+        {"name": "bad.unit", "type": "gauge", "value": 1, "unit": "kilo bytes"},
+        {"name": "nested.attr", "type": "gauge", "value": 1, "attributes": {"ok": "yes", "nested": {"a": 1}}}
+      ],
+      "events": [{"summary": "ok"}, {"category": "no summary"}]
+    }
+  ]
+}`
+	var data DataV4
+	require.NoError(t, json.Unmarshal([]byte(payload), &data))
+
+	errs := data.Validate()
+
+	require.Len(t, data.DataSets, 1)
+	ds := data.DataSets[0]
+	assert.Equal(t, "redis:1234", ds.Entity.Name)
+
+	var names []string
+	for _, m := range ds.Metrics {
+		names = append(names, m.Name)
+	}
+	assert.Equal(t, []string{"valid.gauge", "bad.unit", "nested.attr"}, names)
+	assert.Empty(t, ds.Metrics[1].Unit)
+	assert.Equal(t, map[string]interface{}{"ok": "yes"}, ds.Metrics[2].Attributes)
+	assert.Len(t, ds.Events, 1)
+
+	var rejected, sanitised int
+	for _, err := range errs {
+		if err.Sanitised {
+			sanitised++
+		} else {
+			rejected++
+		}
+	}
+	assert.Equal(t, 8, rejected)
+	assert.Equal(t, 2, sanitised)
+	assert.Equal(t, ValidationError{DatasetIndex: 0, Item: ItemDataset, Reason: "entity name is required when ignore_entity is false"}, errs[0])
+	assert.Equal(t, 1, errs[1].DatasetIndex)
+	assert.Equal(t, "string.gauge", errs[1].Name)
+}
+
+func TestDataV4_Validate_IgnoreEntity(t *testing.T) {
+	data := DataV4{DataSets: []Dataset{{IgnoreEntity: true, Metrics: []Metric{{Name: "m", Type: MetricTypeCount, Value: json.RawMessage("2")}}}}}
+
+	assert.Empty(t, data.Validate())
+	assert.Len(t, data.DataSets[0].Metrics, 1)
+}
@@ -29,6 +29,7 @@ type FBSupervisorConfig struct {
 	FluentBitNRLibPath   string
 	FluentBitParsersPath string
 	FluentBitVerbose     bool
+	FluentBitMetricsPort int
 }
 
 // IsLogForwarderAvailable checks whether all the required files for FluentBit execution are available
@@ -59,6 +60,7 @@ var (
 
 // NewFBSupervisor builds a Fluent Bit supervisor which forwards the output to agent logs.
 func NewFBSupervisor(fbIntCfg FBSupervisorConfig, cfgLoader *logs.CfgLoader, agentIDNotifier id.UpdateNotifyFn, notifier hostname.ChangeNotifier, sendEventFn SendEventFn) *Supervisor {
+	reporter := newDroppedRecordsReporter(fbIntCfg.FluentBitMetricsPort, sendEventFn)
 	return &Supervisor{
 		listenAgentIDChanges:   agentIDNotifier,
 		hostnameChangeNotifier: notifier,
@@ -68,21 +70,23 @@ func NewFBSupervisor(fbIntCfg FBSupervisorConfig, cfgLoader *logs.CfgLoader, age
 		buildExecutor:          buildFbExecutor(fbIntCfg, cfgLoader),
 		log:                    sFBLogger,
 		traceOutput:            fbIntCfg.FluentBitVerbose,
-		preRunActions:          fbPreRunActions(sendEventFn),
-		postRunActions:         fbPostRunActions(sendEventFn),
+		preRunActions:          fbPreRunActions(sendEventFn, reporter),
+		postRunActions:         fbPostRunActions(sendEventFn, reporter),
 		parseOutputFn:          logs.ParseFBOutput,
 	}
 }
 
-func fbPreRunActions(sendEventFn SendEventFn) func(ctx2.Context) {
-	return func(ctx2.Context) {
+func fbPreRunActions(sendEventFn SendEventFn, reporter *droppedRecordsReporter) func(ctx2.Context) {
+	return func(ctx ctx2.Context) {
 		event := NewSupervisorEvent("Fluent Bit Started", statusRunning)
 		sendEventFn(event, entity.EmptyKey)
+		reporter.start(ctx)
 	}
 }
 
-func fbPostRunActions(sendEventFn SendEventFn) func(ctx2.Context, cmdExitStatus) {
+func fbPostRunActions(sendEventFn SendEventFn, reporter *droppedRecordsReporter) func(ctx2.Context, cmdExitStatus) {
 	return func(ctx ctx2.Context, exitCode cmdExitStatus) {
+		reporter.stop()
 		event := NewSupervisorEvent("Fluent Bit Stopped", exitCode)
 		sendEventFn(event, entity.EmptyKey)
 	}
@@ -92,7 +96,7 @@ func fbPostRunActions(sendEventFn SendEventFn) func(ctx2.Context, cmdExitStatus)
 func buildFbExecutor(fbIntCfg FBSupervisorConfig, cfgLoader *logs.CfgLoader) func() (Executor, error) {
 	return func() (Executor, error) {
 
-		cfgContent, externalCfg, cErr := cfgLoader.LoadAndFormat()
+		cfgContent, parsersContent, externalCfg, cErr := cfgLoader.LoadAndFormat()
 		if cErr != nil {
 			return nil, cErr
 		}
@@ -116,6 +120,14 @@ func buildFbExecutor(fbIntCfg FBSupervisorConfig, cfgLoader *logs.CfgLoader) fun
 			args = append(args, "-R", externalCfg.ParsersFilePath)
 		}
 
+		if parsersContent != "" {
+			parsersTmpPath, err := saveToTempFile([]byte(parsersContent))
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to create temporary fb parsers file")
+			}
+			args = append(args, "-R", parsersTmpPath)
+		}
+
 		if fbIntCfg.FluentBitVerbose {
 			args = append(args, "-vv")
 		}
@@ -0,0 +1,142 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package v4
+
+import (
+	ctx2 "context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/integrations/v4/logs"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+const (
+	fbMetricsURLFormat      = "http://127.0.0.1:%d/api/v1/metrics"
+	fbMetricsReportInterval = 30 * time.Second
+	fbMetricsTimeout        = 5 * time.Second
+)
+
+// LogForwarderSample reports the log records of a source dropped by rate limiting and sampling since the
+// previous sample.
+type LogForwarderSample struct {
+	sample.BaseEvent
+	LogSource      string `json:"logSource"`
+	DroppedRecords uint64 `json:"droppedRecords"`
+}
+
+// fbMetrics is the subset of the Fluent Bit internal metrics used by the agent.
+type fbMetrics struct {
+	Filter map[string]struct {
+		DropRecords uint64 `json:"drop_records"`
+	} `json:"filter"`
+}
+
+// droppedRecordsReporter periodically reports the records dropped by the Fluent Bit throttling filters while
+// Fluent Bit is running.
+type droppedRecordsReporter struct {
+	metricsURL  string
+	interval    time.Duration
+	client      *http.Client
+	sendEventFn SendEventFn
+	cancel      ctx2.CancelFunc
+	done        chan struct{} // closed when the reporting goroutine returns
+}
+
+func newDroppedRecordsReporter(metricsPort int, sendEventFn SendEventFn) *droppedRecordsReporter {
+	return &droppedRecordsReporter{
+		metricsURL:  fmt.Sprintf(fbMetricsURLFormat, metricsPort),
+		interval:    fbMetricsReportInterval,
+		client:      &http.Client{Timeout: fbMetricsTimeout},
+		sendEventFn: sendEventFn,
+	}
+}
+
+// start reports the dropped records of a new Fluent Bit process until it is stopped.
+func (r *droppedRecordsReporter) start(ctx ctx2.Context) {
+	r.stop()
+	ctx, r.cancel = ctx2.WithCancel(ctx)
+	done := make(chan struct{})
+	r.done = done
+	go func() {
+		defer close(done)
+		// metrics are reset when Fluent Bit restarts
+		dropped := map[string]uint64{}
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.report(ctx, dropped)
+			}
+		}
+	}()
+}
+
+// stop stops reporting and waits for any ongoing report to finish.
+func (r *droppedRecordsReporter) stop() {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+		r.cancel, r.done = nil, nil
+	}
+}
+
+// report sends a sample for each throttled log source that dropped records since the previous report, whose
+// last values read for each filter are kept in dropped.
+func (r *droppedRecordsReporter) report(ctx ctx2.Context, dropped map[string]uint64) {
+	metrics, err := r.fetchMetrics(ctx)
+	if err != nil {
+		// Fluent Bit only serves metrics when rate limiting or sampling are configured
+		sFBLogger.WithError(err).Debug("Cannot read Fluent Bit metrics.")
+		return
+	}
+	droppedBySource := map[string]uint64{}
+	for alias, filter := range metrics.Filter {
+		source, ok := logs.ThrottledSource(alias)
+		if !ok {
+			continue
+		}
+		delta := filter.DropRecords
+		if last := dropped[alias]; last <= filter.DropRecords {
+			delta -= last
+		}
+		dropped[alias] = filter.DropRecords
+		droppedBySource[source] += delta
+	}
+	for source, dropped := range droppedBySource {
+		if dropped == 0 {
+			continue
+		}
+		r.sendEventFn(&LogForwarderSample{
+			BaseEvent: sample.BaseEvent{
+				EventType: "LogForwarderSample",
+				Timestmp:  time.Now().Unix(),
+			},
+			LogSource:      source,
+			DroppedRecords: dropped,
+		}, entity.EmptyKey)
+	}
+}
+
+func (r *droppedRecordsReporter) fetchMetrics(ctx ctx2.Context) (metrics fbMetrics, err error) {
+	req, err := http.NewRequest(http.MethodGet, r.metricsURL, nil)
+	if err != nil {
+		return
+	}
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return metrics, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&metrics)
+	return
+}
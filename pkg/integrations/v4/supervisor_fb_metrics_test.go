@@ -0,0 +1,99 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package v4
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/newrelic/infrastructure-agent/pkg/entity"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+func TestDroppedRecordsReporter_Report(t *testing.T) {
+	// GIVEN Fluent Bit metrics for rate limiting and sampling filters
+	throttled, sampled := 10, 5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/metrics", r.URL.Path)
+		_, _ = fmt.Fprintf(w, `{"input":{"tail.0":{"records":100}},"filter":{`+
+			`"nri-throttle-app":{"drop_records":%d,"add_records":0},`+
+			`"nri-sample-app":{"drop_records":%d,"add_records":0},`+
+			`"grep.0":{"drop_records":50,"add_records":0}}}`, throttled, sampled)
+	}))
+	defer server.Close()
+
+	var samples []*LogForwarderSample
+	reporter := newDroppedRecordsReporter(0, func(event sample.Event, entityKey entity.Key) {
+		samples = append(samples, event.(*LogForwarderSample))
+	})
+	reporter.metricsURL = server.URL + "/api/v1/metrics"
+	dropped := map[string]uint64{}
+
+	// WHEN the dropped records are reported
+	reporter.report(context.Background(), dropped)
+
+	// THEN the records dropped by the source filters are added
+	assert.Len(t, samples, 1)
+	assert.Equal(t, "LogForwarderSample", samples[0].EventType)
+	assert.Equal(t, "app", samples[0].LogSource)
+	assert.Equal(t, uint64(15), samples[0].DroppedRecords)
+
+	// AND further reports only include the records dropped since the previous one
+	throttled = 30
+	reporter.report(context.Background(), dropped)
+	assert.Len(t, samples, 2)
+	assert.Equal(t, uint64(20), samples[1].DroppedRecords)
+
+	// AND no samples are sent for the sources that didn't drop records since the previous report
+	reporter.report(context.Background(), dropped)
+	assert.Len(t, samples, 2)
+}
+
+func TestDroppedRecordsReporter_ReportUnavailableMetrics(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	reporter := newDroppedRecordsReporter(0, func(event sample.Event, entityKey entity.Key) {
+		assert.Fail(t, "no samples expected")
+	})
+	reporter.metricsURL = server.URL
+
+	reporter.report(context.Background(), map[string]uint64{})
+}
+
+func TestDroppedRecordsReporter_StartStop(t *testing.T) {
+	// GIVEN Fluent Bit metrics reporting dropped records
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"filter":{"nri-throttle-app":{"drop_records":10}}}`)
+	}))
+	defer server.Close()
+
+	reports := make(chan *LogForwarderSample, 100)
+	reporter := newDroppedRecordsReporter(0, func(event sample.Event, entityKey entity.Key) {
+		reports <- event.(*LogForwarderSample)
+	})
+	reporter.metricsURL = server.URL
+	reporter.interval = time.Millisecond
+
+	for i := 0; i < 3; i++ {
+		// WHEN the reporter is started for a new Fluent Bit process
+		reporter.start(context.Background())
+		// THEN all the records dropped by the new process are reported
+		sample := <-reports
+		assert.Equal(t, uint64(10), sample.DroppedRecords)
+
+		// AND once stopped, no more reports are sent
+		reporter.stop()
+		for len(reports) > 0 {
+			<-reports
+		}
+		time.Sleep(5 * time.Millisecond)
+		assert.Empty(t, reports)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Container runtimes whose cgroups are identified.
+const (
+	runtimeDocker     = "docker"
+	runtimeContainerd = "containerd"
+	runtimeCRIO       = "cri-o"
+	runtimePodman     = "podman"
+	runtimeNspawn     = "systemd-nspawn"
+	runtimeLXC        = "lxc"
+)
+
+// unlimited is the value of the cgroup v2 limit files when no limit is set.
+const unlimited = "max"
+
+var (
+	// systemd cgroup driver scopes, e.g. docker-<id>.scope, cri-containerd-<id>.scope, crio-<id>.scope, libpod-<id>.scope
+	scopeRegex = regexp.MustCompile(`^(docker|cri-containerd|crio|libpod)-([0-9a-f]{64})\.scope$`)
+	// cgroupfs driver directories are named after the container ID, under a runtime directory (e.g. /docker/<id>)
+	containerIDRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	// systemd-nspawn machines, run either as a service or as a scope registered by machined
+	nspawnRegex = regexp.MustCompile(`^(?:systemd-nspawn@(.+)\.service|machine-(.+)\.scope)$`)
+	// LXC 4+ containers
+	lxcRegex = regexp.MustCompile(`^lxc\.payload\.(.+)$`)
+)
+
+var scopeRuntimes = map[string]string{
+	"docker":         runtimeDocker,
+	"cri-containerd": runtimeContainerd,
+	"crio":           runtimeCRIO,
+	"libpod":         runtimePodman,
+}
+
+// containerID is the container running in a cgroup.
+type containerID struct {
+	ID      string
+	Runtime string
+}
+
+// identify returns the container running in a cgroup, from its path relative to the cgroup hierarchy root.
+func identify(cgroupPath string) (c containerID, ok bool) {
+	name := filepath.Base(cgroupPath)
+	if parts := scopeRegex.FindStringSubmatch(name); parts != nil {
+		return containerID{ID: parts[2], Runtime: scopeRuntimes[parts[1]]}, true
+	}
+	if containerIDRegex.MatchString(name) {
+		return containerID{ID: name, Runtime: cgroupfsRuntime(filepath.Dir(cgroupPath))}, true
+	}
+	if parts := nspawnRegex.FindStringSubmatch(name); parts != nil {
+		machine := parts[1]
+		if machine == "" {
+			machine = parts[2]
+		}
+		// systemd escapes the dashes of the unit names
+		return containerID{ID: strings.Replace(machine, `\x2d`, "-", -1), Runtime: runtimeNspawn}, true
+	}
+	if parts := lxcRegex.FindStringSubmatch(name); parts != nil {
+		return containerID{ID: parts[1], Runtime: runtimeLXC}, true
+	}
+	return containerID{}, false
+}
+
+// cgroupfsRuntime returns the container runtime from the parent directories of a cgroupfs driver container, or
+// an empty string if they don't tell (e.g. kubepods, which are used by any Kubernetes runtime).
+func cgroupfsRuntime(parent string) string {
+	switch {
+	case strings.Contains(parent, "docker"):
+		return runtimeDocker
+	case strings.Contains(parent, "containerd"):
+		return runtimeContainerd
+	case strings.Contains(parent, "crio"):
+		return runtimeCRIO
+	case strings.Contains(parent, "libpod"):
+		return runtimePodman
+	default:
+		return ""
+	}
+}
+
+// isSlice returns true if the cgroup is a systemd slice, grouping the resources of several units.
+func isSlice(cgroupPath string) bool {
+	return strings.HasSuffix(cgroupPath, ".slice")
+}
+
+// isUnified returns true if the cgroup hierarchy is mounted in cgroup v2 (unified) mode.
+func isUnified(root string) bool {
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return err == nil
+}
+
+// stats are the counters and gauges read from the cgroup v2 interface files.
+type stats struct {
+	cpu       map[string]uint64 // cpu.stat: usage_usec, user_usec, system_usec, nr_periods, nr_throttled, throttled_usec
+	cpuQuota  float64           // cpu.max, in cores. 0 if unlimited
+	memory    uint64            // memory.current
+	memStat   map[string]uint64 // memory.stat: anon, file...
+	memLimit  uint64            // memory.max. 0 if unlimited
+	swap      *uint64           // memory.swap.current, nil if swap accounting is disabled
+	memEvents map[string]uint64 // memory.events: oom, oom_kill...
+	io        ioStats           // io.stat, all devices
+	pids      *uint64           // pids.current, nil if the pids controller is disabled
+	pidsLimit uint64            // pids.max. 0 if unlimited
+}
+
+// ioStats are the accumulated IO counters of a cgroup.
+type ioStats struct {
+	readBytes, writeBytes, reads, writes uint64
+}
+
+// readStats reads the resources usage of a cgroup. CPU and memory stats are required, the rest are only
+// available if their controllers are enabled for the cgroup.
+func readStats(dir string) (s stats, err error) {
+	if s.cpu, err = readKeyValues(filepath.Join(dir, "cpu.stat")); err != nil {
+		return s, err
+	}
+	if s.memory, _, err = readUint(filepath.Join(dir, "memory.current")); err != nil {
+		return s, err
+	}
+	if s.memStat, err = readKeyValues(filepath.Join(dir, "memory.stat")); err != nil {
+		return s, err
+	}
+	s.memLimit, _, _ = readUint(filepath.Join(dir, "memory.max"))
+	if swap, ok, err := readUint(filepath.Join(dir, "memory.swap.current")); err == nil && ok {
+		s.swap = &swap
+	}
+	s.memEvents, _ = readKeyValues(filepath.Join(dir, "memory.events"))
+	s.cpuQuota, _ = readCPUMax(filepath.Join(dir, "cpu.max"))
+	s.io, _ = readIOStat(filepath.Join(dir, "io.stat"))
+	if pids, ok, err := readUint(filepath.Join(dir, "pids.current")); err == nil && ok {
+		s.pids = &pids
+	}
+	s.pidsLimit, _, _ = readUint(filepath.Join(dir, "pids.max"))
+	return s, nil
+}
+
+// readKeyValues reads flat keyed files, e.g. cpu.stat:
+//
+//	usage_usec 1234
+//	user_usec 1000
+func readKeyValues(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in %s for %s: %s", path, fields[0], err)
+		}
+		values[fields[0]] = value
+	}
+	return values, scanner.Err()
+}
+
+// readUint reads single value files. It returns ok=false if the value is "max" (unlimited).
+func readUint(path string) (value uint64, ok bool, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, false, err
+	}
+	text := strings.TrimSpace(string(content))
+	if text == unlimited {
+		return 0, false, nil
+	}
+	value, err = strconv.ParseUint(text, 10, 64)
+	return value, err == nil, err
+}
+
+// readCPUMax returns the CPU limit in cores from the cpu.max file ("$MAX $PERIOD"), or 0 if unlimited.
+func readCPUMax(path string) (float64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return parseCPUMax(string(content))
+}
+
+func parseCPUMax(content string) (float64, error) {
+	fields := strings.Fields(content)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("unexpected cpu.max format: %q", content)
+	}
+	if fields[0] == unlimited {
+		return 0, nil
+	}
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	period, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || period == 0 {
+		return 0, fmt.Errorf("invalid cpu.max period: %q", fields[1])
+	}
+	return quota / period, nil
+}
+
+// readIOStat returns the IO counters of all the devices in the io.stat file.
+func readIOStat(path string) (ioStats, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ioStats{}, err
+	}
+	return parseIOStat(string(content)), nil
+}
+
+// parseIOStat sums the counters of all the devices, e.g.:
+//
+//	8:0 rbytes=1024 wbytes=2048 rios=10 wios=20 dbytes=0 dios=0
+func parseIOStat(content string) (s ioStats) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch kv[0] {
+			case "rbytes":
+				s.readBytes += value
+			case "wbytes":
+				s.writeBytes += value
+			case "rios":
+				s.reads += value
+			case "wios":
+				s.writes += value
+			}
+		}
+	}
+	return s
+}
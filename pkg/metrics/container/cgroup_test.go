@@ -0,0 +1,72 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package container
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testContainerID = strings.Repeat("0123456789abcdef", 4)
+
+func TestIdentify(t *testing.T) {
+	tests := []struct {
+		cgroupPath string
+		expected   containerID
+	}{
+		{"/system.slice/docker-" + testContainerID + ".scope", containerID{testContainerID, runtimeDocker}},
+		{"/docker/" + testContainerID, containerID{testContainerID, runtimeDocker}},
+		{"/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + testContainerID + ".scope", containerID{testContainerID, runtimeContainerd}},
+		{"/system.slice/containerd.service/default/" + testContainerID, containerID{testContainerID, runtimeContainerd}},
+		{"/kubepods.slice/kubepods-pod1234.slice/crio-" + testContainerID + ".scope", containerID{testContainerID, runtimeCRIO}},
+		{"/kubepods/besteffort/pod1234/" + testContainerID, containerID{testContainerID, ""}},
+		{"/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + testContainerID + ".scope", containerID{testContainerID, runtimePodman}},
+		{"/machine.slice/systemd-nspawn@debian.service", containerID{"debian", runtimeNspawn}},
+		{`/machine.slice/machine-my\x2dmachine.scope`, containerID{"my-machine", runtimeNspawn}},
+		{"/lxc.payload.web", containerID{"web", runtimeLXC}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cgroupPath, func(t *testing.T) {
+			c, ok := identify(tt.cgroupPath)
+
+			assert.True(t, ok)
+			assert.Equal(t, tt.expected, c)
+		})
+	}
+}
+
+func TestIdentify_NotAContainer(t *testing.T) {
+	for _, cgroupPath := range []string{
+		"/system.slice",
+		"/system.slice/sshd.service",
+		"/system.slice/libpod-conmon-" + testContainerID + ".scope",
+		"/init.scope",
+	} {
+		_, ok := identify(cgroupPath)
+		assert.False(t, ok, cgroupPath)
+	}
+}
+
+func TestParseCPUMax(t *testing.T) {
+	cores, err := parseCPUMax("150000 100000\n")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, cores)
+
+	cores, err = parseCPUMax("max 100000\n")
+	require.NoError(t, err)
+	assert.Zero(t, cores)
+
+	_, err = parseCPUMax("max")
+	assert.Error(t, err)
+}
+
+func TestParseIOStat(t *testing.T) {
+	s := parseIOStat(`8:0 rbytes=1024 wbytes=2048 rios=10 wios=20 dbytes=0 dios=0
+253:0 rbytes=1000 wbytes=0 rios=5 wios=0 dbytes=0 dios=0
+`)
+
+	assert.Equal(t, ioStats{readBytes: 2024, writeBytes: 2048, reads: 15, writes: 20}, s)
+}
@@ -0,0 +1,286 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var cslog = log.WithComponent("ContainerSampler")
+
+// Sample contains the resources usage of a container or a systemd slice, from its cgroup v2 interface files.
+// Rates and OOM events are relative to the previous sample, so they're omitted in the first one.
+type Sample struct {
+	sample.BaseEvent
+
+	// Path of the cgroup, relative to the cgroup hierarchy root
+	CgroupPath string `json:"cgroupPath"`
+	// ID of the container (the machine name for systemd-nspawn and LXC containers), empty for slices
+	ContainerID string `json:"containerId,omitempty"`
+	// Runtime of the container, when it can be told by the cgroup path
+	ContainerRuntime string `json:"containerRuntime,omitempty"`
+
+	// CPU usage, as a percentage of a single core
+	CPUPercent       *float64 `json:"cpuPercent,omitempty"`
+	CPUUserPercent   *float64 `json:"cpuUserPercent,omitempty"`
+	CPUSystemPercent *float64 `json:"cpuSystemPercent,omitempty"`
+	// CPU limit in cores, omitted if unlimited
+	CPULimitCores *float64 `json:"cpuLimitCores,omitempty"`
+	// Percentage of the CPU enforcement periods in which the cgroup was throttled
+	CPUThrottledPeriodsPercent *float64 `json:"cpuThrottledPeriodsPercent,omitempty"`
+	// Time the cgroup was throttled, in milliseconds per second
+	CPUThrottledTimeMsPerSecond *float64 `json:"cpuThrottledTimeMsPerSecond,omitempty"`
+
+	// Memory used by the cgroup, including the page cache
+	MemoryUsageBytes    uint64 `json:"memoryUsageBytes"`
+	MemoryResidentBytes uint64 `json:"memoryResidentSizeBytes"`
+	MemoryCacheBytes    uint64 `json:"memoryCacheBytes"`
+	// Memory limit, omitted if unlimited
+	MemoryLimitBytes   *uint64  `json:"memoryLimitBytes,omitempty"`
+	MemoryUsagePercent *float64 `json:"memoryUsageLimitPercent,omitempty"`
+	// Swap used by the cgroup, omitted if swap accounting is disabled
+	MemorySwapUsageBytes *uint64 `json:"memorySwapUsageBytes,omitempty"`
+	// Times the cgroup reached its memory limit and the OOM killer was invoked, and processes killed by it
+	MemoryOOMEvents *uint64 `json:"memoryOomEvents,omitempty"`
+	MemoryOOMKills  *uint64 `json:"memoryOomKills,omitempty"`
+
+	IOReadBytesPerSecond  *float64 `json:"ioReadBytesPerSecond,omitempty"`
+	IOWriteBytesPerSecond *float64 `json:"ioWriteBytesPerSecond,omitempty"`
+	IOReadCountPerSecond  *float64 `json:"ioReadCountPerSecond,omitempty"`
+	IOWriteCountPerSecond *float64 `json:"ioWriteCountPerSecond,omitempty"`
+
+	// Processes (tasks) running in the cgroup, omitted if the pids controller is disabled
+	ProcessCount *uint64 `json:"processCount,omitempty"`
+	ProcessLimit *uint64 `json:"processLimit,omitempty"`
+
+	*metrics.PressureSample
+}
+
+// Sampler reports the resources usage of the containers and slices in the cgroup v2 hierarchy, regardless of
+// their runtime.
+type Sampler struct {
+	context    agent.AgentContext
+	sampleRate time.Duration
+	root       string
+	now        func() time.Time
+	last       map[string]statsCache // key: cgroup path
+	pressure   map[string]*metrics.PressureMonitor
+}
+
+type statsCache struct {
+	stats stats
+	time  time.Time
+}
+
+func NewSampler(context agent.AgentContext) *Sampler {
+	sampleRateSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		sampleRateSec = context.Config().MetricsContainerSampleRate
+	}
+
+	return &Sampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		root:       helpers.HostSys("fs", "cgroup"),
+		now:        time.Now,
+		last:       map[string]statsCache{},
+		pressure:   map[string]*metrics.PressureMonitor{},
+	}
+}
+
+func (s *Sampler) OnStartup() {}
+
+func (s *Sampler) Name() string {
+	return "ContainerSampler"
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *Sampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+func (s *Sampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in container.Sampler: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	if !isUnified(s.root) {
+		cslog.WithField("root", s.root).Debug("Cgroup v2 hierarchy not found, not sampling containers.")
+		return nil, nil
+	}
+
+	now := s.now()
+	seen := map[string]bool{}
+	err = filepath.Walk(s.root, func(dir string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() || dir == s.root {
+			// cgroups can be removed while walking the hierarchy
+			return nil
+		}
+		cgroupPath := "/" + filepath.ToSlash(relPath(s.root, dir))
+		container, isContainer := identify(cgroupPath)
+		if !isContainer && !isSlice(cgroupPath) {
+			return nil
+		}
+		if ss, err := s.sampleCgroup(dir, cgroupPath, container, now); err != nil {
+			cslog.WithError(err).WithField("cgroup", cgroupPath).Debug("Cannot sample cgroup.")
+		} else {
+			seen[cgroupPath] = true
+			eventBatch = append(eventBatch, ss)
+		}
+		if isContainer {
+			// the stats of a cgroup include its descendants
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// forget the removed cgroups
+	for cgroupPath := range s.last {
+		if !seen[cgroupPath] {
+			delete(s.last, cgroupPath)
+			delete(s.pressure, cgroupPath)
+		}
+	}
+	return eventBatch, nil
+}
+
+// sampleCgroup returns the sample of a cgroup, storing its stats to calculate the rates of the next one.
+func (s *Sampler) sampleCgroup(dir, cgroupPath string, container containerID, now time.Time) (*Sample, error) {
+	current, err := readStats(dir)
+	if err != nil {
+		return nil, err
+	}
+	var last *statsCache
+	if l, ok := s.last[cgroupPath]; ok {
+		last = &l
+	}
+	s.last[cgroupPath] = statsCache{stats: current, time: now}
+
+	ss := newSample(cgroupPath, container, current, last, now)
+
+	monitor, ok := s.pressure[cgroupPath]
+	if !ok {
+		monitor = metrics.NewCgroupPressureMonitor(dir)
+		s.pressure[cgroupPath] = monitor
+	}
+	if ss.PressureSample, err = monitor.Sample(); err != nil {
+		cslog.WithError(err).WithField("cgroup", cgroupPath).Debug("Cannot sample cgroup pressure stall information.")
+	}
+	return ss, nil
+}
+
+// newSample calculates the sample of a cgroup from its current stats and, if any, the stats of the previous sample.
+func newSample(cgroupPath string, container containerID, current stats, last *statsCache, now time.Time) *Sample {
+	ss := &Sample{
+		CgroupPath:           cgroupPath,
+		ContainerID:          container.ID,
+		ContainerRuntime:     container.Runtime,
+		MemoryUsageBytes:     current.memory,
+		MemoryResidentBytes:  current.memStat["anon"],
+		MemoryCacheBytes:     current.memStat["file"],
+		MemorySwapUsageBytes: current.swap,
+		ProcessCount:         current.pids,
+	}
+	ss.Type("ContainerSample")
+
+	if current.cpuQuota > 0 {
+		ss.CPULimitCores = &current.cpuQuota
+	}
+	if current.memLimit > 0 {
+		ss.MemoryLimitBytes = &current.memLimit
+		percent := float64(current.memory) / float64(current.memLimit) * 100
+		ss.MemoryUsagePercent = &percent
+	}
+	if current.pidsLimit > 0 {
+		ss.ProcessLimit = &current.pidsLimit
+	}
+
+	if last == nil {
+		return ss
+	}
+	elapsed := now.Sub(last.time).Seconds()
+	if elapsed <= 0 {
+		return ss
+	}
+
+	// cpu.stat times are in microseconds
+	ss.CPUPercent = scale(rate(current.cpu["usage_usec"], last.stats.cpu["usage_usec"], elapsed), 1e-4)
+	ss.CPUUserPercent = scale(rate(current.cpu["user_usec"], last.stats.cpu["user_usec"], elapsed), 1e-4)
+	ss.CPUSystemPercent = scale(rate(current.cpu["system_usec"], last.stats.cpu["system_usec"], elapsed), 1e-4)
+	ss.CPUThrottledTimeMsPerSecond = scale(rate(current.cpu["throttled_usec"], last.stats.cpu["throttled_usec"], elapsed), 1e-3)
+	if periods, ok := delta(current.cpu["nr_periods"], last.stats.cpu["nr_periods"]); ok && periods > 0 {
+		if throttled, ok := delta(current.cpu["nr_throttled"], last.stats.cpu["nr_throttled"]); ok {
+			percent := float64(throttled) / float64(periods) * 100
+			ss.CPUThrottledPeriodsPercent = &percent
+		}
+	}
+
+	if current.memEvents != nil && last.stats.memEvents != nil {
+		if events, ok := delta(current.memEvents["oom"], last.stats.memEvents["oom"]); ok {
+			ss.MemoryOOMEvents = &events
+		}
+		if kills, ok := delta(current.memEvents["oom_kill"], last.stats.memEvents["oom_kill"]); ok {
+			ss.MemoryOOMKills = &kills
+		}
+	}
+
+	ss.IOReadBytesPerSecond = rate(current.io.readBytes, last.stats.io.readBytes, elapsed)
+	ss.IOWriteBytesPerSecond = rate(current.io.writeBytes, last.stats.io.writeBytes, elapsed)
+	ss.IOReadCountPerSecond = rate(current.io.reads, last.stats.io.reads, elapsed)
+	ss.IOWriteCountPerSecond = rate(current.io.writes, last.stats.io.writes, elapsed)
+	return ss
+}
+
+// delta returns the increase of a counter, or ok=false if it was reset.
+func delta(current, last uint64) (uint64, bool) {
+	if current < last {
+		return 0, false
+	}
+	return current - last, true
+}
+
+// rate returns the increase per second of a counter, or nil if it was reset.
+func rate(current, last uint64, elapsed float64) *float64 {
+	d, ok := delta(current, last)
+	if !ok {
+		return nil
+	}
+	r := float64(d) / elapsed
+	return &r
+}
+
+func scale(value *float64, factor float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	scaled := *value * factor
+	return &scaled
+}
+
+// relPath returns the path of target relative to base, or target if it is not within base.
+func relPath(base, target string) string {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return target
+	}
+	return rel
+}
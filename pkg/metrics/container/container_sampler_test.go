@@ -0,0 +1,163 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package container
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cgroupFiles writes the cgroup v2 interface files of a cgroup into the hierarchy root.
+func cgroupFiles(t *testing.T, root, cgroupPath string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, cgroupPath)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+}
+
+func containerFiles(usageUsec, periods, throttled, oomKills, readBytes string) map[string]string {
+	return map[string]string{
+		"cpu.stat":       "usage_usec " + usageUsec + "\nuser_usec 1000000\nsystem_usec 500000\nnr_periods " + periods + "\nnr_throttled " + throttled + "\nthrottled_usec 20000\n",
+		"cpu.max":        "50000 100000\n",
+		"memory.current": "104857600\n",
+		"memory.max":     "209715200\n",
+		"memory.stat":    "anon 73400320\nfile 31457280\nkernel_stack 16384\n",
+		"memory.events":  "low 0\nhigh 0\nmax 3\noom 1\noom_kill " + oomKills + "\n",
+		"io.stat":        "8:0 rbytes=" + readBytes + " wbytes=0 rios=10 wios=0 dbytes=0 dios=0\n",
+		"pids.current":   "12\n",
+		"pids.max":       "max\n",
+		"cpu.pressure":   "some avg10=2.00 avg60=1.00 avg300=0.50 total=1000\nfull avg10=1.00 avg60=0.50 avg300=0.25 total=500\n",
+	}
+}
+
+func testSampler(t *testing.T) (s *Sampler, root string, now *time.Time) {
+	t.Helper()
+	root, err := ioutil.TempDir("", "cgroup2")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu io memory pids\n"), 0644))
+	s = NewSampler(nil)
+	s.root = root
+	current := time.Now()
+	s.now = func() time.Time { return current }
+	return s, root, &current
+}
+
+func TestSampler_Sample(t *testing.T) {
+	// GIVEN a cgroup v2 hierarchy with a slice running a container and a service
+	s, root, now := testSampler(t)
+	defer os.RemoveAll(root)
+	containerPath := "/system.slice/docker-" + testContainerID + ".scope"
+	cgroupFiles(t, root, "/system.slice", map[string]string{
+		"cpu.stat":       "usage_usec 5000000\n",
+		"memory.current": "524288000\n",
+		"memory.stat":    "anon 1024\n",
+	})
+	cgroupFiles(t, root, containerPath, containerFiles("2000000", "100", "10", "0", "4096"))
+	cgroupFiles(t, root, containerPath+"/init.scope", containerFiles("1000", "0", "0", "0", "0"))
+	cgroupFiles(t, root, "/system.slice/sshd.service", containerFiles("1000", "0", "0", "0", "0"))
+
+	// WHEN it's sampled for the first time
+	batch, err := s.Sample()
+	require.NoError(t, err)
+
+	// THEN the slice and the container are reported, but not their descendants
+	require.Len(t, batch, 2)
+	samples := map[string]*Sample{}
+	for _, event := range batch {
+		ss := event.(*Sample)
+		samples[ss.CgroupPath] = ss
+	}
+	slice := samples["/system.slice"]
+	require.NotNil(t, slice)
+	assert.Empty(t, slice.ContainerID)
+	assert.Equal(t, uint64(524288000), slice.MemoryUsageBytes)
+	assert.Nil(t, slice.MemoryLimitBytes)
+	assert.Nil(t, slice.ProcessCount)
+
+	ctr := samples[containerPath]
+	require.NotNil(t, ctr)
+	assert.Equal(t, "ContainerSample", ctr.EventType)
+	assert.Equal(t, testContainerID, ctr.ContainerID)
+	assert.Equal(t, "docker", ctr.ContainerRuntime)
+	assert.Equal(t, 0.5, *ctr.CPULimitCores)
+	assert.Equal(t, uint64(104857600), ctr.MemoryUsageBytes)
+	assert.Equal(t, uint64(73400320), ctr.MemoryResidentBytes)
+	assert.Equal(t, uint64(31457280), ctr.MemoryCacheBytes)
+	assert.Equal(t, uint64(209715200), *ctr.MemoryLimitBytes)
+	assert.Equal(t, 50.0, *ctr.MemoryUsagePercent)
+	assert.Equal(t, uint64(12), *ctr.ProcessCount)
+	assert.Nil(t, ctr.ProcessLimit)
+	require.NotNil(t, ctr.PressureSample)
+	assert.Equal(t, 2.0, *ctr.CPUSomeAvg10)
+	// AND the rates are not reported until the next sample
+	assert.Nil(t, ctr.CPUPercent)
+	assert.Nil(t, ctr.MemoryOOMKills)
+	assert.Nil(t, ctr.IOReadBytesPerSecond)
+
+	// WHEN it's sampled again
+	*now = now.Add(10 * time.Second)
+	cgroupFiles(t, root, containerPath, containerFiles("7000000", "200", "30", "2", "14336"))
+	batch, err = s.Sample()
+	require.NoError(t, err)
+
+	// THEN the container rates since the previous sample are reported
+	require.Len(t, batch, 2)
+	for _, event := range batch {
+		if ss := event.(*Sample); ss.CgroupPath == containerPath {
+			ctr = ss
+		}
+	}
+	assert.Equal(t, 50.0, *ctr.CPUPercent)
+	assert.Equal(t, 0.0, *ctr.CPUUserPercent)
+	assert.Equal(t, 20.0, *ctr.CPUThrottledPeriodsPercent)
+	assert.Equal(t, 0.0, *ctr.CPUThrottledTimeMsPerSecond)
+	assert.Equal(t, uint64(0), *ctr.MemoryOOMEvents)
+	assert.Equal(t, uint64(2), *ctr.MemoryOOMKills)
+	assert.Equal(t, 1024.0, *ctr.IOReadBytesPerSecond)
+	assert.Equal(t, 0.0, *ctr.IOWriteCountPerSecond)
+
+	marshaled, err := json.Marshal(ctr)
+	require.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"containerId":"`+testContainerID+`"`)
+	assert.Contains(t, string(marshaled), `"cpuPressureSomeAvg10":2`)
+	assert.NotContains(t, string(marshaled), "processLimit")
+}
+
+func TestSampler_RemovedCgroups(t *testing.T) {
+	s, root, _ := testSampler(t)
+	defer os.RemoveAll(root)
+	containerPath := "/system.slice/docker-" + testContainerID + ".scope"
+	cgroupFiles(t, root, containerPath, containerFiles("1000", "0", "0", "0", "0"))
+	_, err := s.Sample()
+	require.NoError(t, err)
+	require.Contains(t, s.last, containerPath)
+
+	require.NoError(t, os.RemoveAll(filepath.Join(root, containerPath)))
+	_, err = s.Sample()
+	require.NoError(t, err)
+
+	assert.NotContains(t, s.last, containerPath)
+	assert.NotContains(t, s.pressure, containerPath)
+}
+
+func TestSampler_CgroupV1(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup1")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	s := NewSampler(nil)
+	s.root = root
+
+	batch, err := s.Sample()
+
+	assert.NoError(t, err)
+	assert.Empty(t, batch)
+}
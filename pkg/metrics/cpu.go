@@ -62,6 +62,11 @@ func (self *CPUMonitor) Sample() (sample *CPUSample, err error) {
 	delta := cpuDelta(&currentTimes[0], &self.last[0])
 	self.last = currentTimes
 
+	return cpuPercentages(delta), nil
+}
+
+// cpuPercentages returns the share of the CPU time spent in each mode from the CPU times increase.
+func cpuPercentages(delta *cpu.TimesStat) *CPUSample {
 	userDelta := delta.User + delta.Nice
 	systemDelta := delta.System + delta.Irq + delta.Softirq
 	stolenDelta := delta.Steal
@@ -78,7 +83,7 @@ func (self *CPUMonitor) Sample() (sample *CPUSample, err error) {
 	}
 	idlePercent := 100 - userPercent - systemPercent - ioWaitPercent - stolenPercent
 
-	sample = &CPUSample{
+	return &CPUSample{
 		CPUPercent:       userPercent + systemPercent + ioWaitPercent + stolenPercent,
 		CPUUserPercent:   userPercent,
 		CPUSystemPercent: systemPercent,
@@ -86,7 +91,6 @@ func (self *CPUMonitor) Sample() (sample *CPUSample, err error) {
 		CPUIdlePercent:   idlePercent,
 		CPUStealPercent:  stolenPercent,
 	}
-	return
 }
 
 func cpuDelta(current, previous *cpu.TimesStat) *cpu.TimesStat {
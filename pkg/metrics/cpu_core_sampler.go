@@ -0,0 +1,232 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+
+	"github.com/newrelic/infrastructure-agent/internal/agent"
+	"github.com/newrelic/infrastructure-agent/pkg/config"
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/log"
+	"github.com/newrelic/infrastructure-agent/pkg/sample"
+)
+
+var cclog = log.WithComponent("CPUCoreSampler")
+
+// CPUCoreSample contains the usage of a single CPU core since the previous sample. Frequency and interrupt
+// rates are omitted when the kernel doesn't report them (e.g. virtual machines without cpufreq).
+type CPUCoreSample struct {
+	sample.BaseEvent
+	// Name of the core, as in /proc/stat (e.g. cpu0)
+	Core string `json:"cpuCore"`
+	*CPUSample
+	// Current frequency of the core, in MHz
+	FrequencyMHz *float64 `json:"cpuFrequencyMHz,omitempty"`
+	// Hardware interrupts and software interrupts handled by the core, per second
+	InterruptsPerSecond *float64 `json:"cpuInterruptsPerSecond,omitempty"`
+	SoftirqsPerSecond   *float64 `json:"cpuSoftirqsPerSecond,omitempty"`
+}
+
+// CPUCoreSampler reports a CPUCoreSample per CPU core. It's disabled by default, since it sends as many samples
+// as cores the host has.
+type CPUCoreSampler struct {
+	context        agent.AgentContext
+	sampleRate     time.Duration
+	cpuTimes       func(bool) ([]cpu.TimesStat, error)
+	readLines      func(path string) ([]string, error)
+	procDir        string
+	sysCPUDir      string
+	now            func() time.Time
+	last           map[string]cpu.TimesStat // key: core name
+	lastInterrupts map[string]uint64
+	lastSoftirqs   map[string]uint64
+	lastTime       time.Time
+}
+
+func NewCPUCoreSampler(context agent.AgentContext) *CPUCoreSampler {
+	sampleRateSec := config.FREQ_DISABLE_SAMPLING
+	if context != nil {
+		sampleRateSec = context.Config().MetricsCPUCoreSampleRate
+	}
+
+	return &CPUCoreSampler{
+		context:    context,
+		sampleRate: time.Second * time.Duration(sampleRateSec),
+		cpuTimes:   cpu.Times,
+		readLines:  readFileLines,
+		procDir:    helpers.HostProc(),
+		sysCPUDir:  helpers.HostSys("devices", "system", "cpu"),
+		now:        time.Now,
+	}
+}
+
+func (s *CPUCoreSampler) OnStartup() {}
+
+func (s *CPUCoreSampler) Name() string {
+	return "CPUCoreSampler"
+}
+
+func (s *CPUCoreSampler) Interval() time.Duration {
+	return s.sampleRate
+}
+
+func (s *CPUCoreSampler) Disabled() bool {
+	return s.Interval() <= config.FREQ_DISABLE_SAMPLING
+}
+
+// Sample returns a CPUCoreSample per core. The first invocation only stores the CPU times and interrupt
+// counters, so it doesn't return any sample.
+func (s *CPUCoreSampler) Sample() (eventBatch sample.EventBatch, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in CPUCoreSampler.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	currentTimes, err := s.cpuTimes(true)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	// interrupt counters are only available in Linux, so they don't fail the sample
+	interrupts, err := s.readInterrupts("interrupts")
+	if err != nil {
+		cclog.WithError(err).Debug("Cannot read interrupts.")
+	}
+	softirqs, err := s.readInterrupts("softirqs")
+	if err != nil {
+		cclog.WithError(err).Debug("Cannot read softirqs.")
+	}
+
+	last, lastInterrupts, lastSoftirqs := s.last, s.lastInterrupts, s.lastSoftirqs
+	elapsed := now.Sub(s.lastTime).Seconds()
+	s.last = make(map[string]cpu.TimesStat, len(currentTimes))
+	for _, times := range currentTimes {
+		s.last[times.CPU] = times
+	}
+	s.lastInterrupts, s.lastSoftirqs, s.lastTime = interrupts, softirqs, now
+
+	if last == nil {
+		return nil, nil
+	}
+	for i := range currentTimes {
+		current := &currentTimes[i]
+		previous, ok := last[current.CPU]
+		if !ok {
+			// the core was brought online after the previous sample
+			continue
+		}
+		ss := &CPUCoreSample{
+			Core:                current.CPU,
+			CPUSample:           cpuPercentages(cpuDelta(current, &previous)),
+			FrequencyMHz:        s.frequency(current.CPU),
+			InterruptsPerSecond: counterRate(interrupts, lastInterrupts, current.CPU, elapsed),
+			SoftirqsPerSecond:   counterRate(softirqs, lastSoftirqs, current.CPU, elapsed),
+		}
+		ss.Type("CPUCoreSample")
+		eventBatch = append(eventBatch, ss)
+	}
+	return eventBatch, nil
+}
+
+// frequency returns the current frequency of a core in MHz, or nil if cpufreq isn't available. The frequency
+// reported by the cpufreq driver is preferred over the one read from the hardware, which requires root.
+func (s *CPUCoreSampler) frequency(core string) *float64 {
+	for _, file := range []string{"scaling_cur_freq", "cpuinfo_cur_freq"} {
+		content, err := ioutil.ReadFile(filepath.Join(s.sysCPUDir, core, "cpufreq", file))
+		if err != nil {
+			continue
+		}
+		kHz, err := strconv.ParseFloat(strings.TrimSpace(string(content)), 64)
+		if err != nil {
+			continue
+		}
+		mHz := kHz / 1000
+		return &mHz
+	}
+	return nil
+}
+
+// readInterrupts returns the interrupts handled by each core, read from /proc/interrupts or /proc/softirqs.
+func (s *CPUCoreSampler) readInterrupts(file string) (map[string]uint64, error) {
+	lines, err := s.readLines(filepath.Join(s.procDir, file))
+	if err != nil {
+		return nil, err
+	}
+	return parseInterrupts(lines)
+}
+
+// parseInterrupts sums the interrupts handled by each core, from the content of /proc/interrupts or
+// /proc/softirqs, e.g.:
+//
+//	           CPU0       CPU1
+//	  0:         36          0   IO-APIC   2-edge      timer
+//	NMI:          2          3   Non-maskable interrupts
+//	ERR:          0
+//
+// Only the columns of the online cores are reported. Lines without a count for each core (e.g. ERR and MIS
+// totals) are ignored. The returned keys are the core names, as reported by /proc/stat (e.g. cpu0).
+func parseInterrupts(lines []string) (map[string]uint64, error) {
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("missing header")
+	}
+	var cores []string
+	for _, column := range strings.Fields(lines[0]) {
+		if !strings.HasPrefix(column, "CPU") {
+			return nil, fmt.Errorf("unexpected header column %q", column)
+		}
+		cores = append(cores, strings.ToLower(column))
+	}
+	if len(cores) == 0 {
+		return nil, fmt.Errorf("missing header")
+	}
+
+	counts := make(map[string]uint64, len(cores))
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < len(cores)+1 || !strings.HasSuffix(fields[0], ":") {
+			continue
+		}
+		values := make([]uint64, len(cores))
+		valid := true
+		for i := range cores {
+			value, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			values[i] = value
+		}
+		if !valid {
+			continue
+		}
+		for i, core := range cores {
+			counts[core] += values[i]
+		}
+	}
+	return counts, nil
+}
+
+// counterRate returns the increase per second of a core counter, or nil if it's not available in both samples
+// or it was reset.
+func counterRate(current, last map[string]uint64, core string, elapsed float64) *float64 {
+	c, ok := current[core]
+	if !ok {
+		return nil
+	}
+	l, ok := last[core]
+	if !ok || c < l || elapsed <= 0 {
+		return nil
+	}
+	r := float64(c-l) / elapsed
+	return &r
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var interrupts = `           CPU0       CPU1
+  0:         36          0   IO-APIC   2-edge      timer
+  8:          0          1   IO-APIC   8-edge      rtc0
+NMI:          2          3   Non-maskable interrupts
+LOC:       1000       2000   Local timer interrupts
+ERR:          0
+MIS:          0
+`
+
+var softirqs = `                    CPU0       CPU1
+          HI:          1          0
+       TIMER:        100        200
+      NET_RX:         10         20
+`
+
+func TestParseInterrupts(t *testing.T) {
+	counts, err := parseInterrupts(strings.Split(interrupts, "\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]uint64{"cpu0": 1038, "cpu1": 2004}, counts)
+}
+
+func TestParseInterrupts_OfflineCores(t *testing.T) {
+	// cpu1 is offline, so its column is not reported
+	counts, err := parseInterrupts([]string{"  CPU0  CPU2", "  0:  5  7  IO-APIC  2-edge  timer"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]uint64{"cpu0": 5, "cpu2": 7}, counts)
+}
+
+func TestParseInterrupts_Invalid(t *testing.T) {
+	for _, lines := range [][]string{nil, {""}, {"  0:  5  7"}} {
+		_, err := parseInterrupts(lines)
+		assert.Error(t, err, lines)
+	}
+}
+
+func TestCPUCoreSampler_Disabled(t *testing.T) {
+	s := NewCPUCoreSampler(nil)
+
+	assert.True(t, s.Disabled())
+}
+
+func TestCPUCoreSampler_Sample(t *testing.T) {
+	// GIVEN a host with two cores, where only the first one reports its frequency
+	sysDir, err := ioutil.TempDir("", "cpu")
+	require.NoError(t, err)
+	defer os.RemoveAll(sysDir)
+	require.NoError(t, os.MkdirAll(filepath.Join(sysDir, "cpu0", "cpufreq"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sysDir, "cpu0", "cpufreq", "scaling_cur_freq"), []byte("2400000\n"), 0644))
+
+	times := []cpu.TimesStat{
+		{CPU: "cpu0", User: 100, System: 50, Idle: 800, Iowait: 50},
+		{CPU: "cpu1", User: 10, System: 10, Idle: 980},
+	}
+	files := map[string]string{"interrupts": interrupts, "softirqs": softirqs}
+	now := time.Now()
+
+	s := NewCPUCoreSampler(nil)
+	s.sysCPUDir = sysDir
+	s.procDir = "/proc"
+	s.cpuTimes = func(perCPU bool) ([]cpu.TimesStat, error) {
+		require.True(t, perCPU)
+		return times, nil
+	}
+	s.readLines = func(path string) ([]string, error) {
+		content, ok := files[filepath.Base(path)]
+		if !ok {
+			return nil, errors.New("no such file or directory")
+		}
+		return strings.Split(content, "\n"), nil
+	}
+	s.now = func() time.Time { return now }
+
+	// WHEN it's sampled for the first time
+	batch, err := s.Sample()
+	require.NoError(t, err)
+
+	// THEN no samples are returned
+	assert.Empty(t, batch)
+
+	// WHEN it's sampled again
+	now = now.Add(10 * time.Second)
+	times = []cpu.TimesStat{
+		{CPU: "cpu0", User: 150, System: 70, Idle: 820, Iowait: 60},
+		{CPU: "cpu1", User: 15, System: 10, Idle: 1075},
+	}
+	files["interrupts"] = strings.Replace(interrupts, "1000       2000", "1500       2100", 1)
+	delete(files, "softirqs")
+	batch, err = s.Sample()
+	require.NoError(t, err)
+
+	// THEN a sample per core is returned, with the usage since the previous sample
+	require.Len(t, batch, 2)
+	cpu0 := batch[0].(*CPUCoreSample)
+	assert.Equal(t, "CPUCoreSample", cpu0.EventType)
+	assert.Equal(t, "cpu0", cpu0.Core)
+	assert.InDelta(t, 50.0, cpu0.CPUUserPercent, 0.001)
+	assert.InDelta(t, 20.0, cpu0.CPUSystemPercent, 0.001)
+	assert.InDelta(t, 10.0, cpu0.CPUIOWaitPercent, 0.001)
+	assert.InDelta(t, 20.0, cpu0.CPUIdlePercent, 0.001)
+	assert.Equal(t, 2400.0, *cpu0.FrequencyMHz)
+	assert.Equal(t, 50.0, *cpu0.InterruptsPerSecond)
+	// AND the rates of the counters missing in any of the samples are omitted
+	assert.Nil(t, cpu0.SoftirqsPerSecond)
+
+	cpu1 := batch[1].(*CPUCoreSample)
+	assert.Equal(t, "cpu1", cpu1.Core)
+	assert.InDelta(t, 5.0, cpu1.CPUUserPercent, 0.001)
+	assert.InDelta(t, 95.0, cpu1.CPUIdlePercent, 0.001)
+	assert.Nil(t, cpu1.FrequencyMHz)
+	assert.Equal(t, 10.0, *cpu1.InterruptsPerSecond)
+
+	marshaled, err := json.Marshal(cpu1)
+	require.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"cpuCore":"cpu1"`)
+	assert.Contains(t, string(marshaled), `"cpuIdlePercent":95`)
+	assert.NotContains(t, string(marshaled), "cpuFrequencyMHz")
+}
@@ -0,0 +1,218 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infrastructure-agent/pkg/helpers"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/acquire"
+)
+
+// Resources whose Pressure Stall Information is reported.
+const (
+	pressureCPU    = "cpu"
+	pressureMemory = "memory"
+	pressureIO     = "io"
+)
+
+// PressureSample contains the Linux Pressure Stall Information (PSI): the share of time in which some (or all)
+// the non-idle tasks were stalled waiting for a resource, averaged over 10, 60 and 300 seconds, and the stall
+// time per second since the previous sample. Fields are nil, so omitted, when the kernel doesn't report them.
+type PressureSample struct {
+	CPUSomeAvg10          *float64 `json:"cpuPressureSomeAvg10,omitempty"`
+	CPUSomeAvg60          *float64 `json:"cpuPressureSomeAvg60,omitempty"`
+	CPUSomeAvg300         *float64 `json:"cpuPressureSomeAvg300,omitempty"`
+	CPUSomeStallPerSecond *float64 `json:"cpuPressureSomeStallMicrosPerSecond,omitempty"`
+	CPUFullAvg10          *float64 `json:"cpuPressureFullAvg10,omitempty"`
+	CPUFullAvg60          *float64 `json:"cpuPressureFullAvg60,omitempty"`
+	CPUFullAvg300         *float64 `json:"cpuPressureFullAvg300,omitempty"`
+	CPUFullStallPerSecond *float64 `json:"cpuPressureFullStallMicrosPerSecond,omitempty"`
+
+	MemorySomeAvg10          *float64 `json:"memoryPressureSomeAvg10,omitempty"`
+	MemorySomeAvg60          *float64 `json:"memoryPressureSomeAvg60,omitempty"`
+	MemorySomeAvg300         *float64 `json:"memoryPressureSomeAvg300,omitempty"`
+	MemorySomeStallPerSecond *float64 `json:"memoryPressureSomeStallMicrosPerSecond,omitempty"`
+	MemoryFullAvg10          *float64 `json:"memoryPressureFullAvg10,omitempty"`
+	MemoryFullAvg60          *float64 `json:"memoryPressureFullAvg60,omitempty"`
+	MemoryFullAvg300         *float64 `json:"memoryPressureFullAvg300,omitempty"`
+	MemoryFullStallPerSecond *float64 `json:"memoryPressureFullStallMicrosPerSecond,omitempty"`
+
+	IOSomeAvg10          *float64 `json:"ioPressureSomeAvg10,omitempty"`
+	IOSomeAvg60          *float64 `json:"ioPressureSomeAvg60,omitempty"`
+	IOSomeAvg300         *float64 `json:"ioPressureSomeAvg300,omitempty"`
+	IOSomeStallPerSecond *float64 `json:"ioPressureSomeStallMicrosPerSecond,omitempty"`
+	IOFullAvg10          *float64 `json:"ioPressureFullAvg10,omitempty"`
+	IOFullAvg60          *float64 `json:"ioPressureFullAvg60,omitempty"`
+	IOFullAvg300         *float64 `json:"ioPressureFullAvg300,omitempty"`
+	IOFullStallPerSecond *float64 `json:"ioPressureFullStallMicrosPerSecond,omitempty"`
+}
+
+// pressureStall is a line of a PSI file, for the "some" or the "full" stalled tasks.
+type pressureStall struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	Total  uint64 // accumulated stall time, in microseconds
+}
+
+// pressureValues are the reported values for a pressureStall line.
+type pressureValues struct {
+	avg10, avg60, avg300, stallPerSecond *float64
+}
+
+type PressureMonitor struct {
+	readLines func(resource string) ([]string, error)
+	now       func() time.Time
+	last      map[string]uint64 // key: resource and line (e.g. "cpu some"), value: accumulated stall time
+	lastTime  time.Time
+}
+
+// NewPressureMonitor returns a monitor for the host Pressure Stall Information, read from /proc/pressure.
+func NewPressureMonitor() *PressureMonitor {
+	return newPressureMonitor(func(resource string) ([]string, error) {
+		return readFileLines(helpers.HostProc("pressure", resource))
+	})
+}
+
+// NewCgroupPressureMonitor returns a monitor for the Pressure Stall Information of the tasks of a cgroup v2,
+// read from the <resource>.pressure files of its directory (e.g. /sys/fs/cgroup/system.slice/docker-<id>.scope).
+func NewCgroupPressureMonitor(cgroupDir string) *PressureMonitor {
+	return newPressureMonitor(func(resource string) ([]string, error) {
+		return readFileLines(filepath.Join(cgroupDir, resource+".pressure"))
+	})
+}
+
+// readFileLines returns the lines of a procfs or sysfs file. Reading PSI files fails with EOPNOTSUPP if the
+// kernel was booted without PSI support.
+func readFileLines(path string) ([]string, error) {
+	lines, err := acquire.ReadLines(path)
+	if err == io.EOF {
+		err = nil
+	}
+	return lines, err
+}
+
+func newPressureMonitor(readLines func(resource string) ([]string, error)) *PressureMonitor {
+	return &PressureMonitor{
+		readLines: readLines,
+		now:       time.Now,
+		last:      map[string]uint64{},
+	}
+}
+
+// Sample returns the Pressure Stall Information, or nil if it isn't available (e.g. kernels older than 4.20, or
+// booted without PSI support).
+func (m *PressureMonitor) Sample() (sample *PressureSample, err error) {
+	defer func() {
+		if panicErr := recover(); panicErr != nil {
+			err = fmt.Errorf("Panic in PressureMonitor.Sample: %v\nStack: %s", panicErr, debug.Stack())
+		}
+	}()
+
+	now := m.now()
+	elapsed := now.Sub(m.lastTime).Seconds()
+	if m.lastTime.IsZero() {
+		elapsed = 0
+	}
+	m.lastTime = now
+
+	sample = &PressureSample{}
+	available := false
+	for _, resource := range []string{pressureCPU, pressureMemory, pressureIO} {
+		lines, err := m.readLines(resource)
+		if err != nil || len(lines) == 0 {
+			continue
+		}
+		some, full, err := parsePressure(lines)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s pressure: %s", resource, err)
+		}
+		available = true
+		s := m.values(resource+" some", some, elapsed)
+		f := m.values(resource+" full", full, elapsed)
+		switch resource {
+		case pressureCPU:
+			sample.CPUSomeAvg10, sample.CPUSomeAvg60, sample.CPUSomeAvg300, sample.CPUSomeStallPerSecond = s.avg10, s.avg60, s.avg300, s.stallPerSecond
+			sample.CPUFullAvg10, sample.CPUFullAvg60, sample.CPUFullAvg300, sample.CPUFullStallPerSecond = f.avg10, f.avg60, f.avg300, f.stallPerSecond
+		case pressureMemory:
+			sample.MemorySomeAvg10, sample.MemorySomeAvg60, sample.MemorySomeAvg300, sample.MemorySomeStallPerSecond = s.avg10, s.avg60, s.avg300, s.stallPerSecond
+			sample.MemoryFullAvg10, sample.MemoryFullAvg60, sample.MemoryFullAvg300, sample.MemoryFullStallPerSecond = f.avg10, f.avg60, f.avg300, f.stallPerSecond
+		case pressureIO:
+			sample.IOSomeAvg10, sample.IOSomeAvg60, sample.IOSomeAvg300, sample.IOSomeStallPerSecond = s.avg10, s.avg60, s.avg300, s.stallPerSecond
+			sample.IOFullAvg10, sample.IOFullAvg60, sample.IOFullAvg300, sample.IOFullStallPerSecond = f.avg10, f.avg60, f.avg300, f.stallPerSecond
+		}
+	}
+	if !available {
+		return nil, nil
+	}
+	return sample, nil
+}
+
+// values returns the values to report for a PSI line, storing its accumulated stall time for the next sample.
+// The stall time per second is only reported when there is a previous sample to compare with.
+func (m *PressureMonitor) values(key string, stall *pressureStall, elapsed float64) (v pressureValues) {
+	if stall == nil {
+		delete(m.last, key)
+		return v
+	}
+	avg10, avg60, avg300 := stall.Avg10, stall.Avg60, stall.Avg300
+	v.avg10, v.avg60, v.avg300 = &avg10, &avg60, &avg300
+	if last, ok := m.last[key]; ok && elapsed > 0 && stall.Total >= last {
+		perSecond := float64(stall.Total-last) / elapsed
+		v.stallPerSecond = &perSecond
+	}
+	m.last[key] = stall.Total
+	return v
+}
+
+// parsePressure parses the content of a PSI file, e.g.:
+//
+//	some avg10=0.12 avg60=0.05 avg300=0.01 total=123456
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=4567
+//
+// The "full" line is nil when it is not reported (e.g. CPU pressure before kernel 5.13).
+func parsePressure(lines []string) (some, full *pressureStall, err error) {
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		stall := &pressureStall{}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return nil, nil, fmt.Errorf("unexpected field %q", field)
+			}
+			switch kv[0] {
+			case "avg10":
+				stall.Avg10, err = strconv.ParseFloat(kv[1], 64)
+			case "avg60":
+				stall.Avg60, err = strconv.ParseFloat(kv[1], 64)
+			case "avg300":
+				stall.Avg300, err = strconv.ParseFloat(kv[1], 64)
+			case "total":
+				stall.Total, err = strconv.ParseUint(kv[1], 10, 64)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		switch fields[0] {
+		case "some":
+			some = stall
+		case "full":
+			full = stall
+		}
+	}
+	if some == nil {
+		return nil, nil, fmt.Errorf("missing some line")
+	}
+	return some, full, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var cpuPressure = `some avg10=1.50 avg60=0.75 avg300=0.25 total=1000000
+full avg10=0.50 avg60=0.25 avg300=0.05 total=200000
+`
+
+// cpu pressure before kernel 5.13 only reports the "some" line
+var cpuPressureWithoutFull = `some avg10=1.50 avg60=0.75 avg300=0.25 total=1000000
+`
+
+var ioPressure = `some avg10=10.00 avg60=5.00 avg300=2.50 total=3000000
+full avg10=8.00 avg60=4.00 avg300=2.00 total=2000000
+`
+
+func TestParsePressure(t *testing.T) {
+	some, full, err := parsePressure(strings.Split(cpuPressure, "\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, &pressureStall{Avg10: 1.5, Avg60: 0.75, Avg300: 0.25, Total: 1000000}, some)
+	assert.Equal(t, &pressureStall{Avg10: 0.5, Avg60: 0.25, Avg300: 0.05, Total: 200000}, full)
+}
+
+func TestParsePressure_WithoutFull(t *testing.T) {
+	some, full, err := parsePressure(strings.Split(cpuPressureWithoutFull, "\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, &pressureStall{Avg10: 1.5, Avg60: 0.75, Avg300: 0.25, Total: 1000000}, some)
+	assert.Nil(t, full)
+}
+
+func TestParsePressure_Invalid(t *testing.T) {
+	for _, content := range []string{"", "some avg10=abc", "some avg10", "full avg10=0.00 avg60=0.00 avg300=0.00 total=0"} {
+		_, _, err := parsePressure(strings.Split(content, "\n"))
+		assert.Error(t, err, content)
+	}
+}
+
+func TestPressureMonitor_Sample(t *testing.T) {
+	// GIVEN a kernel reporting cpu and io pressure, but not memory pressure
+	files := map[string]string{pressureCPU: cpuPressure, pressureIO: ioPressure}
+	m := newPressureMonitor(func(resource string) ([]string, error) {
+		content, ok := files[resource]
+		if !ok {
+			return nil, errors.New("no such file or directory")
+		}
+		return strings.Split(content, "\n"), nil
+	})
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	// WHEN it's sampled for the first time
+	sample, err := m.Sample()
+	require.NoError(t, err)
+
+	// THEN the stall averages are reported
+	require.NotNil(t, sample)
+	assert.Equal(t, 1.5, *sample.CPUSomeAvg10)
+	assert.Equal(t, 0.75, *sample.CPUSomeAvg60)
+	assert.Equal(t, 0.25, *sample.CPUSomeAvg300)
+	assert.Equal(t, 0.05, *sample.CPUFullAvg300)
+	assert.Equal(t, 8.0, *sample.IOFullAvg10)
+	assert.Nil(t, sample.MemorySomeAvg10)
+	assert.Nil(t, sample.MemoryFullAvg10)
+	// AND the stall time per second is not, as there's no previous sample
+	assert.Nil(t, sample.CPUSomeStallPerSecond)
+	assert.Nil(t, sample.IOFullStallPerSecond)
+
+	// WHEN it's sampled again
+	files[pressureCPU] = strings.Replace(cpuPressure, "total=1000000", "total=1500000", 1)
+	files[pressureIO] = strings.Replace(ioPressure, "total=2000000", "total=2100000", 1)
+	now = now.Add(10 * time.Second)
+	sample, err = m.Sample()
+	require.NoError(t, err)
+
+	// THEN the stall time per second since the previous sample is reported
+	assert.Equal(t, 50000.0, *sample.CPUSomeStallPerSecond)
+	assert.Equal(t, 0.0, *sample.CPUFullStallPerSecond)
+	assert.Equal(t, 0.0, *sample.IOSomeStallPerSecond)
+	assert.Equal(t, 10000.0, *sample.IOFullStallPerSecond)
+
+	// AND memory fields are omitted
+	marshaled, err := json.Marshal(sample)
+	require.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"cpuPressureSomeStallMicrosPerSecond":50000`)
+	assert.NotContains(t, string(marshaled), "memory")
+}
+
+func TestPressureMonitor_Unavailable(t *testing.T) {
+	m := newPressureMonitor(func(resource string) ([]string, error) {
+		return []string{""}, errors.New("no such file or directory")
+	})
+
+	sample, err := m.Sample()
+
+	assert.NoError(t, err)
+	assert.Nil(t, sample)
+}
+
+func TestCgroupPressureMonitor(t *testing.T) {
+	// GIVEN a cgroup v2 directory with a cpu pressure file
+	dir, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cpu.pressure"), []byte(cpuPressure), 0644))
+
+	// WHEN its pressure is sampled
+	sample, err := NewCgroupPressureMonitor(dir).Sample()
+	require.NoError(t, err)
+
+	// THEN the cpu pressure of its tasks is reported
+	require.NotNil(t, sample)
+	assert.Equal(t, 1.5, *sample.CPUSomeAvg10)
+	assert.Nil(t, sample.IOSomeAvg10)
+}
+
+func TestSystemSample_OmitsUnavailablePressure(t *testing.T) {
+	marshaled, err := json.Marshal(&SystemSample{LoadSample: &LoadSample{}})
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(marshaled), "Pressure")
+}
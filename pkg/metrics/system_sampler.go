@@ -30,27 +30,30 @@ type SystemSample struct {
 	*LoadSample
 	*MemorySample
 	*DiskSample
+	*PressureSample
 }
 
 type SystemSampler struct {
-	CpuMonitor     *CPUMonitor
-	DiskMonitor    *DiskMonitor
-	LoadMonitor    *LoadMonitor
-	MemoryMonitor  *MemoryMonitor
-	context        agent.AgentContext
-	stopChannel    chan bool
-	waitForCleanup *sync.WaitGroup
+	CpuMonitor      *CPUMonitor
+	DiskMonitor     *DiskMonitor
+	LoadMonitor     *LoadMonitor
+	MemoryMonitor   *MemoryMonitor
+	PressureMonitor *PressureMonitor
+	context         agent.AgentContext
+	stopChannel     chan bool
+	waitForCleanup  *sync.WaitGroup
 }
 
 func NewSystemSampler(context agent.AgentContext, storageSampler *storage.Sampler) *SystemSampler {
 	cfg := context.Config()
 	return &SystemSampler{
-		CpuMonitor:     NewCPUMonitor(context),
-		DiskMonitor:    NewDiskMonitor(storageSampler),
-		LoadMonitor:    NewLoadMonitor(),
-		MemoryMonitor:  NewMemoryMonitor(cfg.IgnoreReclaimable),
-		context:        context,
-		waitForCleanup: &sync.WaitGroup{},
+		CpuMonitor:      NewCPUMonitor(context),
+		DiskMonitor:     NewDiskMonitor(storageSampler),
+		LoadMonitor:     NewLoadMonitor(),
+		MemoryMonitor:   NewMemoryMonitor(cfg.IgnoreReclaimable),
+		PressureMonitor: NewPressureMonitor(),
+		context:         context,
+		waitForCleanup:  &sync.WaitGroup{},
 	}
 }
 
@@ -128,6 +131,15 @@ func (s *SystemSampler) Sample() (results sample.EventBatch, err error) {
 	}
 	seg.End()
 
+	// pressure stall information is only available in recent Linux kernels, so it doesn't fail the sample
+	ctx, seg = trx.StartSegment(ctx, "pressure sample")
+	if pressureSample, err := s.PressureMonitor.Sample(); err != nil {
+		syslog.WithError(err).Debug("Cannot sample pressure stall information.")
+	} else {
+		sample.PressureSample = pressureSample
+	}
+	seg.End()
+
 	if s.Debug() {
 		helpers.LogStructureDetails(syslog, sample, "SystemSample", "final", nil)
 	}
@@ -8,6 +8,7 @@ import (
 	config2 "github.com/newrelic/infrastructure-agent/pkg/config"
 	"github.com/newrelic/infrastructure-agent/pkg/helpers"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics"
+	"github.com/newrelic/infrastructure-agent/pkg/metrics/container"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/network"
 	"github.com/newrelic/infrastructure-agent/pkg/metrics/process"
 	metricsSender "github.com/newrelic/infrastructure-agent/pkg/metrics/sender"
@@ -115,6 +116,8 @@ func RegisterPlugins(agent *agnt.Agent) error {
 	nfsSampler := nfs.NewSampler(agent.Context)
 	networkSampler := network.NewNetworkSampler(agent.Context)
 	systemSampler := metrics.NewSystemSampler(agent.Context, storageSampler)
+	containerSampler := container.NewSampler(agent.Context)
+	cpuCoreSampler := metrics.NewCPUCoreSampler(agent.Context)
 
 	// Prime Storage Sampler, ignoring results
 	if !storageSampler.Disabled() {
@@ -137,6 +140,8 @@ func RegisterPlugins(agent *agnt.Agent) error {
 	sender.RegisterSampler(nfsSampler)
 	sender.RegisterSampler(networkSampler)
 	sender.RegisterSampler(procSampler)
+	sender.RegisterSampler(containerSampler)
+	sender.RegisterSampler(cpuCoreSampler)
 
 	agent.RegisterMetricsSender(sender)
 